@@ -8,7 +8,6 @@ package analyzelogs
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"os"
 	"time"
@@ -55,6 +54,12 @@ type CliParams struct {
 
 	// inactivityTimeout represents the time in seconds that the program will wait for new logs before exiting
 	inactivityTimeout time.Duration
+
+	// verbose prints the decisions taken on each log: lines, processing rules and final attributes
+	verbose bool
+
+	// expectedPath represents the path to a file of expected logs the output is compared to
+	expectedPath string
 }
 
 // Commands returns a slice of subcommands for the 'agent' command.
@@ -91,13 +96,35 @@ func Commands(globalParams *command.GlobalParams) []*cobra.Command {
 	cmd.Flags().StringVarP(&cliParams.CoreConfigPath, "core-config", "C", defaultCoreConfigPath, "Path to the core configuration file (optional)")
 	// Add flag for inactivity timeout (optional)
 	cmd.Flags().DurationVarP(&cliParams.inactivityTimeout, "inactivity-timeout", "t", defaultInactivityTimeout, "Time that the program will wait for new logs before exiting (optional)")
+	// Add flag for the decision trace (optional)
+	cmd.Flags().BoolVar(&cliParams.verbose, "verbose", false, "Print the lines, the processing rules evaluated and the final attributes of each log (optional)")
+	// Add flag for the expected results (optional)
+	cmd.Flags().StringVar(&cliParams.expectedPath, "expected", "", "Path to a YAML file of expected logs, the command fails if the output doesn't match it (optional)")
 
 	return []*cobra.Command{cmd}
 }
 
 // runAnalyzeLogs initializes the launcher and sends the log config file path to the source provider.
 func runAnalyzeLogs(cliParams *CliParams, config config.Component, ac autodiscovery.Component) error {
-	outputChan, launchers, pipelineProvider, err := runAnalyzeLogsHelper(cliParams, config, ac)
+	var expected []expectedLog
+	if cliParams.expectedPath != "" {
+		var err error
+		if expected, err = loadExpectedLogs(cliParams.expectedPath); err != nil {
+			return err
+		}
+	}
+
+	var tracer processor.Tracer
+	var decisionTracer *lineTracer
+	var reportsReady chan struct{}
+	if cliParams.verbose {
+		decisionTracer = newLineTracer()
+		tracer = decisionTracer
+		reportsReady = decisionTracer.ready
+	}
+	reporter := newTraceReporter(os.Stdout)
+
+	outputChan, launchers, pipelineProvider, err := runAnalyzeLogsHelper(cliParams, config, ac, tracer)
 	if err != nil {
 		return err
 	}
@@ -105,35 +132,54 @@ func runAnalyzeLogs(cliParams *CliParams, config config.Component, ac autodiscov
 	// Set up an inactivity timeout
 	inactivityTimeout := cliParams.inactivityTimeout
 	idleTimer := time.NewTimer(inactivityTimeout)
+	resetIdleTimer := func() {
+		if !idleTimer.Stop() {
+			<-idleTimer.C
+		}
+		idleTimer.Reset(inactivityTimeout)
+	}
 
+	var logs []outputLog
 	for {
 		select {
+		case <-reportsReady:
+			for _, report := range decisionTracer.drain() {
+				reporter.print(report)
+			}
+			resetIdleTimer()
 		case msg := <-outputChan:
-			parsedMessage := processor.JSONPayload
-			err := json.Unmarshal(msg.GetContent(), &parsedMessage)
+			parsedMessage, err := decodeOutputLog(msg)
 			if err != nil {
 				fmt.Printf("Failed to parse message: %v\n", err)
 				continue
 			}
 
-			fmt.Println(parsedMessage.Message)
+			if !cliParams.verbose {
+				fmt.Println(parsedMessage.Message)
+			}
+			logs = append(logs, parsedMessage)
 
 			// Reset the inactivity timer every time a message is processed
-			if !idleTimer.Stop() {
-				<-idleTimer.C
-			}
-			idleTimer.Reset(inactivityTimeout)
+			resetIdleTimer()
 		case <-idleTimer.C:
 			// Timeout reached, signal quit
 			launchers.Stop()
 			pipelineProvider.Stop()
+			if decisionTracer != nil {
+				for _, report := range decisionTracer.flush() {
+					reporter.print(report)
+				}
+			}
+			if cliParams.expectedPath != "" {
+				return checkExpectedLogs(os.Stdout, expected, logs)
+			}
 			return nil
 		}
 	}
 }
 
 // Used to make testing easier
-func runAnalyzeLogsHelper(cliParams *CliParams, config config.Component, ac autodiscovery.Component) (chan *message.Message, *launchers.Launchers, pipeline.Provider, error) {
+func runAnalyzeLogsHelper(cliParams *CliParams, config config.Component, ac autodiscovery.Component, tracer processor.Tracer) (chan *message.Message, *launchers.Launchers, pipeline.Provider, error) {
 	configSource := sources.NewConfigSources()
 	sources, err := getSources(ac, cliParams)
 	if err != nil {
@@ -151,7 +197,7 @@ func runAnalyzeLogsHelper(cliParams *CliParams, config config.Component, ac auto
 		}
 		configSource.AddSource(source)
 	}
-	return agentimpl.SetUpLaunchers(config, configSource)
+	return agentimpl.SetUpLaunchers(config, configSource, tracer)
 }
 
 func getSources(ac autodiscovery.Component, cliParams *CliParams) ([]*sources.LogSource, error) {
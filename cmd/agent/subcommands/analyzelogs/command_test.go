@@ -6,9 +6,11 @@
 package analyzelogs
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -26,6 +28,8 @@ import (
 	taggerfxmock "github.com/DataDog/datadog-agent/comp/core/tagger/fx-mock"
 	workloadmeta "github.com/DataDog/datadog-agent/comp/core/workloadmeta/def"
 	workloadmetafxmock "github.com/DataDog/datadog-agent/comp/core/workloadmeta/fx-mock"
+	logsconfig "github.com/DataDog/datadog-agent/comp/logs/agent/config"
+	"github.com/DataDog/datadog-agent/pkg/logs/message"
 	"github.com/DataDog/datadog-agent/pkg/logs/processor"
 	"github.com/DataDog/datadog-agent/pkg/util/fxutil"
 )
@@ -128,7 +132,7 @@ Auto-discovery IDs:
 		LogConfigPath:  tempConfigFile.Name(),
 		CoreConfigPath: tempConfigFile.Name(),
 	}
-	outputChan, launcher, pipelineProvider, err := runAnalyzeLogsHelper(cliParams, config, ac)
+	outputChan, launcher, pipelineProvider, err := runAnalyzeLogsHelper(cliParams, config, ac, nil)
 	assert.Nil(t, err)
 	expectedOutput := []string{
 		"=== apm check ===",
@@ -199,6 +203,197 @@ func TestRunAnalyzeLogsInvalidConfig(t *testing.T) {
 		LogConfigPath:  tempConfigFile.Name(),
 		CoreConfigPath: tempConfigFile.Name(),
 	}
-	_, _, _, err := runAnalyzeLogsHelper(cliParams, config, ac)
+	_, _, _, err := runAnalyzeLogsHelper(cliParams, config, ac, nil)
 	assert.Error(t, err)
 }
+
+func TestCommandVerboseExpected(t *testing.T) {
+	fxutil.TestOneShotSubcommand(t,
+		Commands(&command.GlobalParams{}),
+		[]string{"analyze-logs", "--verbose", "--expected", "expected.yaml", "path/to/log/config.yaml"},
+		runAnalyzeLogs,
+		func(_ core.BundleParams, cliParams *CliParams) {
+			require.True(t, cliParams.verbose)
+			require.Equal(t, "expected.yaml", cliParams.expectedPath)
+		})
+}
+
+func TestRunAnalyzeLogsVerbose(t *testing.T) {
+	tempDir := "tmp"
+	defer os.RemoveAll(tempDir)
+	logs := `2024-03-01 INFO login user=alice
+2024-03-01 ERROR failure
+  at main.go:12
+  at lib.go:3
+2024-03-01 DEBUG noise
+2024-03-01 INFO done
+`
+	tempLogFile := CreateTestFile(tempDir, "app.log", logs)
+	require.NotNil(t, tempLogFile)
+
+	yamlContent := fmt.Sprintf(`logs:
+  - type: file
+    path: %s
+    service: web
+    log_processing_rules:
+      - type: multi_line
+        name: new_log
+        pattern: \d{4}-\d{2}-\d{2}
+      - type: exclude_at_match
+        name: no_debug
+        pattern: DEBUG
+      - type: mask_sequences
+        name: mask_user
+        pattern: user=\w+
+        replace_placeholder: user=[masked]
+`, tempLogFile.Name())
+	tempConfigFile := CreateTestFile(tempDir, "config.yaml", yamlContent)
+	require.NotNil(t, tempConfigFile)
+	config := config.NewMock(t)
+
+	adsched := scheduler.NewController()
+	ac := fxutil.Test[autodiscovery.Mock](t,
+		fx.Supply(autodiscoveryimpl.MockParams{Scheduler: adsched}),
+		secretsimpl.MockModule(),
+		autodiscoveryimpl.MockModule(),
+		workloadmetafxmock.MockModule(workloadmeta.NewParams()),
+		core.MockBundle(),
+		taggerfxmock.MockModule(),
+	)
+
+	cliParams := &CliParams{
+		LogConfigPath:  tempConfigFile.Name(),
+		CoreConfigPath: tempConfigFile.Name(),
+	}
+	tracer := newLineTracer()
+	outputChan, launcher, pipelineProvider, err := runAnalyzeLogsHelper(cliParams, config, ac, tracer)
+	require.NoError(t, err)
+
+	var out bytes.Buffer
+	reporter := newTraceReporter(&out)
+	var sent []outputLog
+	for i := 0; i < 3; i++ {
+		log, err := decodeOutputLog(<-outputChan)
+		require.NoError(t, err)
+		sent = append(sent, log)
+	}
+	launcher.Stop()
+	pipelineProvider.Stop()
+	reports := tracer.flush()
+	require.Len(t, reports, 4)
+	for _, report := range reports {
+		reporter.print(report)
+	}
+
+	path := tempLogFile.Name()
+	assert.Equal(t, path+` line 1
+  exclude_at_match "no_debug": no match
+  mask_sequences "mask_user": match
+    -> 2024-03-01 INFO login user=[masked]
+  sent: status=info service=web tags=filename:app.log,dirname:tmp
+  message: 2024-03-01 INFO login user=[masked]
+`+path+` lines 2-4 (multi-line group of 3 lines)
+  exclude_at_match "no_debug": no match
+  mask_sequences "mask_user": no match
+  sent: status=info service=web tags=filename:app.log,dirname:tmp
+  message: 2024-03-01 ERROR failure\n  at main.go:12\n  at lib.go:3
+`+path+` line 5
+  exclude_at_match "no_debug": match
+  dropped by exclude_at_match "no_debug"
+`+path+` line 6
+  exclude_at_match "no_debug": no match
+  mask_sequences "mask_user": no match
+  sent: status=info service=web tags=filename:app.log,dirname:tmp
+  message: 2024-03-01 INFO done
+`, out.String())
+
+	expected := []expectedLog{
+		{Message: "2024-03-01 INFO login user=[masked]", Service: "web", Tags: []string{"filename:app.log"}},
+		{Message: `2024-03-01 ERROR failure\n  at main.go:12\n  at lib.go:3`, Status: "info"},
+		{Message: "2024-03-01 INFO done"},
+	}
+	out.Reset()
+	assert.NoError(t, checkExpectedLogs(&out, expected, sent))
+	assert.Equal(t, "All 3 logs match the expected results\n", out.String())
+}
+
+func TestCheckExpectedLogs(t *testing.T) {
+	logs := []outputLog{
+		{Message: "first", Status: "info", Service: "web", Tags: "env:prod,filename:app.log"},
+		{Message: "second", Status: "error", Service: "web"},
+	}
+
+	var out bytes.Buffer
+	err := checkExpectedLogs(&out, []expectedLog{
+		{Message: "first", Status: "warn", Tags: []string{"env:prod", "team:a"}},
+		{Message: "second"},
+		{Message: "third"},
+	}, logs)
+	assert.EqualError(t, err, "2 logs don't match the expected results")
+	assert.Equal(t, `log #1: status is "info", expected "warn", tag "team:a" is missing
+log #3: missing, expected "third"
+`, out.String())
+
+	out.Reset()
+	err = checkExpectedLogs(&out, []expectedLog{{Message: "first"}}, logs)
+	assert.EqualError(t, err, "1 logs don't match the expected results")
+	assert.Equal(t, "log #2: unexpected log \"second\"\n", out.String())
+}
+
+func TestLoadExpectedLogs(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "expected.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`- message: hello
+  status: info
+  tags: [env:prod]
+- message: world
+`), 0644))
+	expected, err := loadExpectedLogs(path)
+	require.NoError(t, err)
+	assert.Equal(t, []expectedLog{
+		{Message: "hello", Status: "info", Tags: []string{"env:prod"}},
+		{Message: "world"},
+	}, expected)
+}
+
+func TestLineTracerFlushesUnsentLogs(t *testing.T) {
+	tracer := newLineTracer()
+	rule := &logsconfig.ProcessingRule{Type: logsconfig.MaskSequences, Name: "mask"}
+	held := message.NewMessage([]byte("held"), nil, "", 0)
+	sent := message.NewMessage([]byte("sent"), nil, "", 0)
+	tracer.RuleApplied(held, rule, false, []byte("held"))
+	tracer.RuleApplied(sent, rule, false, []byte("sent"))
+	tracer.Sent(sent)
+
+	<-tracer.ready
+	reports := tracer.drain()
+	require.Len(t, reports, 1)
+	assert.Equal(t, sent, reports[0].msg)
+	assert.False(t, reports[0].unsent)
+
+	reports = tracer.flush()
+	require.Len(t, reports, 1)
+	assert.Equal(t, held, reports[0].msg)
+	assert.True(t, reports[0].unsent)
+	assert.Empty(t, tracer.flush())
+
+	var out bytes.Buffer
+	newTraceReporter(&out).print(reports[0])
+	assert.Equal(t, "log\n  mask_sequences \"mask\": no match\n  not sent: collapsed with its duplicates or not encoded\n", out.String())
+}
+
+func TestLineTracerReportsSampleDrops(t *testing.T) {
+	tracer := newLineTracer()
+	rule := &logsconfig.ProcessingRule{Type: logsconfig.Sample, Name: "limit_errors"}
+	dropped := message.NewMessage([]byte("ERROR second"), nil, "", 0)
+	tracer.RuleApplied(dropped, rule, true, []byte("ERROR second"))
+	tracer.Dropped(dropped, rule)
+
+	// the log dropped by the sample rule is reported as such, not as unsent when flushed
+	reports := tracer.flush()
+	require.Len(t, reports, 1)
+	assert.False(t, reports[0].unsent)
+
+	var out bytes.Buffer
+	newTraceReporter(&out).print(reports[0])
+	assert.Equal(t, "log\n  sample \"limit_errors\": match\n  dropped by sample \"limit_errors\"\n", out.String())
+}
@@ -0,0 +1,87 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package analyzelogs
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// expectedLog is a log expected in the output of the command. The empty fields
+// are not checked, and the expected tags must be part of the tags of the log.
+type expectedLog struct {
+	Message string   `yaml:"message"`
+	Status  string   `yaml:"status"`
+	Service string   `yaml:"service"`
+	Tags    []string `yaml:"tags"`
+}
+
+// loadExpectedLogs reads a YAML list of expected logs.
+func loadExpectedLogs(path string) ([]expectedLog, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var expected []expectedLog
+	if err := yaml.Unmarshal(data, &expected); err != nil {
+		return nil, fmt.Errorf("invalid expected results file %s: %v", path, err)
+	}
+	return expected, nil
+}
+
+// mismatch returns why a log doesn't match the expected one, or an empty string.
+func (e expectedLog) mismatch(log outputLog) string {
+	var diffs []string
+	if log.Message != e.Message {
+		diffs = append(diffs, fmt.Sprintf("message is %q, expected %q", log.Message, e.Message))
+	}
+	if e.Status != "" && log.Status != e.Status {
+		diffs = append(diffs, fmt.Sprintf("status is %q, expected %q", log.Status, e.Status))
+	}
+	if e.Service != "" && log.Service != e.Service {
+		diffs = append(diffs, fmt.Sprintf("service is %q, expected %q", log.Service, e.Service))
+	}
+	tags := make(map[string]bool)
+	for _, tag := range strings.Split(log.Tags, ",") {
+		tags[tag] = true
+	}
+	for _, tag := range e.Tags {
+		if !tags[tag] {
+			diffs = append(diffs, fmt.Sprintf("tag %q is missing", tag))
+		}
+	}
+	return strings.Join(diffs, ", ")
+}
+
+// checkExpectedLogs compares the logs sent by the processor with the expected
+// ones, in order, prints the differences and returns an error if any.
+func checkExpectedLogs(out io.Writer, expected []expectedLog, logs []outputLog) error {
+	failures := 0
+	for i := 0; i < len(expected) || i < len(logs); i++ {
+		switch {
+		case i >= len(logs):
+			fmt.Fprintf(out, "log #%d: missing, expected %q\n", i+1, expected[i].Message)
+		case i >= len(expected):
+			fmt.Fprintf(out, "log #%d: unexpected log %q\n", i+1, logs[i].Message)
+		default:
+			diff := expected[i].mismatch(logs[i])
+			if diff == "" {
+				continue
+			}
+			fmt.Fprintf(out, "log #%d: %s\n", i+1, diff)
+		}
+		failures++
+	}
+	if failures > 0 {
+		return fmt.Errorf("%d logs don't match the expected results", failures)
+	}
+	fmt.Fprintf(out, "All %d logs match the expected results\n", len(expected))
+	return nil
+}
@@ -0,0 +1,204 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package analyzelogs
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/DataDog/datadog-agent/comp/logs/agent/config"
+	"github.com/DataDog/datadog-agent/pkg/logs/message"
+)
+
+// ruleDecision is the outcome of a processing rule evaluated on a log.
+type ruleDecision struct {
+	rule    *config.ProcessingRule
+	matched bool
+	content string
+}
+
+// traceReport holds the decisions taken by the processor on a log, it is
+// reported once the log is sent or dropped, or when the tracer is flushed.
+type traceReport struct {
+	msg       *message.Message
+	rules     []ruleDecision
+	droppedBy *config.ProcessingRule
+	// unsent is true if the log was neither sent nor dropped by a rule, e.g.
+	// when it was collapsed with its duplicates.
+	unsent bool
+}
+
+// lineTracer implements processor.Tracer, it collects the decisions taken on
+// each log and queues a report once the log is sent or dropped. The reports
+// are queued without limit so that the processor is never blocked, ready
+// receives a value when reports are available.
+type lineTracer struct {
+	mu      sync.Mutex
+	pending map[*message.Message][]ruleDecision
+	reports []*traceReport
+	ready   chan struct{}
+}
+
+func newLineTracer() *lineTracer {
+	return &lineTracer{
+		pending: make(map[*message.Message][]ruleDecision),
+		ready:   make(chan struct{}, 1),
+	}
+}
+
+// RuleApplied implements processor.Tracer
+func (t *lineTracer) RuleApplied(msg *message.Message, rule *config.ProcessingRule, matched bool, content []byte) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.pending[msg] = append(t.pending[msg], ruleDecision{rule: rule, matched: matched, content: string(content)})
+}
+
+// Dropped implements processor.Tracer
+func (t *lineTracer) Dropped(msg *message.Message, rule *config.ProcessingRule) {
+	t.report(msg, rule)
+}
+
+// Sent implements processor.Tracer
+func (t *lineTracer) Sent(msg *message.Message) {
+	t.report(msg, nil)
+}
+
+func (t *lineTracer) report(msg *message.Message, droppedBy *config.ProcessingRule) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	rules := t.pending[msg]
+	delete(t.pending, msg)
+	t.reports = append(t.reports, &traceReport{msg: msg, rules: rules, droppedBy: droppedBy})
+	select {
+	case t.ready <- struct{}{}:
+	default:
+	}
+}
+
+// drain returns the queued reports.
+func (t *lineTracer) drain() []*traceReport {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	reports := t.reports
+	t.reports = nil
+	return reports
+}
+
+// flush reports the logs which were neither sent nor dropped, and returns
+// all the queued reports. It is called once the processor is stopped.
+func (t *lineTracer) flush() []*traceReport {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for msg, rules := range t.pending {
+		t.reports = append(t.reports, &traceReport{msg: msg, rules: rules, unsent: true})
+	}
+	clear(t.pending)
+	reports := t.reports
+	t.reports = nil
+	return reports
+}
+
+// outputLog is a log as encoded by the processor, see processor.JSONPayload.
+type outputLog struct {
+	Message string `json:"message"`
+	Status  string `json:"status"`
+	Service string `json:"service"`
+	Tags    string `json:"ddtags"`
+}
+
+func decodeOutputLog(msg *message.Message) (outputLog, error) {
+	var log outputLog
+	err := json.Unmarshal(msg.GetContent(), &log)
+	return log, err
+}
+
+// traceReporter prints the decision traces, with the lines of the files each
+// log has been read from.
+type traceReporter struct {
+	out io.Writer
+	// offsets holds the offset of the end of the last log read from each file.
+	offsets map[string]int64
+	// files holds the content of the files the logs are read from.
+	files map[string][]byte
+}
+
+func newTraceReporter(out io.Writer) *traceReporter {
+	return &traceReporter{
+		out:     out,
+		offsets: make(map[string]int64),
+		files:   make(map[string][]byte),
+	}
+}
+
+func (r *traceReporter) print(report *traceReport) {
+	fmt.Fprintln(r.out, r.location(report.msg))
+	for _, decision := range report.rules {
+		outcome := "no match"
+		if decision.matched {
+			outcome = "match"
+		}
+		fmt.Fprintf(r.out, "  %s %q: %s\n", decision.rule.Type, decision.rule.Name, outcome)
+		if decision.matched && (decision.rule.Type == config.MaskSequences || decision.rule.Type == config.ExtractFields) {
+			fmt.Fprintf(r.out, "    -> %s\n", decision.content)
+		}
+	}
+
+	if report.droppedBy != nil {
+		fmt.Fprintf(r.out, "  dropped by %s %q\n", report.droppedBy.Type, report.droppedBy.Name)
+		return
+	}
+	if report.unsent {
+		fmt.Fprintln(r.out, "  not sent: collapsed with its duplicates or not encoded")
+		return
+	}
+	log, err := decodeOutputLog(report.msg)
+	if err != nil {
+		fmt.Fprintf(r.out, "  failed to parse message: %v\n", err)
+		return
+	}
+	fmt.Fprintf(r.out, "  sent: status=%s service=%s tags=%s\n", log.Status, log.Service, log.Tags)
+	fmt.Fprintf(r.out, "  message: %s\n", log.Message)
+}
+
+// location returns the lines of the file a log has been read from, several
+// lines being aggregated into a single log by the multi-line rules. The lines
+// are found from the offset of the end of the log and of the previous one.
+func (r *traceReporter) location(msg *message.Message) string {
+	if msg.Origin == nil || !strings.HasPrefix(msg.Origin.Identifier, "file:") {
+		return "log"
+	}
+	end, err := strconv.ParseInt(msg.Origin.Offset, 10, 64)
+	if err != nil {
+		return "log"
+	}
+	path := strings.TrimPrefix(msg.Origin.Identifier, "file:")
+	start := r.offsets[path]
+	r.offsets[path] = end
+
+	content, ok := r.files[path]
+	if !ok {
+		// the files are fully read by the time their first log is processed,
+		// ignore the errors to report the offsets instead of the lines
+		content, _ = os.ReadFile(path)
+		r.files[path] = content
+	}
+	if end > int64(len(content)) || start >= end {
+		return fmt.Sprintf("%s bytes %d-%d", path, start, end)
+	}
+
+	first := bytes.Count(content[:start], []byte{'\n'}) + 1
+	last := bytes.Count(content[:end-1], []byte{'\n'}) + 1
+	if first == last {
+		return fmt.Sprintf("%s line %d", path, first)
+	}
+	return fmt.Sprintf("%s lines %d-%d (multi-line group of %d lines)", path, first, last, last-first+1)
+}
@@ -0,0 +1,91 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package dogstatsdcapture
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	log "github.com/DataDog/datadog-agent/comp/core/log/def"
+	replay "github.com/DataDog/datadog-agent/comp/dogstatsd/replay/impl"
+)
+
+// maxReportedTags is the number of tags reported for each metric.
+const maxReportedTags = 5
+
+//nolint:revive // TODO(AML) Fix revive linter
+func dogstatsdCaptureAnalyze(_ log.Component, cliParams *cliParams) error {
+	reader, err := replay.NewTrafficCaptureReader(cliParams.analyzeFilePath, 1, false)
+	if err != nil {
+		return fmt.Errorf("could not open %s: %w", cliParams.analyzeFilePath, err)
+	}
+	defer reader.Close()
+
+	analysis, err := replay.AnalyzeCapture(reader)
+	if err != nil {
+		return fmt.Errorf("could not read %s: %w", cliParams.analyzeFilePath, err)
+	}
+
+	printAnalysis(os.Stdout, analysis, cliParams.analyzeTop)
+	return nil
+}
+
+// printAnalysis writes the report of the analysis of a capture, with the top
+// metrics and origins.
+func printAnalysis(w io.Writer, analysis *replay.CaptureAnalysis, top int) {
+	fmt.Fprintf(w, "Packets: %d\n", analysis.Packets)
+	fmt.Fprintf(w, "Messages: %d\n", analysis.Messages)
+	fmt.Fprintf(w, "Duration: %s\n", analysis.Duration)
+
+	types := make([]string, 0, len(analysis.MessageTypes))
+	for messageType, count := range analysis.MessageTypes {
+		types = append(types, fmt.Sprintf("%s: %d", messageType, count))
+	}
+	sort.Strings(types)
+	fmt.Fprintf(w, "Message types: %s\n", strings.Join(types, ", "))
+
+	sizes := analysis.PacketSizes
+	fmt.Fprintf(w, "Packet sizes (bytes): min %d, mean %.1f, p50 %d, p99 %d, max %d\n",
+		sizes.Min, sizes.Mean, sizes.P50, sizes.P99, sizes.Max)
+
+	fmt.Fprintf(w, "\nTop metrics (%d distinct names):\n", len(analysis.Metrics))
+	for _, metric := range analysis.Metrics[:min(top, len(analysis.Metrics))] {
+		fmt.Fprintf(w, "  %s: %d messages, %d contexts", metric.Name, metric.Messages, metric.Contexts)
+		if tags := formatTagCardinality(metric.TagCardinality); tags != "" {
+			fmt.Fprintf(w, ", tag cardinality: %s", tags)
+		}
+		fmt.Fprintln(w)
+	}
+
+	fmt.Fprintf(w, "\nTop origins (%d distinct origins):\n", len(analysis.Origins))
+	for _, origin := range analysis.Origins[:min(top, len(analysis.Origins))] {
+		fmt.Fprintf(w, "  %s: %d packets, %d messages, %d bytes, %.2f messages/s\n",
+			origin.Origin, origin.Packets, origin.Messages, origin.Bytes, origin.MessagesPerSecond)
+	}
+}
+
+// formatTagCardinality returns the tag names with the most values first.
+func formatTagCardinality(cardinality map[string]int) string {
+	names := make([]string, 0, len(cardinality))
+	for name := range cardinality {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if cardinality[names[i]] != cardinality[names[j]] {
+			return cardinality[names[i]] > cardinality[names[j]]
+		}
+		return names[i] < names[j]
+	})
+
+	tags := make([]string, 0, maxReportedTags)
+	for _, name := range names[:min(maxReportedTags, len(names))] {
+		tags = append(tags, fmt.Sprintf("%s (%d)", name, cardinality[name]))
+	}
+	return strings.Join(tags, ", ")
+}
@@ -34,6 +34,7 @@ import (
 
 const (
 	defaultCaptureDuration = time.Duration(1) * time.Minute
+	defaultAnalyzeTop      = 10
 )
 
 // cliParams are the command-line arguments for this subcommand
@@ -43,6 +44,16 @@ type cliParams struct {
 	dsdCaptureDuration   time.Duration
 	dsdCaptureFilePath   string
 	dsdCaptureCompressed bool
+
+	// capture filters
+	dsdCaptureMetricPrefixes []string
+	dsdCapturePids           []int32
+	dsdCaptureContainerIDs   []string
+	dsdCaptureMessageTypes   []string
+
+	// analyze flags
+	analyzeFilePath string
+	analyzeTop      int
 }
 
 // Commands returns a slice of subcommands for the 'agent' command.
@@ -67,6 +78,30 @@ func Commands(globalParams *command.GlobalParams) []*cobra.Command {
 	dogstatsdCaptureCmd.Flags().DurationVarP(&cliParams.dsdCaptureDuration, "duration", "d", defaultCaptureDuration, "Duration traffic capture should span.")
 	dogstatsdCaptureCmd.Flags().StringVarP(&cliParams.dsdCaptureFilePath, "path", "p", "", "Directory path to write the capture to.")
 	dogstatsdCaptureCmd.Flags().BoolVarP(&cliParams.dsdCaptureCompressed, "compressed", "z", true, "Should capture be zstd compressed.")
+	dogstatsdCaptureCmd.Flags().StringSliceVar(&cliParams.dsdCaptureMetricPrefixes, "metric-prefix", nil, "Only capture the metrics whose name starts with one of these prefixes.")
+	dogstatsdCaptureCmd.Flags().Int32SliceVar(&cliParams.dsdCapturePids, "pid", nil, "Only capture the packets sent by one of these processes (or containers, see --container-id).")
+	dogstatsdCaptureCmd.Flags().StringSliceVar(&cliParams.dsdCaptureContainerIDs, "container-id", nil, "Only capture the packets sent by one of these containers (or processes, see --pid).")
+	dogstatsdCaptureCmd.Flags().StringSliceVar(&cliParams.dsdCaptureMessageTypes, "message-type", nil, "Only capture the messages of these types: metric, event or service_check.")
+
+	analyzeCmd := &cobra.Command{
+		Use:   "analyze <capture file>",
+		Short: "Report statistics about the traffic of a dogstatsd capture",
+		Long:  `Read a capture file and report the top metric names, their tag cardinality, the packet sizes and the rates of the origins of the traffic.`,
+		Args:  cobra.ExactArgs(1),
+		RunE: func(_ *cobra.Command, args []string) error {
+			if cliParams.analyzeTop <= 0 {
+				return fmt.Errorf("--top must be greater than 0, got %d", cliParams.analyzeTop)
+			}
+			cliParams.analyzeFilePath = args[0]
+			return fxutil.OneShot(dogstatsdCaptureAnalyze,
+				fx.Supply(cliParams),
+				fx.Supply(command.GetDefaultCoreBundleParams(cliParams.GlobalParams)),
+				core.Bundle(),
+			)
+		},
+	}
+	analyzeCmd.Flags().IntVarP(&cliParams.analyzeTop, "top", "n", defaultAnalyzeTop, "Number of metrics and origins to report.")
+	dogstatsdCaptureCmd.AddCommand(analyzeCmd)
 
 	// shut up grpc client!
 	grpclog.SetLoggerV2(grpclog.NewLoggerV2(io.Discard, io.Discard, io.Discard))
@@ -112,9 +147,13 @@ func dogstatsdCapture(_ log.Component, config config.Component, cliParams *cliPa
 	cli := pb.NewAgentSecureClient(conn)
 
 	resp, err := cli.DogstatsdCaptureTrigger(ctx, &pb.CaptureTriggerRequest{
-		Duration:   cliParams.dsdCaptureDuration.String(),
-		Path:       cliParams.dsdCaptureFilePath,
-		Compressed: cliParams.dsdCaptureCompressed,
+		Duration:       cliParams.dsdCaptureDuration.String(),
+		Path:           cliParams.dsdCaptureFilePath,
+		Compressed:     cliParams.dsdCaptureCompressed,
+		MetricPrefixes: cliParams.dsdCaptureMetricPrefixes,
+		Pids:           cliParams.dsdCapturePids,
+		ContainerIds:   cliParams.dsdCaptureContainerIDs,
+		MessageTypes:   cliParams.dsdCaptureMessageTypes,
 	})
 	if err != nil {
 		return err
@@ -6,6 +6,7 @@
 package dogstatsdcapture
 
 import (
+	"bytes"
 	"testing"
 	"time"
 
@@ -14,6 +15,7 @@ import (
 	"github.com/DataDog/datadog-agent/cmd/agent/command"
 	"github.com/DataDog/datadog-agent/comp/core"
 	"github.com/DataDog/datadog-agent/comp/core/secrets"
+	replay "github.com/DataDog/datadog-agent/comp/dogstatsd/replay/impl"
 	"github.com/DataDog/datadog-agent/pkg/util/fxutil"
 )
 
@@ -28,3 +30,72 @@ func TestCommand(t *testing.T) {
 			require.Equal(t, false, secretParams.Enabled)
 		})
 }
+
+func TestCommandFilters(t *testing.T) {
+	fxutil.TestOneShotSubcommand(t,
+		Commands(&command.GlobalParams{}),
+		[]string{"dogstatsd-capture", "--metric-prefix", "app.,db.", "--pid", "12", "--pid", "13", "--container-id", "abc", "--message-type", "metric"},
+		dogstatsdCapture,
+		func(cliParams *cliParams, _ core.BundleParams, _ secrets.Params) {
+			require.Equal(t, []string{"app.", "db."}, cliParams.dsdCaptureMetricPrefixes)
+			require.Equal(t, []int32{12, 13}, cliParams.dsdCapturePids)
+			require.Equal(t, []string{"abc"}, cliParams.dsdCaptureContainerIDs)
+			require.Equal(t, []string{"metric"}, cliParams.dsdCaptureMessageTypes)
+		})
+}
+
+func TestAnalyzeCommand(t *testing.T) {
+	fxutil.TestOneShotSubcommand(t,
+		Commands(&command.GlobalParams{}),
+		[]string{"dogstatsd-capture", "analyze", "capture.dog", "--top", "3"},
+		dogstatsdCaptureAnalyze,
+		func(cliParams *cliParams, _ core.BundleParams) {
+			require.Equal(t, "capture.dog", cliParams.analyzeFilePath)
+			require.Equal(t, 3, cliParams.analyzeTop)
+		})
+}
+
+func TestAnalyzeCommandRejectsInvalidTop(t *testing.T) {
+	for _, top := range []string{"0", "-5"} {
+		t.Run(top, func(t *testing.T) {
+			cmd := Commands(&command.GlobalParams{})[0]
+			cmd.SetArgs([]string{"analyze", "capture.dog", "--top", top})
+			cmd.SilenceErrors = true
+			cmd.SilenceUsage = true
+			require.ErrorContains(t, cmd.Execute(), "--top must be greater than 0")
+		})
+	}
+}
+
+func TestPrintAnalysis(t *testing.T) {
+	analysis := &replay.CaptureAnalysis{
+		Packets:      3,
+		Messages:     4,
+		Duration:     2 * time.Second,
+		MessageTypes: map[string]int{"metric": 3, "event": 1},
+		PacketSizes:  replay.SizeStats{Min: 10, Max: 30, Mean: 20, P50: 20, P99: 30},
+		Metrics: []replay.MetricStats{
+			{Name: "app.requests", Messages: 2, Contexts: 2, TagCardinality: map[string]int{"env": 1, "path": 2}},
+			{Name: "app.latency", Messages: 1, Contexts: 1},
+		},
+		Origins: []replay.OriginStats{
+			{Origin: "container_id://abc", Packets: 2, Messages: 3, Bytes: 40, MessagesPerSecond: 1.5},
+			{Origin: "pid:12", Packets: 1, Messages: 1, Bytes: 20, MessagesPerSecond: 0.5},
+		},
+	}
+
+	var b bytes.Buffer
+	printAnalysis(&b, analysis, 1)
+	require.Equal(t, `Packets: 3
+Messages: 4
+Duration: 2s
+Message types: event: 1, metric: 3
+Packet sizes (bytes): min 10, mean 20.0, p50 20, p99 30, max 30
+
+Top metrics (2 distinct names):
+  app.requests: 2 messages, 2 contexts, tag cardinality: path (2), env (1)
+
+Top origins (2 distinct origins):
+  container_id://abc: 2 packets, 3 messages, 40 bytes, 1.50 messages/s
+`, b.String())
+}
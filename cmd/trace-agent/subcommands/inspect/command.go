@@ -0,0 +1,142 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+// Package inspect implements 'trace-agent inspect' cli.
+package inspect
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"go.uber.org/fx"
+
+	"github.com/DataDog/datadog-agent/cmd/trace-agent/subcommands"
+	"github.com/DataDog/datadog-agent/comp/core/config"
+	"github.com/DataDog/datadog-agent/comp/core/secrets"
+	apiutil "github.com/DataDog/datadog-agent/pkg/api/util"
+	"github.com/DataDog/datadog-agent/pkg/trace/agent"
+	"github.com/DataDog/datadog-agent/pkg/util/fxutil"
+	"github.com/DataDog/datadog-agent/pkg/util/option"
+)
+
+// maxLineSize is the maximum size of a span streamed by the trace-agent.
+const maxLineSize = 16 * 1024 * 1024
+
+// cliParams are the command-line arguments for this subcommand.
+type cliParams struct {
+	service  string
+	resource string
+	traceID  uint64
+	limit    int
+	json     bool
+}
+
+// MakeCommand returns a command for the `inspect` CLI command
+func MakeCommand(globalParamsGetter func() *subcommands.GlobalParams) *cobra.Command {
+	cliParams := &cliParams{}
+	cmd := &cobra.Command{
+		Use:   "inspect",
+		Short: "Stream the spans processed by a running trace-agent, along with their sampling decision",
+		Long: `Stream the spans received by a running trace-agent, as they are after their normalization
+and obfuscation, along with the decision taken on them: kept or dropped by a sampler,
+filtered, or invalid. This shows why a trace did not make it to Datadog.`,
+		RunE: func(*cobra.Command, []string) error {
+			return fxutil.OneShot(inspect,
+				fx.Supply(cliParams),
+				fx.Supply(config.NewAgentParams(globalParamsGetter().ConfPath, config.WithFleetPoliciesDirPath(globalParamsGetter().FleetPoliciesDirPath))),
+				fx.Supply(option.None[secrets.Component]()),
+				config.Module(),
+			)
+		},
+		SilenceUsage: true,
+	}
+	cmd.Flags().StringVar(&cliParams.service, "service", "", "only stream the spans of this service")
+	cmd.Flags().StringVar(&cliParams.resource, "resource", "", "only stream the spans of this resource")
+	cmd.Flags().Uint64Var(&cliParams.traceID, "trace-id", 0, "only stream the spans of this trace ID, in decimal")
+	cmd.Flags().IntVarP(&cliParams.limit, "limit", "n", 0, "stop after this number of spans (0 streams until interrupted)")
+	cmd.Flags().BoolVar(&cliParams.json, "json", false, "print the spans as JSON lines")
+	return cmd
+}
+
+func inspect(cliParams *cliParams, config config.Component) error {
+	if err := apiutil.SetAuthToken(config); err != nil {
+		return err
+	}
+	port := config.GetInt("apm_config.debug.port")
+	if port <= 0 {
+		return fmt.Errorf("invalid apm_config.debug.port -- %d", port)
+	}
+	return streamSpans(apiutil.GetClient(), fmt.Sprintf("https://127.0.0.1:%d/debug/traces", port), cliParams, os.Stdout)
+}
+
+// streamSpans streams the spans selected by cliParams from the endpoint u to w.
+func streamSpans(c *http.Client, u string, cliParams *cliParams, w io.Writer) error {
+	q := url.Values{}
+	if cliParams.service != "" {
+		q.Set("service", cliParams.service)
+	}
+	if cliParams.resource != "" {
+		q.Set("resource", cliParams.resource)
+	}
+	if cliParams.traceID != 0 {
+		q.Set("trace_id", strconv.FormatUint(cliParams.traceID, 10))
+	}
+	if cliParams.limit > 0 {
+		q.Set("limit", strconv.Itoa(cliParams.limit))
+	}
+	req, err := http.NewRequest(http.MethodGet, u+"?"+q.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+apiutil.GetAuthToken())
+	resp, err := c.Do(req)
+	if err != nil {
+		return fmt.Errorf("error reaching the trace-agent, is it running with apm_config.debug.port enabled? %s", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("error inspecting spans: %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(nil, maxLineSize)
+	for scanner.Scan() {
+		if cliParams.json {
+			fmt.Fprintln(w, scanner.Text())
+			continue
+		}
+		var s agent.InspectedSpan
+		if err := json.Unmarshal(scanner.Bytes(), &s); err != nil {
+			return fmt.Errorf("error decoding span: %s", err)
+		}
+		fmt.Fprintln(w, formatSpan(&s))
+	}
+	return scanner.Err()
+}
+
+// formatSpan returns the one-line summary of s.
+func formatSpan(s *agent.InspectedSpan) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s %-8s", s.Time.Format(time.RFC3339Nano), s.Decision)
+	if s.Sampler != "" {
+		fmt.Fprintf(&sb, " sampler=%s", s.Sampler)
+	}
+	if s.Reason != "" {
+		fmt.Fprintf(&sb, " reason=%q", s.Reason)
+	}
+	fmt.Fprintf(&sb, " trace_id=%d span_id=%d env=%q service=%q name=%q resource=%q duration=%s error=%d",
+		s.TraceID, s.SpanID, s.Env, s.Service, s.Name, s.Resource, time.Duration(s.Duration), s.Error)
+	return sb.String()
+}
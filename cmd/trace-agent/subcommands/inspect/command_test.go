@@ -0,0 +1,64 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package inspect
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DataDog/datadog-agent/cmd/trace-agent/subcommands"
+	"github.com/DataDog/datadog-agent/pkg/util/fxutil"
+)
+
+func TestInspectCommand(t *testing.T) {
+	fxutil.TestOneShotSubcommand(t,
+		[]*cobra.Command{MakeCommand(func() *subcommands.GlobalParams {
+			return &subcommands.GlobalParams{}
+		})},
+		[]string{"inspect", "--service", "web", "--trace-id", "42", "-n", "10"},
+		inspect,
+		func(cliParams *cliParams) {
+			assert.Equal(t, "web", cliParams.service)
+			assert.Equal(t, uint64(42), cliParams.traceID)
+			assert.Equal(t, 10, cliParams.limit)
+			assert.False(t, cliParams.json)
+		})
+}
+
+func TestStreamSpans(t *testing.T) {
+	const line = `{"time":"2024-01-02T03:04:05Z","env":"prod","trace_id":1,"span_id":2,"parent_id":0,"service":"web","name":"http.request","resource":"GET /users","start":0,"duration":1500000,"error":0,"decision":"dropped","sampler":"priority"}`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, "web", req.URL.Query().Get("service"))
+		assert.Equal(t, "5", req.URL.Query().Get("limit"))
+		assert.False(t, req.URL.Query().Has("trace_id"))
+		w.Write([]byte(line + "\n"))
+	}))
+	defer server.Close()
+
+	var out bytes.Buffer
+	require.NoError(t, streamSpans(server.Client(), server.URL, &cliParams{service: "web", limit: 5}, &out))
+	assert.Equal(t, `2024-01-02T03:04:05Z dropped  sampler=priority trace_id=1 span_id=2 env="prod" service="web" name="http.request" resource="GET /users" duration=1.5ms error=0`+"\n", out.String())
+
+	out.Reset()
+	require.NoError(t, streamSpans(server.Client(), server.URL, &cliParams{service: "web", limit: 5, json: true}, &out))
+	assert.Equal(t, line+"\n", out.String())
+}
+
+func TestStreamSpansError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		http.Error(w, "no session token provided", http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	err := streamSpans(server.Client(), server.URL, &cliParams{}, &bytes.Buffer{})
+	assert.EqualError(t, err, "error inspecting spans: 401 Unauthorized: no session token provided")
+}
@@ -117,6 +117,7 @@ func createAgentDemultiplexerOptions(config config.Component, params Params) agg
 	if params.useDogstatsdNoAggregationPipelineConfig {
 		options.EnableNoAggregationPipeline = config.GetBool("dogstatsd_no_aggregation_pipeline")
 	}
+	options.UseDogstatsdContextLimiter = config.GetInt("dogstatsd_context_limiter.metric_limit") > 0
 
 	// Override FlushInterval only if flushInterval is set by the user
 	if v, ok := params.flushInterval.Get(); ok {
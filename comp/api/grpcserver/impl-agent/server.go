@@ -99,7 +99,13 @@ func (s *serverSecure) DogstatsdCaptureTrigger(_ context.Context, req *pb.Captur
 		return &pb.CaptureTriggerResponse{}, err
 	}
 
-	p, err := s.capture.StartCapture(req.GetPath(), d, req.GetCompressed())
+	filter := dsdReplay.CaptureFilter{
+		MetricPrefixes: req.GetMetricPrefixes(),
+		Pids:           req.GetPids(),
+		ContainerIDs:   req.GetContainerIds(),
+		MessageTypes:   req.GetMessageTypes(),
+	}
+	p, err := s.capture.StartCapture(req.GetPath(), d, req.GetCompressed(), filter)
 	if err != nil {
 		return &pb.CaptureTriggerResponse{}, err
 	}
@@ -0,0 +1,311 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package listeners
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/snappy"
+	"github.com/prometheus/prometheus/prompb"
+
+	"github.com/DataDog/datadog-agent/comp/core/tagger/types"
+	"github.com/DataDog/datadog-agent/comp/dogstatsd/packets"
+	"github.com/DataDog/datadog-agent/pkg/config/model"
+	pkgconfigsetup "github.com/DataDog/datadog-agent/pkg/config/setup"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+var (
+	remoteWriteExpvars        = expvar.NewMap("dogstatsd-remote-write")
+	remoteWriteRequests       = expvar.Int{}
+	remoteWriteRequestErrors  = expvar.Int{}
+	remoteWriteSamples        = expvar.Int{}
+	remoteWriteDroppedSamples = expvar.Int{}
+)
+
+const (
+	// RemoteWritePath is the path of the Prometheus remote-write endpoint.
+	RemoteWritePath = "/api/v1/write"
+	// containerIDHeader is the header holding the container ID of the client.
+	containerIDHeader = "Datadog-Container-ID"
+	// metricNameLabel is the label holding the name of a Prometheus series.
+	metricNameLabel = "__name__"
+	// remoteWriteShutdownTimeout is the time given to the in-flight requests
+	// to complete when the listener stops.
+	remoteWriteShutdownTimeout = 5 * time.Second
+)
+
+var (
+	// metricNameReplacer replaces the characters of a Prometheus metric name
+	// which are reserved by the DogStatsD protocol.
+	metricNameReplacer = strings.NewReplacer(":", "_", "|", "_", "@", "_", "#", "_", ",", "_", " ", "_", "\n", "_")
+	// tagValueReplacer replaces the characters of a Prometheus label value
+	// which are reserved by the DogStatsD protocol.
+	tagValueReplacer = strings.NewReplacer(",", "_", "|", "_", "\n", "_")
+)
+
+func init() {
+	remoteWriteExpvars.Set("Requests", &remoteWriteRequests)
+	remoteWriteExpvars.Set("RequestErrors", &remoteWriteRequestErrors)
+	remoteWriteExpvars.Set("Samples", &remoteWriteSamples)
+	remoteWriteExpvars.Set("DroppedSamples", &remoteWriteDroppedSamples)
+}
+
+// RemoteWriteListener implements the StatsdListener interface for the
+// Prometheus remote-write protocol. It accepts remote-write requests over
+// HTTP and converts their samples to timestamped DogStatsD gauges, so that
+// they are processed like the other DogStatsD packets.
+// The origin of the samples is read from the Datadog-Container-ID header when
+// client origin detection is enabled.
+type RemoteWriteListener struct {
+	listener                net.Listener
+	server                  *http.Server
+	packetsBuffer           *packets.Buffer
+	sharedPacketPoolManager *packets.PoolManager[packets.Packet]
+	maxBodySize             int64
+	maxDecodedSize          int64
+	originDetection         bool
+	listenWg                sync.WaitGroup
+	telemetryStore          *TelemetryStore
+}
+
+// NewRemoteWriteListener returns an idle Prometheus remote-write listener
+func NewRemoteWriteListener(packetOut chan packets.Packets, sharedPacketPoolManager *packets.PoolManager[packets.Packet], cfg model.Reader, telemetryStore *TelemetryStore, packetsTelemetryStore *packets.TelemetryStore) (*RemoteWriteListener, error) {
+	var url string
+
+	port := cfg.GetString("dogstatsd_remote_write_port")
+	if cfg.GetBool("dogstatsd_non_local_traffic") {
+		// Listen to all network interfaces
+		url = fmt.Sprintf(":%s", port)
+	} else {
+		url = net.JoinHostPort(pkgconfigsetup.GetBindHostFromConfig(cfg), port)
+	}
+
+	listener, err := net.Listen("tcp", url)
+	if err != nil {
+		return nil, fmt.Errorf("can't listen: %s", err)
+	}
+
+	packetsBufferSize := cfg.GetInt("dogstatsd_packet_buffer_size")
+	flushTimeout := cfg.GetDuration("dogstatsd_packet_buffer_flush_timeout")
+
+	l := &RemoteWriteListener{
+		listener:                listener,
+		packetsBuffer:           packets.NewBuffer(uint(packetsBufferSize), flushTimeout, packetOut, "remote_write", packetsTelemetryStore),
+		sharedPacketPoolManager: sharedPacketPoolManager,
+		maxBodySize:             cfg.GetInt64("dogstatsd_remote_write_max_body_size"),
+		maxDecodedSize:          cfg.GetInt64("dogstatsd_remote_write_max_decoded_size"),
+		originDetection:         cfg.GetBool("dogstatsd_origin_detection_client"),
+		telemetryStore:          telemetryStore,
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(RemoteWritePath, l.handleWrite)
+	l.server = &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	log.Debugf("dogstatsd-remote-write: %s successfully initialized", listener.Addr())
+	return l, nil
+}
+
+// LocalAddr returns the local network address of the listener.
+func (l *RemoteWriteListener) LocalAddr() string {
+	return l.listener.Addr().String()
+}
+
+// Listen runs the intake loop. Should be called in its own goroutine
+func (l *RemoteWriteListener) Listen() {
+	l.listenWg.Add(1)
+
+	go func() {
+		defer l.listenWg.Done()
+		log.Infof("dogstatsd-remote-write: starting to listen on %s", l.listener.Addr())
+		if err := l.server.Serve(l.listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Errorf("dogstatsd-remote-write: error serving requests: %v", err)
+		}
+	}()
+}
+
+// Stop closes the HTTP server and stops listening
+func (l *RemoteWriteListener) Stop() {
+	// let the in-flight requests send their packets before closing the buffer
+	ctx, cancel := context.WithTimeout(context.Background(), remoteWriteShutdownTimeout)
+	defer cancel()
+	if err := l.server.Shutdown(ctx); err != nil {
+		l.server.Close()
+	}
+	l.listenWg.Wait()
+	l.packetsBuffer.Flush()
+	l.packetsBuffer.Close()
+}
+
+func (l *RemoteWriteListener) handleWrite(w http.ResponseWriter, r *http.Request) {
+	t1 := time.Now()
+	remoteWriteRequests.Add(1)
+
+	if r.Method != http.MethodPost {
+		l.requestError(w, http.StatusMethodNotAllowed, "method %s not allowed", r.Method)
+		return
+	}
+
+	compressed, err := io.ReadAll(http.MaxBytesReader(w, r.Body, l.maxBodySize))
+	if err != nil {
+		l.requestError(w, http.StatusRequestEntityTooLarge, "could not read request body: %v", err)
+		return
+	}
+	// the decoded length is read from the header of the block, check it before allocating
+	decodedLen, err := snappy.DecodedLen(compressed)
+	if err != nil {
+		l.requestError(w, http.StatusBadRequest, "could not decompress request body: %v", err)
+		return
+	}
+	if int64(decodedLen) > l.maxDecodedSize {
+		l.requestError(w, http.StatusRequestEntityTooLarge, "decompressed request body of %d bytes is too large", decodedLen)
+		return
+	}
+	body, err := snappy.Decode(nil, compressed)
+	if err != nil {
+		l.requestError(w, http.StatusBadRequest, "could not decompress request body: %v", err)
+		return
+	}
+	var request prompb.WriteRequest
+	if err := request.Unmarshal(body); err != nil {
+		l.requestError(w, http.StatusBadRequest, "could not decode write request: %v", err)
+		return
+	}
+
+	origin := packets.NoOrigin
+	if containerID := r.Header.Get(containerIDHeader); l.originDetection && containerID != "" {
+		origin = types.NewEntityID(types.ContainerID, containerID).String()
+	}
+	l.appendSeries(request.Timeseries, origin)
+
+	l.telemetryStore.tlmRemoteWriteRequests.Inc("ok")
+	w.WriteHeader(http.StatusNoContent)
+	l.telemetryStore.tlmListener.Observe(float64(time.Since(t1).Nanoseconds()), "remote_write", "tcp", "remote_write")
+}
+
+func (l *RemoteWriteListener) requestError(w http.ResponseWriter, status int, format string, params ...interface{}) {
+	message := fmt.Sprintf(format, params...)
+	log.Debugf("dogstatsd-remote-write: %s", message)
+	remoteWriteRequestErrors.Add(1)
+	l.telemetryStore.tlmRemoteWriteRequests.Inc("error")
+	http.Error(w, message, status)
+}
+
+// appendSeries converts the samples of the series to DogStatsD messages and
+// sends them to the packets buffer, packed into as few packets as possible.
+func (l *RemoteWriteListener) appendSeries(series []prompb.TimeSeries, origin string) {
+	packet := l.sharedPacketPoolManager.Get()
+	length := 0
+	var message []byte
+	for _, ts := range series {
+		sc, ok := seriesContextFromLabels(ts.Labels)
+		for _, sample := range ts.Samples {
+			if !ok || math.IsNaN(sample.Value) || math.IsInf(sample.Value, 0) {
+				l.dropSample()
+				continue
+			}
+			message = appendSampleMessage(message[:0], sc, sample)
+			if len(message) > len(packet.Buffer) {
+				l.dropSample()
+				continue
+			}
+			if length > 0 && length+1+len(message) > len(packet.Buffer) {
+				l.flushPacket(packet, length, origin)
+				packet = l.sharedPacketPoolManager.Get()
+				length = 0
+			}
+			if length > 0 {
+				packet.Buffer[length] = '\n'
+				length++
+			}
+			length += copy(packet.Buffer[length:], message)
+			remoteWriteSamples.Add(1)
+			l.telemetryStore.tlmRemoteWriteSamples.Inc("ok")
+		}
+	}
+
+	if length > 0 {
+		l.flushPacket(packet, length, origin)
+	} else {
+		l.sharedPacketPoolManager.Put(packet)
+	}
+	l.packetsBuffer.Flush()
+}
+
+func (l *RemoteWriteListener) flushPacket(packet *packets.Packet, length int, origin string) {
+	packet.Contents = packet.Buffer[:length]
+	packet.Origin = origin
+	packet.ProcessID = 0
+	packet.Source = packets.RemoteWrite
+	l.packetsBuffer.Append(packet)
+}
+
+func (l *RemoteWriteListener) dropSample() {
+	remoteWriteDroppedSamples.Add(1)
+	l.telemetryStore.tlmRemoteWriteSamples.Inc("dropped")
+}
+
+// seriesContext holds the name and the tags shared by the samples of a series.
+type seriesContext struct {
+	name string
+	tags []string
+}
+
+// seriesContextFromLabels returns the name and the tags of a series. It
+// returns false if the series has no name.
+func seriesContextFromLabels(labels []prompb.Label) (seriesContext, bool) {
+	var p seriesContext
+	for _, label := range labels {
+		if label.Value == "" {
+			// empty labels are equivalent to missing labels in Prometheus
+			continue
+		}
+		if label.Name == metricNameLabel {
+			p.name = metricNameReplacer.Replace(label.Value)
+			continue
+		}
+		p.tags = append(p.tags, label.Name+":"+tagValueReplacer.Replace(label.Value))
+	}
+	return p, p.name != ""
+}
+
+// appendSampleMessage appends the DogStatsD gauge message of a sample to buf.
+// The timestamp of the sample is truncated to the second.
+func appendSampleMessage(buf []byte, p seriesContext, sample prompb.Sample) []byte {
+	buf = append(buf, p.name...)
+	buf = append(buf, ':')
+	buf = strconv.AppendFloat(buf, sample.Value, 'f', -1, 64)
+	buf = append(buf, "|g"...)
+	if len(p.tags) > 0 {
+		buf = append(buf, "|#"...)
+		for i, tag := range p.tags {
+			if i > 0 {
+				buf = append(buf, ',')
+			}
+			buf = append(buf, tag...)
+		}
+	}
+	if ts := sample.Timestamp / 1000; ts > 0 {
+		buf = append(buf, "|T"...)
+		buf = strconv.AppendInt(buf, ts, 10)
+	}
+	return buf
+}
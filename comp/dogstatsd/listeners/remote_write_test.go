@@ -0,0 +1,184 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+//go:build !windows
+
+package listeners
+
+import (
+	"bytes"
+	"math"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/golang/snappy"
+	"github.com/prometheus/prometheus/prompb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DataDog/datadog-agent/comp/dogstatsd/packets"
+)
+
+func newTestRemoteWriteListener(t *testing.T, cfg map[string]interface{}) (*RemoteWriteListener, chan packets.Packets) {
+	cfg["dogstatsd_remote_write_port"] = 0
+	packetChannel := make(chan packets.Packets, 10)
+	deps := fulfillDepsWithConfig(t, cfg)
+	telemetryStore := NewTelemetryStore(nil, deps.Telemetry)
+	packetsTelemetryStore := packets.NewTelemetryStore(nil, deps.Telemetry)
+	s, err := NewRemoteWriteListener(packetChannel, newPacketPoolManagerUDP(deps.Config, packetsTelemetryStore), deps.Config, telemetryStore, packetsTelemetryStore)
+	require.NoError(t, err)
+	s.Listen()
+	t.Cleanup(s.Stop)
+	return s, packetChannel
+}
+
+func postWriteRequest(t *testing.T, s *RemoteWriteListener, request *prompb.WriteRequest, header http.Header) *http.Response {
+	body, err := request.Marshal()
+	require.NoError(t, err)
+	req, err := http.NewRequest(http.MethodPost, "http://"+s.LocalAddr()+RemoteWritePath, bytes.NewReader(snappy.Encode(nil, body)))
+	require.NoError(t, err)
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	return resp
+}
+
+func TestRemoteWriteReceive(t *testing.T) {
+	s, packetChannel := newTestRemoteWriteListener(t, map[string]interface{}{"dogstatsd_origin_detection_client": true})
+
+	request := &prompb.WriteRequest{
+		Timeseries: []prompb.TimeSeries{
+			{
+				Labels: []prompb.Label{
+					{Name: "__name__", Value: "http_requests:rate5m"},
+					{Name: "code", Value: "200"},
+					{Name: "path", Value: "/a,b|c"},
+					{Name: "empty", Value: ""},
+				},
+				Samples: []prompb.Sample{
+					{Value: 1.5, Timestamp: 1700000000123},
+					{Value: math.NaN(), Timestamp: 1700000001000},
+				},
+			},
+			{
+				// series without name are dropped
+				Labels:  []prompb.Label{{Name: "code", Value: "500"}},
+				Samples: []prompb.Sample{{Value: 1, Timestamp: 1700000000000}},
+			},
+			{
+				Labels:  []prompb.Label{{Name: "__name__", Value: "up"}},
+				Samples: []prompb.Sample{{Value: 1e6, Timestamp: 1700000000000}},
+			},
+		},
+	}
+	resp := postWriteRequest(t, s, request, http.Header{"Datadog-Container-Id": {"abcdef"}})
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+	select {
+	case pkts := <-packetChannel:
+		require.Len(t, pkts, 1)
+		packet := pkts[0]
+		assert.Equal(t, "http_requests_rate5m:1.5|g|#code:200,path:/a_b_c|T1700000000\nup:1000000|g|T1700000000", string(packet.Contents))
+		assert.Equal(t, "container_id://abcdef", packet.Origin)
+		assert.Equal(t, packets.RemoteWrite, packet.Source)
+		assert.Equal(t, "remote_write", packet.ListenerID)
+	case <-time.After(2 * time.Second):
+		assert.FailNow(t, "Timeout on receive channel")
+	}
+}
+
+func TestRemoteWriteOriginDetectionDisabled(t *testing.T) {
+	s, packetChannel := newTestRemoteWriteListener(t, map[string]interface{}{"dogstatsd_origin_detection_client": false})
+
+	request := &prompb.WriteRequest{
+		Timeseries: []prompb.TimeSeries{{
+			Labels:  []prompb.Label{{Name: "__name__", Value: "up"}},
+			Samples: []prompb.Sample{{Value: 1, Timestamp: 1700000000000}},
+		}},
+	}
+	resp := postWriteRequest(t, s, request, http.Header{"Datadog-Container-Id": {"abcdef"}})
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+	select {
+	case pkts := <-packetChannel:
+		require.Len(t, pkts, 1)
+		assert.Equal(t, packets.NoOrigin, pkts[0].Origin)
+	case <-time.After(2 * time.Second):
+		assert.FailNow(t, "Timeout on receive channel")
+	}
+}
+
+func TestRemoteWriteSplitPackets(t *testing.T) {
+	s, packetChannel := newTestRemoteWriteListener(t, map[string]interface{}{"dogstatsd_buffer_size": 60})
+
+	request := &prompb.WriteRequest{
+		Timeseries: []prompb.TimeSeries{{
+			Labels: []prompb.Label{{Name: "__name__", Value: "up"}, {Name: "job", Value: "api"}},
+			Samples: []prompb.Sample{
+				{Value: 1, Timestamp: 1700000000000},
+				{Value: 2, Timestamp: 1700000001000},
+				{Value: 3, Timestamp: 1700000002000},
+			},
+		}, {
+			// messages longer than the buffer are dropped
+			Labels:  []prompb.Label{{Name: "__name__", Value: "up"}, {Name: "job", Value: "a_very_long_job_name_which_does_not_fit"}},
+			Samples: []prompb.Sample{{Value: 1, Timestamp: 1700000000000}},
+		}},
+	}
+	resp := postWriteRequest(t, s, request, nil)
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+	select {
+	case pkts := <-packetChannel:
+		require.Len(t, pkts, 2)
+		assert.Equal(t, "up:1|g|#job:api|T1700000000\nup:2|g|#job:api|T1700000001", string(pkts[0].Contents))
+		assert.Equal(t, "up:3|g|#job:api|T1700000002", string(pkts[1].Contents))
+	case <-time.After(2 * time.Second):
+		assert.FailNow(t, "Timeout on receive channel")
+	}
+}
+
+func TestRemoteWriteInvalidRequests(t *testing.T) {
+	s, _ := newTestRemoteWriteListener(t, map[string]interface{}{"dogstatsd_remote_write_max_body_size": 16})
+	url := "http://" + s.LocalAddr() + RemoteWritePath
+
+	resp, err := http.Get(url)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+
+	resp, err = http.Post(url, "application/x-protobuf", bytes.NewReader([]byte("not snappy")))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	resp, err = http.Post(url, "application/x-protobuf", bytes.NewReader(make([]byte, 32)))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusRequestEntityTooLarge, resp.StatusCode)
+}
+
+func TestRemoteWriteDecodedSizeLimit(t *testing.T) {
+	s, _ := newTestRemoteWriteListener(t, map[string]interface{}{"dogstatsd_remote_write_max_decoded_size": 1024})
+	url := "http://" + s.LocalAddr() + RemoteWritePath
+
+	// a snappy block starts with the varint of its decoded length, this one declares 1GiB
+	resp, err := http.Post(url, "application/x-protobuf", bytes.NewReader([]byte{0x80, 0x80, 0x80, 0x80, 0x04, 0x00}))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusRequestEntityTooLarge, resp.StatusCode)
+
+	request := &prompb.WriteRequest{
+		Timeseries: []prompb.TimeSeries{{
+			Labels:  []prompb.Label{{Name: "__name__", Value: "up"}},
+			Samples: []prompb.Sample{{Value: 1, Timestamp: 1700000000000}},
+		}},
+	}
+	resp = postWriteRequest(t, s, request, nil)
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+}
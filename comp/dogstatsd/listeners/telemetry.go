@@ -23,6 +23,9 @@ type TelemetryStore struct {
 	tlmUDSOriginDetectionError telemetry.Counter
 	tlmUDSPacketsBytes         telemetry.Counter
 	tlmUDSConnections          telemetry.Gauge
+	// Remote write
+	tlmRemoteWriteRequests telemetry.Counter
+	tlmRemoteWriteSamples  telemetry.Counter
 
 	tlmListener telemetry.Histogram
 }
@@ -46,6 +49,10 @@ func NewTelemetryStore(buckets []float64, telemetrycomp telemetry.Component) *Te
 			[]string{"listener_id", "transport"}, "Dogstatsd UDS packets bytes"),
 		tlmUDSConnections: telemetrycomp.NewGauge("dogstatsd", "uds_connections",
 			[]string{"listener_id", "transport"}, "Dogstatsd UDS connections count"),
+		tlmRemoteWriteRequests: telemetrycomp.NewCounter("dogstatsd", "remote_write_requests",
+			[]string{"state"}, "Dogstatsd Prometheus remote-write requests count"),
+		tlmRemoteWriteSamples: telemetrycomp.NewCounter("dogstatsd", "remote_write_samples",
+			[]string{"state"}, "Dogstatsd Prometheus remote-write samples count"),
 		tlmListener: telemetrycomp.NewHistogram(
 			"dogstatsd",
 			"listener_read_latency",
@@ -0,0 +1,216 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package mapper
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Actions applied on the mapped metrics, inspired by the Prometheus relabeling.
+const (
+	// actionDrop drops the metric
+	actionDrop = "drop"
+	// actionDropTags removes the tags of the given keys
+	actionDropTags = "drop_tags"
+	// actionKeepTags removes the tags of the keys not in the given list
+	actionKeepTags = "keep_tags"
+	// actionRenameTag renames the key of a tag
+	actionRenameTag = "rename_tag"
+	// actionReplaceTag rewrites the value of a tag matching a regex
+	actionReplaceTag = "replace_tag"
+)
+
+// MetricActionConfig represent an action applied on the metrics of a mapping,
+// once mapped. The action is only applied when the tags of the metric match
+// all the `if_tags` conditions.
+type MetricActionConfig struct {
+	Action      string            `mapstructure:"action" json:"action" yaml:"action"`
+	TagKeys     []string          `mapstructure:"tag_keys" json:"tag_keys" yaml:"tag_keys"`
+	SourceTag   string            `mapstructure:"source_tag" json:"source_tag" yaml:"source_tag"`
+	TargetTag   string            `mapstructure:"target_tag" json:"target_tag" yaml:"target_tag"`
+	Regex       string            `mapstructure:"regex" json:"regex" yaml:"regex"`
+	Replacement string            `mapstructure:"replacement" json:"replacement" yaml:"replacement"`
+	IfTags      map[string]string `mapstructure:"if_tags" json:"if_tags" yaml:"if_tags"`
+}
+
+// metricAction is a validated MetricActionConfig
+type metricAction struct {
+	action      string
+	tagKeys     map[string]struct{}
+	sourceTag   string
+	targetTag   string
+	regex       *regexp.Regexp
+	replacement string
+	// ifTags holds the regexes the values of the tags must match, by tag key
+	ifTags map[string]*regexp.Regexp
+}
+
+func newMetricAction(config MetricActionConfig) (*metricAction, error) {
+	action := &metricAction{
+		action:      config.Action,
+		sourceTag:   config.SourceTag,
+		targetTag:   config.TargetTag,
+		replacement: config.Replacement,
+	}
+
+	switch config.Action {
+	case actionDrop:
+	case actionDropTags, actionKeepTags:
+		if len(config.TagKeys) == 0 {
+			return nil, fmt.Errorf("action `%s`: tag_keys is required", config.Action)
+		}
+		action.tagKeys = make(map[string]struct{}, len(config.TagKeys))
+		for _, key := range config.TagKeys {
+			action.tagKeys[key] = struct{}{}
+		}
+	case actionRenameTag:
+		if config.SourceTag == "" || config.TargetTag == "" {
+			return nil, fmt.Errorf("action `%s`: source_tag and target_tag are required", config.Action)
+		}
+	case actionReplaceTag:
+		if config.SourceTag == "" {
+			return nil, fmt.Errorf("action `%s`: source_tag is required", config.Action)
+		}
+		if action.targetTag == "" {
+			action.targetTag = config.SourceTag
+		}
+		regex, err := compileAnchored(config.Regex)
+		if err != nil {
+			return nil, fmt.Errorf("action `%s`: %v", config.Action, err)
+		}
+		action.regex = regex
+	default:
+		return nil, fmt.Errorf("invalid action `%s`, must be one of `%s`, `%s`, `%s`, `%s` or `%s`", config.Action, actionDrop, actionDropTags, actionKeepTags, actionRenameTag, actionReplaceTag)
+	}
+
+	if len(config.IfTags) > 0 {
+		action.ifTags = make(map[string]*regexp.Regexp, len(config.IfTags))
+		for key, value := range config.IfTags {
+			regex, err := compileAnchored(value)
+			if err != nil {
+				return nil, fmt.Errorf("action `%s`, if_tags `%s`: %v", config.Action, key, err)
+			}
+			action.ifTags[key] = regex
+		}
+	}
+	return action, nil
+}
+
+// compileAnchored compiles a regex matching whole values, an empty regex matches any value.
+func compileAnchored(expr string) (*regexp.Regexp, error) {
+	if expr == "" {
+		expr = ".*"
+	}
+	regex, err := regexp.Compile("^(?:" + expr + ")$")
+	if err != nil {
+		return nil, fmt.Errorf("cannot compile regex `%s`: %v", expr, err)
+	}
+	return regex, nil
+}
+
+// splitTag returns the key and the value of a tag, the value of a tag without
+// colon is empty.
+func splitTag(tag string) (string, string) {
+	if idx := strings.IndexByte(tag, ':'); idx >= 0 {
+		return tag[:idx], tag[idx+1:]
+	}
+	return tag, ""
+}
+
+// matches returns true if the tags satisfy all the conditions of the action.
+func (a *metricAction) matches(tags []string) bool {
+	for key, regex := range a.ifTags {
+		found := false
+		for _, tag := range tags {
+			if k, v := splitTag(tag); k == key && regex.MatchString(v) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// apply applies the action to the tags, it returns false if the metric must be dropped.
+func (a *metricAction) apply(tags []string) ([]string, bool) {
+	if !a.matches(tags) {
+		return tags, true
+	}
+
+	switch a.action {
+	case actionDrop:
+		return nil, false
+	case actionDropTags, actionKeepTags:
+		keep := a.action == actionKeepTags
+		filtered := tags[:0]
+		for _, tag := range tags {
+			key, _ := splitTag(tag)
+			if _, listed := a.tagKeys[key]; listed == keep {
+				filtered = append(filtered, tag)
+			}
+		}
+		return filtered, true
+	case actionRenameTag:
+		for i, tag := range tags {
+			if key, _ := splitTag(tag); key == a.sourceTag {
+				tags[i] = a.targetTag + tag[len(key):]
+			}
+		}
+	case actionReplaceTag:
+		var added []string
+		for i, tag := range tags {
+			key, value := splitTag(tag)
+			if key != a.sourceTag {
+				continue
+			}
+			matches := a.regex.FindStringSubmatchIndex(value)
+			if matches == nil {
+				continue
+			}
+			replaced := string(a.regex.ExpandString(nil, a.replacement, value, matches))
+			if a.targetTag == a.sourceTag {
+				tags[i] = a.targetTag + ":" + replaced
+			} else {
+				added = append(added, a.targetTag+":"+replaced)
+			}
+		}
+		if len(added) > 0 {
+			// the replaced values override the existing values of the target tag
+			filtered := tags[:0]
+			for _, tag := range tags {
+				if key, _ := splitTag(tag); key != a.targetTag {
+					filtered = append(filtered, tag)
+				}
+			}
+			tags = append(filtered, added...)
+		}
+	}
+	return tags, true
+}
+
+// ApplyActions applies the actions of the mapping to the tags of a mapped
+// metric, which are the tags of the metric followed by the tags of the
+// MapResult. It returns the new tags, and false if the metric must be dropped.
+// The given slice is not modified.
+func (r *MapResult) ApplyActions(tags []string) ([]string, bool) {
+	if len(r.actions) == 0 {
+		return tags, true
+	}
+	result := make([]string, len(tags), len(tags)+len(r.actions))
+	copy(result, tags)
+	for _, action := range r.actions {
+		var keep bool
+		if result, keep = action.apply(result); !keep {
+			return nil, false
+		}
+	}
+	return result, true
+}
@@ -40,10 +40,11 @@ type MappingProfileConfig struct {
 
 // MetricMapping represent one mapping rule
 type MetricMappingConfig struct {
-	Match     string            `mapstructure:"match" json:"match" yaml:"match"`
-	MatchType string            `mapstructure:"match_type" json:"match_type" yaml:"match_type"`
-	Name      string            `mapstructure:"name" json:"name" yaml:"name"`
-	Tags      map[string]string `mapstructure:"tags" json:"tags" yaml:"tags"`
+	Match     string               `mapstructure:"match" json:"match" yaml:"match"`
+	MatchType string               `mapstructure:"match_type" json:"match_type" yaml:"match_type"`
+	Name      string               `mapstructure:"name" json:"name" yaml:"name"`
+	Tags      map[string]string    `mapstructure:"tags" json:"tags" yaml:"tags"`
+	Actions   []MetricActionConfig `mapstructure:"actions" json:"actions" yaml:"actions"`
 }
 
 // MetricMapper contains mappings and cache instance
@@ -61,9 +62,10 @@ type MappingProfile struct {
 
 // MetricMapping represent one mapping rule
 type MetricMapping struct {
-	name  string
-	tags  map[string]string
-	regex *regexp.Regexp
+	name    string
+	tags    map[string]string
+	regex   *regexp.Regexp
+	actions []*metricAction
 }
 
 // MapResult represent the outcome of the mapping
@@ -71,6 +73,7 @@ type MapResult struct {
 	Name    string
 	Tags    []string
 	matched bool
+	actions []*metricAction
 }
 
 // NewMetricMapper creates, validates, prepares a new MetricMapper
@@ -106,7 +109,15 @@ func NewMetricMapper(configProfiles []MappingProfileConfig, cacheSize int) (*Met
 			if err != nil {
 				return nil, err
 			}
-			profile.Mappings = append(profile.Mappings, &MetricMapping{name: currentMapping.Name, tags: currentMapping.Tags, regex: regex})
+			var actions []*metricAction
+			for j, actionConfig := range currentMapping.Actions {
+				action, err := newMetricAction(actionConfig)
+				if err != nil {
+					return nil, fmt.Errorf("profile: %s, mapping num %d, action num %d: %v", profile.Name, i, j, err)
+				}
+				actions = append(actions, action)
+			}
+			profile.Mappings = append(profile.Mappings, &MetricMapping{name: currentMapping.Name, tags: currentMapping.Tags, regex: regex, actions: actions})
 		}
 		profiles = append(profiles, profile)
 	}
@@ -167,7 +178,7 @@ func (m *MetricMapper) Map(metricName string) *MapResult {
 				tags = append(tags, tagKey+":"+tagValue)
 			}
 
-			mapResult := &MapResult{Name: name, matched: true, Tags: tags}
+			mapResult := &MapResult{Name: name, matched: true, Tags: tags, actions: mapping.actions}
 			m.cache.add(metricName, mapResult)
 			return mapResult
 		}
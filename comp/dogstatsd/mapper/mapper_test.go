@@ -474,6 +474,65 @@ dogstatsd_mapper_profiles:
 			},
 			expectedError: "invalid match type",
 		},
+		{
+			name: "Invalid action",
+			config: `
+dogstatsd_mapper_profiles:
+  - name: test
+    prefix: 'test.'
+    mappings:
+      - match: "test.job.duration"
+        name: "test.job.duration"
+        actions:
+          - action: relabel
+`,
+			expectedError: "profile: test, mapping num 0, action num 0: invalid action `relabel`",
+		},
+		{
+			name: "Missing tag keys",
+			config: `
+dogstatsd_mapper_profiles:
+  - name: test
+    prefix: 'test.'
+    mappings:
+      - match: "test.job.duration"
+        name: "test.job.duration"
+        actions:
+          - action: keep_tags
+`,
+			expectedError: "tag_keys is required",
+		},
+		{
+			name: "Missing rename target",
+			config: `
+dogstatsd_mapper_profiles:
+  - name: test
+    prefix: 'test.'
+    mappings:
+      - match: "test.job.duration"
+        name: "test.job.duration"
+        actions:
+          - action: rename_tag
+            source_tag: foo
+`,
+			expectedError: "source_tag and target_tag are required",
+		},
+		{
+			name: "Invalid replace regex",
+			config: `
+dogstatsd_mapper_profiles:
+  - name: test
+    prefix: 'test.'
+    mappings:
+      - match: "test.job.duration"
+        name: "test.job.duration"
+        actions:
+          - action: replace_tag
+            source_tag: foo
+            regex: "("
+`,
+			expectedError: "cannot compile regex",
+		},
 		{
 			name: "Missing profile name",
 			config: `
@@ -515,6 +574,75 @@ dogstatsd_mapper_profiles:
 	}
 }
 
+func TestMappingActions(t *testing.T) {
+	mapper, err := getMapper(t, `
+dogstatsd_mapper_profiles:
+  - name: test
+    prefix: 'test.'
+    mappings:
+      - match: "test.request.*"
+        name: "test.request"
+        tags:
+          kind: "$1"
+        actions:
+          - action: drop
+            if_tags:
+              kind: "debug"
+          - action: replace_tag
+            source_tag: path
+            regex: '/users/(\w+)/.*'
+            replacement: '/users/$1'
+          - action: replace_tag
+            source_tag: status
+            target_tag: status_class
+            regex: '(\d)\d\d'
+            replacement: '${1}xx'
+          - action: rename_tag
+            source_tag: host_name
+            target_tag: origin_host
+            if_tags:
+              team: ""
+          - action: keep_tags
+            tag_keys: [kind, path, status_class, origin_host, host_name]
+      - match: "test.job.*"
+        name: "test.job"
+        actions:
+          - action: drop_tags
+            tag_keys: [job_id, run]
+`)
+	require.NoError(t, err)
+
+	result := mapper.Map("test.request.http")
+	require.NotNil(t, result)
+	tags := []string{"path:/users/bob/settings", "status:503", "host_name:web1", "team:core", "user:bob"}
+	actual, keep := result.ApplyActions(append(tags, result.Tags...))
+	assert.True(t, keep)
+	assert.Equal(t, []string{"path:/users/bob", "origin_host:web1", "kind:http", "status_class:5xx"}, actual)
+	// the given tags are not modified
+	assert.Equal(t, "path:/users/bob/settings", tags[0])
+
+	// the replaced value overrides the existing value of the target tag
+	actual, keep = result.ApplyActions(append([]string{"status_class:unknown", "status:404"}, result.Tags...))
+	assert.True(t, keep)
+	assert.Equal(t, []string{"kind:http", "status_class:4xx"}, actual)
+
+	// the conditions of the rename aren't satisfied without the team tag
+	actual, keep = result.ApplyActions(append([]string{"host_name:web1", "path:/static"}, result.Tags...))
+	assert.True(t, keep)
+	assert.Equal(t, []string{"host_name:web1", "path:/static", "kind:http"}, actual)
+
+	result = mapper.Map("test.request.debug")
+	require.NotNil(t, result)
+	_, keep = result.ApplyActions(result.Tags)
+	assert.False(t, keep)
+
+	result = mapper.Map("test.job.backup")
+	require.NotNil(t, result)
+	actual, keep = result.ApplyActions([]string{"job_id:1234", "env:prod", "run", "owner:ops"})
+	assert.True(t, keep)
+	assert.Equal(t, []string{"env:prod", "owner:ops"}, actual)
+}
+
 func getMapper(t *testing.T, configString string) (*MetricMapper, error) {
 	var profiles []MappingProfileConfig
 
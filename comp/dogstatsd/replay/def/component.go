@@ -21,7 +21,8 @@ type Component interface {
 	IsOngoing() bool
 
 	// StartCapture starts a TrafficCapture and returns an error in the event of an issue.
-	StartCapture(p string, d time.Duration, compressed bool) (string, error)
+	// Only the messages selected by the filter are written to the capture.
+	StartCapture(p string, d time.Duration, compressed bool, filter CaptureFilter) (string, error)
 
 	// StopCapture stops an ongoing TrafficCapture.
 	StopCapture()
@@ -52,6 +53,24 @@ type UnixDogstatsdMsg struct {
 	Ancillary     []byte
 }
 
+// Message types of the CaptureFilter.
+const (
+	MetricMessageType       = "metric"
+	EventMessageType        = "event"
+	ServiceCheckMessageType = "service_check"
+)
+
+// CaptureFilter selects the messages written to a capture. The packets are
+// kept when their origin matches one of the PIDs or container IDs, and their
+// messages when they match one of the message types and, for metrics, one of
+// the metric name prefixes. Empty lists match everything.
+type CaptureFilter struct {
+	MetricPrefixes []string
+	Pids           []int32
+	ContainerIDs   []string
+	MessageTypes   []string
+}
+
 // CaptureBuffer holds pointers to captured packet's buffers (and oob buffer if required) and the protobuf
 // message used for serialization.
 type CaptureBuffer struct {
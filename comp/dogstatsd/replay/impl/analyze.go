@@ -0,0 +1,220 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package replayimpl
+
+import (
+	"bytes"
+	"io"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	replay "github.com/DataDog/datadog-agent/comp/dogstatsd/replay/def"
+)
+
+// CaptureAnalysis holds statistics about the traffic of a capture.
+type CaptureAnalysis struct {
+	Packets  int
+	Messages int
+	// Duration is the time between the first and the last packets.
+	Duration time.Duration
+	// MessageTypes counts the messages by type.
+	MessageTypes map[string]int
+	PacketSizes  SizeStats
+	// Metrics holds the metrics, sorted by decreasing number of messages.
+	Metrics []MetricStats
+	// Origins holds the origins of the packets, sorted by decreasing number of messages.
+	Origins []OriginStats
+}
+
+// SizeStats holds the distribution of the packet sizes, in bytes.
+type SizeStats struct {
+	Min  int
+	Max  int
+	Mean float64
+	P50  int
+	P99  int
+}
+
+// MetricStats holds statistics about the messages of a metric name.
+type MetricStats struct {
+	Name     string
+	Messages int
+	// Contexts is the number of unique tag sets of the metric.
+	Contexts int
+	// TagCardinality is the number of unique values of each tag name.
+	TagCardinality map[string]int
+}
+
+// OriginStats holds statistics about the packets sent by an origin.
+type OriginStats struct {
+	// Origin is the container ID of the origin if it is known, its process
+	// ID otherwise.
+	Origin   string
+	Packets  int
+	Messages int
+	Bytes    int
+	// MessagesPerSecond is the rate of messages over the duration of the capture.
+	MessagesPerSecond float64
+}
+
+type metricAccumulator struct {
+	messages int
+	contexts map[string]struct{}
+	values   map[string]map[string]struct{}
+}
+
+// AnalyzeCapture reads all the packets of a capture and returns statistics
+// about its traffic. The reader is rewound before and after the analysis.
+func AnalyzeCapture(reader *TrafficCaptureReader) (*CaptureAnalysis, error) {
+	tsResolution := time.Nanosecond
+	if reader.Version < minNanoVersion {
+		tsResolution = time.Second
+	}
+	// the pid map is only available in recent captures
+	pidMap, _, _ := reader.ReadState()
+
+	analysis := &CaptureAnalysis{MessageTypes: make(map[string]int)}
+	metrics := make(map[string]*metricAccumulator)
+	origins := make(map[string]*OriginStats)
+	var sizes []int
+	var first, last int64
+
+	reader.Seek(0)
+	defer reader.Seek(0)
+	for {
+		msg, err := reader.ReadNext()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		if analysis.Packets == 0 {
+			first = msg.Timestamp
+		}
+		last = msg.Timestamp
+		analysis.Packets++
+		payload := msg.Payload[:min(int(msg.PayloadSize), len(msg.Payload))]
+		sizes = append(sizes, len(payload))
+
+		originID, ok := pidMap[msg.Pid]
+		if !ok {
+			originID = "pid:" + strconv.Itoa(int(msg.Pid))
+		}
+		origin, ok := origins[originID]
+		if !ok {
+			origin = &OriginStats{Origin: originID}
+			origins[originID] = origin
+		}
+		origin.Packets++
+		origin.Bytes += len(payload)
+
+		for _, message := range bytes.Split(payload, []byte{'\n'}) {
+			if len(bytes.TrimSpace(message)) == 0 {
+				continue
+			}
+			analysis.Messages++
+			origin.Messages++
+			messageType := messageType(message)
+			analysis.MessageTypes[messageType]++
+			if messageType == replay.MetricMessageType {
+				analyzeMetric(metrics, message)
+			}
+		}
+	}
+
+	analysis.Duration = time.Duration(last-first) * tsResolution
+	analysis.PacketSizes = sizeStats(sizes)
+
+	for name, metric := range metrics {
+		stats := MetricStats{
+			Name:           name,
+			Messages:       metric.messages,
+			Contexts:       len(metric.contexts),
+			TagCardinality: make(map[string]int, len(metric.values)),
+		}
+		for tagName, values := range metric.values {
+			stats.TagCardinality[tagName] = len(values)
+		}
+		analysis.Metrics = append(analysis.Metrics, stats)
+	}
+	sort.Slice(analysis.Metrics, func(i, j int) bool {
+		if analysis.Metrics[i].Messages != analysis.Metrics[j].Messages {
+			return analysis.Metrics[i].Messages > analysis.Metrics[j].Messages
+		}
+		return analysis.Metrics[i].Name < analysis.Metrics[j].Name
+	})
+
+	seconds := max(analysis.Duration.Seconds(), 1)
+	for _, origin := range origins {
+		origin.MessagesPerSecond = float64(origin.Messages) / seconds
+		analysis.Origins = append(analysis.Origins, *origin)
+	}
+	sort.Slice(analysis.Origins, func(i, j int) bool {
+		if analysis.Origins[i].Messages != analysis.Origins[j].Messages {
+			return analysis.Origins[i].Messages > analysis.Origins[j].Messages
+		}
+		return analysis.Origins[i].Origin < analysis.Origins[j].Origin
+	})
+
+	return analysis, nil
+}
+
+// analyzeMetric accumulates the name and the tags of a metric message.
+func analyzeMetric(metrics map[string]*metricAccumulator, message []byte) {
+	name := metricName(message)
+	metric, ok := metrics[name]
+	if !ok {
+		metric = &metricAccumulator{
+			contexts: make(map[string]struct{}),
+			values:   make(map[string]map[string]struct{}),
+		}
+		metrics[name] = metric
+	}
+	metric.messages++
+
+	var tags []string
+	for _, field := range strings.Split(string(message), "|")[1:] {
+		if strings.HasPrefix(field, "#") {
+			tags = strings.Split(field[1:], ",")
+			break
+		}
+	}
+	slices.Sort(tags)
+	tags = slices.Compact(tags)
+	metric.contexts[strings.Join(tags, ",")] = struct{}{}
+
+	for _, tag := range tags {
+		tagName, value, _ := strings.Cut(tag, ":")
+		values, ok := metric.values[tagName]
+		if !ok {
+			values = make(map[string]struct{})
+			metric.values[tagName] = values
+		}
+		values[value] = struct{}{}
+	}
+}
+
+func sizeStats(sizes []int) SizeStats {
+	if len(sizes) == 0 {
+		return SizeStats{}
+	}
+	slices.Sort(sizes)
+	total := 0
+	for _, size := range sizes {
+		total += size
+	}
+	return SizeStats{
+		Min:  sizes[0],
+		Max:  sizes[len(sizes)-1],
+		Mean: float64(total) / float64(len(sizes)),
+		P50:  sizes[(len(sizes)-1)*50/100],
+		P99:  sizes[(len(sizes)-1)*99/100],
+	}
+}
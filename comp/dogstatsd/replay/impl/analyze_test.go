@@ -0,0 +1,75 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package replayimpl
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnalyzeCapture(t *testing.T) {
+	reader, err := NewTrafficCaptureReader("resources/test/datadog-capture.dog.zstd", 1, false)
+	require.NoError(t, err)
+	defer reader.Close()
+
+	analysis, err := AnalyzeCapture(reader)
+	require.NoError(t, err)
+
+	assert.Equal(t, 21, analysis.Packets)
+	assert.Equal(t, 21, analysis.Messages)
+	assert.Equal(t, 13*time.Second, analysis.Duration)
+	assert.Equal(t, map[string]int{"metric": 21}, analysis.MessageTypes)
+	assert.Equal(t, SizeStats{Min: 30, Max: 30, Mean: 30, P50: 30, P99: 30}, analysis.PacketSizes)
+	assert.Equal(t, []MetricStats{{Name: "jaime.uds.test", Messages: 21, Contexts: 1, TagCardinality: map[string]int{"shell": 1}}}, analysis.Metrics)
+
+	// the processes of a same container are grouped together
+	require.Len(t, analysis.Origins, 15)
+	assert.Equal(t, OriginStats{
+		Origin:            "container_id://c1371eaf97a11f43ac700fd8524b4ea316d83a7259282a9e9eeac8d071406b22",
+		Packets:           7,
+		Messages:          7,
+		Bytes:             210,
+		MessagesPerSecond: 7.0 / 13,
+	}, analysis.Origins[0])
+	assert.Equal(t, "pid:2809", analysis.Origins[1].Origin)
+
+	// the reader is rewound
+	msg, err := reader.ReadNext()
+	require.NoError(t, err)
+	assert.Equal(t, int32(2809), msg.Pid)
+}
+
+func TestAnalyzeMetric(t *testing.T) {
+	metrics := make(map[string]*metricAccumulator)
+	analyzeMetric(metrics, []byte("app.requests:1|c|#env:prod,path:/a"))
+	analyzeMetric(metrics, []byte("app.requests:1|c|@0.5|#path:/a,env:prod,env:prod"))
+	analyzeMetric(metrics, []byte("app.requests:1|c|#env:prod,path:/b,canary"))
+	analyzeMetric(metrics, []byte("app.requests:2|c"))
+
+	metric := metrics["app.requests"]
+	require.NotNil(t, metric)
+	assert.Equal(t, 4, metric.messages)
+	assert.Len(t, metric.contexts, 3)
+	assert.Equal(t, map[string]map[string]struct{}{
+		"env":    {"prod": {}},
+		"path":   {"/a": {}, "/b": {}},
+		"canary": {"": {}},
+	}, metric.values)
+}
+
+func TestSizeStats(t *testing.T) {
+	assert.Equal(t, SizeStats{}, sizeStats(nil))
+	assert.Equal(t, SizeStats{Min: 1, Max: 100, Mean: 50.5, P50: 50, P99: 99}, sizeStats(func() []int {
+		sizes := make([]int, 100)
+		for i := range sizes {
+			sizes[i] = 100 - i
+		}
+		return sizes
+	}()))
+}
@@ -76,17 +76,21 @@ func (tc *trafficCapture) IsOngoing() bool {
 }
 
 // StartCapture starts a TrafficCapture and returns an error in the event of an issue.
-func (tc *trafficCapture) StartCapture(p string, d time.Duration, compressed bool) (string, error) {
+func (tc *trafficCapture) StartCapture(p string, d time.Duration, compressed bool, filter replay.CaptureFilter) (string, error) {
 	if tc.IsOngoing() {
 		return "", fmt.Errorf("Ongoing capture in progress")
 	}
 
+	if err := validateFilter(filter); err != nil {
+		return "", err
+	}
+
 	target, path, err := OpenFile(afero.NewOsFs(), p, tc.defaultlocation())
 	if err != nil {
 		return "", err
 	}
 
-	go tc.writer.Capture(target, d, compressed)
+	go tc.writer.Capture(target, d, compressed, filter)
 
 	return path, nil
 }
@@ -0,0 +1,120 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package replayimpl
+
+import (
+	"bytes"
+	"fmt"
+	"slices"
+	"strings"
+
+	replay "github.com/DataDog/datadog-agent/comp/dogstatsd/replay/def"
+)
+
+var (
+	eventPrefix        = []byte("_e{")
+	serviceCheckPrefix = []byte("_sc|")
+)
+
+// validateFilter returns an error if the filter has an unknown message type.
+func validateFilter(filter replay.CaptureFilter) error {
+	for _, messageType := range filter.MessageTypes {
+		switch messageType {
+		case replay.MetricMessageType, replay.EventMessageType, replay.ServiceCheckMessageType:
+		default:
+			return fmt.Errorf("unknown message type %q, expected one of: %s, %s, %s", messageType,
+				replay.MetricMessageType, replay.EventMessageType, replay.ServiceCheckMessageType)
+		}
+	}
+	return nil
+}
+
+// isEmptyFilter returns true if the filter selects all the messages.
+func isEmptyFilter(filter replay.CaptureFilter) bool {
+	return len(filter.MetricPrefixes) == 0 && len(filter.Pids) == 0 &&
+		len(filter.ContainerIDs) == 0 && len(filter.MessageTypes) == 0
+}
+
+// matchOrigin returns true if the origin of a packet is selected by the filter.
+func matchOrigin(filter replay.CaptureFilter, pid int32, containerID string) bool {
+	if len(filter.Pids) == 0 && len(filter.ContainerIDs) == 0 {
+		return true
+	}
+	return slices.Contains(filter.Pids, pid) || (containerID != "" && slices.Contains(filter.ContainerIDs, containerID))
+}
+
+// filterPayload returns the messages of a payload selected by the filter, the
+// payload is returned as is when all its messages are selected.
+func filterPayload(filter replay.CaptureFilter, payload []byte) []byte {
+	if len(filter.MetricPrefixes) == 0 && len(filter.MessageTypes) == 0 {
+		return payload
+	}
+
+	filtered := make([]byte, 0, len(payload))
+	all := true
+	for remaining := payload; len(remaining) > 0; {
+		message := remaining
+		if i := bytes.IndexByte(remaining, '\n'); i >= 0 {
+			message, remaining = remaining[:i], remaining[i+1:]
+		} else {
+			remaining = nil
+		}
+		if len(bytes.TrimSpace(message)) == 0 {
+			continue
+		}
+		if !matchMessage(filter, message) {
+			all = false
+			continue
+		}
+		filtered = append(filtered, message...)
+		filtered = append(filtered, '\n')
+	}
+	if all {
+		return payload
+	}
+	return filtered
+}
+
+// matchMessage returns true if a message is selected by the filter.
+func matchMessage(filter replay.CaptureFilter, message []byte) bool {
+	messageType := messageType(message)
+	if len(filter.MessageTypes) > 0 && !slices.Contains(filter.MessageTypes, messageType) {
+		return false
+	}
+	if len(filter.MetricPrefixes) == 0 {
+		return true
+	}
+	if messageType != replay.MetricMessageType {
+		return false
+	}
+	name := metricName(message)
+	for _, prefix := range filter.MetricPrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// messageType returns the type of a DogStatsD message.
+func messageType(message []byte) string {
+	switch {
+	case bytes.HasPrefix(message, eventPrefix):
+		return replay.EventMessageType
+	case bytes.HasPrefix(message, serviceCheckPrefix):
+		return replay.ServiceCheckMessageType
+	default:
+		return replay.MetricMessageType
+	}
+}
+
+// metricName returns the name of a DogStatsD metric message.
+func metricName(message []byte) string {
+	if i := bytes.IndexByte(message, ':'); i >= 0 {
+		message = message[:i]
+	}
+	return string(message)
+}
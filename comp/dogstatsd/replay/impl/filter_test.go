@@ -0,0 +1,63 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package replayimpl
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	replay "github.com/DataDog/datadog-agent/comp/dogstatsd/replay/def"
+)
+
+const testPayload = "app.requests:1|c|#env:prod\n_e{5,4}:title|text\nsys.cpu:0.5|g\n_sc|check|0\n"
+
+func TestValidateFilter(t *testing.T) {
+	assert.NoError(t, validateFilter(replay.CaptureFilter{MessageTypes: []string{"metric", "event", "service_check"}}))
+	assert.Error(t, validateFilter(replay.CaptureFilter{MessageTypes: []string{"metrics"}}))
+}
+
+func TestMatchOrigin(t *testing.T) {
+	assert.True(t, matchOrigin(replay.CaptureFilter{}, 12, ""))
+	assert.True(t, matchOrigin(replay.CaptureFilter{Pids: []int32{12}}, 12, ""))
+	assert.False(t, matchOrigin(replay.CaptureFilter{Pids: []int32{12}}, 13, "container_id://abc"))
+	assert.True(t, matchOrigin(replay.CaptureFilter{Pids: []int32{12}, ContainerIDs: []string{"container_id://abc"}}, 13, "container_id://abc"))
+	assert.False(t, matchOrigin(replay.CaptureFilter{ContainerIDs: []string{"container_id://abc"}}, 13, ""))
+}
+
+func TestFilterPayload(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		filter   replay.CaptureFilter
+		expected string
+	}{
+		{"no filter", replay.CaptureFilter{}, testPayload},
+		{"metric prefix", replay.CaptureFilter{MetricPrefixes: []string{"app."}}, "app.requests:1|c|#env:prod\n"},
+		{"message types", replay.CaptureFilter{MessageTypes: []string{"event", "service_check"}}, "_e{5,4}:title|text\n_sc|check|0\n"},
+		{"all messages", replay.CaptureFilter{MessageTypes: []string{"metric", "event", "service_check"}}, testPayload},
+		{"no match", replay.CaptureFilter{MetricPrefixes: []string{"db."}}, ""},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, string(filterPayload(tc.filter, []byte(testPayload))))
+		})
+	}
+}
+
+func TestWriterFilterMessage(t *testing.T) {
+	writer := &TrafficCaptureWriter{filter: replay.CaptureFilter{Pids: []int32{12}, MetricPrefixes: []string{"sys."}}}
+
+	msg := &replay.CaptureBuffer{Pid: 12}
+	msg.Pb.Payload = []byte(testPayload + "unused buffer")
+	msg.Pb.PayloadSize = int32(len(testPayload))
+	assert.True(t, writer.filterMessage(msg))
+	assert.Equal(t, "sys.cpu:0.5|g\n", string(msg.Pb.Payload))
+	assert.Equal(t, int32(len("sys.cpu:0.5|g\n")), msg.Pb.PayloadSize)
+
+	msg = &replay.CaptureBuffer{Pid: 13}
+	msg.Pb.Payload = []byte(testPayload)
+	msg.Pb.PayloadSize = int32(len(testPayload))
+	assert.False(t, writer.filterMessage(msg))
+}
@@ -65,6 +65,9 @@ type TrafficCaptureWriter struct {
 	taggerState map[int32]string
 	tagger      tagger.Component
 
+	// filter selects the messages written to the capture
+	filter replay.CaptureFilter
+
 	// Synchronizes access to ongoing, accepting and closing of Traffic
 	sync.RWMutex
 }
@@ -82,14 +85,16 @@ func NewTrafficCaptureWriter(depth int, tagger tagger.Component) *TrafficCapture
 // processMessage receives a capture buffer and writes it to disk while also tracking
 // the PID map to be persisted to the taggerState. Should not normally be called directly.
 func (tc *TrafficCaptureWriter) processMessage(msg *replay.CaptureBuffer) error {
-	err := tc.writeNext(msg)
+	if tc.filterMessage(msg) {
+		err := tc.writeNext(msg)
 
-	if err != nil {
-		return err
-	}
+		if err != nil {
+			return err
+		}
 
-	if msg.ContainerID != "" {
-		tc.taggerState[msg.Pid] = msg.ContainerID
+		if msg.ContainerID != "" {
+			tc.taggerState[msg.Pid] = msg.ContainerID
+		}
 	}
 
 	if tc.sharedPacketPoolManager != nil {
@@ -103,6 +108,25 @@ func (tc *TrafficCaptureWriter) processMessage(msg *replay.CaptureBuffer) error
 	return nil
 }
 
+// filterMessage applies the filter of the capture to a capture buffer, and
+// returns false if none of its messages should be written.
+func (tc *TrafficCaptureWriter) filterMessage(msg *replay.CaptureBuffer) bool {
+	if isEmptyFilter(tc.filter) {
+		return true
+	}
+	if !matchOrigin(tc.filter, msg.Pid, msg.ContainerID) {
+		return false
+	}
+
+	payload := filterPayload(tc.filter, msg.Pb.Payload[:msg.Pb.PayloadSize])
+	if len(payload) == 0 {
+		return false
+	}
+	msg.Pb.Payload = payload
+	msg.Pb.PayloadSize = int32(len(payload))
+	return true
+}
+
 // validateLocation validates the location passed as an argument is writable.
 // The location and/or and error if any are returned.
 func validateLocation(fs afero.Fs, location string, defaultLocation string) (string, error) {
@@ -153,12 +177,14 @@ func OpenFile(fs afero.Fs, l string, defaultLocation string) (afero.File, string
 	return f, p, err
 }
 
-// Capture start the traffic capture and writes the packets to file at the
-// specified location and for the specified duration.
-func (tc *TrafficCaptureWriter) Capture(target io.WriteCloser, d time.Duration, compressed bool) {
+// Capture start the traffic capture and writes the packets selected by the
+// filter to file at the specified location and for the specified duration.
+func (tc *TrafficCaptureWriter) Capture(target io.WriteCloser, d time.Duration, compressed bool, filter replay.CaptureFilter) {
 	defer target.Close()
 	log.Debug("Starting capture...")
 
+	tc.filter = filter
+
 	if compressed {
 		tc.zWriter = zstd.NewWriter(target)
 		tc.writer = bufio.NewWriter(tc.zWriter)
@@ -104,7 +104,7 @@ func writerTest(t *testing.T, z bool) {
 		defer wg.Done()
 
 		close(start)
-		writer.Capture(file, testDuration, z)
+		writer.Capture(file, testDuration, z, replay.CaptureFilter{})
 	}(&wg)
 
 	wgc := make(chan struct{})
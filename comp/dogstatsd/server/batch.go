@@ -15,6 +15,7 @@ import (
 	"github.com/DataDog/datadog-agent/comp/core/telemetry"
 	"github.com/DataDog/datadog-agent/pkg/aggregator"
 	"github.com/DataDog/datadog-agent/pkg/aggregator/ckey"
+	pkgconfigsetup "github.com/DataDog/datadog-agent/pkg/config/setup"
 	"github.com/DataDog/datadog-agent/pkg/metrics"
 	"github.com/DataDog/datadog-agent/pkg/metrics/event"
 	"github.com/DataDog/datadog-agent/pkg/metrics/servicecheck"
@@ -76,6 +77,8 @@ type batcher struct {
 type shardKeyGenerator struct {
 	keyGenerator *ckey.KeyGenerator
 	tagsBuffer   *tagset.HashingTagsAccumulator
+	// rollup removes the tags removed by the rollup rules, nil if there are no rules.
+	rollup *aggregator.RollupTagsFilter
 }
 
 func (s *shardKeyGenerator) Generate(sample metrics.MetricSample, shards int) uint32 {
@@ -83,6 +86,10 @@ func (s *shardKeyGenerator) Generate(sample metrics.MetricSample, shards int) ui
 	// it in the sample?) would reduce CPU usage, avoiding to recompute
 	// the tags hashes while generating the context key.
 	s.tagsBuffer.Append(sample.Tags...)
+	if s.rollup != nil {
+		// shard on the tags of the rolled up context
+		s.rollup.Filter(sample.Name, sample.Mtype, s.tagsBuffer)
+	}
 	h := s.keyGenerator.Generate(sample.Name, sample.Host, s.tagsBuffer)
 	s.tagsBuffer.Reset()
 	return fastrange(h, shards)
@@ -146,6 +153,7 @@ func newShardGenerator() shardKeyGenerator {
 	return shardKeyGenerator{
 		keyGenerator: ckey.NewKeyGenerator(),
 		tagsBuffer:   tagset.NewHashingTagsAccumulator(),
+		rollup:       aggregator.NewRollupTagsFilter(pkgconfigsetup.Datadog()),
 	}
 }
 
@@ -0,0 +1,48 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build test
+
+package server
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	configmock "github.com/DataDog/datadog-agent/pkg/config/mock"
+	"github.com/DataDog/datadog-agent/pkg/metrics"
+)
+
+func TestShardKeyGeneratorRollup(t *testing.T) {
+	cfg := configmock.New(t)
+	cfg.SetWithoutSource("dogstatsd_rollup_rules", []map[string]interface{}{
+		{"metric": "http.*", "drop_tags": []string{"pod_name"}},
+	})
+	generator := newShardGenerator()
+	shards := 1 << 16
+	sample := func(name string, mtype metrics.MetricType, tags ...string) metrics.MetricSample {
+		return metrics.MetricSample{Name: name, Mtype: mtype, Tags: tags, SampleRate: 1}
+	}
+
+	// the contexts rolled up together are sent to the same pipeline
+	assert.Equal(t,
+		generator.Generate(sample("http.requests", metrics.CounterType, "env:prod", "pod_name:web-1"), shards),
+		generator.Generate(sample("http.requests", metrics.CounterType, "pod_name:web-2", "env:prod"), shards))
+	assert.Equal(t,
+		generator.Generate(sample("http.requests", metrics.CounterType, "env:prod", "pod_name:web-1"), shards),
+		generator.Generate(sample("http.requests", metrics.CounterType, "env:prod"), shards))
+	assert.Equal(t,
+		generator.Generate(sample("http.inflight", metrics.GaugeType, "env:prod", "pod_name:web-1"), shards),
+		generator.Generate(sample("http.inflight", metrics.GaugeType, "env:prod", "pod_name:web-2"), shards))
+
+	// the other contexts keep being sharded on all their tags
+	assert.NotEqual(t,
+		generator.Generate(sample("http.bytes", metrics.RateType, "env:prod", "pod_name:web-1"), shards),
+		generator.Generate(sample("http.bytes", metrics.RateType, "env:prod", "pod_name:web-2"), shards))
+	assert.NotEqual(t,
+		generator.Generate(sample("queue.size", metrics.CounterType, "env:prod", "pod_name:web-1"), shards),
+		generator.Generate(sample("queue.size", metrics.CounterType, "env:prod", "pod_name:web-2"), shards))
+}
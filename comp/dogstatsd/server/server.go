@@ -53,6 +53,7 @@ var (
 	dogstatsdMetricPackets            = expvar.Int{}
 	dogstatsdPacketsLastSec           = expvar.Int{}
 	dogstatsdUnterminatedMetricErrors = expvar.Int{}
+	dogstatsdMetricMapperDrops        = expvar.Int{}
 
 	// while we try to add the origin tag in the tlmProcessed metric, we want to
 	// avoid having it growing indefinitely, hence this safeguard to limit the
@@ -179,6 +180,7 @@ func initTelemetry() {
 	dogstatsdExpvars.Set("MetricParseErrors", &dogstatsdMetricParseErrors)
 	dogstatsdExpvars.Set("MetricPackets", &dogstatsdMetricPackets)
 	dogstatsdExpvars.Set("UnterminatedMetricErrors", &dogstatsdUnterminatedMetricErrors)
+	dogstatsdExpvars.Set("MetricMapperDrops", &dogstatsdMetricMapperDrops)
 }
 
 // TODO: (components) - merge with newServerCompat once NewServerlessServer is removed
@@ -397,6 +399,15 @@ func (s *server) start(context.Context) error {
 		}
 	}
 
+	if s.config.GetInt("dogstatsd_remote_write_port") > 0 {
+		remoteWriteListener, err := listeners.NewRemoteWriteListener(packetsChannel, sharedPacketPoolManager, s.config, s.listernersTelemetry, s.packetsTelemetry)
+		if err != nil {
+			s.log.Errorf("Can't init Prometheus remote-write listener: %s", err.Error())
+		} else {
+			tmpListeners = append(tmpListeners, remoteWriteListener)
+		}
+	}
+
 	pipeName := s.config.GetString("dogstatsd_pipe_name")
 	if len(pipeName) > 0 {
 		namedPipeListener, err := listeners.NewNamedPipeListener(pipeName, packetsChannel, sharedPacketPoolManager, s.config, s.tCapture, s.listernersTelemetry, s.packetsTelemetry, s.telemetry)
@@ -753,9 +764,18 @@ func (s *server) parseMetricMessage(metricSamples []metrics.MetricSample, parser
 	if s.mapper != nil {
 		mapResult := s.mapper.Map(sample.name)
 		if mapResult != nil {
-			s.log.Tracef("Dogstatsd mapper: metric mapped from %q to %q with tags %v", sample.name, mapResult.Name, mapResult.Tags)
+			tags, keep := mapResult.ApplyActions(append(sample.tags, mapResult.Tags...))
+			if !keep {
+				s.log.Tracef("Dogstatsd mapper: metric %q dropped", sample.name)
+				dogstatsdMetricMapperDrops.Add(1)
+				if len(sample.values) > 0 {
+					s.sharedFloat64List.put(sample.values)
+				}
+				return metricSamples, nil
+			}
+			s.log.Tracef("Dogstatsd mapper: metric mapped from %q to %q with tags %v", sample.name, mapResult.Name, tags)
 			sample.name = mapResult.Name
-			sample.tags = append(sample.tags, mapResult.Tags...)
+			sample.tags = tags
 		}
 	}
 
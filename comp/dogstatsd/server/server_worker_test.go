@@ -332,6 +332,43 @@ dogstatsd_mapper_profiles:
 			},
 			expectedCacheSize: 1000,
 		},
+		{
+			name: "Actions",
+			config: `
+dogstatsd_port: __random__
+dogstatsd_mapper_profiles:
+  - name: test
+    prefix: 'test.'
+    mappings:
+      - match: "test.http.*.latency"
+        name: "test.http.latency"
+        tags:
+          endpoint: "$1"
+        actions:
+          - action: drop
+            if_tags:
+              env: "staging|dev"
+          - action: drop_tags
+            tag_keys: [user_id]
+          - action: rename_tag
+            source_tag: host_name
+            target_tag: origin_host
+          - action: replace_tag
+            source_tag: path
+            regex: '/users/\d+'
+            replacement: '/users/:id'
+`,
+			packets: [][]byte{
+				[]byte("test.http.users.latency:666|g|#user_id:42,env:prod,host_name:web1,path:/users/42"),
+				[]byte("test.http.debug.latency:666|g|#env:staging"),
+				[]byte("test.http.users.latency:666|g"),
+			},
+			expectedSamples: []*tMetricSample{
+				defaultMetric().withName("test.http.latency").withTags([]string{"env:prod", "origin_host:web1", "path:/users/:id", "endpoint:users"}),
+				defaultMetric().withName("test.http.latency").withTags([]string{"endpoint:users"}),
+			},
+			expectedCacheSize: 1000,
+		},
 		{
 			name: "Cache size",
 			config: `
@@ -367,6 +404,7 @@ dogstatsd_mapper_profiles:
 			var b batcherMock
 			s.parsePackets(&b, parser, genTestPackets(scenario.packets...), metrics.MetricSampleBatch{})
 
+			require.Len(t, b.samples, len(scenario.expectedSamples))
 			for idx, sample := range b.samples {
 				scenario.expectedSamples[idx].testMetric(t, sample)
 			}
@@ -467,6 +467,7 @@ func newHTTPPassthroughPipeline(
 			make(chan struct{}),
 			serverlessMeta,
 			sender.ArraySerializer,
+			nil, // formats
 			endpoints.BatchWait,
 			endpoints.BatchMaxSize,
 			endpoints.BatchMaxContentSize,
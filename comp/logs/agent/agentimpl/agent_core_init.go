@@ -26,8 +26,10 @@ import (
 	"github.com/DataDog/datadog-agent/pkg/logs/launchers/journald"
 	"github.com/DataDog/datadog-agent/pkg/logs/launchers/listener"
 	"github.com/DataDog/datadog-agent/pkg/logs/launchers/windowsevent"
+	"github.com/DataDog/datadog-agent/pkg/logs/patterns"
 	"github.com/DataDog/datadog-agent/pkg/logs/pipeline"
 	"github.com/DataDog/datadog-agent/pkg/logs/schedulers"
+	pkgtelemetry "github.com/DataDog/datadog-agent/pkg/telemetry"
 	"github.com/DataDog/datadog-agent/pkg/util/option"
 )
 
@@ -48,6 +50,9 @@ func (a *logAgent) SetupPipeline(processingRules []*config.ProcessingRule, wmeta
 		a.hostname,
 		a.config,
 		a.compression,
+		// the agent registers its default sender as the stats telemetry sender on startup
+		pkgtelemetry.GetStatsTelemetryProvider(),
+		patterns.MaskDigits,
 		a.config.GetBool("logs_config.disable_distributed_senders"), // legacy
 		false, // serverless
 	)
@@ -59,12 +64,14 @@ func (a *logAgent) SetupPipeline(processingRules []*config.ProcessingRule, wmeta
 	fileValidatePodContainer := a.config.GetBool("logs_config.validate_pod_container_id")
 	fileScanPeriod := time.Duration(a.config.GetFloat64("logs_config.file_scan_period") * float64(time.Second))
 	fileWildcardSelectionMode := a.config.GetString("logs_config.file_wildcard_selection_mode")
+	fileTailCompressed := a.config.GetBool("logs_config.tail_compressed_files")
 	lnchrs.AddLauncher(filelauncher.NewLauncher(
 		fileLimits,
 		filelauncher.DefaultSleepDuration,
 		fileValidatePodContainer,
 		fileScanPeriod,
 		fileWildcardSelectionMode,
+		fileTailCompressed,
 		a.flarecontroller,
 		a.tagger))
 	lnchrs.AddLauncher(listener.NewLauncher(a.config.GetInt("logs_config.frame_size")))
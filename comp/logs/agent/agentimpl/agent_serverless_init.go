@@ -52,6 +52,8 @@ func (a *logAgent) SetupPipeline(
 		a.hostname,
 		a.config,
 		a.compression,
+		nil,  // metricSender
+		nil,  // digitMasker
 		true, // disable distributed sending for serverless
 		true, // serverless
 	)
@@ -63,12 +65,14 @@ func (a *logAgent) SetupPipeline(
 	fileValidatePodContainer := a.config.GetBool("logs_config.validate_pod_container_id")
 	fileScanPeriod := time.Duration(a.config.GetFloat64("logs_config.file_scan_period") * float64(time.Second))
 	fileWildcardSelectionMode := a.config.GetString("logs_config.file_wildcard_selection_mode")
+	fileTailCompressed := a.config.GetBool("logs_config.tail_compressed_files")
 	lnchrs.AddLauncher(filelauncher.NewLauncher(
 		fileLimits,
 		filelauncher.DefaultSleepDuration,
 		fileValidatePodContainer,
 		fileScanPeriod,
 		fileWildcardSelectionMode,
+		fileTailCompressed,
 		a.flarecontroller,
 		a.tagger))
 	a.schedulers = schedulers.NewSchedulers(a.sources, a.services)
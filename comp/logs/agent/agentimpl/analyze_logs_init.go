@@ -19,20 +19,23 @@ import (
 	"github.com/DataDog/datadog-agent/pkg/logs/launchers"
 	filelauncher "github.com/DataDog/datadog-agent/pkg/logs/launchers/file"
 	"github.com/DataDog/datadog-agent/pkg/logs/message"
+	"github.com/DataDog/datadog-agent/pkg/logs/patterns"
 	"github.com/DataDog/datadog-agent/pkg/logs/pipeline"
+	"github.com/DataDog/datadog-agent/pkg/logs/processor"
 	"github.com/DataDog/datadog-agent/pkg/logs/sources"
 	"github.com/DataDog/datadog-agent/pkg/logs/tailers"
 )
 
-// SetUpLaunchers intializes the launcher. The launchers schedule the tailers to read the log files provided by the analyze-logs command
-func SetUpLaunchers(conf configComponent.Component, sourceProvider *sources.ConfigSources) (chan *message.Message, *launchers.Launchers, pipeline.Provider, error) {
+// SetUpLaunchers intializes the launcher. The launchers schedule the tailers to read the log files provided by the analyze-logs command.
+// The tracer, if not nil, is notified of the decisions taken by the processor on each log.
+func SetUpLaunchers(conf configComponent.Component, sourceProvider *sources.ConfigSources, tracer processor.Tracer) (chan *message.Message, *launchers.Launchers, pipeline.Provider, error) {
 	processingRules, err := config.GlobalProcessingRules(conf)
 	if err != nil {
 		return nil, nil, nil, err
 	}
 
 	diagnosticMessageReceiver := diagnostic.NewBufferedMessageReceiver(nil, nil)
-	pipelineProvider := pipeline.NewProcessorOnlyProvider(diagnosticMessageReceiver, processingRules, conf, nil)
+	pipelineProvider := pipeline.NewProcessorOnlyProvider(diagnosticMessageReceiver, processingRules, conf, nil, patterns.MaskDigits, tracer)
 
 	// setup the launchers
 	lnchrs := launchers.NewLaunchers(nil, pipelineProvider, nil, nil)
@@ -40,12 +43,14 @@ func SetUpLaunchers(conf configComponent.Component, sourceProvider *sources.Conf
 	fileValidatePodContainer := pkgconfigsetup.Datadog().GetBool("logs_config.validate_pod_container_id")
 	fileScanPeriod := time.Duration(pkgconfigsetup.Datadog().GetFloat64("logs_config.file_scan_period") * float64(time.Second))
 	fileWildcardSelectionMode := pkgconfigsetup.Datadog().GetString("logs_config.file_wildcard_selection_mode")
+	fileTailCompressed := pkgconfigsetup.Datadog().GetBool("logs_config.tail_compressed_files")
 	fileLauncher := filelauncher.NewLauncher(
 		fileLimits,
 		filelauncher.DefaultSleepDuration,
 		fileValidatePodContainer,
 		fileScanPeriod,
 		fileWildcardSelectionMode,
+		fileTailCompressed,
 		flare.NewFlareController(),
 		nil)
 	tracker := tailers.NewTailerTracker()
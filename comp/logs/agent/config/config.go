@@ -48,6 +48,15 @@ const DDOTIntakeOrigin IntakeOrigin = "ddot"
 // OTelCollectorIntakeOrigin is the OSS OTel Collector origin
 const OTelCollectorIntakeOrigin IntakeOrigin = "otel-collector"
 
+// DefaultIntakeFormat indicates that the payloads are sent in the format of the Datadog intake.
+const DefaultIntakeFormat IntakeFormat = ""
+
+// OTLPHTTPIntakeFormat sends the payloads as JSON encoded OTLP/HTTP logs requests.
+const OTLPHTTPIntakeFormat IntakeFormat = "otlp_http"
+
+// NDJSONIntakeFormat sends the payloads as newline-delimited JSON logs.
+const NDJSONIntakeFormat IntakeFormat = "ndjson"
+
 // logs-intake endpoints depending on the site and environment.
 var logsEndpoints = map[string]int{
 	"agent-intake.logs.datadoghq.com": 10516,
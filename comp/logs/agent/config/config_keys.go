@@ -29,6 +29,7 @@ const (
 	GzipCompressionLevel = 6
 	ZstdCompressionKind  = "zstd"
 	ZstdCompressionLevel = 1
+	NoneCompressionKind  = "none"
 )
 
 // defaultLogsConfigKeys defines the default YAML keys used to retrieve logs configuration
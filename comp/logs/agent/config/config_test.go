@@ -382,6 +382,34 @@ func (suite *ConfigTestSuite) TestMultipleHttpEndpointsInConfig() {
 	suite.compareEndpoints(expectedEndpoints, endpoints)
 }
 
+func (suite *ConfigTestSuite) TestGenericHTTPAdditionalEndpoints() {
+	suite.config.SetWithoutSource("api_key", "123")
+	suite.config.SetWithoutSource("logs_config.logs_dd_url", "agent-http-intake.logs.datadoghq.com:443")
+	suite.config.SetWithoutSource("logs_config.additional_endpoints", `[
+	{"format": "otlp_http", "url": "https://collector.internal:4318/v1/logs", "headers": {"X-Team": "logs"}, "is_reliable": false},
+	{"format": "ndjson", "host": "no.url.endpoint"},
+	{"format": "unknown", "url": "https://collector.internal/logs"},
+	{"format": "ndjson", "url": "http://collector.internal/logs"}]`)
+
+	endpoints, err := BuildHTTPEndpoints(suite.config, "test-track", "test-proto", "test-source")
+	suite.Nil(err)
+	suite.Len(endpoints.Endpoints, 3)
+
+	otlp := endpoints.Endpoints[1]
+	suite.Equal(OTLPHTTPIntakeFormat, otlp.Format)
+	suite.Equal("https://collector.internal:4318/v1/logs", otlp.URL)
+	suite.Equal(map[string]string{"X-Team": "logs"}, otlp.Headers)
+	suite.False(otlp.IsReliable())
+	suite.Equal("Sending logs as otlp_http to https://collector.internal:4318/v1/logs", otlp.GetStatus("", true))
+
+	ndjson := endpoints.Endpoints[2]
+	suite.Equal(NDJSONIntakeFormat, ndjson.Format)
+	suite.Equal("http://collector.internal/logs", ndjson.URL)
+	suite.Equal(3, ndjson.additionalEndpointsIdx)
+
+	suite.Equal(DefaultIntakeFormat, endpoints.Main.Format)
+}
+
 func (suite *ConfigTestSuite) TestMultipleHttpEndpointsInConfig2() {
 	suite.config.SetWithoutSource("api_key", "123")
 	suite.config.SetWithoutSource("logs_config.batch_wait", 1)
@@ -7,6 +7,8 @@ package config
 
 import (
 	"fmt"
+	"slices"
+	"strings"
 	"time"
 
 	"go.uber.org/atomic"
@@ -29,6 +31,9 @@ type IntakeProtocol string
 // IntakeOrigin indicates the log source to use for an endpoint intake.
 type IntakeOrigin string
 
+// IntakeFormat indicates the format of the payloads sent to an endpoint intake.
+type IntakeFormat string
+
 const (
 	_ EPIntakeVersion = iota
 	// EPIntakeVersion1 is version 1 of the envets platform intake API
@@ -72,6 +77,21 @@ type Endpoint struct {
 	TrackType IntakeTrackType
 	Protocol  IntakeProtocol
 	Origin    IntakeOrigin
+
+	// Format, URL and Headers are used to send the logs to an arbitrary HTTP endpoint
+	// instead of a Datadog intake.
+	Format  IntakeFormat      `mapstructure:"format" json:"format"`
+	URL     string            `mapstructure:"url" json:"url"`
+	Headers map[string]string `mapstructure:"headers" json:"headers"`
+
+	// Sources and Services restrict the logs sent to an additional endpoint to the
+	// logs of these sources or services, all the logs are sent when both are empty.
+	Sources  []string `mapstructure:"sources" json:"sources"`
+	Services []string `mapstructure:"services" json:"services"`
+
+	// ownCompression is true when an additional endpoint doesn't use the
+	// compression of the main endpoint.
+	ownCompression bool
 }
 
 // unmarshalEndpoint is used to load additional endpoints from the configuration which stored as JSON/mapstructure.
@@ -187,6 +207,15 @@ func loadHTTPAdditionalEndpoints(main Endpoint, l *LogsConfigKeys, intakeTrackTy
 
 	newEndpoints := make([]Endpoint, 0, len(additionals))
 	for idx, e := range additionals {
+		if !e.Format.isValid() {
+			log.Warnf("Ignoring logs additional endpoint %d: unknown format '%s'", idx, e.Format)
+			continue
+		}
+		if e.Format != DefaultIntakeFormat && e.URL == "" {
+			log.Warnf("Ignoring logs additional endpoint %d: a url is required for the '%s' format", idx, e.Format)
+			continue
+		}
+
 		newE := NewEndpoint(e.APIKey, configKeyUsed, e.Host, e.Port, false)
 
 		newE.isAdditionalEndpoint = true
@@ -194,6 +223,9 @@ func loadHTTPAdditionalEndpoints(main Endpoint, l *LogsConfigKeys, intakeTrackTy
 		newE.UseCompression = main.UseCompression
 		newE.CompressionKind = main.CompressionKind
 		newE.CompressionLevel = main.CompressionLevel
+		if e.CompressionKind != "" {
+			newE.setOwnCompression(main, e.CompressionKind, e.CompressionLevel)
+		}
 		newE.ProxyAddress = e.ProxyAddress
 		newE.isReliable = e.IsReliable == nil || *e.IsReliable
 		newE.ConnectionResetInterval = e.ConnectionResetInterval
@@ -206,6 +238,11 @@ func loadHTTPAdditionalEndpoints(main Endpoint, l *LogsConfigKeys, intakeTrackTy
 		newE.TrackType = e.TrackType
 		newE.Protocol = e.Protocol
 		newE.Origin = e.Origin
+		newE.Format = e.Format
+		newE.URL = e.URL
+		newE.Headers = e.Headers
+		newE.Sources = e.Sources
+		newE.Services = e.Services
 
 		if e.UseSSL != nil {
 			newE.useSSL = *e.UseSSL
@@ -238,8 +275,69 @@ func (e *Endpoint) UseSSL() bool {
 	return e.useSSL
 }
 
+// isValid returns true if the format is supported by the HTTP destinations.
+func (f IntakeFormat) isValid() bool {
+	switch f {
+	case DefaultIntakeFormat, OTLPHTTPIntakeFormat, NDJSONIntakeFormat:
+		return true
+	}
+	return false
+}
+
+// setOwnCompression sets the compression configured on an additional endpoint,
+// the compression of the main endpoint is kept if the kind is invalid.
+func (e *Endpoint) setOwnCompression(main Endpoint, kind string, level int) {
+	switch kind {
+	case NoneCompressionKind:
+		e.UseCompression = false
+	case GzipCompressionKind, ZstdCompressionKind:
+		if level == 0 {
+			level = GzipCompressionLevel
+			if kind == ZstdCompressionKind {
+				level = ZstdCompressionLevel
+			}
+		}
+		e.UseCompression = true
+		e.CompressionKind = kind
+		e.CompressionLevel = level
+	default:
+		log.Warnf("Invalid compression kind '%s' for the additional endpoint %s, using the compression of the main endpoint", kind, e.Host)
+		return
+	}
+	e.ownCompression = e.UseCompression != main.UseCompression ||
+		(e.UseCompression && (e.CompressionKind != main.CompressionKind || e.CompressionLevel != main.CompressionLevel))
+}
+
+// EncodingKey returns the key identifying how the payloads sent to the endpoint
+// are encoded, empty when they are encoded as the payloads of the main endpoint.
+// The endpoints using another format, their own compression, or receiving a
+// subset of the logs share the payloads encoded with the same key.
+func (e *Endpoint) EncodingKey() string {
+	if e.Format == DefaultIntakeFormat && !e.ownCompression && len(e.Sources) == 0 && len(e.Services) == 0 {
+		return ""
+	}
+	compression := "none"
+	if e.UseCompression {
+		compression = fmt.Sprintf("%s:%d", e.CompressionKind, e.CompressionLevel)
+	}
+	return fmt.Sprintf("%s|%s|%s|%s", e.Format, compression, strings.Join(e.Sources, ","), strings.Join(e.Services, ","))
+}
+
+// IncludesLog returns true if the logs of the given source and service are
+// sent to the endpoint.
+func (e *Endpoint) IncludesLog(source, service string) bool {
+	if len(e.Sources) == 0 && len(e.Services) == 0 {
+		return true
+	}
+	return slices.Contains(e.Sources, source) || slices.Contains(e.Services, service)
+}
+
 // GetStatus returns the endpoint status
 func (e *Endpoint) GetStatus(prefix string, useHTTP bool) string {
+	if useHTTP && e.Format != DefaultIntakeFormat {
+		return fmt.Sprintf("%sSending logs as %s to %s", prefix, e.Format, e.URL)
+	}
+
 	compression := "uncompressed"
 	if e.UseCompression {
 		compression = "compressed"
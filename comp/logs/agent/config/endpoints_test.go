@@ -464,6 +464,54 @@ func (suite *EndpointsTestSuite) TestAdditionalEndpointsMappedCorrectly() {
 	suite.Equal("2", endpoint.GetAPIKey())
 }
 
+func (suite *EndpointsTestSuite) TestAdditionalEndpointsCompressionAndFilters() {
+	suite.config.SetWithoutSource("logs_config.compression_kind", "gzip")
+	suite.config.SetWithoutSource("logs_config.additional_endpoints", `[
+		{"api_key": "1", "host": "a"},
+		{"api_key": "2", "host": "b", "compression_kind": "zstd"},
+		{"api_key": "3", "host": "c", "compression_kind": "none"},
+		{"api_key": "4", "host": "d", "compression_kind": "gzip"},
+		{"api_key": "5", "host": "e", "compression_kind": "notgzip"},
+		{"api_key": "6", "host": "f", "sources": ["nginx"], "services": ["web"]}
+	]`)
+
+	endpoints, err := BuildHTTPEndpointsWithConfig(suite.config, defaultLogsConfigKeys(suite.config), "", "", "", "")
+	suite.Require().NoError(err)
+	suite.Require().Len(endpoints.Endpoints, 7)
+	main := endpoints.Main
+	suite.Equal(GzipCompressionKind, main.CompressionKind)
+	suite.Empty(main.EncodingKey())
+
+	// the endpoints without their own compression nor filters share the payloads of the main endpoint
+	for _, idx := range []int{1, 4, 5} {
+		endpoint := endpoints.Endpoints[idx]
+		suite.Equal(main.UseCompression, endpoint.UseCompression, endpoint.Host)
+		suite.Equal(main.CompressionKind, endpoint.CompressionKind, endpoint.Host)
+		suite.Equal(main.CompressionLevel, endpoint.CompressionLevel, endpoint.Host)
+		suite.Empty(endpoint.EncodingKey(), endpoint.Host)
+	}
+
+	zstd := endpoints.Endpoints[2]
+	suite.True(zstd.UseCompression)
+	suite.Equal(ZstdCompressionKind, zstd.CompressionKind)
+	suite.Equal(ZstdCompressionLevel, zstd.CompressionLevel)
+	suite.NotEmpty(zstd.EncodingKey())
+
+	none := endpoints.Endpoints[3]
+	suite.False(none.UseCompression)
+	suite.NotEmpty(none.EncodingKey())
+	suite.NotEqual(zstd.EncodingKey(), none.EncodingKey())
+
+	filtered := endpoints.Endpoints[6]
+	suite.Equal([]string{"nginx"}, filtered.Sources)
+	suite.Equal([]string{"web"}, filtered.Services)
+	suite.NotEmpty(filtered.EncodingKey())
+	suite.True(filtered.IncludesLog("nginx", "api"))
+	suite.True(filtered.IncludesLog("redis", "web"))
+	suite.False(filtered.IncludesLog("redis", "api"))
+	suite.True(main.IncludesLog("redis", "api"))
+}
+
 func (suite *EndpointsTestSuite) TestIsReliableDefaultTrue() {
 	var (
 		endpoints *Endpoints
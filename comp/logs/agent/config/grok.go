@@ -0,0 +1,103 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// grokReference matches a grok pattern reference: %{NAME} or %{NAME:field}
+var grokReference = regexp.MustCompile(`%\{(\w+)(?::([\w.]+))?\}`)
+
+// maxGrokExpansionDepth limits the nesting of grok patterns referencing other patterns.
+const maxGrokExpansionDepth = 8
+
+// grokPatterns are the built-in patterns which can be referenced in the
+// pattern of an extract_fields processing rule.
+var grokPatterns = map[string]string{
+	"WORD":              `\b\w+\b`,
+	"NOTSPACE":          `\S+`,
+	"SPACE":             `\s*`,
+	"DATA":              `.*?`,
+	"GREEDYDATA":        `.*`,
+	"INT":               `[+-]?\d+`,
+	"POSINT":            `\b[1-9]\d*\b`,
+	"NUMBER":            `[+-]?(?:\d+(?:\.\d*)?|\.\d+)`,
+	"BASE16NUM":         `(?:0[xX])?[0-9A-Fa-f]+`,
+	"QUOTEDSTRING":      `"(?:[^"\\]|\\.)*"|'(?:[^'\\]|\\.)*'`,
+	"UUID":              `[A-Fa-f0-9]{8}-(?:[A-Fa-f0-9]{4}-){3}[A-Fa-f0-9]{12}`,
+	"IPV4":              `(?:(?:25[0-5]|2[0-4]\d|1?\d?\d)\.){3}(?:25[0-5]|2[0-4]\d|1?\d?\d)`,
+	"IPV6":              `(?:[0-9A-Fa-f]{0,4}:){2,7}[0-9A-Fa-f]{0,4}`,
+	"IP":                `%{IPV6}|%{IPV4}`,
+	"HOSTNAME":          `\b[0-9A-Za-z][0-9A-Za-z-]{0,62}(?:\.[0-9A-Za-z][0-9A-Za-z-]{0,62})*\.?\b`,
+	"IPORHOST":          `%{IP}|%{HOSTNAME}`,
+	"USER":              `[a-zA-Z0-9._-]+`,
+	"URIPATH":           `(?:/[A-Za-z0-9$.+!*'(){},~:;=@#%&_\-]*)+`,
+	"URIPARAM":          `\?[A-Za-z0-9$.+!*'|(){},~@#%&/=:;_?\-\[\]<>]*`,
+	"URIPATHPARAM":      `%{URIPATH}(?:%{URIPARAM})?`,
+	"LOGLEVEL":          `(?i:trace|debug|info|notice|warn(?:ing)?|err(?:or)?|crit(?:ical)?|fatal|severe|emerg(?:ency)?|alert)`,
+	"MONTH":             `\b(?:Jan|Feb|Mar|Apr|May|Jun|Jul|Aug|Sep|Oct|Nov|Dec)[a-z]*\b`,
+	"MONTHDAY":          `(?:0[1-9]|[12]\d|3[01]|[1-9])`,
+	"YEAR":              `\d{4}`,
+	"TIME":              `(?:[01]?\d|2[0-3]):[0-5]\d(?::[0-5]\d(?:[.,]\d+)?)?`,
+	"ISO8601_TIMEZONE":  `Z|[+-](?:[01]?\d|2[0-3]):?[0-5]\d`,
+	"TIMESTAMP_ISO8601": `%{YEAR}-\d{2}-\d{2}[T ]%{TIME}(?:%{ISO8601_TIMEZONE})?`,
+	"HTTPDATE":          `%{MONTHDAY}/%{MONTH}/%{YEAR}:%{TIME} [+-]\d{4}`,
+	"SYSLOGTIMESTAMP":   `%{MONTH} +%{MONTHDAY} %{TIME}`,
+}
+
+// expandGrokPattern replaces the grok pattern references contained in the given
+// pattern by their regular expression. A reference with a field name, e.g.
+// %{IP:client}, becomes a named capture group.
+// Patterns without any reference are returned unchanged.
+func expandGrokPattern(pattern string) (string, error) {
+	return expandGrokPatternWithDepth(pattern, 0)
+}
+
+func expandGrokPatternWithDepth(pattern string, depth int) (string, error) {
+	if !strings.Contains(pattern, "%{") {
+		return pattern, nil
+	}
+	if depth > maxGrokExpansionDepth {
+		return "", fmt.Errorf("grok patterns are nested too deeply in %s", pattern)
+	}
+
+	var err error
+	expanded := grokReference.ReplaceAllStringFunc(pattern, func(reference string) string {
+		parts := grokReference.FindStringSubmatch(reference)
+		definition, found := grokPatterns[parts[1]]
+		if !found {
+			err = fmt.Errorf("unknown grok pattern %s", parts[1])
+			return reference
+		}
+		definition, expandErr := expandGrokPatternWithDepth(definition, depth+1)
+		if expandErr != nil {
+			err = expandErr
+			return reference
+		}
+		if parts[2] == "" {
+			return "(?:" + definition + ")"
+		}
+		return "(?P<" + grokFieldGroupName(parts[2]) + ">" + definition + ")"
+	})
+	if err != nil {
+		return "", err
+	}
+	return expanded, nil
+}
+
+// grokFieldGroupName returns a valid capture group name for a field name,
+// nested field names (e.g. http.status_code) are supported using `__` as separator.
+func grokFieldGroupName(field string) string {
+	return strings.ReplaceAll(field, ".", "__")
+}
+
+// FieldName returns the attribute name for a capture group of an extract_fields rule.
+func FieldName(groupName string) string {
+	return strings.ReplaceAll(groupName, "__", ".")
+}
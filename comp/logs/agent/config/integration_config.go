@@ -26,6 +26,12 @@ const (
 	IntegrationType   = "integration"
 	WindowsEventType  = "windows_event"
 	StringChannelType = "string_channel"
+	SyslogType        = "syslog"
+
+	// Syslog transport protocols
+	SyslogProtocolTCP  = "tcp"
+	SyslogProtocolUDP  = "udp"
+	SyslogProtocolUnix = "unix"
 
 	// UTF16BE for UTF-16 Big endian encoding
 	UTF16BE string = "utf-16-be"
@@ -33,6 +39,9 @@ const (
 	UTF16LE string = "utf-16-le"
 	// SHIFTJIS for Shift JIS (Japanese) encoding
 	SHIFTJIS string = "shift-jis"
+
+	// JSONParser decodes the logs as JSON objects
+	JSONParser string = "json"
 )
 
 // LogsConfig represents a log source config, which can be for instance
@@ -44,7 +53,8 @@ type LogsConfig struct {
 
 	Port        int    // Network
 	IdleTimeout string `mapstructure:"idle_timeout" json:"idle_timeout" yaml:"idle_timeout"` // Network
-	Path        string // File, Journald
+	Path        string // File, Journald, Syslog (unix socket)
+	Protocol    string // Syslog
 
 	Encoding     string           `mapstructure:"encoding" json:"encoding" yaml:"encoding"`                   // File
 	ExcludePaths StringSliceField `mapstructure:"exclude_paths" json:"exclude_paths" yaml:"exclude_paths"`    // File
@@ -93,6 +103,19 @@ type LogsConfig struct {
 	AutoMultiLine               *bool   `mapstructure:"auto_multi_line_detection" json:"auto_multi_line_detection" yaml:"auto_multi_line_detection"`
 	AutoMultiLineSampleSize     int     `mapstructure:"auto_multi_line_sample_size" json:"auto_multi_line_sample_size" yaml:"auto_multi_line_sample_size"`
 	AutoMultiLineMatchThreshold float64 `mapstructure:"auto_multi_line_match_threshold" json:"auto_multi_line_match_threshold" yaml:"auto_multi_line_match_threshold"`
+
+	Parser     string      `mapstructure:"parser" json:"parser" yaml:"parser"`
+	JSONFields *JSONFields `mapstructure:"json_fields" json:"json_fields" yaml:"json_fields"`
+}
+
+// JSONFields holds the keys of the fields of JSON logs promoted into the
+// message metadata when using the json parser. Empty keys use the defaults.
+type JSONFields struct {
+	Timestamp string `mapstructure:"timestamp" json:"timestamp" yaml:"timestamp"`
+	Level     string `mapstructure:"level" json:"level" yaml:"level"`
+	Message   string `mapstructure:"message" json:"message" yaml:"message"`
+	Service   string `mapstructure:"service" json:"service" yaml:"service"`
+	TraceID   string `mapstructure:"trace_id" json:"trace_id" yaml:"trace_id"`
 }
 
 // StringSliceField is a custom type for unmarshalling comma-separated string values or typical yaml fields into a slice of strings.
@@ -164,6 +187,11 @@ func (c *LogsConfig) Dump(multiline bool) string {
 		fmt.Fprintf(&b, ws("IncludeUserUnits: %#v,"), c.IncludeUserUnits)
 		fmt.Fprintf(&b, ws("ExcludeUserUnits: %#v,"), c.ExcludeUserUnits)
 		fmt.Fprintf(&b, ws("ContainerMode: %t,"), c.ContainerMode)
+	case SyslogType:
+		fmt.Fprintf(&b, ws("Protocol: %#v,"), c.Protocol)
+		fmt.Fprintf(&b, ws("Port: %d,"), c.Port)
+		fmt.Fprintf(&b, ws("Path: %#v,"), c.Path)
+		fmt.Fprintf(&b, ws("IdleTimeout: %#v,"), c.IdleTimeout)
 	case WindowsEventType:
 		fmt.Fprintf(&b, ws("ChannelPath: %#v,"), c.ChannelPath)
 		fmt.Fprintf(&b, ws("Query: %#v,"), c.Query)
@@ -190,7 +218,9 @@ func (c *LogsConfig) Dump(multiline bool) string {
 		fmt.Fprint(&b, ws("AutoMultiLine: nil,"))
 	}
 	fmt.Fprintf(&b, ws("AutoMultiLineSampleSize: %d,"), c.AutoMultiLineSampleSize)
-	fmt.Fprintf(&b, ws("AutoMultiLineMatchThreshold: %f}"), c.AutoMultiLineMatchThreshold)
+	fmt.Fprintf(&b, ws("AutoMultiLineMatchThreshold: %f,"), c.AutoMultiLineMatchThreshold)
+	fmt.Fprintf(&b, ws("Parser: %#v,"), c.Parser)
+	fmt.Fprintf(&b, ws("JSONFields: %#v}"), c.JSONFields)
 	return b.String()
 }
 
@@ -201,7 +231,8 @@ func (c *LogsConfig) PublicJSON() ([]byte, error) {
 	return json.Marshal(&struct {
 		Type            string            `json:"type,omitempty"`
 		Port            int               `json:"port,omitempty"`           // Network
-		Path            string            `json:"path,omitempty"`           // File, Journald
+		Path            string            `json:"path,omitempty"`           // File, Journald, Syslog
+		Protocol        string            `json:"protocol,omitempty"`       // Syslog
 		Encoding        string            `json:"encoding,omitempty"`       // File
 		ExcludePaths    []string          `json:"exclude_paths,omitempty"`  // File
 		TailingMode     string            `json:"start_position,omitempty"` // File
@@ -211,10 +242,12 @@ func (c *LogsConfig) PublicJSON() ([]byte, error) {
 		Tags            []string          `json:"tags,omitempty"`
 		ProcessingRules []*ProcessingRule `json:"log_processing_rules,omitempty"`
 		AutoMultiLine   *bool             `json:"auto_multi_line_detection,omitempty"`
+		Parser          string            `json:"parser,omitempty"`
 	}{
 		Type:            c.Type,
 		Port:            c.Port,
 		Path:            c.Path,
+		Protocol:        c.Protocol,
 		Encoding:        c.Encoding,
 		ExcludePaths:    c.ExcludePaths,
 		TailingMode:     c.TailingMode,
@@ -224,6 +257,7 @@ func (c *LogsConfig) PublicJSON() ([]byte, error) {
 		Tags:            c.Tags,
 		ProcessingRules: c.ProcessingRules,
 		AutoMultiLine:   c.AutoMultiLine,
+		Parser:          c.Parser,
 	})
 }
 
@@ -288,6 +322,14 @@ func (c *LogsConfig) Validate() error {
 		return fmt.Errorf("tcp source must have a port")
 	case c.Type == UDPType && c.Port == 0:
 		return fmt.Errorf("udp source must have a port")
+	case c.Type == SyslogType:
+		err := c.validateSyslog()
+		if err != nil {
+			return err
+		}
+	}
+	if c.Parser != "" && c.Parser != JSONParser {
+		return fmt.Errorf("invalid parser '%v', must be %s", c.Parser, JSONParser)
 	}
 	err := ValidateProcessingRules(c.ProcessingRules)
 	if err != nil {
@@ -307,6 +349,31 @@ func (c *LogsConfig) validateTailingMode() error {
 	return nil
 }
 
+func (c *LogsConfig) validateSyslog() error {
+	switch c.SyslogProtocol() {
+	case SyslogProtocolTCP, SyslogProtocolUDP:
+		if c.Port == 0 {
+			return fmt.Errorf("syslog source over %s must have a port", c.SyslogProtocol())
+		}
+	case SyslogProtocolUnix:
+		if c.Path == "" {
+			return fmt.Errorf("syslog source over unix socket must have a path")
+		}
+	default:
+		return fmt.Errorf("invalid protocol '%v' for syslog source, must be one of %s, %s or %s", c.Protocol, SyslogProtocolTCP, SyslogProtocolUDP, SyslogProtocolUnix)
+	}
+	return nil
+}
+
+// SyslogProtocol returns the transport protocol used by a syslog source,
+// defaults to tcp when nothing's been configured.
+func (c *LogsConfig) SyslogProtocol() string {
+	if c.Protocol == "" {
+		return SyslogProtocolTCP
+	}
+	return strings.ToLower(c.Protocol)
+}
+
 // LegacyAutoMultiLineEnabled determines whether the agent has fallen back to legacy auto multi line detection
 // for compatibility reasons.
 func (c *LogsConfig) LegacyAutoMultiLineEnabled(coreConfig pkgconfigmodel.Reader) bool {
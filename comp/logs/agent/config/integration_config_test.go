@@ -21,7 +21,11 @@ func TestValidateShouldSucceedWithValidConfigs(t *testing.T) {
 		{Type: FileType, Path: "/var/log/foo.log"},
 		{Type: TCPType, Port: 1234},
 		{Type: UDPType, Port: 5678},
+		{Type: SyslogType, Port: 514},
+		{Type: SyslogType, Protocol: "udp", Port: 514},
+		{Type: SyslogType, Protocol: "unix", Path: "/var/run/syslog.sock"},
 		{Type: DockerType},
+		{Type: FileType, Path: "/var/log/foo.log", Parser: JSONParser, JSONFields: &JSONFields{Message: "msg"}},
 		{Type: JournaldType, ProcessingRules: []*ProcessingRule{{Name: "foo", Type: ExcludeAtMatch, Pattern: ".*"}}},
 	}
 
@@ -37,6 +41,10 @@ func TestValidateShouldFailWithInvalidConfigs(t *testing.T) {
 		{Type: FileType},
 		{Type: TCPType},
 		{Type: UDPType},
+		{Type: SyslogType},
+		{Type: SyslogType, Protocol: "unix", Port: 514},
+		{Type: SyslogType, Protocol: "sctp", Port: 514},
+		{Type: FileType, Path: "/var/log/foo.log", Parser: "xml"},
 		{Type: DockerType, ProcessingRules: []*ProcessingRule{{Name: "foo"}}},
 		{Type: DockerType, ProcessingRules: []*ProcessingRule{{Name: "foo", Type: "bar"}}},
 		{Type: DockerType, ProcessingRules: []*ProcessingRule{{Name: "foo", Type: ExcludeAtMatch}}},
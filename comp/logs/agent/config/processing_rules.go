@@ -16,6 +16,15 @@ const (
 	IncludeAtMatch = "include_at_match"
 	MaskSequences  = "mask_sequences"
 	MultiLine      = "multi_line"
+	ExtractFields  = "extract_fields"
+	GenerateMetric = "generate_metric"
+	Sample         = "sample"
+)
+
+// Metric types of the generate_metric processing rules
+const (
+	MetricTypeCount        = "count"
+	MetricTypeDistribution = "distribution"
 )
 
 // ProcessingRule defines an exclusion or a masking rule to
@@ -25,6 +34,26 @@ type ProcessingRule struct {
 	Name               string
 	ReplacePlaceholder string `mapstructure:"replace_placeholder" json:"replace_placeholder" yaml:"replace_placeholder"`
 	Pattern            string
+	// StatusField, ServiceField and TimestampField name the fields captured by an
+	// extract_fields rule overriding the status, service and timestamp of the log.
+	StatusField     string `mapstructure:"status_field" json:"status_field,omitempty" yaml:"status_field"`
+	ServiceField    string `mapstructure:"service_field" json:"service_field,omitempty" yaml:"service_field"`
+	TimestampField  string `mapstructure:"timestamp_field" json:"timestamp_field,omitempty" yaml:"timestamp_field"`
+	TimestampFormat string `mapstructure:"timestamp_format" json:"timestamp_format,omitempty" yaml:"timestamp_format"`
+	// MetricName, MetricType, ValueField and TagFields define the metric generated by
+	// a generate_metric rule for every matching log, DropLog drops the matching logs.
+	MetricName string           `mapstructure:"metric_name" json:"metric_name,omitempty" yaml:"metric_name"`
+	MetricType string           `mapstructure:"metric_type" json:"metric_type,omitempty" yaml:"metric_type"`
+	ValueField string           `mapstructure:"value_field" json:"value_field,omitempty" yaml:"value_field"`
+	TagFields  StringSliceField `mapstructure:"tag_fields" json:"tag_fields,omitempty" yaml:"tag_fields"`
+	DropLog    bool             `mapstructure:"drop_log" json:"drop_log,omitempty" yaml:"drop_log"`
+	// SampleRate is the ratio of logs kept by a sample rule, while MaxPerSecond
+	// limits the number of logs kept every second, for each key when KeyField
+	// (a captured field) or KeyTag (a tag name) is set.
+	SampleRate   float64 `mapstructure:"sample_rate" json:"sample_rate,omitempty" yaml:"sample_rate"`
+	MaxPerSecond float64 `mapstructure:"max_per_second" json:"max_per_second,omitempty" yaml:"max_per_second"`
+	KeyField     string  `mapstructure:"key_field" json:"key_field,omitempty" yaml:"key_field"`
+	KeyTag       string  `mapstructure:"key_tag" json:"key_tag,omitempty" yaml:"key_tag"`
 	// TODO: should be moved out
 	Regex       *regexp.Regexp
 	Placeholder []byte
@@ -42,7 +71,7 @@ func ValidateProcessingRules(rules []*ProcessingRule) error {
 		}
 
 		switch rule.Type {
-		case ExcludeAtMatch, IncludeAtMatch, MaskSequences, MultiLine:
+		case ExcludeAtMatch, IncludeAtMatch, MaskSequences, MultiLine, ExtractFields, GenerateMetric, Sample:
 			break
 		case "":
 			return fmt.Errorf("type must be set for processing rule `%s`", rule.Name)
@@ -53,6 +82,23 @@ func ValidateProcessingRules(rules []*ProcessingRule) error {
 		if rule.Pattern == "" {
 			return fmt.Errorf("no pattern provided for processing rule: %s", rule.Name)
 		}
+		switch rule.Type {
+		case ExtractFields:
+			if err := validateExtractFieldsRule(rule); err != nil {
+				return err
+			}
+			continue
+		case GenerateMetric:
+			if err := validateGenerateMetricRule(rule); err != nil {
+				return err
+			}
+			continue
+		case Sample:
+			if err := validateSampleRule(rule); err != nil {
+				return err
+			}
+			continue
+		}
 		_, err := regexp.Compile(rule.Pattern)
 		if err != nil {
 			return fmt.Errorf("invalid pattern %s for processing rule: %s", rule.Pattern, rule.Name)
@@ -61,6 +107,99 @@ func ValidateProcessingRules(rules []*ProcessingRule) error {
 	return nil
 }
 
+// validateExtractFieldsRule validates the pattern of an extract_fields rule,
+// which can reference grok patterns and must define at least one field.
+func validateExtractFieldsRule(rule *ProcessingRule) error {
+	fields, err := capturedFields(rule)
+	if err != nil {
+		return err
+	}
+	if len(fields) == 0 {
+		return fmt.Errorf("no field captured by the pattern of processing rule: %s", rule.Name)
+	}
+	return checkFieldsAreCaptured(rule, fields, rule.StatusField, rule.ServiceField, rule.TimestampField)
+}
+
+// validateGenerateMetricRule validates the metric definition of a generate_metric
+// rule, whose pattern can reference grok patterns as well.
+func validateGenerateMetricRule(rule *ProcessingRule) error {
+	if rule.MetricName == "" {
+		return fmt.Errorf("no metric name provided for processing rule: %s", rule.Name)
+	}
+	switch rule.MetricType {
+	case "", MetricTypeCount:
+	case MetricTypeDistribution:
+		if rule.ValueField == "" {
+			return fmt.Errorf("a value field must be provided for the distribution of processing rule: %s", rule.Name)
+		}
+	default:
+		return fmt.Errorf("metric type %s is not supported for processing rule: %s", rule.MetricType, rule.Name)
+	}
+	fields, err := capturedFields(rule)
+	if err != nil {
+		return err
+	}
+	return checkFieldsAreCaptured(rule, fields, append([]string{rule.ValueField}, rule.TagFields...)...)
+}
+
+// validateSampleRule validates a sample rule, which either keeps a ratio of the
+// matching logs or limits their rate, optionally for each key.
+func validateSampleRule(rule *ProcessingRule) error {
+	switch {
+	case rule.SampleRate != 0 && rule.MaxPerSecond != 0:
+		return fmt.Errorf("sample_rate and max_per_second can't be both set for processing rule: %s", rule.Name)
+	case rule.SampleRate != 0:
+		if rule.SampleRate < 0 || rule.SampleRate > 1 {
+			return fmt.Errorf("sample_rate must be between 0 and 1 for processing rule: %s", rule.Name)
+		}
+		if rule.KeyField != "" || rule.KeyTag != "" {
+			return fmt.Errorf("a key can only be used with max_per_second for processing rule: %s", rule.Name)
+		}
+	case rule.MaxPerSecond < 0:
+		return fmt.Errorf("max_per_second must be positive for processing rule: %s", rule.Name)
+	case rule.MaxPerSecond == 0:
+		return fmt.Errorf("sample_rate or max_per_second must be set for processing rule: %s", rule.Name)
+	}
+	if rule.KeyField != "" && rule.KeyTag != "" {
+		return fmt.Errorf("key_field and key_tag can't be both set for processing rule: %s", rule.Name)
+	}
+	fields, err := capturedFields(rule)
+	if err != nil {
+		return err
+	}
+	return checkFieldsAreCaptured(rule, fields, rule.KeyField)
+}
+
+// capturedFields returns the fields captured by the pattern of the rule.
+func capturedFields(rule *ProcessingRule) (map[string]bool, error) {
+	pattern, err := expandGrokPattern(rule.Pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern %s for processing rule: %s: %v", rule.Pattern, rule.Name, err)
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern %s for processing rule: %s", rule.Pattern, rule.Name)
+	}
+	fields := make(map[string]bool)
+	for _, name := range re.SubexpNames() {
+		if name != "" {
+			fields[FieldName(name)] = true
+		}
+	}
+	return fields, nil
+}
+
+// checkFieldsAreCaptured returns an error if one of the given fields, when set,
+// is not captured by the pattern of the rule.
+func checkFieldsAreCaptured(rule *ProcessingRule, captured map[string]bool, fields ...string) error {
+	for _, field := range fields {
+		if field != "" && !captured[field] {
+			return fmt.Errorf("field %s is not captured by the pattern of processing rule: %s", field, rule.Name)
+		}
+	}
+	return nil
+}
+
 // CompileProcessingRules compiles all processing rule regular expressions.
 func CompileProcessingRules(rules []*ProcessingRule) error {
 	for _, rule := range rules {
@@ -79,6 +218,15 @@ func CompileProcessingRules(rules []*ProcessingRule) error {
 			if err != nil {
 				return err
 			}
+		case ExtractFields, GenerateMetric, Sample:
+			pattern, err := expandGrokPattern(rule.Pattern)
+			if err != nil {
+				return err
+			}
+			rule.Regex, err = regexp.Compile(pattern)
+			if err != nil {
+				return err
+			}
 		}
 	}
 	return nil
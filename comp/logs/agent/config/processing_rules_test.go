@@ -33,3 +33,91 @@ func TestCompileShouldFailWithInvalidRules(t *testing.T) {
 		assert.Nil(t, rule.Regex)
 	}
 }
+
+func TestCompileExtractFieldsRuleWithGrokPattern(t *testing.T) {
+	rules := []*ProcessingRule{{Name: "access", Type: ExtractFields, Pattern: `%{IP:client} "%{WORD:http.method} %{URIPATHPARAM:http.url}" %{INT:http.status_code}`}}
+	assert.Nil(t, ValidateProcessingRules(rules))
+	assert.Nil(t, CompileProcessingRules(rules))
+
+	matches := rules[0].Regex.FindStringSubmatch(`10.0.0.1 "GET /index.html?q=1" 200`)
+	assert.NotNil(t, matches)
+	fields := make(map[string]string)
+	for i, name := range rules[0].Regex.SubexpNames() {
+		if name != "" {
+			fields[FieldName(name)] = matches[i]
+		}
+	}
+	assert.Equal(t, map[string]string{
+		"client":           "10.0.0.1",
+		"http.method":      "GET",
+		"http.url":         "/index.html?q=1",
+		"http.status_code": "200",
+	}, fields)
+}
+
+func TestValidateExtractFieldsRules(t *testing.T) {
+	validRules := []*ProcessingRule{
+		{Name: "regex", Type: ExtractFields, Pattern: `level=(?P<level>\w+)`, StatusField: "level"},
+		{Name: "grok", Type: ExtractFields, Pattern: `%{TIMESTAMP_ISO8601:ts} %{LOGLEVEL:level} %{GREEDYDATA:message}`, TimestampField: "ts"},
+	}
+	for _, rule := range validRules {
+		assert.Nil(t, ValidateProcessingRules([]*ProcessingRule{rule}), rule.Name)
+	}
+
+	invalidRules := []*ProcessingRule{
+		{Name: "no field", Type: ExtractFields, Pattern: `level=\w+`},
+		{Name: "unknown grok", Type: ExtractFields, Pattern: `%{UNKNOWN:field}`},
+		{Name: "missing status field", Type: ExtractFields, Pattern: `level=(?P<level>\w+)`, StatusField: "status"},
+	}
+	for _, rule := range invalidRules {
+		assert.NotNil(t, ValidateProcessingRules([]*ProcessingRule{rule}), rule.Name)
+	}
+}
+
+func TestValidateGenerateMetricRules(t *testing.T) {
+	validRules := []*ProcessingRule{
+		{Name: "count", Type: GenerateMetric, Pattern: `status=(?P<status>\d+)`, MetricName: "app.requests", TagFields: []string{"status"}},
+		{Name: "no capture", Type: GenerateMetric, Pattern: `ERROR`, MetricName: "app.errors", MetricType: MetricTypeCount, DropLog: true},
+		{Name: "distribution", Type: GenerateMetric, Pattern: `took %{NUMBER:duration}ms`, MetricName: "app.latency", MetricType: MetricTypeDistribution, ValueField: "duration"},
+	}
+	for _, rule := range validRules {
+		assert.Nil(t, ValidateProcessingRules([]*ProcessingRule{rule}), rule.Name)
+		assert.Nil(t, CompileProcessingRules([]*ProcessingRule{rule}), rule.Name)
+		assert.NotNil(t, rule.Regex, rule.Name)
+	}
+
+	invalidRules := []*ProcessingRule{
+		{Name: "no metric name", Type: GenerateMetric, Pattern: `ERROR`},
+		{Name: "unknown metric type", Type: GenerateMetric, Pattern: `ERROR`, MetricName: "app.errors", MetricType: "gauge"},
+		{Name: "no value field", Type: GenerateMetric, Pattern: `took %{NUMBER:duration}ms`, MetricName: "app.latency", MetricType: MetricTypeDistribution},
+		{Name: "missing tag field", Type: GenerateMetric, Pattern: `status=(?P<status>\d+)`, MetricName: "app.requests", TagFields: []string{"code"}},
+	}
+	for _, rule := range invalidRules {
+		assert.NotNil(t, ValidateProcessingRules([]*ProcessingRule{rule}), rule.Name)
+	}
+}
+
+func TestValidateSampleRules(t *testing.T) {
+	validRules := []*ProcessingRule{
+		{Name: "ratio", Type: Sample, Pattern: `DEBUG`, SampleRate: 0.1},
+		{Name: "rate", Type: Sample, Pattern: `DEBUG`, MaxPerSecond: 10},
+		{Name: "rate per field", Type: Sample, Pattern: `user=%{WORD:user}`, MaxPerSecond: 1, KeyField: "user"},
+		{Name: "rate per tag", Type: Sample, Pattern: `.*`, MaxPerSecond: 0.5, KeyTag: "pod_name"},
+	}
+	for _, rule := range validRules {
+		assert.Nil(t, ValidateProcessingRules([]*ProcessingRule{rule}), rule.Name)
+	}
+
+	invalidRules := []*ProcessingRule{
+		{Name: "no limit", Type: Sample, Pattern: `DEBUG`},
+		{Name: "both limits", Type: Sample, Pattern: `DEBUG`, SampleRate: 0.1, MaxPerSecond: 10},
+		{Name: "ratio too big", Type: Sample, Pattern: `DEBUG`, SampleRate: 10},
+		{Name: "negative rate", Type: Sample, Pattern: `DEBUG`, MaxPerSecond: -1},
+		{Name: "ratio with key", Type: Sample, Pattern: `.*`, SampleRate: 0.1, KeyTag: "pod_name"},
+		{Name: "both keys", Type: Sample, Pattern: `user=%{WORD:user}`, MaxPerSecond: 1, KeyField: "user", KeyTag: "pod_name"},
+		{Name: "missing key field", Type: Sample, Pattern: `DEBUG`, MaxPerSecond: 1, KeyField: "user"},
+	}
+	for _, rule := range invalidRules {
+		assert.NotNil(t, ValidateProcessingRules([]*ProcessingRule{rule}), rule.Name)
+	}
+}
@@ -228,6 +228,8 @@ func (a *Agent) SetupPipeline(
 		a.hostname,
 		a.config,
 		a.compression,
+		nil, // metricSender
+		nil, // digitMasker
 		a.config.GetBool("logs_config.disable_distributed_senders"),
 		false, // serverless
 	)
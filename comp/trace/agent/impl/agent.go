@@ -144,6 +144,7 @@ func NewAgent(deps dependencies) (traceagent.Component, error) {
 		statsdCl,
 		deps.Compressor,
 	)
+	setupSensitiveDataScanner(c.Agent, tracecfg)
 
 	c.config.OnUpdateAPIKey(c.UpdateAPIKey)
 
@@ -70,6 +70,14 @@ func runAgentSidekicks(ag component) error {
 	// trace-agent would largely increase the number of module pulled by OTEL when using the pkg/trace go-module.
 	ag.Agent.DebugServer.AddRoute("/config", ag.config.GetConfigHandler())
 	ag.Agent.DebugServer.AddRoute("/config/set", ag.config.SetHandler())
+	// The spans streamed by the inspector hold the data of the applications, so only the
+	// clients holding the IPC auth token, such as `trace-agent inspect`, may access them.
+	ag.Agent.DebugServer.AddRoute("/debug/traces", http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if apiutil.Validate(w, req) != nil {
+			return
+		}
+		ag.Agent.Inspector.ServeHTTP(w, req)
+	}))
 	// The below endpoint is deprecated and has been replaced with /config/set on the debug server.
 	// It will be removed in a future version.
 	api.AttachEndpoint(api.Endpoint{
@@ -0,0 +1,97 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package agentimpl
+
+import (
+	"sync/atomic"
+
+	"github.com/DataDog/datadog-agent/pkg/logs/sds"
+	"github.com/DataDog/datadog-agent/pkg/remoteconfig/state"
+	pkgagent "github.com/DataDog/datadog-agent/pkg/trace/agent"
+	tracecfg "github.com/DataDog/datadog-agent/pkg/trace/config"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+// sdsPipelineID identifies the trace-agent scanner in the Sensitive Data Scanner telemetry.
+const sdsPipelineID = "apm"
+
+// sensitiveDataScanner scans span values with the Sensitive Data Scanner rules of the logs,
+// as received through remote configuration.
+type sensitiveDataScanner struct {
+	scanner *sds.Scanner
+	// active reports whether the scanner has rules to apply.
+	active atomic.Bool
+}
+
+var _ pkgagent.SensitiveDataScanner = (*sensitiveDataScanner)(nil)
+
+// setupSensitiveDataScanner sets the sensitive data scanner of the agent if the scanning of
+// the spans is enabled and supported, and subscribes it to the Sensitive Data Scanner rules.
+func setupSensitiveDataScanner(agnt *pkgagent.Agent, cfg *tracecfg.AgentConfig) {
+	if !cfg.SensitiveDataScanning.Enabled {
+		return
+	}
+	if !sds.SDSEnabled {
+		log.Warn("apm_config.sds.enabled is set, but this trace-agent is built without Sensitive Data Scanner support: the spans won't be scanned.")
+		return
+	}
+	if cfg.RemoteConfigClient == nil {
+		log.Warn("apm_config.sds.enabled is set, but remote configuration is disabled: the spans won't be scanned as the Sensitive Data Scanner rules are received through remote configuration.")
+		return
+	}
+	s := &sensitiveDataScanner{scanner: sds.CreateScanner(sdsPipelineID)}
+	cfg.RemoteConfigClient.Subscribe(state.ProductSDSRules, s.onUpdate(sds.StandardRules))
+	cfg.RemoteConfigClient.Subscribe(state.ProductSDSAgentConfig, s.onUpdate(sds.AgentConfig))
+	agnt.SensitiveDataScanner = s
+}
+
+// onUpdate returns the remote configuration callback reconfiguring the scanner with the
+// updates of the given type.
+func (s *sensitiveDataScanner) onUpdate(reconfigType sds.ReconfigureOrderType) func(map[string]state.RawConfig, func(string, state.ApplyStatus)) {
+	return func(updates map[string]state.RawConfig, applyStateCallback func(string, state.ApplyStatus)) {
+		// an empty list of updates means that no configuration applies to this agent anymore
+		if len(updates) == 0 {
+			isActive, err := s.scanner.Reconfigure(sds.ReconfigureOrder{Type: sds.StopProcessing})
+			if err != nil {
+				log.Errorf("Can't stop the scanning of the spans for sensitive data: %v", err)
+			}
+			s.active.Store(isActive)
+			return
+		}
+		for path, config := range updates {
+			isActive, err := s.scanner.Reconfigure(sds.ReconfigureOrder{
+				Type:   reconfigType,
+				Config: config.Config,
+			})
+			s.active.Store(isActive)
+			if err != nil {
+				log.Errorf("Can't update the Sensitive Data Scanner configuration of the spans: %v", err)
+				applyStateCallback(path, state.ApplyStatus{
+					State: state.ApplyStateError,
+					Error: err.Error(),
+				})
+				continue
+			}
+			applyStateCallback(path, state.ApplyStatus{State: state.ApplyStateAcknowledged})
+		}
+	}
+}
+
+// Scan implements pkgagent.SensitiveDataScanner.
+func (s *sensitiveDataScanner) Scan(value []byte) (bool, []byte, []string, error) {
+	if !s.active.Load() {
+		return false, nil, nil, nil
+	}
+	mutated, processed, rules, err := s.scanner.ScanEvent(value)
+	if err != nil {
+		return false, nil, nil, err
+	}
+	names := make([]string, 0, len(rules))
+	for _, rule := range rules {
+		names = append(names, rule.Name)
+	}
+	return mutated, processed, names, nil
+}
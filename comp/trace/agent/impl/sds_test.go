@@ -0,0 +1,63 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package agentimpl
+
+import (
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/DataDog/datadog-agent/pkg/logs/sds"
+	"github.com/DataDog/datadog-agent/pkg/remoteconfig/state"
+	pkgagent "github.com/DataDog/datadog-agent/pkg/trace/agent"
+	"github.com/DataDog/datadog-agent/pkg/trace/config"
+	"github.com/DataDog/datadog-agent/pkg/trace/remoteconfighandler"
+)
+
+func TestSetupSensitiveDataScanner(t *testing.T) {
+	t.Run("disabled", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		cfg := config.New()
+		cfg.RemoteConfigClient = remoteconfighandler.NewMockRemoteClient(ctrl)
+		agnt := &pkgagent.Agent{}
+		setupSensitiveDataScanner(agnt, cfg)
+		assert.Nil(t, agnt.SensitiveDataScanner)
+	})
+
+	t.Run("no-remote-config", func(t *testing.T) {
+		cfg := config.New()
+		cfg.SensitiveDataScanning.Enabled = true
+		agnt := &pkgagent.Agent{}
+		setupSensitiveDataScanner(agnt, cfg)
+		assert.Nil(t, agnt.SensitiveDataScanner)
+	})
+
+	t.Run("enabled", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		remoteClient := remoteconfighandler.NewMockRemoteClient(ctrl)
+		cfg := config.New()
+		cfg.SensitiveDataScanning.Enabled = true
+		cfg.RemoteConfigClient = remoteClient
+		agnt := &pkgagent.Agent{}
+		if !sds.SDSEnabled {
+			// without Sensitive Data Scanner support, the spans are not scanned
+			setupSensitiveDataScanner(agnt, cfg)
+			assert.Nil(t, agnt.SensitiveDataScanner)
+			return
+		}
+		remoteClient.EXPECT().Subscribe(state.ProductSDSRules, gomock.Any()).Times(1)
+		remoteClient.EXPECT().Subscribe(state.ProductSDSAgentConfig, gomock.Any()).Times(1)
+		setupSensitiveDataScanner(agnt, cfg)
+		assert.NotNil(t, agnt.SensitiveDataScanner)
+
+		// no rules have been received yet
+		mutated, _, rules, err := agnt.SensitiveDataScanner.Scan([]byte("jane@example.com"))
+		assert.NoError(t, err)
+		assert.False(t, mutated)
+		assert.Empty(t, rules)
+	})
+}
@@ -640,6 +640,7 @@ func TestFullYamlConfig(t *testing.T) {
 	assert.True(t, o.Redis.Enabled)
 	assert.True(t, o.Memcached.Enabled)
 	assert.True(t, o.Memcached.KeepCommand)
+	assert.True(t, o.GraphQL.Enabled)
 	assert.True(t, o.CreditCards.Enabled)
 	assert.True(t, o.CreditCards.Luhn)
 	assert.True(t, o.Cache.Enabled)
@@ -1040,6 +1041,165 @@ func TestLoadEnv(t *testing.T) {
 		assert.Contains(t, cfg.ReplaceTags, rule2)
 	})
 
+	for _, envKey := range []string{
+		"DD_APM_ZIPKIN_RECEIVER_ENABLED",
+		"DD_APM_JAEGER_RECEIVER_ENABLED",
+	} {
+		t.Run(envKey, func(t *testing.T) {
+			t.Setenv(envKey, "true")
+
+			c := buildConfigComponent(t, true, fx.Replace(corecomp.MockParams{
+				Params: corecomp.Params{ConfFilePath: "./testdata/full.yaml"},
+			}))
+
+			cfg := c.Object()
+
+			assert.NotNil(t, cfg)
+			assert.Equal(t, envKey == "DD_APM_ZIPKIN_RECEIVER_ENABLED", cfg.ZipkinReceiverEnabled)
+			assert.Equal(t, envKey == "DD_APM_JAEGER_RECEIVER_ENABLED", cfg.JaegerReceiverEnabled)
+		})
+	}
+
+	env = "DD_APM_SPAN_DERIVED_METRICS_DIMENSIONS"
+	t.Run(env, func(t *testing.T) {
+		t.Setenv("DD_APM_SPAN_DERIVED_METRICS_ENABLED", "true")
+		t.Setenv("DD_APM_SPAN_DERIVED_METRICS_MAX_CONTEXTS", "500")
+		t.Setenv(env, "http.route customer.tier")
+
+		c := buildConfigComponent(t, true, fx.Replace(corecomp.MockParams{
+			Params: corecomp.Params{ConfFilePath: "./testdata/full.yaml"},
+		}))
+
+		cfg := c.Object()
+
+		assert.NotNil(t, cfg)
+		assert.True(t, cfg.SpanDerivedMetricsEnabled)
+		assert.Equal(t, []string{"http.route", "customer.tier"}, cfg.SpanDerivedMetricsDimensions)
+		assert.Equal(t, 500, cfg.SpanDerivedMetricsMaxContexts)
+	})
+
+	env = "DD_APM_TAIL_SAMPLER_POLICIES"
+	t.Run(env, func(t *testing.T) {
+		t.Setenv("DD_APM_TAIL_SAMPLER_ENABLED", "true")
+		t.Setenv("DD_APM_TAIL_SAMPLER_WINDOW", "10s")
+		t.Setenv(env, `[{"name":"slow","service":"db","min_duration_ms":500}, {"name":"gold","error":true,"tags":{"tier":"gold"}}]`)
+
+		c := buildConfigComponent(t, true, fx.Replace(corecomp.MockParams{
+			Params: corecomp.Params{ConfFilePath: "./testdata/full.yaml"},
+		}))
+
+		cfg := c.Object()
+
+		assert.NotNil(t, cfg)
+		assert.True(t, cfg.TailSamplerEnabled)
+		assert.Equal(t, 10*time.Second, cfg.TailSamplerWindow)
+		assert.Equal(t, []*traceconfig.TailSamplerPolicy{
+			{Name: "slow", Service: "db", MinDurationMs: 500},
+			{Name: "gold", Error: true, Tags: map[string]string{"tier": "gold"}},
+		}, cfg.TailSamplerPolicies)
+	})
+
+	env = "DD_APM_SPOOL_DIR"
+	t.Run(env, func(t *testing.T) {
+		t.Setenv("DD_APM_SPOOL_ENABLED", "true")
+		t.Setenv("DD_APM_SPOOL_MAX_BYTES", "1048576")
+		t.Setenv("DD_APM_SPOOL_MAX_AGE", "30m")
+		t.Setenv(env, "/var/spool/trace-agent")
+
+		c := buildConfigComponent(t, true, fx.Replace(corecomp.MockParams{
+			Params: corecomp.Params{ConfFilePath: "./testdata/full.yaml"},
+		}))
+
+		cfg := c.Object()
+
+		assert.NotNil(t, cfg)
+		assert.True(t, cfg.SpoolEnabled)
+		assert.Equal(t, "/var/spool/trace-agent", cfg.SpoolDir)
+		assert.EqualValues(t, 1048576, cfg.SpoolMaxBytes)
+		assert.Equal(t, 30*time.Minute, cfg.SpoolMaxAge)
+	})
+
+	env = "DD_APM_TARGET_TPS_BUDGET_MIN_TPS"
+	t.Run(env, func(t *testing.T) {
+		t.Setenv("DD_APM_TARGET_TPS_BUDGET_ENABLED", "true")
+		t.Setenv(env, "0.5")
+
+		c := buildConfigComponent(t, true, fx.Replace(corecomp.MockParams{
+			Params: corecomp.Params{ConfFilePath: "./testdata/full.yaml"},
+		}))
+
+		cfg := c.Object()
+
+		assert.NotNil(t, cfg)
+		assert.True(t, cfg.TargetTPSBudgetEnabled)
+		assert.Equal(t, 0.5, cfg.TargetTPSBudgetMinTPS)
+	})
+
+	env = "DD_APM_OTLP_EXPORTER_ENDPOINT"
+	t.Run(env, func(t *testing.T) {
+		t.Setenv("DD_APM_OTLP_EXPORTER_ENABLED", "true")
+		t.Setenv("DD_APM_OTLP_EXPORTER_PROTOCOL", "http")
+		t.Setenv("DD_APM_OTLP_EXPORTER_HEADERS", `{"x-tenant":"apm"}`)
+		t.Setenv("DD_APM_OTLP_EXPORTER_TIMEOUT", "5s")
+		t.Setenv(env, "https://collector.example.com:4318/v1/traces")
+
+		c := buildConfigComponent(t, true, fx.Replace(corecomp.MockParams{
+			Params: corecomp.Params{ConfFilePath: "./testdata/full.yaml"},
+		}))
+
+		cfg := c.Object()
+
+		assert.NotNil(t, cfg)
+		assert.True(t, cfg.OTLPExporter.Enabled)
+		assert.Equal(t, traceconfig.OTLPExportProtocolHTTP, cfg.OTLPExporter.Protocol)
+		assert.Equal(t, "https://collector.example.com:4318/v1/traces", cfg.OTLPExporter.Endpoint)
+		assert.Equal(t, map[string]string{"x-tenant": "apm"}, cfg.OTLPExporter.Headers)
+		assert.False(t, cfg.OTLPExporter.Insecure)
+		assert.Equal(t, 5*time.Second, cfg.OTLPExporter.Timeout)
+	})
+
+	env = "DD_APM_SDS_META_KEYS"
+	t.Run(env, func(t *testing.T) {
+		t.Setenv("DD_APM_SDS_ENABLED", "true")
+		t.Setenv("DD_APM_SDS_SPAN_EVENTS", "false")
+		t.Setenv(env, "http.url user.email")
+
+		c := buildConfigComponent(t, true, fx.Replace(corecomp.MockParams{
+			Params: corecomp.Params{ConfFilePath: "./testdata/full.yaml"},
+		}))
+
+		cfg := c.Object()
+
+		assert.NotNil(t, cfg)
+		assert.True(t, cfg.SensitiveDataScanning.Enabled)
+		assert.Equal(t, []string{"http.url", "user.email"}, cfg.SensitiveDataScanning.MetaKeys)
+		assert.False(t, cfg.SensitiveDataScanning.SpanEvents)
+	})
+
+	env = "DD_APM_SPAN_RULES"
+	t.Run(env, func(t *testing.T) {
+		t.Setenv(env, `[{"name":"scrub","conditions":[{"key":"span.service","pattern":"^web$"},{"key":"http.status_code","op":">=","value":500}],"actions":[{"action":"hash","key":"user.email"},{"action":"truncate","key":"sql.query","length":100}]}]`)
+
+		c := buildConfigComponent(t, true, fx.Replace(corecomp.MockParams{
+			Params: corecomp.Params{ConfFilePath: "./testdata/full.yaml"},
+		}))
+
+		cfg := c.Object()
+
+		assert.NotNil(t, cfg)
+		assert.Equal(t, []*traceconfig.SpanRule{{
+			Name: "scrub",
+			Conditions: []traceconfig.SpanRuleCondition{
+				{Key: "span.service", Pattern: "^web$"},
+				{Key: "http.status_code", Op: ">=", Value: 500},
+			},
+			Actions: []traceconfig.SpanRuleAction{
+				{Action: "hash", Key: "user.email"},
+				{Action: "truncate", Key: "sql.query", Length: 100},
+			},
+		}}, cfg.SpanRules)
+	})
+
 	env = "DD_APM_FILTER_TAGS_REQUIRE"
 	t.Run(env, func(t *testing.T) {
 		t.Setenv(env, `important1 important2:value1`)
@@ -1591,6 +1751,20 @@ func TestLoadEnv(t *testing.T) {
 		assert.True(t, cfg.Obfuscation.Memcached.KeepCommand)
 	})
 
+	env = "DD_APM_OBFUSCATION_GRAPHQL_ENABLED"
+	t.Run(env, func(t *testing.T) {
+		t.Setenv(env, "false")
+
+		c := buildConfigComponent(t, true, fx.Replace(corecomp.MockParams{
+			Params: corecomp.Params{ConfFilePath: "./testdata/full.yaml"},
+		}))
+		cfg := c.Object()
+
+		assert.NotNil(t, cfg)
+		assert.False(t, pkgconfigsetup.Datadog().GetBool("apm_config.obfuscation.graphql.enabled"))
+		assert.False(t, cfg.Obfuscation.GraphQL.Enabled)
+	})
+
 	env = "DD_APM_OBFUSCATION_MONGODB_ENABLED"
 	t.Run(env, func(t *testing.T) {
 		t.Setenv(env, "true")
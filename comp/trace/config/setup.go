@@ -12,6 +12,7 @@ import (
 	"net/http"
 	"net/url"
 	"os"
+	"path/filepath"
 	"regexp"
 	"strconv"
 	"strings"
@@ -273,6 +274,15 @@ func applyDatadogConfig(c *config.AgentConfig, core corecompcfg.Component) error
 	if core.IsSet("apm_config.peer_tags") {
 		c.PeerTags = core.GetStringSlice("apm_config.peer_tags")
 	}
+	if core.IsSet("apm_config.span_derived_metrics.enabled") {
+		c.SpanDerivedMetricsEnabled = core.GetBool("apm_config.span_derived_metrics.enabled")
+	}
+	if core.IsSet("apm_config.span_derived_metrics.dimensions") {
+		c.SpanDerivedMetricsDimensions = core.GetStringSlice("apm_config.span_derived_metrics.dimensions")
+	}
+	if core.IsSet("apm_config.span_derived_metrics.max_contexts") {
+		c.SpanDerivedMetricsMaxContexts = core.GetInt("apm_config.span_derived_metrics.max_contexts")
+	}
 
 	if core.IsSet("apm_config.extra_sample_rate") {
 		c.ExtraSampleRate = core.GetFloat64("apm_config.extra_sample_rate")
@@ -290,6 +300,12 @@ func applyDatadogConfig(c *config.AgentConfig, core corecompcfg.Component) error
 	if core.IsSet("apm_config.errors_per_second") {
 		c.ErrorTPS = core.GetFloat64("apm_config.errors_per_second")
 	}
+	if core.IsSet("apm_config.target_tps_budget.enabled") {
+		c.TargetTPSBudgetEnabled = core.GetBool("apm_config.target_tps_budget.enabled")
+	}
+	if core.IsSet("apm_config.target_tps_budget.min_tps") {
+		c.TargetTPSBudgetMinTPS = core.GetFloat64("apm_config.target_tps_budget.min_tps")
+	}
 	if core.IsSet("apm_config.enable_rare_sampler") {
 		c.RareSamplerEnabled = core.GetBool("apm_config.enable_rare_sampler")
 	}
@@ -317,6 +333,31 @@ func applyDatadogConfig(c *config.AgentConfig, core corecompcfg.Component) error
 		c.ErrorTrackingStandalone = core.GetBool("apm_config.error_tracking_standalone.enabled")
 	}
 
+	if core.IsSet("apm_config.zipkin_receiver.enabled") {
+		c.ZipkinReceiverEnabled = core.GetBool("apm_config.zipkin_receiver.enabled")
+	}
+	if core.IsSet("apm_config.jaeger_receiver.enabled") {
+		c.JaegerReceiverEnabled = core.GetBool("apm_config.jaeger_receiver.enabled")
+	}
+
+	if core.IsSet("apm_config.tail_sampler.enabled") {
+		c.TailSamplerEnabled = core.GetBool("apm_config.tail_sampler.enabled")
+	}
+	if core.IsSet("apm_config.tail_sampler.window") {
+		c.TailSamplerWindow = core.GetDuration("apm_config.tail_sampler.window")
+	}
+	if core.IsSet("apm_config.tail_sampler.max_buffer_bytes") {
+		c.TailSamplerMaxBufferBytes = core.GetInt("apm_config.tail_sampler.max_buffer_bytes")
+	}
+	if k := "apm_config.tail_sampler.policies"; core.IsSet(k) {
+		policies := make([]*config.TailSamplerPolicy, 0)
+		if err := structure.UnmarshalKey(core, k, &policies); err != nil {
+			log.Errorf("Bad format for %q it should be of the form '[{\"name\": \"slow\",\"service\":\"web\",\"min_duration_ms\":500}]', error: %v", k, err)
+		} else {
+			c.TailSamplerPolicies = policies
+		}
+	}
+
 	if core.IsSet("apm_config.max_remote_traces_per_second") {
 		c.MaxRemoteTPS = core.GetFloat64("apm_config.max_remote_traces_per_second")
 	}
@@ -367,6 +408,15 @@ func applyDatadogConfig(c *config.AgentConfig, core corecompcfg.Component) error
 		}
 	}
 
+	if k := "apm_config.span_rules"; core.IsSet(k) {
+		rules := make([]*config.SpanRule, 0)
+		if err := structure.UnmarshalKey(core, k, &rules); err != nil {
+			log.Errorf("Bad format for %q it should be of the form '[{\"name\":\"rule\",\"conditions\":[{\"key\":\"tag\",\"pattern\":\"pattern\"}],\"actions\":[{\"action\":\"delete\",\"key\":\"tag\"}]}]', error: %v", k, err)
+		} else {
+			c.SpanRules = rules
+		}
+	}
+
 	if core.IsSet("bind_host") || core.IsSet("apm_config.apm_non_local_traffic") {
 		if core.IsSet("bind_host") {
 			host := core.GetString("bind_host")
@@ -464,6 +514,7 @@ func applyDatadogConfig(c *config.AgentConfig, core corecompcfg.Component) error
 	}
 	c.Obfuscation.Memcached.Enabled = pkgconfigsetup.Datadog().GetBool("apm_config.obfuscation.memcached.enabled")
 	c.Obfuscation.Memcached.KeepCommand = pkgconfigsetup.Datadog().GetBool("apm_config.obfuscation.memcached.keep_command")
+	c.Obfuscation.GraphQL.Enabled = pkgconfigsetup.Datadog().GetBool("apm_config.obfuscation.graphql.enabled")
 	c.Obfuscation.Redis.Enabled = pkgconfigsetup.Datadog().GetBool("apm_config.obfuscation.redis.enabled")
 	c.Obfuscation.Redis.RemoveAllArgs = pkgconfigsetup.Datadog().GetBool("apm_config.obfuscation.redis.remove_all_args")
 	c.Obfuscation.Valkey.Enabled = pkgconfigsetup.Datadog().GetBool("apm_config.obfuscation.valkey.enabled")
@@ -474,6 +525,14 @@ func applyDatadogConfig(c *config.AgentConfig, core corecompcfg.Component) error
 	c.Obfuscation.Cache.Enabled = pkgconfigsetup.Datadog().GetBool("apm_config.obfuscation.cache.enabled")
 	c.Obfuscation.Cache.MaxSize = pkgconfigsetup.Datadog().GetInt64("apm_config.obfuscation.cache.max_size")
 
+	if core.IsSet("apm_config.sds.enabled") {
+		c.SensitiveDataScanning.Enabled = core.GetBool("apm_config.sds.enabled")
+	}
+	c.SensitiveDataScanning.MetaKeys = core.GetStringSlice("apm_config.sds.meta_keys")
+	if core.IsSet("apm_config.sds.span_events") {
+		c.SensitiveDataScanning.SpanEvents = core.GetBool("apm_config.sds.span_events")
+	}
+
 	if core.IsSet("apm_config.filter_tags.require") {
 		tags := core.GetStringSlice("apm_config.filter_tags.require")
 		for _, tag := range tags {
@@ -529,6 +588,36 @@ func applyDatadogConfig(c *config.AgentConfig, core corecompcfg.Component) error
 		// Default of 4 was chosen through experimentation, but may not be the optimal value.
 		c.MaxSenderRetries = 4
 	}
+	if core.IsSet("apm_config.spool.enabled") {
+		c.SpoolEnabled = core.GetBool("apm_config.spool.enabled")
+	}
+	if core.IsSet("apm_config.spool.dir") {
+		c.SpoolDir = core.GetString("apm_config.spool.dir")
+	} else {
+		c.SpoolDir = filepath.Join(core.GetString("run_path"), "trace-agent-spool")
+	}
+	if core.IsSet("apm_config.spool.max_bytes") {
+		c.SpoolMaxBytes = core.GetInt64("apm_config.spool.max_bytes")
+	}
+	if core.IsSet("apm_config.spool.max_age") {
+		c.SpoolMaxAge = core.GetDuration("apm_config.spool.max_age")
+	}
+	if core.IsSet("apm_config.otlp_exporter.enabled") {
+		c.OTLPExporter.Enabled = core.GetBool("apm_config.otlp_exporter.enabled")
+	}
+	if core.IsSet("apm_config.otlp_exporter.protocol") {
+		c.OTLPExporter.Protocol = core.GetString("apm_config.otlp_exporter.protocol")
+	}
+	if core.IsSet("apm_config.otlp_exporter.endpoint") {
+		c.OTLPExporter.Endpoint = core.GetString("apm_config.otlp_exporter.endpoint")
+	}
+	if core.IsSet("apm_config.otlp_exporter.insecure") {
+		c.OTLPExporter.Insecure = core.GetBool("apm_config.otlp_exporter.insecure")
+	}
+	if core.IsSet("apm_config.otlp_exporter.timeout") {
+		c.OTLPExporter.Timeout = core.GetDuration("apm_config.otlp_exporter.timeout")
+	}
+	c.OTLPExporter.Headers = core.GetStringMapString("apm_config.otlp_exporter.headers")
 	if core.IsSet("apm_config.sync_flushing") {
 		c.SynchronousFlushing = core.GetBool("apm_config.sync_flushing")
 	}
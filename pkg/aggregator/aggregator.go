@@ -224,6 +224,8 @@ func init() {
 	tagsetTlm = newTagsetTelemetry([]uint64{90, 100})
 
 	aggregatorExpvars.Set("MetricTags", expvar.Func(expMetricTags))
+	aggregatorExpvars.Set("DogstatsdContextLimiter", expvar.Func(expContextLimiter))
+	aggregatorExpvars.Set("DogstatsdRollup", expvar.Func(expContextRollup))
 }
 
 // BufferedAggregator aggregates metrics in buckets for dogstatsd Metrics
@@ -0,0 +1,341 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package aggregator
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/DataDog/datadog-agent/pkg/aggregator/ckey"
+	"github.com/DataDog/datadog-agent/pkg/config/model"
+	"github.com/DataDog/datadog-agent/pkg/telemetry"
+)
+
+const (
+	// maxContextLimiterOffenders is the maximum number of metric names whose
+	// overflows are counted for the status page.
+	maxContextLimiterOffenders = 1000
+	// topContextLimiterOffenders is the number of metric names listed on the status page.
+	topContextLimiterOffenders = 10
+	// contextLimiterStatsWindow is the window over which the overflows are
+	// counted for the status page.
+	contextLimiterStatsWindow = time.Minute
+	// maxContextLimiterOverflowed is the maximum number of contexts whose
+	// overflow context is cached by a context limiter.
+	maxContextLimiterOverflowed = 100000
+	// contextLimiterOverflowTag marks the overflow contexts, so that they never
+	// share the key of a context, e.g. when all its tag values are known.
+	contextLimiterOverflowTag = "overflow:true"
+)
+
+var (
+	tlmContextLimiterOverflows = telemetry.NewCounter("aggregator", "dogstatsd_context_limiter_overflows",
+		[]string{"shard"}, "Count the number of dogstatsd samples aggregated into an overflow context")
+	tlmContextLimiterLimitedMetrics = telemetry.NewGauge("aggregator", "dogstatsd_context_limiter_limited_metrics",
+		[]string{"shard"}, "Number of dogstatsd metrics whose number of contexts reached the limit")
+
+	contextLimiterOffenders = newContextLimiterStats(time.Now)
+)
+
+// limiterKey identifies the contexts counted together by the contextLimiter.
+type limiterKey struct {
+	name   string
+	origin ckey.TagsKey
+}
+
+// limitedMetric holds the contexts of a metric name below the limit.
+type limitedMetric struct {
+	contexts int
+	// values counts the contexts using each value of each tag name.
+	values map[string]map[string]int
+}
+
+// contextLimiter caps the number of contexts of each metric name, optionally
+// for each origin. The samples of the contexts above the limit are aggregated
+// into an overflow context, see overflowTags.
+//
+// contextLimiter is not thread-safe, each contextResolver has its own.
+type contextLimiter struct {
+	limit         int
+	perOrigin     bool
+	overflowValue string
+	shard         string
+
+	metrics map[limiterKey]*limitedMetric
+	// contexts holds the contexts counted below the limit.
+	contexts map[ckey.ContextKey]limiterKey
+	// limited holds the metrics which reached the limit.
+	limited map[limiterKey]struct{}
+	// overflowed holds the overflow context of the contexts above the limit,
+	// so that their samples don't go through the limiter again.
+	overflowed map[ckey.ContextKey]ckey.ContextKey
+}
+
+func newContextLimiter(limit int, perOrigin bool, overflowValue string, shard string) *contextLimiter {
+	return &contextLimiter{
+		limit:         limit,
+		perOrigin:     perOrigin,
+		overflowValue: overflowValue,
+		shard:         shard,
+		metrics:       make(map[limiterKey]*limitedMetric),
+		contexts:      make(map[ckey.ContextKey]limiterKey),
+		limited:       make(map[limiterKey]struct{}),
+		overflowed:    make(map[ckey.ContextKey]ckey.ContextKey),
+	}
+}
+
+// newContextLimiterFromConfig returns the context limiter of a DogStatsD
+// pipeline, nil if the number of contexts is not limited. The contexts are
+// distributed between the pipelines by their key, each pipeline caps its share
+// of the contexts of each metric name so that the limit applies to all the
+// pipelines together.
+func newContextLimiterFromConfig(cfg model.Reader, pipelineCount int, shard string) *contextLimiter {
+	limit := cfg.GetInt("dogstatsd_context_limiter.metric_limit")
+	if limit <= 0 {
+		return nil
+	}
+	if pipelineCount > 1 {
+		limit = (limit + pipelineCount - 1) / pipelineCount
+	}
+	return newContextLimiter(
+		limit,
+		cfg.GetBool("dogstatsd_context_limiter.per_origin"),
+		cfg.GetString("dogstatsd_context_limiter.overflow_tag_value"),
+		shard,
+	)
+}
+
+func (l *contextLimiter) key(name string, origin ckey.TagsKey) limiterKey {
+	if !l.perOrigin {
+		origin = 0
+	}
+	return limiterKey{name: name, origin: origin}
+}
+
+// admit tracks a new context and returns true if it is below the limit of its metric.
+func (l *contextLimiter) admit(contextKey ckey.ContextKey, name string, origin ckey.TagsKey, tags []string) bool {
+	key := l.key(name, origin)
+	metric, ok := l.metrics[key]
+	if !ok {
+		metric = &limitedMetric{values: make(map[string]map[string]int)}
+		l.metrics[key] = metric
+	}
+	if metric.contexts >= l.limit {
+		l.limited[key] = struct{}{}
+		l.overflow(name)
+		return false
+	}
+
+	metric.contexts++
+	for _, tag := range tags {
+		tagName, value := splitTag(tag)
+		values, ok := metric.values[tagName]
+		if !ok {
+			values = make(map[string]int)
+			metric.values[tagName] = values
+		}
+		values[value]++
+	}
+	l.contexts[contextKey] = key
+	return true
+}
+
+// overflow counts a sample aggregated into an overflow context.
+func (l *contextLimiter) overflow(name string) {
+	tlmContextLimiterOverflows.Inc(l.shard)
+	contextLimiterOffenders.overflow(name)
+}
+
+// overflowContext returns the overflow context a context above the limit has
+// been aggregated into, if known.
+func (l *contextLimiter) overflowContext(contextKey ckey.ContextKey) (ckey.ContextKey, bool) {
+	overflowKey, ok := l.overflowed[contextKey]
+	return overflowKey, ok
+}
+
+// setOverflowContext records the overflow context of a context above the limit.
+func (l *contextLimiter) setOverflowContext(contextKey ckey.ContextKey, overflowKey ckey.ContextKey) {
+	if len(l.overflowed) >= maxContextLimiterOverflowed {
+		clear(l.overflowed)
+	}
+	l.overflowed[contextKey] = overflowKey
+}
+
+// overflowTags returns the tags of the overflow context of a context above
+// the limit: the tag values which are not used by the contexts below the
+// limit are replaced by the overflow value. Tags without value are replaced
+// by the overflow value as a whole. When all the values are known, i.e. the
+// tags recombine the values of several contexts, the value of the tag with
+// the most values is replaced. The overflow contexts are also marked with
+// contextLimiterOverflowTag so that they never share the key of a context.
+func (l *contextLimiter) overflowTags(name string, origin ckey.TagsKey, tags []string) []string {
+	metric := l.metrics[l.key(name, origin)]
+	overflowTags := make([]string, 0, len(tags)+1)
+	replaced := false
+	widest, widestValues := -1, 0
+	for _, tag := range tags {
+		tagName, value := splitTag(tag)
+		if _, known := metric.values[tagName][value]; !known {
+			tag = l.overflowTag(tagName, value)
+			replaced = true
+		} else if values := len(metric.values[tagName]); values > widestValues {
+			widest, widestValues = len(overflowTags), values
+		}
+		overflowTags = append(overflowTags, tag)
+	}
+	if !replaced && widest >= 0 {
+		overflowTags[widest] = l.overflowTag(splitTag(overflowTags[widest]))
+	}
+	return append(overflowTags, contextLimiterOverflowTag)
+}
+
+// overflowTag returns the tag replacing the given tag in an overflow context.
+func (l *contextLimiter) overflowTag(tagName, value string) string {
+	if value == "" {
+		return l.overflowValue
+	}
+	return tagName + ":" + l.overflowValue
+}
+
+// remove stops tracking an expired context.
+func (l *contextLimiter) remove(contextKey ckey.ContextKey, tags []string) {
+	key, ok := l.contexts[contextKey]
+	if !ok {
+		return
+	}
+	delete(l.contexts, contextKey)
+
+	metric := l.metrics[key]
+	metric.contexts--
+	for _, tag := range tags {
+		tagName, value := splitTag(tag)
+		values := metric.values[tagName]
+		if values[value]--; values[value] <= 0 {
+			delete(values, value)
+		}
+		if len(values) == 0 {
+			delete(metric.values, tagName)
+		}
+	}
+	if metric.contexts <= 0 {
+		delete(l.metrics, key)
+	}
+	if _, limited := l.limited[key]; limited && metric.contexts < l.limit {
+		delete(l.limited, key)
+		// the new contexts of the metric can be admitted again
+		clear(l.overflowed)
+	}
+}
+
+func (l *contextLimiter) updateMetrics() {
+	tlmContextLimiterLimitedMetrics.Set(float64(len(l.limited)), l.shard)
+}
+
+// splitTag returns the name and the value of a tag, the value of a tag
+// without colon is empty.
+func splitTag(tag string) (string, string) {
+	name, value, _ := strings.Cut(tag, ":")
+	return name, value
+}
+
+// contextLimiterStats counts the samples aggregated into an overflow context
+// by metric name, for all the context limiters, over windows of
+// contextLimiterStatsWindow. The counts of the last complete window are reported.
+type contextLimiterStats struct {
+	mu          sync.Mutex
+	now         func() time.Time
+	windowStart time.Time
+	current     contextLimiterWindow
+	last        contextLimiterWindow
+}
+
+// contextLimiterWindow holds the overflows counted during a window.
+type contextLimiterWindow struct {
+	total     uint64
+	overflows map[string]uint64
+}
+
+// ContextLimiterOffender is a metric name whose contexts reached the limit.
+type ContextLimiterOffender struct {
+	Metric            string
+	OverflowedSamples uint64
+}
+
+func newContextLimiterStats(now func() time.Time) *contextLimiterStats {
+	return &contextLimiterStats{
+		now:         now,
+		windowStart: now(),
+		current:     contextLimiterWindow{overflows: make(map[string]uint64)},
+	}
+}
+
+// rotate starts a new window once the current one is complete.
+func (s *contextLimiterStats) rotate() {
+	elapsed := s.now().Sub(s.windowStart)
+	if elapsed < contextLimiterStatsWindow {
+		return
+	}
+	if elapsed < 2*contextLimiterStatsWindow {
+		s.last = s.current
+	} else {
+		// no overflow was counted during the previous window
+		s.last = contextLimiterWindow{}
+	}
+	s.current = contextLimiterWindow{overflows: make(map[string]uint64)}
+	s.windowStart = s.windowStart.Add(elapsed.Truncate(contextLimiterStatsWindow))
+}
+
+func (s *contextLimiterStats) overflow(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rotate()
+	s.current.total++
+	if _, ok := s.current.overflows[name]; ok || len(s.current.overflows) < maxContextLimiterOffenders {
+		s.current.overflows[name]++
+	}
+}
+
+// lastWindow returns the number of overflowed samples during the last complete
+// window, and the metric names with the most overflowed samples.
+func (s *contextLimiterStats) lastWindow(n int) (uint64, []ContextLimiterOffender) {
+	s.mu.Lock()
+	s.rotate()
+	total := s.last.total
+	offenders := make([]ContextLimiterOffender, 0, len(s.last.overflows))
+	for name, count := range s.last.overflows {
+		offenders = append(offenders, ContextLimiterOffender{Metric: name, OverflowedSamples: count})
+	}
+	s.mu.Unlock()
+
+	sort.Slice(offenders, func(i, j int) bool {
+		if offenders[i].OverflowedSamples != offenders[j].OverflowedSamples {
+			return offenders[i].OverflowedSamples > offenders[j].OverflowedSamples
+		}
+		return offenders[i].Metric < offenders[j].Metric
+	})
+	if len(offenders) > n {
+		offenders = offenders[:n]
+	}
+	return total, offenders
+}
+
+func (s *contextLimiterStats) reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.windowStart = s.now()
+	s.current = contextLimiterWindow{overflows: make(map[string]uint64)}
+	s.last = contextLimiterWindow{}
+}
+
+func expContextLimiter() interface{} {
+	total, offenders := contextLimiterOffenders.lastWindow(topContextLimiterOffenders)
+	return map[string]interface{}{
+		"OverflowedSamples": total,
+		"TopOffenders":      offenders,
+	}
+}
@@ -0,0 +1,162 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build test
+
+package aggregator
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	nooptagger "github.com/DataDog/datadog-agent/comp/core/tagger/impl-noop"
+	"github.com/DataDog/datadog-agent/pkg/aggregator/internal/tags"
+	configmock "github.com/DataDog/datadog-agent/pkg/config/mock"
+	"github.com/DataDog/datadog-agent/pkg/metrics"
+)
+
+func testContextLimiter(t *testing.T, store *tags.Store) {
+	contextLimiterOffenders.reset()
+	defer contextLimiterOffenders.reset()
+
+	limiter := newContextLimiter(2, false, "overflow", "test")
+	contextResolver := newTimestampContextResolver(nooptagger.NewComponent(), store, "test", 2, 4, limiter, nil)
+	sample := func(name string, tags ...string) *metrics.MetricSample {
+		return &metrics.MetricSample{Name: name, Mtype: metrics.GaugeType, Tags: tags, SampleRate: 1}
+	}
+
+	key1 := contextResolver.trackContext(sample("my.metric", "env:prod", "request_id:1"), 4)
+	key2 := contextResolver.trackContext(sample("my.metric", "env:prod", "request_id:2"), 4)
+	assert.NotEqual(t, key1, key2)
+
+	// the contexts above the limit share the overflow context
+	key3 := contextResolver.trackContext(sample("my.metric", "env:prod", "request_id:3"), 4)
+	key4 := contextResolver.trackContext(sample("my.metric", "env:prod", "request_id:4", "request"), 6)
+	key5 := contextResolver.trackContext(sample("my.metric", "request_id:5", "env:prod", "request"), 6)
+	assert.Equal(t, key4, key5)
+	assert.NotEqual(t, key3, key4)
+	context, ok := contextResolver.get(key3)
+	require.True(t, ok)
+	assertContext(t, context, "my.metric", []string{"env:prod", "request_id:overflow", "overflow:true"}, "")
+	context, ok = contextResolver.get(key4)
+	require.True(t, ok)
+	assertContext(t, context, "my.metric", []string{"env:prod", "request_id:overflow", "overflow", "overflow:true"}, "")
+
+	// other metrics are not limited
+	contextResolver.trackContext(sample("my.other.metric", "request_id:3"), 4)
+	assert.Equal(t, 5, contextResolver.length())
+	assert.Len(t, limiter.limited, 1)
+
+	// the samples of an overflowed context go to its overflow context
+	assert.Equal(t, key3, contextResolver.trackContext(sample("my.metric", "env:prod", "request_id:3"), 4))
+	assert.Len(t, limiter.overflowed, 3)
+	assert.Equal(t, uint64(4), contextLimiterOffenders.current.overflows["my.metric"])
+
+	// the expired contexts free room for new contexts
+	contextResolver.expireContexts(7)
+	assert.Equal(t, 1, contextResolver.length())
+	assert.Empty(t, limiter.limited)
+	assert.Empty(t, limiter.contexts)
+	assert.Empty(t, limiter.overflowed)
+	key6 := contextResolver.trackContext(sample("my.metric", "env:prod", "request_id:6"), 8)
+	context, ok = contextResolver.get(key6)
+	require.True(t, ok)
+	assertContext(t, context, "my.metric", []string{"env:prod", "request_id:6"}, "")
+}
+
+func TestContextLimiter(t *testing.T) {
+	testWithTagsStore(t, testContextLimiter)
+}
+
+func TestContextLimiterPerOrigin(t *testing.T) {
+	limiter := newContextLimiter(1, true, "overflow", "test")
+	assert.True(t, limiter.admit(1, "my.metric", 1, []string{"id:1"}))
+	assert.True(t, limiter.admit(2, "my.metric", 2, []string{"id:2"}))
+	assert.False(t, limiter.admit(3, "my.metric", 1, []string{"id:3"}))
+	assert.Equal(t, []string{"id:overflow", "overflow:true"}, limiter.overflowTags("my.metric", 1, []string{"id:3"}))
+	// all the values are known for the origin, the tag with the most values is replaced
+	assert.Equal(t, []string{"id:overflow", "overflow:true"}, limiter.overflowTags("my.metric", 2, []string{"id:2"}))
+
+	limiter = newContextLimiter(1, false, "overflow", "test")
+	assert.True(t, limiter.admit(1, "my.metric", 1, []string{"id:1"}))
+	assert.False(t, limiter.admit(2, "my.metric", 2, []string{"id:2"}))
+}
+
+func testContextLimiterRecombinedTags(t *testing.T, store *tags.Store) {
+	contextLimiterOffenders.reset()
+	defer contextLimiterOffenders.reset()
+
+	limiter := newContextLimiter(2, false, "overflow", "test")
+	contextResolver := newTimestampContextResolver(nooptagger.NewComponent(), store, "test", 2, 4, limiter, nil)
+	sample := func(tags ...string) *metrics.MetricSample {
+		return &metrics.MetricSample{Name: "my.metric", Mtype: metrics.GaugeType, Tags: tags, SampleRate: 1}
+	}
+
+	contextResolver.trackContext(sample("a:1", "b:1"), 4)
+	contextResolver.trackContext(sample("a:2", "b:2"), 4)
+
+	// all the tag values of the contexts are known, they still go to overflow
+	// contexts, with the value of the tag with the most values replaced
+	key := contextResolver.trackContext(sample("a:1", "b:2"), 4)
+	context, ok := contextResolver.get(key)
+	require.True(t, ok)
+	assertContext(t, context, "my.metric", []string{"a:overflow", "b:2", "overflow:true"}, "")
+	key = contextResolver.trackContext(sample("b:1", "a:2"), 4)
+	context, ok = contextResolver.get(key)
+	require.True(t, ok)
+	assertContext(t, context, "my.metric", []string{"a:2", "b:overflow", "overflow:true"}, "")
+	assert.Equal(t, 4, contextResolver.length())
+	assert.Len(t, limiter.contexts, 2)
+}
+
+func TestContextLimiterRecombinedTags(t *testing.T) {
+	testWithTagsStore(t, testContextLimiterRecombinedTags)
+}
+
+func TestContextLimiterFromConfig(t *testing.T) {
+	cfg := configmock.New(t)
+	assert.Nil(t, newContextLimiterFromConfig(cfg, 1, "0"))
+
+	cfg.SetWithoutSource("dogstatsd_context_limiter.metric_limit", 10)
+	cfg.SetWithoutSource("dogstatsd_context_limiter.per_origin", true)
+	limiter := newContextLimiterFromConfig(cfg, 1, "0")
+	require.NotNil(t, limiter)
+	assert.Equal(t, 10, limiter.limit)
+	assert.True(t, limiter.perOrigin)
+	assert.Equal(t, "overflow", limiter.overflowValue)
+
+	// the limit is shared by the pipelines
+	assert.Equal(t, 4, newContextLimiterFromConfig(cfg, 3, "1").limit)
+}
+
+func TestContextLimiterStats(t *testing.T) {
+	now := time.Unix(1000, 0)
+	stats := newContextLimiterStats(func() time.Time { return now })
+	for i := 0; i < 3; i++ {
+		stats.overflow("b")
+	}
+	stats.overflow("a")
+	stats.overflow("c")
+
+	// the current window is not reported
+	total, offenders := stats.lastWindow(2)
+	assert.Zero(t, total)
+	assert.Empty(t, offenders)
+
+	now = now.Add(contextLimiterStatsWindow)
+	stats.overflow("a")
+	total, offenders = stats.lastWindow(2)
+	assert.Equal(t, uint64(5), total)
+	assert.Equal(t, []ContextLimiterOffender{{Metric: "b", OverflowedSamples: 3}, {Metric: "a", OverflowedSamples: 1}}, offenders)
+
+	// the windows without overflows are reported empty
+	now = now.Add(2 * contextLimiterStatsWindow)
+	total, offenders = stats.lastWindow(2)
+	assert.Zero(t, total)
+	assert.Empty(t, offenders)
+}
@@ -76,6 +76,10 @@ type contextResolver struct {
 	keyGenerator     *ckey.KeyGenerator
 	taggerBuffer     *tagset.HashingTagsAccumulator
 	metricBuffer     *tagset.HashingTagsAccumulator
+	// limiter caps the number of contexts of each metric name, nil if there is no limit.
+	limiter *contextLimiter
+	// rollup strips tags from the contexts of some metrics, nil if there are no rollup rules.
+	rollup *contextRollup
 }
 
 // generateContextKey generates the contextKey associated with the context of the metricSample
@@ -101,12 +105,49 @@ func newContextResolver(tagger tagger.Component, cache *tags.Store, id string) *
 
 // trackContext returns the contextKey associated with the context of the metricSample and tracks that context
 func (cr *contextResolver) trackContext(metricSampleContext metrics.MetricSampleContext, timestamp int64) ckey.ContextKey {
+	contextKey, _, _ := cr.trackSourceContext(metricSampleContext, timestamp)
+	return contextKey
+}
+
+// trackSourceContext is trackContext, also returning the key of the context of the
+// metricSample before rollup, and whether a rollup rule applies to it.
+func (cr *contextResolver) trackSourceContext(metricSampleContext metrics.MetricSampleContext, timestamp int64) (ckey.ContextKey, ckey.ContextKey, bool) {
 	metricSampleContext.GetTags(cr.taggerBuffer, cr.metricBuffer, cr.tagger.EnrichTags) // tags here are not sorted and can contain duplicates
 	defer cr.taggerBuffer.Reset()
 	defer cr.metricBuffer.Reset()
 
 	contextKey, taggerKey, metricKey := cr.generateContextKey(metricSampleContext) // the generator will remove duplicates (and doesn't mind the order)
 
+	rule := -1
+	sourceKey := contextKey
+	if cr.rollup != nil {
+		if rule = cr.rollup.rule(metricSampleContext.GetName(), metricSampleContext.GetMetricType()); rule >= 0 {
+			cr.rollup.apply(rule, cr.taggerBuffer, cr.metricBuffer)
+			contextKey, taggerKey, metricKey = cr.generateContextKey(metricSampleContext)
+		}
+	}
+
+	if _, ok := cr.contextsByKey[contextKey]; !ok && cr.limiter != nil {
+		name := metricSampleContext.GetName()
+		if overflowKey, ok := cr.limiter.overflowContext(contextKey); ok && cr.has(overflowKey) {
+			// The context has already been folded into an overflow context which is still tracked
+			cr.limiter.overflow(name)
+			contextKey = overflowKey
+		} else if !cr.limiter.admit(contextKey, name, taggerKey, cr.metricBuffer.Get()) {
+			// Fold the context into the overflow context of its metric
+			limitedKey := contextKey
+			overflowTags := cr.limiter.overflowTags(name, taggerKey, cr.metricBuffer.Get())
+			cr.metricBuffer.Reset()
+			cr.metricBuffer.Append(overflowTags...)
+			contextKey, taggerKey, metricKey = cr.generateContextKey(metricSampleContext)
+			cr.limiter.setOverflowContext(limitedKey, contextKey)
+		}
+	}
+
+	if rule >= 0 {
+		cr.rollup.track(sourceKey, contextKey, rule, metricSampleContext.GetMetricType(), timestamp)
+	}
+
 	if entry, ok := cr.contextsByKey[contextKey]; !ok {
 		mtype := metricSampleContext.GetMetricType()
 		context := &Context{
@@ -135,7 +176,7 @@ func (cr *contextResolver) trackContext(metricSampleContext metrics.MetricSample
 		}
 	}
 
-	return contextKey
+	return contextKey, sourceKey, rule >= 0
 }
 
 func (cr *contextResolver) get(key ckey.ContextKey) (*Context, bool) {
@@ -143,6 +184,11 @@ func (cr *contextResolver) get(key ckey.ContextKey) (*Context, bool) {
 	return ctx.context, found
 }
 
+func (cr *contextResolver) has(key ckey.ContextKey) bool {
+	_, found := cr.contextsByKey[key]
+	return found
+}
+
 func (cr *contextResolver) length() int {
 	return len(cr.contextsByKey)
 }
@@ -152,6 +198,9 @@ func (cr *contextResolver) remove(expiredContextKey ckey.ContextKey) {
 	delete(cr.contextsByKey, expiredContextKey)
 
 	if context != nil {
+		if cr.limiter != nil {
+			cr.limiter.remove(expiredContextKey, context.metricTags.Tags())
+		}
 		cr.countsByMtype[context.mtype]--
 		cr.bytesByMtype[context.mtype] -= uint64(context.SizeInBytes())
 		cr.dataBytesByMtype[context.mtype] -= uint64(context.DataSizeInBytes())
@@ -174,6 +223,12 @@ func (cr *contextResolver) updateMetrics(countsByMTypeGauge telemetry.Gauge, byt
 		bytesByMTypeGauge.Set(float64(bytes), cr.id, mtype, tags.BytesKindStruct)
 		bytesByMTypeGauge.Set(float64(dataBytes), cr.id, mtype, tags.BytesKindData)
 	}
+	if cr.limiter != nil {
+		cr.limiter.updateMetrics()
+	}
+	if cr.rollup != nil {
+		cr.rollup.updateMetrics()
+	}
 }
 
 func (cr *contextResolver) release() {
@@ -221,9 +276,12 @@ type timestampContextResolver struct {
 	counterExpireTime int64
 }
 
-func newTimestampContextResolver(tagger tagger.Component, cache *tags.Store, id string, contextExpireTime, counterExpireTime int64) *timestampContextResolver {
+func newTimestampContextResolver(tagger tagger.Component, cache *tags.Store, id string, contextExpireTime, counterExpireTime int64, limiter *contextLimiter, rollup *contextRollup) *timestampContextResolver {
+	resolver := newContextResolver(tagger, cache, id)
+	resolver.limiter = limiter
+	resolver.rollup = rollup
 	return &timestampContextResolver{
-		resolver: newContextResolver(tagger, cache, id),
+		resolver: resolver,
 
 		contextExpireTime: contextExpireTime,
 		counterExpireTime: counterExpireTime,
@@ -236,6 +294,12 @@ func (cr *timestampContextResolver) trackContext(metricSampleContext metrics.Met
 	return contextKey
 }
 
+// trackSourceContext returns the contextKey associated with the context of the metricSample, the
+// key of its context before rollup and whether it is rolled up, and tracks that context
+func (cr *timestampContextResolver) trackSourceContext(metricSampleContext metrics.MetricSampleContext, currentTimestamp int64) (ckey.ContextKey, ckey.ContextKey, bool) {
+	return cr.resolver.trackSourceContext(metricSampleContext, currentTimestamp)
+}
+
 func (cr *timestampContextResolver) length() int {
 	return cr.resolver.length()
 }
@@ -259,6 +323,9 @@ func (cr *timestampContextResolver) expireContexts(timestamp int64) {
 			cr.resolver.remove(ck)
 		}
 	}
+	if cr.resolver.rollup != nil {
+		cr.resolver.rollup.expire(timestamp, cr.contextExpireTime, cr.counterExpireTime)
+	}
 }
 
 func (cr *timestampContextResolver) sendOriginTelemetry(timestamp float64, series metrics.SerieSink, hostname string, tags []string) {
@@ -0,0 +1,407 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package aggregator
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"sync"
+
+	"github.com/DataDog/datadog-agent/pkg/aggregator/ckey"
+	"github.com/DataDog/datadog-agent/pkg/config/model"
+	"github.com/DataDog/datadog-agent/pkg/config/structure"
+	"github.com/DataDog/datadog-agent/pkg/metrics"
+	"github.com/DataDog/datadog-agent/pkg/tagset"
+	"github.com/DataDog/datadog-agent/pkg/telemetry"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+// maxRollupCachedNames is the maximum number of metric names whose matching rule is cached
+// by each contextRollup. The rules are matched on each sample of the other metrics.
+const maxRollupCachedNames = 100000
+
+var (
+	tlmRollupContextsSaved = telemetry.NewGauge("aggregator", "dogstatsd_rollup_contexts_saved",
+		[]string{"shard", "rule"}, "Number of dogstatsd contexts saved by each rollup rule")
+
+	rollupStats = newContextRollupStats()
+)
+
+// RollupRuleConfig is a rule of dogstatsd_rollup_rules.
+type RollupRuleConfig struct {
+	// Metric is a glob pattern, matched against the metric names with path.Match.
+	Metric string `mapstructure:"metric" json:"metric" yaml:"metric"`
+	// DropTags lists the tag names removed from the contexts of the matching metrics.
+	DropTags []string `mapstructure:"drop_tags" json:"drop_tags" yaml:"drop_tags"`
+	// KeepTags lists the only tag names kept in the contexts of the matching metrics.
+	KeepTags []string `mapstructure:"keep_tags" json:"keep_tags" yaml:"keep_tags"`
+}
+
+// rollupRule strips tags from the contexts of the metrics matching its pattern.
+type rollupRule struct {
+	metric string
+	// tagNames holds the tag names dropped, or the only ones kept if keep is true.
+	tagNames map[string]struct{}
+	keep     bool
+}
+
+func newRollupRule(config RollupRuleConfig) (*rollupRule, error) {
+	if config.Metric == "" {
+		return nil, errors.New("missing metric pattern")
+	}
+	if _, err := path.Match(config.Metric, ""); err != nil {
+		return nil, fmt.Errorf("invalid metric pattern %q: %v", config.Metric, err)
+	}
+	if (len(config.DropTags) == 0) == (len(config.KeepTags) == 0) {
+		return nil, fmt.Errorf("rule for %q must set exactly one of drop_tags and keep_tags", config.Metric)
+	}
+	rule := &rollupRule{
+		metric:   config.Metric,
+		tagNames: make(map[string]struct{}),
+		keep:     len(config.KeepTags) > 0,
+	}
+	names := config.DropTags
+	if rule.keep {
+		names = config.KeepTags
+	}
+	for _, name := range names {
+		rule.tagNames[name] = struct{}{}
+	}
+	return rule, nil
+}
+
+// match returns true if the rule applies to the metric name.
+func (r *rollupRule) match(name string) bool {
+	matched, _ := path.Match(r.metric, name)
+	return matched
+}
+
+// apply strips the tags of the rule from tags.
+func (r *rollupRule) apply(tags *tagset.HashingTagsAccumulator) {
+	tags.RetainFunc(func(tag string) bool {
+		name, _ := splitTag(tag)
+		_, listed := r.tagNames[name]
+		return listed == r.keep
+	})
+}
+
+// rollupRulesFromConfig returns the valid rules of dogstatsd_rollup_rules, logging the
+// invalid ones.
+func rollupRulesFromConfig(cfg model.Reader) []*rollupRule {
+	if !cfg.IsSet("dogstatsd_rollup_rules") {
+		return nil
+	}
+	var configs []RollupRuleConfig
+	if err := structure.UnmarshalKey(cfg, "dogstatsd_rollup_rules", &configs); err != nil {
+		log.Errorf("Could not parse dogstatsd_rollup_rules: %v", err)
+		return nil
+	}
+	rules := make([]*rollupRule, 0, len(configs))
+	for _, config := range configs {
+		rule, err := newRollupRule(config)
+		if err != nil {
+			log.Errorf("Ignoring invalid rule of dogstatsd_rollup_rules: %v", err)
+			continue
+		}
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+// rollupSource is a context folded into a rolled up context.
+type rollupSource struct {
+	rolledUp ckey.ContextKey
+	rule     int
+	mtype    metrics.MetricType
+	lastSeen int64
+}
+
+// contextRollup removes tags from the contexts of the metrics matching its rules before
+// their context key is generated, so that the contexts which only differ by these tags
+// are aggregated together. The first matching rule applies.
+//
+// The value of a rolled up gauge is the sum of the last value of each of its contexts in
+// the bucket. The rates and monotonic counts are not rolled up: their value is the
+// difference between the last samples of their context, so the samples of the contexts
+// aggregated together would be mixed up. A warning is logged the first time such a metric
+// matches a rule.
+//
+// It tracks the contexts folded into each rolled up context to report the number of
+// contexts saved by each rule.
+//
+// contextRollup is not thread-safe, each contextResolver has its own.
+type contextRollup struct {
+	rules []*rollupRule
+	shard string
+
+	// ruleByName caches the index of the rule matching each metric name, -1 if none.
+	ruleByName map[string]int
+	// unmergeable holds the names of the metrics matching a rule whose type can't be
+	// rolled up, to warn once about each of them.
+	unmergeable map[string]struct{}
+	// sources holds the contexts folded into a rolled up context, by their key before rollup.
+	sources map[ckey.ContextKey]rollupSource
+	// rolledUp counts the sources of each rolled up context.
+	rolledUp map[ckey.ContextKey]int
+	// sourcesByRule and contextsByRule count the sources and the rolled up contexts of each rule.
+	sourcesByRule  []int
+	contextsByRule []int
+	// gauges holds the last value of the rolled up gauges of each bucket.
+	gauges map[int64]*rollupGauges
+}
+
+// rollupGauges holds the last value of each source of the rolled up gauges of a bucket,
+// and their sum by rolled up context.
+type rollupGauges struct {
+	values map[ckey.ContextKey]rollupGaugeValue
+	sums   map[ckey.ContextKey]float64
+}
+
+// rollupGaugeValue is the last value of a source of a rolled up gauge.
+type rollupGaugeValue struct {
+	rolledUp ckey.ContextKey
+	value    float64
+}
+
+func newContextRollup(rules []*rollupRule, shard string) *contextRollup {
+	return &contextRollup{
+		rules:          rules,
+		shard:          shard,
+		ruleByName:     make(map[string]int),
+		unmergeable:    make(map[string]struct{}),
+		sources:        make(map[ckey.ContextKey]rollupSource),
+		rolledUp:       make(map[ckey.ContextKey]int),
+		sourcesByRule:  make([]int, len(rules)),
+		contextsByRule: make([]int, len(rules)),
+		gauges:         make(map[int64]*rollupGauges),
+	}
+}
+
+// rollupMergeable returns true if the samples of the contexts of the metric type can be
+// aggregated together.
+func rollupMergeable(mtype metrics.MetricType) bool {
+	switch mtype {
+	case metrics.RateType, metrics.MonotonicCountType:
+		return false
+	default:
+		return true
+	}
+}
+
+// rule returns the index of the first rule matching the metric name, -1 if none or if
+// the metric type can't be rolled up.
+func (r *contextRollup) rule(name string, mtype metrics.MetricType) int {
+	idx := r.matchingRule(name)
+	if idx < 0 || rollupMergeable(mtype) {
+		return idx
+	}
+	if _, ok := r.unmergeable[name]; !ok && len(r.unmergeable) < maxRollupCachedNames {
+		r.unmergeable[name] = struct{}{}
+		log.Warnf("Not rolling up the %s metric %q matching the dogstatsd_rollup_rules pattern %q: the samples of its contexts can't be aggregated together",
+			mtype, name, r.rules[idx].metric)
+	}
+	return -1
+}
+
+// matchingRule returns the index of the first rule matching the metric name, -1 if none.
+func (r *contextRollup) matchingRule(name string) int {
+	if idx, ok := r.ruleByName[name]; ok {
+		return idx
+	}
+	idx := -1
+	for i, rule := range r.rules {
+		if rule.match(name) {
+			idx = i
+			break
+		}
+	}
+	if len(r.ruleByName) < maxRollupCachedNames {
+		r.ruleByName[name] = idx
+	}
+	return idx
+}
+
+// apply strips the tags of the rule from the tagger and metric tags of a context.
+func (r *contextRollup) apply(rule int, taggerTags, metricTags *tagset.HashingTagsAccumulator) {
+	r.rules[rule].apply(taggerTags)
+	r.rules[rule].apply(metricTags)
+}
+
+// track records that the context whose key was source before rollup is aggregated into
+// the rolledUp context.
+func (r *contextRollup) track(source, rolledUp ckey.ContextKey, rule int, mtype metrics.MetricType, timestamp int64) {
+	entry, ok := r.sources[source]
+	if ok && entry.rolledUp == rolledUp {
+		entry.lastSeen = timestamp
+		r.sources[source] = entry
+		return
+	}
+	if ok {
+		// the source was folded into another context, e.g. an overflow context
+		r.untrack(source, entry)
+	}
+	r.sources[source] = rollupSource{rolledUp: rolledUp, rule: rule, mtype: mtype, lastSeen: timestamp}
+	r.sourcesByRule[rule]++
+	if r.rolledUp[rolledUp]++; r.rolledUp[rolledUp] == 1 {
+		r.contextsByRule[rule]++
+	}
+}
+
+func (r *contextRollup) untrack(source ckey.ContextKey, entry rollupSource) {
+	delete(r.sources, source)
+	r.sourcesByRule[entry.rule]--
+	if r.rolledUp[entry.rolledUp]--; r.rolledUp[entry.rolledUp] <= 0 {
+		delete(r.rolledUp, entry.rolledUp)
+		r.contextsByRule[entry.rule]--
+	}
+}
+
+// mergeGauge records the value of a sample of the gauge whose context was source before
+// rollup, and returns the value of the rolledUp context in the bucket: the sum of the last
+// value of each of its sources.
+func (r *contextRollup) mergeGauge(bucket int64, source, rolledUp ckey.ContextKey, value float64) float64 {
+	gauges, ok := r.gauges[bucket]
+	if !ok {
+		gauges = &rollupGauges{
+			values: make(map[ckey.ContextKey]rollupGaugeValue),
+			sums:   make(map[ckey.ContextKey]float64),
+		}
+		r.gauges[bucket] = gauges
+	}
+	if previous, ok := gauges.values[source]; ok {
+		// the source may have been folded into another context, e.g. an overflow context
+		gauges.sums[previous.rolledUp] -= previous.value
+	}
+	gauges.values[source] = rollupGaugeValue{rolledUp: rolledUp, value: value}
+	gauges.sums[rolledUp] += value
+	return gauges.sums[rolledUp]
+}
+
+// flushGauges forgets the values of the gauges of the flushed bucket.
+func (r *contextRollup) flushGauges(bucket int64) {
+	delete(r.gauges, bucket)
+}
+
+// expire stops tracking the sources which haven't been seen since their ttl, as the
+// contexts of the contextResolver.
+func (r *contextRollup) expire(timestamp, contextExpireTime, counterExpireTime int64) {
+	for source, entry := range r.sources {
+		ttl := contextExpireTime
+		if entry.mtype == metrics.CounterType {
+			ttl = counterExpireTime
+		}
+		if entry.lastSeen+ttl < timestamp {
+			r.untrack(source, entry)
+		}
+	}
+}
+
+// saved returns the number of contexts saved by the rule.
+func (r *contextRollup) saved(rule int) int {
+	return r.sourcesByRule[rule] - r.contextsByRule[rule]
+}
+
+func (r *contextRollup) updateMetrics() {
+	stats := make([]RollupRuleStats, len(r.rules))
+	for i, rule := range r.rules {
+		stats[i] = RollupRuleStats{
+			Metric:           rule.metric,
+			Contexts:         uint64(r.sourcesByRule[i]),
+			RolledUpContexts: uint64(r.contextsByRule[i]),
+			ContextsSaved:    uint64(r.saved(i)),
+		}
+		tlmRollupContextsSaved.Set(float64(r.saved(i)), r.shard, rule.metric)
+	}
+	rollupStats.set(r.shard, stats)
+}
+
+// RollupTagsFilter removes from the tags of the samples the tags removed by the rules of
+// dogstatsd_rollup_rules. The DogStatsD batchers shard the samples on the filtered tags, so
+// that the samples of the contexts rolled up together are aggregated by the same sampler.
+//
+// RollupTagsFilter is not thread-safe, each batcher has its own.
+type RollupTagsFilter struct {
+	rollup *contextRollup
+}
+
+// NewRollupTagsFilter returns the RollupTagsFilter of the dogstatsd_rollup_rules of cfg, or
+// nil if there are no rules.
+func NewRollupTagsFilter(cfg model.Reader) *RollupTagsFilter {
+	rules := rollupRulesFromConfig(cfg)
+	if len(rules) == 0 {
+		return nil
+	}
+	return &RollupTagsFilter{rollup: newContextRollup(rules, "")}
+}
+
+// Filter removes from tags the tags removed by the rule matching the metric, if any.
+func (f *RollupTagsFilter) Filter(name string, mtype metrics.MetricType, tags *tagset.HashingTagsAccumulator) {
+	// the rates and monotonic counts are not rolled up, the samplers warn about them
+	if rule := f.rollup.matchingRule(name); rule >= 0 && rollupMergeable(mtype) {
+		f.rollup.rules[rule].apply(tags)
+	}
+}
+
+// RollupRuleStats reports the contexts aggregated by a rule of dogstatsd_rollup_rules.
+type RollupRuleStats struct {
+	Metric string
+	// Contexts is the number of contexts matching the rule, before rollup.
+	Contexts uint64
+	// RolledUpContexts is the number of contexts they have been aggregated into.
+	RolledUpContexts uint64
+	// ContextsSaved is the difference between the two.
+	ContextsSaved uint64
+}
+
+// contextRollupStats holds the stats of the rollup rules of each shard, for the status page.
+type contextRollupStats struct {
+	mu      sync.Mutex
+	byShard map[string][]RollupRuleStats
+}
+
+func newContextRollupStats() *contextRollupStats {
+	return &contextRollupStats{byShard: make(map[string][]RollupRuleStats)}
+}
+
+func (s *contextRollupStats) set(shard string, stats []RollupRuleStats) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.byShard[shard] = stats
+}
+
+// rules returns the stats of each rule, summed over the shards.
+func (s *contextRollupStats) rules() []RollupRuleStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var total []RollupRuleStats
+	for _, stats := range s.byShard {
+		if total == nil {
+			total = make([]RollupRuleStats, len(stats))
+			for i, rule := range stats {
+				total[i].Metric = rule.Metric
+			}
+		}
+		for i := 0; i < len(stats) && i < len(total); i++ {
+			total[i].Contexts += stats[i].Contexts
+			total[i].RolledUpContexts += stats[i].RolledUpContexts
+			total[i].ContextsSaved += stats[i].ContextsSaved
+		}
+	}
+	return total
+}
+
+func (s *contextRollupStats) reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.byShard = make(map[string][]RollupRuleStats)
+}
+
+func expContextRollup() interface{} {
+	return map[string]interface{}{
+		"Rules": rollupStats.rules(),
+	}
+}
@@ -0,0 +1,192 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build test
+
+package aggregator
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	nooptagger "github.com/DataDog/datadog-agent/comp/core/tagger/impl-noop"
+	"github.com/DataDog/datadog-agent/pkg/aggregator/internal/tags"
+	configmock "github.com/DataDog/datadog-agent/pkg/config/mock"
+	"github.com/DataDog/datadog-agent/pkg/metrics"
+)
+
+func newTestRollupRules(t *testing.T, configs ...RollupRuleConfig) []*rollupRule {
+	var rules []*rollupRule
+	for _, config := range configs {
+		rule, err := newRollupRule(config)
+		require.NoError(t, err)
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+func testContextRollup(t *testing.T, store *tags.Store) {
+	rollupStats.reset()
+	defer rollupStats.reset()
+
+	rollup := newContextRollup(newTestRollupRules(t,
+		RollupRuleConfig{Metric: "http.*", DropTags: []string{"pod_name", "request_id"}},
+		RollupRuleConfig{Metric: "queue.depth", KeepTags: []string{"env", "queue"}},
+	), "test")
+	contextResolver := newTimestampContextResolver(nooptagger.NewComponent(), store, "test", 2, 4, nil, rollup)
+	sample := func(name string, tags ...string) *metrics.MetricSample {
+		return &metrics.MetricSample{Name: name, Mtype: metrics.CounterType, Tags: tags, SampleRate: 1}
+	}
+
+	key1 := contextResolver.trackContext(sample("http.requests", "env:prod", "pod_name:web-1", "request_id"), 4)
+	key2 := contextResolver.trackContext(sample("http.requests", "pod_name:web-2", "env:prod"), 4)
+	key3 := contextResolver.trackContext(sample("http.requests", "env:staging", "pod_name:web-1"), 4)
+	assert.Equal(t, key1, key2)
+	assert.NotEqual(t, key1, key3)
+	context, ok := contextResolver.get(key1)
+	require.True(t, ok)
+	assertContext(t, context, "http.requests", []string{"env:prod"}, "")
+
+	key4 := contextResolver.trackContext(sample("queue.depth", "env:prod", "queue:jobs", "pod_name:worker-1", "shard"), 4)
+	key5 := contextResolver.trackContext(sample("queue.depth", "queue:jobs", "pod_name:worker-2", "env:prod"), 4)
+	assert.Equal(t, key4, key5)
+	context, ok = contextResolver.get(key4)
+	require.True(t, ok)
+	assertContext(t, context, "queue.depth", []string{"env:prod", "queue:jobs"}, "")
+
+	// other metrics keep all their tags
+	key6 := contextResolver.trackContext(sample("queue.size", "pod_name:worker-1"), 4)
+	context, ok = contextResolver.get(key6)
+	require.True(t, ok)
+	assertContext(t, context, "queue.size", []string{"pod_name:worker-1"}, "")
+
+	assert.Equal(t, 4, contextResolver.length())
+	assert.Equal(t, 1, rollup.saved(0))
+	assert.Equal(t, 1, rollup.saved(1))
+	assert.Equal(t, 0, rollup.rule("http.errors", metrics.CounterType))
+	assert.Equal(t, -1, rollup.rule("queue.size", metrics.CounterType))
+
+	// the gauges are rolled up, the rates are not as their samples would be mixed up
+	gauge := sample("http.inflight", "env:prod", "pod_name:web-1")
+	gauge.Mtype = metrics.GaugeType
+	key7 := contextResolver.trackContext(gauge, 4)
+	context, ok = contextResolver.get(key7)
+	require.True(t, ok)
+	assertContext(t, context, "http.inflight", []string{"env:prod"}, "")
+	rate := sample("http.bytes", "env:prod", "pod_name:web-1")
+	rate.Mtype = metrics.RateType
+	key8 := contextResolver.trackContext(rate, 4)
+	context, ok = contextResolver.get(key8)
+	require.True(t, ok)
+	assertContext(t, context, "http.bytes", []string{"env:prod", "pod_name:web-1"}, "")
+	assert.Equal(t, -1, rollup.rule("http.bytes", metrics.RateType))
+	assert.Equal(t, 6, contextResolver.length())
+
+	contextResolver.updateMetrics(tlmDogstatsdContextsByMtype, tlmDogstatsdContextsBytesByMtype)
+	assert.Equal(t, []RollupRuleStats{
+		{Metric: "http.*", Contexts: 4, RolledUpContexts: 3, ContextsSaved: 1},
+		{Metric: "queue.depth", Contexts: 2, RolledUpContexts: 1, ContextsSaved: 1},
+	}, rollupStats.rules())
+
+	// the sources expire with the contexts
+	contextResolver.trackContext(sample("http.requests", "env:prod", "pod_name:web-1"), 6)
+	contextResolver.expireContexts(9)
+	assert.Equal(t, 1, contextResolver.length())
+	assert.Len(t, rollup.sources, 1)
+	assert.Equal(t, 0, rollup.saved(0))
+	assert.Equal(t, 0, rollup.saved(1))
+}
+
+func TestContextRollup(t *testing.T) {
+	testWithTagsStore(t, testContextRollup)
+}
+
+func TestNewRollupRule(t *testing.T) {
+	for name, config := range map[string]RollupRuleConfig{
+		"no-metric":  {DropTags: []string{"pod_name"}},
+		"bad-glob":   {Metric: "http.[", DropTags: []string{"pod_name"}},
+		"no-tags":    {Metric: "http.*"},
+		"both-lists": {Metric: "http.*", DropTags: []string{"pod_name"}, KeepTags: []string{"env"}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := newRollupRule(config)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestRollupRulesFromConfig(t *testing.T) {
+	cfg := configmock.New(t)
+	assert.Empty(t, rollupRulesFromConfig(cfg))
+
+	cfg.SetWithoutSource("dogstatsd_rollup_rules", []map[string]interface{}{
+		{"metric": "http.*", "drop_tags": []string{"pod_name"}},
+		{"metric": "invalid"},
+		{"metric": "queue.*", "keep_tags": []string{"env"}},
+	})
+	rules := rollupRulesFromConfig(cfg)
+	require.Len(t, rules, 2)
+	assert.Equal(t, "http.*", rules[0].metric)
+	assert.False(t, rules[0].keep)
+	assert.Equal(t, "queue.*", rules[1].metric)
+	assert.True(t, rules[1].keep)
+}
+
+func testTimeSamplerRollup(t *testing.T, store *tags.Store) {
+	rollupStats.reset()
+	defer rollupStats.reset()
+
+	sampler := testTimeSampler(store)
+	sampler.contextResolver.resolver.rollup = newContextRollup(newTestRollupRules(t,
+		RollupRuleConfig{Metric: "*", DropTags: []string{"pod_name"}},
+	), "0")
+
+	gauges := map[string]float64{"pod_name:web-1": 3, "pod_name:web-2": 5}
+	for _, pod := range []string{"pod_name:web-1", "pod_name:web-2"} {
+		sampler.sample(&metrics.MetricSample{Name: "my.count", Value: 2, Mtype: metrics.CountType, Tags: []string{"env:prod", pod}, SampleRate: 1}, 12346.0)
+		sampler.sample(&metrics.MetricSample{Name: "my.gauge", Value: 1, Mtype: metrics.GaugeType, Tags: []string{"env:prod", pod}, SampleRate: 1}, 12346.0)
+		sampler.sample(&metrics.MetricSample{Name: "my.gauge", Value: gauges[pod], Mtype: metrics.GaugeType, Tags: []string{"env:prod", pod}, SampleRate: 1}, 12347.0)
+		sampler.sample(&metrics.MetricSample{Name: "my.distribution", Value: 1, Mtype: metrics.DistributionType, Tags: []string{"env:prod", pod}, SampleRate: 1}, 12346.0)
+	}
+	// the sample of the next bucket is not added to the gauge of this one
+	sampler.sample(&metrics.MetricSample{Name: "my.gauge", Value: 10, Mtype: metrics.GaugeType, Tags: []string{"env:prod", "pod_name:web-1"}, SampleRate: 1}, 12351.0)
+
+	series, sketches := flushSerie(sampler, 12350.0)
+	require.Len(t, series, 2)
+	for _, serie := range series {
+		tags := serie.Tags.UnsafeToReadOnlySliceString()
+		require.Len(t, serie.Points, 1)
+		switch serie.Name {
+		case "my.count":
+			// the counts of the rolled up contexts are summed
+			assert.Equal(t, []string{"env:prod"}, tags)
+			assert.Equal(t, float64(4), serie.Points[0].Value)
+		case "my.gauge":
+			// the last values of the rolled up gauges are summed
+			assert.Equal(t, []string{"env:prod"}, tags)
+			assert.Equal(t, float64(8), serie.Points[0].Value)
+		default:
+			t.Errorf("unexpected serie %s", serie.Name)
+		}
+	}
+	// the samples of the distributions are merged into a single sketch
+	require.Len(t, sketches, 1)
+	require.Len(t, sketches[0].Points, 1)
+	assert.Equal(t, int64(2), sketches[0].Points[0].Sketch.Basic.Cnt)
+	assert.Equal(t, []RollupRuleStats{{Metric: "*", Contexts: 6, RolledUpContexts: 3, ContextsSaved: 3}}, rollupStats.rules())
+
+	// the gauges of the next bucket only sum the values received in that bucket
+	series, _ = flushSerie(sampler, 12360.0)
+	require.Len(t, series, 1)
+	assert.Equal(t, "my.gauge", series[0].Name)
+	assert.Equal(t, float64(10), series[0].Points[0].Value)
+	assert.Len(t, sampler.contextResolver.resolver.rollup.gauges, 0)
+}
+
+func TestTimeSamplerRollup(t *testing.T) {
+	testWithTagsStore(t, testTimeSamplerRollup)
+}
@@ -9,6 +9,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"strconv"
 	"sync"
 	"time"
 
@@ -79,6 +80,8 @@ type AgentDemultiplexerOptions struct {
 
 	DontStartForwarders bool // unit tests don't need the forwarders to be instanciated
 
+	// UseDogstatsdContextLimiter enables the dogstatsd_context_limiter settings
+	// on the DogStatsD pipelines.
 	UseDogstatsdContextLimiter bool
 	DogstatsdMaxMetricsTags    int
 }
@@ -176,6 +179,9 @@ func initAgentDemultiplexer(log log.Component,
 		tagsStore := tags.NewStore(pkgconfigsetup.Datadog().GetBool("aggregator_use_tags_store"), fmt.Sprintf("timesampler #%d", i))
 
 		statsdSampler := NewTimeSampler(TimeSamplerID(i), bucketSize, tagsStore, tagger, agg.hostname)
+		if options.UseDogstatsdContextLimiter {
+			statsdSampler.setContextLimiter(newContextLimiterFromConfig(pkgconfigsetup.Datadog(), statsdPipelinesCount, strconv.Itoa(i)))
+		}
 
 		// its worker (process loop + flush/serialization mechanism)
 
@@ -12,6 +12,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/fx"
 
@@ -28,6 +29,7 @@ import (
 	logscompression "github.com/DataDog/datadog-agent/comp/serializer/logscompression/fx-mock"
 	compression "github.com/DataDog/datadog-agent/comp/serializer/metricscompression/def"
 	metricscompression "github.com/DataDog/datadog-agent/comp/serializer/metricscompression/fx-mock"
+	configmock "github.com/DataDog/datadog-agent/pkg/config/mock"
 	"github.com/DataDog/datadog-agent/pkg/metrics"
 	"github.com/DataDog/datadog-agent/pkg/util/fxutil"
 )
@@ -127,6 +129,26 @@ func TestDemuxNoAggOptionIsDisabledByDefault(t *testing.T) {
 	demux.Stop(false)
 }
 
+func TestDemuxContextLimiterOption(t *testing.T) {
+	cfg := configmock.New(t)
+	cfg.SetWithoutSource("dogstatsd_context_limiter.metric_limit", 10)
+	deps := createDemultiplexerAgentTestDeps(t)
+
+	// the settings are ignored unless the option is enabled
+	opts := demuxTestOptions()
+	demux := initAgentDemultiplexer(deps.Log, NewForwarderTest(deps.Log), deps.OrchestratorFwd, opts, deps.EventPlatform, deps.HaAgent, deps.Compressor, deps.Tagger, "")
+	for _, worker := range demux.statsd.workers {
+		assert.Nil(t, worker.sampler.contextResolver.resolver.limiter)
+	}
+
+	opts.UseDogstatsdContextLimiter = true
+	demux = initAgentDemultiplexer(deps.Log, NewForwarderTest(deps.Log), deps.OrchestratorFwd, opts, deps.EventPlatform, deps.HaAgent, deps.Compressor, deps.Tagger, "")
+	for _, worker := range demux.statsd.workers {
+		require.NotNil(t, worker.sampler.contextResolver.resolver.limiter)
+		assert.Equal(t, (10+len(demux.statsd.workers)-1)/len(demux.statsd.workers), worker.sampler.contextResolver.resolver.limiter.limit)
+	}
+}
+
 func TestMetricSampleTypeConversion(t *testing.T) {
 	require := require.New(t)
 
@@ -56,9 +56,14 @@ func NewTimeSampler(id TimeSamplerID, interval int64, cache *tags.Store, tagger
 	contextExpireTime := pkgconfigsetup.Datadog().GetInt64("dogstatsd_context_expiry_seconds")
 	counterExpireTime := contextExpireTime + pkgconfigsetup.Datadog().GetInt64("dogstatsd_expiry_seconds")
 
+	var rollup *contextRollup
+	if rules := rollupRulesFromConfig(pkgconfigsetup.Datadog()); len(rules) > 0 {
+		rollup = newContextRollup(rules, idString)
+	}
+
 	s := &TimeSampler{
 		interval:           interval,
-		contextResolver:    newTimestampContextResolver(tagger, cache, idString, contextExpireTime, counterExpireTime),
+		contextResolver:    newTimestampContextResolver(tagger, cache, idString, contextExpireTime, counterExpireTime, nil, rollup),
 		metricsByTimestamp: map[int64]metrics.ContextMetrics{},
 		sketchMap:          make(sketchMap),
 		id:                 id,
@@ -69,6 +74,12 @@ func NewTimeSampler(id TimeSamplerID, interval int64, cache *tags.Store, tagger
 	return s
 }
 
+// setContextLimiter sets the limiter capping the number of contexts of each
+// metric name, nil to disable it.
+func (s *TimeSampler) setContextLimiter(limiter *contextLimiter) {
+	s.contextResolver.resolver.limiter = limiter
+}
+
 func (s *TimeSampler) calculateBucketStart(timestamp float64) int64 {
 	return int64(timestamp) - int64(timestamp)%s.interval
 }
@@ -84,9 +95,16 @@ func (s *TimeSampler) sample(metricSample *metrics.MetricSample, timestamp float
 	}
 
 	// Keep track of the context
-	contextKey := s.contextResolver.trackContext(metricSample, int64(timestamp))
+	contextKey, sourceKey, rolledUp := s.contextResolver.trackSourceContext(metricSample, int64(timestamp))
 	bucketStart := s.calculateBucketStart(timestamp)
 
+	if rolledUp && metricSample.Mtype == metrics.GaugeType {
+		// the value of a rolled up gauge is the sum of the last value of its contexts
+		gauge := *metricSample
+		gauge.Value = s.contextResolver.resolver.rollup.mergeGauge(bucketStart, sourceKey, contextKey, metricSample.Value)
+		metricSample = &gauge
+	}
+
 	switch metricSample.Mtype {
 	case metrics.DistributionType:
 		s.sketchMap.insert(bucketStart, contextKey, metricSample.Value, metricSample.SampleRate)
@@ -141,6 +159,9 @@ func (s *TimeSampler) flushSeries(cutoffTime int64, series metrics.SerieSink) {
 			contextMetricsFlusher.Append(float64(bucketTimestamp), contextMetrics)
 
 			delete(s.metricsByTimestamp, bucketTimestamp)
+			if s.contextResolver.resolver.rollup != nil {
+				s.contextResolver.resolver.rollup.flushGauges(bucketTimestamp)
+			}
 		}
 	} else if s.lastCutOffTime+s.interval <= cutoffTime {
 		// Even if there is no metric in this flush, recreate empty counters,
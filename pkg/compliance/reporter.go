@@ -56,6 +56,8 @@ func NewLogReporter(hostname string, sourceName, sourceType string, endpoints *c
 		hostnameimpl.NewHostnameService(),
 		cfg,
 		compression,
+		nil, // metricSender
+		nil, // digitMasker
 		cfg.GetBool("logs_config.disable_distributed_senders"),
 		false, // serverless
 	)
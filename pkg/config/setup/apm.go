@@ -45,6 +45,7 @@ func setupAPM(config pkgconfigmodel.Setup) {
 	config.BindEnvAndSetDefault("apm_config.obfuscation.valkey.remove_all_args", false, "DD_APM_OBFUSCATION_VALKEY_REMOVE_ALL_ARGS")
 	config.BindEnvAndSetDefault("apm_config.obfuscation.memcached.enabled", true, "DD_APM_OBFUSCATION_MEMCACHED_ENABLED")
 	config.BindEnvAndSetDefault("apm_config.obfuscation.memcached.keep_command", false, "DD_APM_OBFUSCATION_MEMCACHED_KEEP_COMMAND")
+	config.BindEnvAndSetDefault("apm_config.obfuscation.graphql.enabled", true, "DD_APM_OBFUSCATION_GRAPHQL_ENABLED")
 	config.BindEnvAndSetDefault("apm_config.obfuscation.cache.enabled", true, "DD_APM_OBFUSCATION_CACHE_ENABLED")
 	config.BindEnvAndSetDefault("apm_config.obfuscation.cache.max_size", 5000000, "DD_APM_OBFUSCATION_CACHE_MAX_SIZE")
 	config.SetKnown("apm_config.filter_tags.require")
@@ -130,6 +131,8 @@ func setupAPM(config pkgconfigmodel.Setup) {
 	config.BindEnv("apm_config.max_traces_per_second", "DD_APM_MAX_TPS", "DD_MAX_TPS") // deprecated
 	config.BindEnv("apm_config.target_traces_per_second", "DD_APM_TARGET_TPS")
 	config.BindEnv("apm_config.errors_per_second", "DD_APM_ERROR_TPS")
+	config.BindEnv("apm_config.target_tps_budget.enabled", "DD_APM_TARGET_TPS_BUDGET_ENABLED")
+	config.BindEnv("apm_config.target_tps_budget.min_tps", "DD_APM_TARGET_TPS_BUDGET_MIN_TPS")
 	config.BindEnv("apm_config.enable_rare_sampler", "DD_APM_ENABLE_RARE_SAMPLER")
 	config.BindEnv("apm_config.disable_rare_sampler", "DD_APM_DISABLE_RARE_SAMPLER") // Deprecated
 	config.BindEnv("apm_config.max_remote_traces_per_second", "DD_APM_MAX_REMOTE_TPS")
@@ -137,6 +140,22 @@ func setupAPM(config pkgconfigmodel.Setup) {
 	config.BindEnv("apm_config.probabilistic_sampler.sampling_percentage", "DD_APM_PROBABILISTIC_SAMPLER_SAMPLING_PERCENTAGE")
 	config.BindEnv("apm_config.probabilistic_sampler.hash_seed", "DD_APM_PROBABILISTIC_SAMPLER_HASH_SEED")
 	config.BindEnvAndSetDefault("apm_config.error_tracking_standalone.enabled", false, "DD_APM_ERROR_TRACKING_STANDALONE_ENABLED")
+	config.BindEnv("apm_config.zipkin_receiver.enabled", "DD_APM_ZIPKIN_RECEIVER_ENABLED")
+	config.BindEnv("apm_config.jaeger_receiver.enabled", "DD_APM_JAEGER_RECEIVER_ENABLED")
+	config.BindEnv("apm_config.span_derived_metrics.enabled", "DD_APM_SPAN_DERIVED_METRICS_ENABLED")
+	config.BindEnvAndSetDefault("apm_config.span_derived_metrics.dimensions", []string{}, "DD_APM_SPAN_DERIVED_METRICS_DIMENSIONS")
+	config.BindEnv("apm_config.span_derived_metrics.max_contexts", "DD_APM_SPAN_DERIVED_METRICS_MAX_CONTEXTS")
+	config.BindEnv("apm_config.tail_sampler.enabled", "DD_APM_TAIL_SAMPLER_ENABLED")
+	config.BindEnv("apm_config.tail_sampler.window", "DD_APM_TAIL_SAMPLER_WINDOW")
+	config.BindEnv("apm_config.tail_sampler.max_buffer_bytes", "DD_APM_TAIL_SAMPLER_MAX_BUFFER_BYTES")
+	config.BindEnvAndSetDefault("apm_config.tail_sampler.policies", []interface{}{}, "DD_APM_TAIL_SAMPLER_POLICIES")
+	config.ParseEnvAsSlice("apm_config.tail_sampler.policies", func(in string) []interface{} {
+		var policies []interface{}
+		if err := json.Unmarshal([]byte(in), &policies); err != nil {
+			log.Errorf(`"apm_config.tail_sampler.policies" can not be parsed: %v`, err)
+		}
+		return policies
+	})
 
 	config.BindEnv("apm_config.max_memory", "DD_APM_MAX_MEMORY")
 	config.BindEnv("apm_config.max_cpu_percent", "DD_APM_MAX_CPU_PERCENT")
@@ -146,10 +165,35 @@ func setupAPM(config pkgconfigmodel.Setup) {
 	config.BindEnv("apm_config.connection_limit", "DD_APM_CONNECTION_LIMIT", "DD_CONNECTION_LIMIT")
 	config.BindEnv("apm_config.connection_reset_interval", "DD_APM_CONNECTION_RESET_INTERVAL")
 	config.BindEnv("apm_config.max_sender_retries", "DD_APM_MAX_SENDER_RETRIES")
+	config.BindEnv("apm_config.spool.enabled", "DD_APM_SPOOL_ENABLED")
+	config.BindEnv("apm_config.spool.dir", "DD_APM_SPOOL_DIR")
+	config.BindEnv("apm_config.spool.max_bytes", "DD_APM_SPOOL_MAX_BYTES")
+	config.BindEnv("apm_config.spool.max_age", "DD_APM_SPOOL_MAX_AGE")
+	config.BindEnv("apm_config.otlp_exporter.enabled", "DD_APM_OTLP_EXPORTER_ENABLED")
+	config.BindEnv("apm_config.otlp_exporter.protocol", "DD_APM_OTLP_EXPORTER_PROTOCOL")
+	config.BindEnv("apm_config.otlp_exporter.endpoint", "DD_APM_OTLP_EXPORTER_ENDPOINT")
+	config.BindEnv("apm_config.otlp_exporter.insecure", "DD_APM_OTLP_EXPORTER_INSECURE")
+	config.BindEnv("apm_config.otlp_exporter.timeout", "DD_APM_OTLP_EXPORTER_TIMEOUT")
+	config.BindEnvAndSetDefault("apm_config.otlp_exporter.headers", map[string]string{}, "DD_APM_OTLP_EXPORTER_HEADERS")
+	config.ParseEnvAsMapStringInterface("apm_config.otlp_exporter.headers", func(in string) map[string]interface{} {
+		var headers map[string]interface{}
+		if err := json.Unmarshal([]byte(in), &headers); err != nil {
+			log.Errorf(`"apm_config.otlp_exporter.headers" can not be parsed: %v`, err)
+		}
+		return headers
+	})
 	config.BindEnv("apm_config.profiling_dd_url", "DD_APM_PROFILING_DD_URL")
 	config.BindEnv("apm_config.profiling_additional_endpoints", "DD_APM_PROFILING_ADDITIONAL_ENDPOINTS")
 	config.BindEnv("apm_config.additional_endpoints", "DD_APM_ADDITIONAL_ENDPOINTS")
 	config.BindEnv("apm_config.replace_tags", "DD_APM_REPLACE_TAGS")
+	config.BindEnvAndSetDefault("apm_config.span_rules", []interface{}{}, "DD_APM_SPAN_RULES")
+	config.ParseEnvAsSlice("apm_config.span_rules", func(in string) []interface{} {
+		var rules []interface{}
+		if err := json.Unmarshal([]byte(in), &rules); err != nil {
+			log.Errorf(`"apm_config.span_rules" can not be parsed: %v`, err)
+		}
+		return rules
+	})
 	config.BindEnv("apm_config.analyzed_spans", "DD_APM_ANALYZED_SPANS")
 	config.BindEnv("apm_config.ignore_resources", "DD_APM_IGNORE_RESOURCES", "DD_IGNORE_RESOURCE")
 	config.BindEnv("apm_config.instrumentation.targets", "DD_APM_INSTRUMENTATION_TARGETS")
@@ -187,6 +231,9 @@ func setupAPM(config pkgconfigmodel.Setup) {
 	config.BindEnvAndSetDefault("apm_config.obfuscation.credit_cards.luhn", false, "DD_APM_OBFUSCATION_CREDIT_CARDS_LUHN")
 	config.BindEnvAndSetDefault("apm_config.obfuscation.credit_cards.keep_values", []string{}, "DD_APM_OBFUSCATION_CREDIT_CARDS_KEEP_VALUES")
 	config.BindEnvAndSetDefault("apm_config.sql_obfuscation_mode", "", "DD_APM_SQL_OBFUSCATION_MODE")
+	config.BindEnv("apm_config.sds.enabled", "DD_APM_SDS_ENABLED")
+	config.BindEnvAndSetDefault("apm_config.sds.meta_keys", []string{}, "DD_APM_SDS_META_KEYS")
+	config.BindEnv("apm_config.sds.span_events", "DD_APM_SDS_SPAN_EVENTS")
 	config.BindEnvAndSetDefault("apm_config.debug.port", 5012, "DD_APM_DEBUG_PORT")
 	config.BindEnv("apm_config.features", "DD_APM_FEATURES")
 	config.ParseEnvAsStringSlice("apm_config.features", func(s string) []string {
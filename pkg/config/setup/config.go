@@ -1426,6 +1426,11 @@ func dogstatsd(config pkgconfigmodel.Setup) {
 	// Options are: udp, uds, named_pipe
 	config.BindEnvAndSetDefault("dogstatsd_eol_required", []string{})
 
+	// The Prometheus remote-write listener is disabled when the port is 0.
+	config.BindEnvAndSetDefault("dogstatsd_remote_write_port", 0)
+	config.BindEnvAndSetDefault("dogstatsd_remote_write_max_body_size", 10*1024*1024)
+	config.BindEnvAndSetDefault("dogstatsd_remote_write_max_decoded_size", 50*1024*1024)
+
 	// The following options allow to configure how the dogstatsd intake buffers and queues incoming datagrams.
 	// When a datagram is received it is first added to a datagrams buffer. This buffer fills up until
 	// we reach `dogstatsd_packet_buffer_size` datagrams or after `dogstatsd_packet_buffer_flush_timeout` ms.
@@ -1454,6 +1459,19 @@ func dogstatsd(config pkgconfigmodel.Setup) {
 	config.BindEnvAndSetDefault("dogstatsd_expiry_seconds", 300)
 	// Control how long we keep dogstatsd contexts in memory.
 	config.BindEnvAndSetDefault("dogstatsd_context_expiry_seconds", 20)
+	// Cap the number of dogstatsd contexts of each metric name, 0 means no limit.
+	config.BindEnvAndSetDefault("dogstatsd_context_limiter.metric_limit", 0)
+	config.BindEnvAndSetDefault("dogstatsd_context_limiter.per_origin", false)
+	config.BindEnvAndSetDefault("dogstatsd_context_limiter.overflow_tag_value", "overflow")
+	// Strip tags from the dogstatsd contexts of some metrics, to aggregate them together.
+	config.BindEnv("dogstatsd_rollup_rules")
+	config.ParseEnvAsSlice("dogstatsd_rollup_rules", func(in string) []interface{} {
+		var rules []interface{}
+		if err := json.Unmarshal([]byte(in), &rules); err != nil {
+			log.Errorf(`"dogstatsd_rollup_rules" can not be parsed: %v`, err)
+		}
+		return rules
+	})
 	config.BindEnvAndSetDefault("dogstatsd_origin_detection", false) // Only supported for socket traffic
 	config.BindEnvAndSetDefault("dogstatsd_origin_detection_client", false)
 	config.BindEnvAndSetDefault("dogstatsd_origin_optout_enabled", true)
@@ -1576,6 +1594,11 @@ func logsagent(config pkgconfigmodel.Setup) {
 	config.BindEnvAndSetDefault("logs_config.kubelet_api_client_read_timeout", "30s")
 	// Internal Use Only: avoid modifying those configuration parameters, this could lead to unexpected results.
 	config.BindEnvAndSetDefault("logs_config.run_path", defaultRunPath)
+
+	// Disk spool of the payloads which can't be sent while the intake is unreachable
+	config.BindEnvAndSetDefault("logs_config.disk_spool.path", "")
+	config.BindEnvAndSetDefault("logs_config.disk_spool.max_size_in_bytes", 0) // 0 means disabled.
+	config.BindEnvAndSetDefault("logs_config.disk_spool.max_age", 6*time.Hour)
 	// DEPRECATED in favor of `logs_config.force_use_http`.
 	config.BindEnvAndSetDefault("logs_config.use_http", false)
 	config.BindEnvAndSetDefault("logs_config.force_use_http", false)
@@ -1674,6 +1697,10 @@ func logsagent(config pkgconfigmodel.Setup) {
 	// more disk I/O at the wildcard log paths
 	config.BindEnvAndSetDefault("logs_config.file_wildcard_selection_mode", "by_name")
 
+	// When enabled, the gzip and zstd compressed files matching the logs sources
+	// are decompressed and read once, e.g. to finish the files compressed by logrotate.
+	config.BindEnvAndSetDefault("logs_config.tail_compressed_files", false)
+
 	// Max size in MB an integration logs file can use
 	config.BindEnvAndSetDefault("logs_config.integrations_logs_files_max_size", 10)
 	// Max disk usage in MB all integrations logs files are allowed to use in total
@@ -1685,6 +1712,12 @@ func logsagent(config pkgconfigmodel.Setup) {
 	config.BindEnvAndSetDefault("logs_config.sds.wait_for_configuration", "")
 	config.BindEnvAndSetDefault("logs_config.sds.buffer_max_size", 0)
 
+	// Deduplication of the identical logs received in a short time window
+	config.BindEnvAndSetDefault("logs_config.dedup.enabled", false)
+	config.BindEnvAndSetDefault("logs_config.dedup.window", 10*time.Second)
+	config.BindEnvAndSetDefault("logs_config.dedup.mask_digits", false)
+	config.BindEnvAndSetDefault("logs_config.dedup.max_entries", 10000)
+
 	// Max size in MB to allow for integrations logs files
 	config.BindEnvAndSetDefault("logs_config.integrations_logs_files_max_size", 100)
 
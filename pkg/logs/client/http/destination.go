@@ -37,6 +37,7 @@ const (
 	TextContentType     = "text/plain"
 	JSONContentType     = "application/json"
 	ProtobufContentType = "application/x-protobuf"
+	NDJSONContentType   = "application/x-ndjson"
 )
 
 // HTTP errors.
@@ -67,6 +68,7 @@ type Destination struct {
 	// Config
 	url                 string
 	endpoint            config.Endpoint
+	encodingKey         string
 	contentType         string
 	host                string
 	client              *httputils.ResetClient
@@ -86,6 +88,11 @@ type Destination struct {
 	shouldRetry    bool
 	lastRetryError error
 
+	// spool stores the payloads on disk while the intake is unreachable, nil when disabled.
+	spool     *diskSpool
+	spoolStop chan struct{}
+	spoolDone chan struct{}
+
 	// Telemetry
 	expVars         *expvar.Map
 	destMeta        *client.DestinationMetadata
@@ -148,10 +155,23 @@ func newDestination(endpoint config.Endpoint,
 
 	workerPool := newDefaultWorkerPool(minConcurrency, maxConcurrency, destMeta)
 
+	var spool *diskSpool
+	if shouldRetry && destMeta.ReportingEnabled {
+		spool = newDiskSpool(cfg, destMeta.TelemetryName())
+	}
+
+	switch endpoint.Format {
+	case config.NDJSONIntakeFormat:
+		contentType = NDJSONContentType
+	case config.OTLPHTTPIntakeFormat:
+		contentType = JSONContentType
+	}
+
 	return &Destination{
-		host:                endpoint.Host,
+		host:                buildHost(endpoint),
 		url:                 buildURL(endpoint),
 		endpoint:            endpoint,
+		encodingKey:         endpoint.EncodingKey(),
 		contentType:         contentType,
 		client:              httputils.NewResetClient(endpoint.ConnectionResetInterval, httpClientFactory(timeout, cfg)),
 		destinationsContext: destinationsContext,
@@ -163,6 +183,9 @@ func newDestination(endpoint config.Endpoint,
 		lastRetryError:      nil,
 		retryLock:           sync.Mutex{},
 		shouldRetry:         shouldRetry,
+		spool:               spool,
+		spoolStop:           make(chan struct{}),
+		spoolDone:           make(chan struct{}),
 		expVars:             expVars,
 		destMeta:            destMeta,
 		isMRF:               endpoint.IsMRF,
@@ -199,6 +222,9 @@ func (d *Destination) Metadata() *client.DestinationMetadata {
 // Start starts reading the input channel
 func (d *Destination) Start(input chan *message.Payload, output chan *message.Payload, isRetrying chan bool) (stopChan <-chan struct{}) {
 	stop := make(chan struct{})
+	if d.spool != nil {
+		go d.drainSpool(output)
+	}
 	go d.run(input, output, stop, isRetrying)
 	return stop
 }
@@ -213,7 +239,22 @@ func (d *Destination) run(input chan *message.Payload, output chan *message.Payl
 		tlmIdle.Add(idle, d.destMeta.TelemetryName())
 		var startInUse = time.Now()
 
-		d.sendConcurrent(p, output, isRetrying)
+		if formatted, ok := d.formatPayload(p); !ok {
+			// the payload couldn't be encoded for this endpoint, retrying won't help.
+			log.Warnf("%s: dropping a payload which couldn't be encoded in the %s format", d.url, d.endpoint.Format)
+			tlmDropped.Inc()
+			output <- p
+		} else if formatted == nil {
+			// none of the logs of the payload are sent to this endpoint
+			output <- p
+		} else if d.spool != nil && !d.spool.isEmpty() {
+			// keep the payloads in order while the spool is drained: the
+			// pending sends are either done or stored in the spool first
+			d.wg.Wait()
+			d.spoolPayload(formatted, output, isRetrying)
+		} else {
+			d.sendConcurrent(formatted, output, isRetrying)
+		}
 
 		inUse := float64(time.Since(startInUse) / time.Millisecond)
 		d.expVars.AddFloat(expVarInUseMsMapKey, inUse)
@@ -223,11 +264,39 @@ func (d *Destination) run(input chan *message.Payload, output chan *message.Payl
 	}
 	// Wait for any pending concurrent sends to finish or terminate
 	d.wg.Wait()
+	if d.spool != nil {
+		close(d.spoolStop)
+		<-d.spoolDone
+	}
 
 	d.updateRetryState(nil, isRetrying)
 	stopChan <- struct{}{}
 }
 
+// formatPayload returns the payload to send to the endpoint. The payloads of
+// the endpoints using another format or compression than the main endpoint, or
+// receiving a subset of the logs, are encoded by the batch strategy. ok is false
+// if the payload doesn't hold the variant of the endpoint, the payload is nil if
+// none of its logs are sent to the endpoint.
+func (d *Destination) formatPayload(payload *message.Payload) (*message.Payload, bool) {
+	if d.encodingKey == "" {
+		return payload, true
+	}
+	variant, ok := payload.Variants[d.encodingKey]
+	if !ok {
+		return nil, false
+	}
+	if variant.Count == 0 {
+		return nil, true
+	}
+	return &message.Payload{
+		MessageMetas:  payload.MessageMetas,
+		Encoded:       variant.Encoded,
+		Encoding:      variant.Encoding,
+		UnencodedSize: variant.UnencodedSize,
+	}, true
+}
+
 func (d *Destination) sendConcurrent(payload *message.Payload, output chan *message.Payload, isRetrying chan bool) {
 	d.wg.Add(1)
 	d.workerPool.run(func() destinationResult {
@@ -279,6 +348,11 @@ func (d *Destination) sendAndRetry(payload *message.Payload, output chan *messag
 		}
 
 		if d.shouldRetry {
+			if _, ok := err.(*client.RetryableError); ok && d.spool != nil && d.spool.store(payload) {
+				// the payload is sent by drainSpool once the intake recovers
+				d.updateBackoff(err)
+				return result
+			}
 			if d.updateRetryState(err, isRetrying) {
 				continue
 			}
@@ -325,9 +399,14 @@ func (d *Destination) unconditionalSend(payload *message.Payload) (err error) {
 		// this can happen when the method or the url are valid.
 		return err
 	}
-	req.Header.Set("DD-API-KEY", d.endpoint.GetAPIKey())
+	if d.endpoint.Format == config.DefaultIntakeFormat {
+		req.Header.Set("DD-API-KEY", d.endpoint.GetAPIKey())
+	}
 	req.Header.Set("Content-Type", d.contentType)
 	req.Header.Set("User-Agent", fmt.Sprintf("datadog-agent/%s", version.AgentVersion))
+	for name, value := range d.endpoint.Headers {
+		req.Header.Set(name, value)
+	}
 
 	if payload.Encoding != "" {
 		req.Header.Set("Content-Encoding", payload.Encoding)
@@ -445,8 +524,21 @@ func httpClientFactory(timeout time.Duration, cfg pkgconfigmodel.Reader) func()
 	}
 }
 
+// buildHost returns the host of a config endpoint.
+func buildHost(endpoint config.Endpoint) string {
+	if endpoint.URL != "" {
+		if u, err := url.Parse(endpoint.URL); err == nil {
+			return u.Host
+		}
+	}
+	return endpoint.Host
+}
+
 // buildURL buils a url from a config endpoint.
 func buildURL(endpoint config.Endpoint) string {
+	if endpoint.URL != "" {
+		return endpoint.URL
+	}
 	var scheme string
 	if endpoint.UseSSL() {
 		scheme = "https"
@@ -7,13 +7,16 @@ package http
 
 import (
 	"errors"
+	"io"
 	"net/http"
+	"net/http/httptest"
 	"regexp"
 	"strconv"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"github.com/DataDog/datadog-agent/pkg/logs/client"
 	"github.com/DataDog/datadog-agent/pkg/logs/message"
@@ -692,3 +695,78 @@ func TestDestinationCompression(t *testing.T) {
 	assert.Len(t, metric, 2)
 	assert.Equal(t, "gzip", metric[0].Tags()["compression_kind"])
 }
+
+func TestDestinationSendsGenericFormat(t *testing.T) {
+	requests := make(chan *http.Request, 1)
+	bodies := make(chan string, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests <- r
+		bodies <- string(body)
+	}))
+	defer server.Close()
+
+	cfg := configmock.New(t)
+	endpoint := config.NewEndpoint("secret", "", "", 0, false)
+	endpoint.Format = config.NDJSONIntakeFormat
+	endpoint.URL = server.URL + "/logs"
+	endpoint.Headers = map[string]string{"Authorization": "Bearer token"}
+
+	destCtx := client.NewDestinationsContext()
+	destCtx.Start()
+	defer destCtx.Stop()
+	dest := NewDestination(endpoint, JSONContentType, destCtx, false, client.NewNoopDestinationMetadata(), cfg, 1, 1, metrics.NewNoopPipelineMonitor(""))
+	assert.Equal(t, endpoint.URL, dest.Target())
+
+	_, ok := dest.formatPayload(&message.Payload{Encoded: []byte("[]")})
+	assert.False(t, ok)
+	payload, ok := dest.formatPayload(&message.Payload{
+		Encoded: []byte("[]"),
+		Variants: map[string]*message.PayloadVariant{
+			endpoint.EncodingKey(): {Encoded: []byte(`{"message":"first"}` + "\n"), Count: 1},
+		},
+	})
+	require.True(t, ok)
+	require.NoError(t, dest.unconditionalSend(payload))
+	request := <-requests
+	assert.Equal(t, "/logs", request.URL.Path)
+	assert.Equal(t, NDJSONContentType, request.Header.Get("Content-Type"))
+	assert.Equal(t, "Bearer token", request.Header.Get("Authorization"))
+	assert.Empty(t, request.Header.Get("DD-API-KEY"))
+	assert.Equal(t, `{"message":"first"}`+"\n", <-bodies)
+}
+
+func TestDestinationSkipsFilteredPayloads(t *testing.T) {
+	requests := make(chan *http.Request, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		requests <- r
+	}))
+	defer server.Close()
+
+	cfg := configmock.New(t)
+	endpoint := config.NewEndpoint("secret", "", "", 0, false)
+	endpoint.URL = server.URL
+	endpoint.Services = []string{"db"}
+
+	destCtx := client.NewDestinationsContext()
+	destCtx.Start()
+	defer destCtx.Stop()
+	dest := NewDestination(endpoint, JSONContentType, destCtx, false, client.NewNoopDestinationMetadata(), cfg, 1, 1, metrics.NewNoopPipelineMonitor(""))
+
+	input := make(chan *message.Payload)
+	output := make(chan *message.Payload, 1)
+	stop := dest.Start(input, output, nil)
+
+	// none of the logs of the payload are sent to the endpoint, the payload is
+	// still reported as sent so that its offsets are committed
+	payload := &message.Payload{
+		Encoded:  []byte(`[{"message":"first"}]`),
+		Variants: map[string]*message.PayloadVariant{endpoint.EncodingKey(): {}},
+	}
+	input <- payload
+	assert.Equal(t, payload, <-output)
+	assert.Empty(t, requests)
+
+	close(input)
+	<-stop
+}
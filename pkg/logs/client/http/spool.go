@@ -0,0 +1,440 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/DataDog/datadog-agent/comp/logs/agent/config"
+	pkgconfigmodel "github.com/DataDog/datadog-agent/pkg/config/model"
+	"github.com/DataDog/datadog-agent/pkg/logs/client"
+	"github.com/DataDog/datadog-agent/pkg/logs/message"
+	"github.com/DataDog/datadog-agent/pkg/logs/metrics"
+	"github.com/DataDog/datadog-agent/pkg/logs/sources"
+	"github.com/DataDog/datadog-agent/pkg/telemetry"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+const (
+	spoolFileExtension    = ".payload"
+	spoolOffsetsExtension = ".offsets"
+)
+
+var (
+	errSpoolFull = errors.New("disk spool full")
+
+	tlmSpoolStored  = telemetry.NewCounter("logs_client_http_destination", "spool_stored", []string{"destination"}, "Payloads stored on disk while the intake is unreachable")
+	tlmSpoolDropped = telemetry.NewCounter("logs_client_http_destination", "spool_dropped", []string{"destination"}, "Payloads dropped from the disk spool because they are too old")
+	tlmSpoolSize    = telemetry.NewGauge("logs_client_http_destination", "spool_size_bytes", []string{"destination"}, "Size of the payloads stored on disk")
+)
+
+// diskSpool stores on disk, in order, the payloads which couldn't be sent
+// because the intake is unreachable, so that they are sent once the intake
+// recovers without applying back pressure on the pipeline.
+//
+// The encoded payloads and their content encoding are stored on disk, along
+// with the offsets of their messages, so that the auditor commits them once the
+// payloads are sent, including the payloads stored by a previous run which are
+// sent again on startup. The auditor ignores the offsets older than the ones it
+// already committed.
+type diskSpool struct {
+	mu           sync.Mutex
+	path         string
+	name         string
+	maxSizeBytes int64
+	maxAge       time.Duration
+	now          func() time.Time
+
+	sizeBytes int64
+	entries   []*spoolEntry
+	nextID    uint64
+
+	// available is notified when a payload is stored, space when one is removed.
+	available chan struct{}
+	space     chan struct{}
+}
+
+// spoolEntry is a payload stored on disk, without its encoded content.
+type spoolEntry struct {
+	file    string
+	size    int64
+	created time.Time
+	payload message.Payload
+}
+
+// spoolOffset is the offset of a message of a stored payload, as committed by the auditor.
+type spoolOffset struct {
+	Identifier         string `json:"identifier"`
+	Offset             string `json:"offset"`
+	TailingMode        string `json:"tailing_mode"`
+	IngestionTimestamp int64  `json:"ingestion_timestamp"`
+}
+
+// newDiskSpool returns a disk spool for the given destination, or nil if the
+// spool is disabled, i.e. when logs_config.disk_spool.max_size_in_bytes is 0.
+func newDiskSpool(cfg pkgconfigmodel.Reader, name string) *diskSpool {
+	maxSizeBytes := cfg.GetInt64("logs_config.disk_spool.max_size_in_bytes")
+	if maxSizeBytes <= 0 {
+		return nil
+	}
+
+	root := cfg.GetString("logs_config.disk_spool.path")
+	if root == "" {
+		root = filepath.Join(cfg.GetString("logs_config.run_path"), "spool")
+	}
+	path := filepath.Join(root, sanitizeSpoolName(name))
+	if err := os.MkdirAll(path, 0700); err != nil {
+		log.Errorf("Can't create the logs disk spool directory %s, the disk spool is disabled: %v", path, err)
+		return nil
+	}
+
+	s := &diskSpool{
+		path:         path,
+		name:         name,
+		maxSizeBytes: maxSizeBytes,
+		maxAge:       cfg.GetDuration("logs_config.disk_spool.max_age"),
+		now:          time.Now,
+		available:    make(chan struct{}, 1),
+		space:        make(chan struct{}, 1),
+	}
+	s.load()
+	return s
+}
+
+// load loads the payloads stored by a previous run, so that they are sent again.
+func (s *diskSpool) load() {
+	files, err := filepath.Glob(filepath.Join(s.path, "*"+spoolFileExtension))
+	if err != nil {
+		return
+	}
+	for _, file := range files {
+		id, encoding, ok := parseSpoolFileName(filepath.Base(file))
+		info, err := os.Stat(file)
+		if !ok || err != nil {
+			log.Warnf("Ignoring %s in the logs disk spool", file)
+			continue
+		}
+		entry := &spoolEntry{
+			file:    file,
+			size:    info.Size(),
+			created: info.ModTime(),
+			payload: message.Payload{Encoding: encoding, MessageMetas: loadSpoolOffsets(file)},
+		}
+		s.entries = append(s.entries, entry)
+		s.sizeBytes += entry.size
+		s.nextID = max(s.nextID, id+1)
+	}
+	// the zero-padded identifiers sort the files in the order they were stored
+	sort.Slice(s.entries, func(i, j int) bool { return s.entries[i].file < s.entries[j].file })
+	if len(s.entries) > 0 {
+		log.Infof("Sending again %d payloads stored in the logs disk spool by a previous run", len(s.entries))
+		tlmSpoolSize.Set(float64(s.sizeBytes), s.name)
+	}
+}
+
+// store writes the payload on disk, and returns false if the spool is full
+// or if the payload can't be written.
+func (s *diskSpool) store(payload *message.Payload) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	size := int64(len(payload.Encoded))
+	if s.sizeBytes+size > s.maxSizeBytes {
+		return false
+	}
+
+	file := filepath.Join(s.path, spoolFileName(s.nextID, payload.Encoding))
+	// the offsets are written first, a payload is only loaded by the next run once written
+	if err := storeSpoolOffsets(file, payload.MessageMetas); err != nil {
+		log.Warnf("Can't store the payload offsets in the logs disk spool: %v", err)
+		return false
+	}
+	if err := os.WriteFile(file, payload.Encoded, 0600); err != nil {
+		log.Warnf("Can't store the payload in the logs disk spool: %v", err)
+		removeSpoolOffsets(file)
+		return false
+	}
+	s.nextID++
+
+	entry := &spoolEntry{file: file, size: size, created: s.now(), payload: *payload}
+	entry.payload.Encoded = nil
+	s.entries = append(s.entries, entry)
+	s.sizeBytes += size
+	tlmSpoolStored.Inc(s.name)
+	tlmSpoolSize.Set(float64(s.sizeBytes), s.name)
+	notify(s.available)
+	return true
+}
+
+// isEmpty returns true if no payload is stored.
+func (s *diskSpool) isEmpty() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.entries) == 0
+}
+
+// oldest returns the oldest payload stored and its entry, or nil if no payload
+// is stored. The payloads older than the maximum age are dropped.
+func (s *diskSpool) oldest() (*message.Payload, *spoolEntry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for len(s.entries) > 0 {
+		entry := s.entries[0]
+		if s.maxAge > 0 && s.now().Sub(entry.created) > s.maxAge {
+			log.Debugf("Dropping a payload of %d logs from the logs disk spool: older than %s", entry.payload.Count(), s.maxAge)
+			tlmSpoolDropped.Inc(s.name)
+			s.removeLocked(entry)
+			continue
+		}
+		encoded, err := os.ReadFile(entry.file)
+		if err != nil {
+			log.Warnf("Can't read a payload from the logs disk spool, dropping it: %v", err)
+			s.removeLocked(entry)
+			continue
+		}
+		payload := entry.payload
+		payload.Encoded = encoded
+		return &payload, entry
+	}
+	return nil, nil
+}
+
+// remove removes a payload returned by oldest once it has been sent.
+func (s *diskSpool) remove(entry *spoolEntry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.removeLocked(entry)
+}
+
+func (s *diskSpool) removeLocked(entry *spoolEntry) {
+	if len(s.entries) == 0 || s.entries[0] != entry {
+		return
+	}
+	s.entries[0] = nil
+	s.entries = s.entries[1:]
+	s.sizeBytes -= entry.size
+	if err := os.Remove(entry.file); err != nil && !os.IsNotExist(err) {
+		log.Warnf("Can't remove a payload from the logs disk spool: %v", err)
+	}
+	removeSpoolOffsets(entry.file)
+	tlmSpoolSize.Set(float64(s.sizeBytes), s.name)
+	notify(s.space)
+}
+
+// drainSpool sends, in order, the payloads stored in the spool, and forwards
+// them to the output once sent so that the auditor commits their offsets.
+func (d *Destination) drainSpool(output chan *message.Payload) {
+	defer close(d.spoolDone)
+	for {
+		select {
+		case <-d.spoolStop:
+			return
+		default:
+		}
+
+		payload, entry := d.spool.oldest()
+		if payload == nil {
+			select {
+			case <-d.spool.available:
+				continue
+			case <-d.spoolStop:
+				return
+			}
+		}
+
+		d.retryLock.Lock()
+		nbErrors := d.nbErrors
+		d.retryLock.Unlock()
+		if backoffDuration := d.backoff.GetBackoffDuration(nbErrors); backoffDuration > 0 {
+			if !d.waitForSpoolBackoff(backoffDuration) {
+				return
+			}
+		}
+
+		err := d.unconditionalSend(payload)
+		if err == context.Canceled {
+			return
+		}
+		d.updateBackoff(err)
+		if _, ok := err.(*client.RetryableError); ok {
+			continue
+		}
+
+		d.spool.remove(entry)
+		metrics.LogsSent.Add(payload.Count())
+		metrics.TlmLogsSent.Add(float64(payload.Count()))
+		output <- payload
+	}
+}
+
+// spoolPayload stores a payload in the spool, behind the payloads waiting to
+// be sent. Back pressure is only applied on the pipeline when the spool is full.
+func (d *Destination) spoolPayload(payload *message.Payload, output chan *message.Payload, isRetrying chan bool) {
+	for !d.spool.store(payload) {
+		if d.spool.isEmpty() {
+			// the payload doesn't fit in the spool, send it as usual
+			d.setSpoolFull(false, isRetrying)
+			d.sendConcurrent(payload, output, isRetrying)
+			return
+		}
+		d.setSpoolFull(true, isRetrying)
+		select {
+		case <-d.spool.space:
+		case <-d.destinationsContext.Context().Done():
+			return
+		}
+	}
+	d.setSpoolFull(false, isRetrying)
+}
+
+// setSpoolFull reports the destination as retrying while the spool is full, so
+// that the sender doesn't wait for this destination to accept payloads.
+func (d *Destination) setSpoolFull(full bool, isRetrying chan bool) {
+	d.retryLock.Lock()
+	defer d.retryLock.Unlock()
+
+	if full && d.lastRetryError == nil {
+		if isRetrying != nil {
+			isRetrying <- true
+		}
+		d.lastRetryError = errSpoolFull
+	} else if !full && d.lastRetryError == errSpoolFull {
+		if isRetrying != nil {
+			isRetrying <- false
+		}
+		d.lastRetryError = nil
+	}
+}
+
+// updateBackoff updates the number of errors used to compute the backoff
+// duration, without reporting the destination as retrying since the failed
+// payloads are stored in the spool.
+func (d *Destination) updateBackoff(err error) {
+	d.retryLock.Lock()
+	defer d.retryLock.Unlock()
+
+	if _, ok := err.(*client.RetryableError); ok {
+		d.nbErrors = d.backoff.IncError(d.nbErrors)
+	} else {
+		d.nbErrors = d.backoff.DecError(d.nbErrors)
+	}
+}
+
+// waitForSpoolBackoff waits for the backoff duration, and returns false if the
+// destination is stopped in the meantime.
+func (d *Destination) waitForSpoolBackoff(backoffDuration time.Duration) bool {
+	timer := time.NewTimer(backoffDuration)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-d.spoolStop:
+		return false
+	case <-d.destinationsContext.Context().Done():
+		return false
+	}
+}
+
+// notify wakes up the goroutine waiting on the channel, if any.
+func notify(c chan struct{}) {
+	select {
+	case c <- struct{}{}:
+	default:
+	}
+}
+
+// storeSpoolOffsets writes the offsets of the messages of a payload next to its file.
+func storeSpoolOffsets(file string, metas []*message.MessageMetadata) error {
+	offsets := make([]spoolOffset, len(metas))
+	for i, meta := range metas {
+		offsets[i].IngestionTimestamp = meta.IngestionTimestamp
+		if meta.Origin == nil {
+			continue
+		}
+		offsets[i].Identifier = meta.Origin.Identifier
+		offsets[i].Offset = meta.Origin.Offset
+		if meta.Origin.LogSource != nil && meta.Origin.LogSource.Config != nil {
+			offsets[i].TailingMode = meta.Origin.LogSource.Config.TailingMode
+		}
+	}
+	data, err := json.Marshal(offsets)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(spoolOffsetsFile(file), data, 0600)
+}
+
+// loadSpoolOffsets reads the offsets written by storeSpoolOffsets, and returns
+// the metadata the auditor needs to commit them. It returns nil if they can't
+// be read, the payload is then sent without updating the auditor.
+func loadSpoolOffsets(file string) []*message.MessageMetadata {
+	data, err := os.ReadFile(spoolOffsetsFile(file))
+	if err != nil {
+		log.Warnf("Can't read the offsets of %s in the logs disk spool, they won't be committed: %v", file, err)
+		return nil
+	}
+	var offsets []spoolOffset
+	if err := json.Unmarshal(data, &offsets); err != nil {
+		log.Warnf("Can't read the offsets of %s in the logs disk spool, they won't be committed: %v", file, err)
+		return nil
+	}
+	metas := make([]*message.MessageMetadata, len(offsets))
+	for i, offset := range offsets {
+		origin := message.NewOrigin(sources.NewLogSource("", &config.LogsConfig{TailingMode: offset.TailingMode}))
+		origin.Identifier = offset.Identifier
+		origin.Offset = offset.Offset
+		metas[i] = &message.MessageMetadata{Origin: origin, IngestionTimestamp: offset.IngestionTimestamp}
+	}
+	return metas
+}
+
+func removeSpoolOffsets(file string) {
+	if err := os.Remove(spoolOffsetsFile(file)); err != nil && !os.IsNotExist(err) {
+		log.Warnf("Can't remove the payload offsets from the logs disk spool: %v", err)
+	}
+}
+
+// spoolOffsetsFile returns the name of the file storing the offsets of a payload.
+func spoolOffsetsFile(file string) string {
+	return strings.TrimSuffix(file, spoolFileExtension) + spoolOffsetsExtension
+}
+
+// spoolFileName returns the name of the file storing a payload, holding its
+// identifier and content encoding.
+func spoolFileName(id uint64, encoding string) string {
+	if encoding == "" {
+		return fmt.Sprintf("%020d%s", id, spoolFileExtension)
+	}
+	return fmt.Sprintf("%020d.%s%s", id, encoding, spoolFileExtension)
+}
+
+// parseSpoolFileName parses a file name returned by spoolFileName.
+func parseSpoolFileName(name string) (uint64, string, bool) {
+	idStr, encoding, _ := strings.Cut(strings.TrimSuffix(name, spoolFileExtension), ".")
+	id, err := strconv.ParseUint(idStr, 10, 64)
+	return id, encoding, err == nil
+}
+
+// sanitizeSpoolName turns a destination name into a directory name.
+func sanitizeSpoolName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || r == ':' {
+			return '_'
+		}
+		return r
+	}, name)
+}
@@ -0,0 +1,207 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package http
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DataDog/datadog-agent/comp/logs/agent/config"
+	"github.com/DataDog/datadog-agent/pkg/logs/client"
+	"github.com/DataDog/datadog-agent/pkg/logs/message"
+	"github.com/DataDog/datadog-agent/pkg/logs/metrics"
+	"github.com/DataDog/datadog-agent/pkg/logs/sources"
+
+	configmock "github.com/DataDog/datadog-agent/pkg/config/mock"
+)
+
+func newTestPayload(content string) *message.Payload {
+	return &message.Payload{MessageMetas: []*message.MessageMetadata{{}}, Encoded: []byte(content)}
+}
+
+func TestNewDiskSpool(t *testing.T) {
+	cfg := configmock.New(t)
+	assert.Nil(t, newDiskSpool(cfg, "logs_0_reliable_0"))
+
+	path := t.TempDir()
+	cfg.SetWithoutSource("logs_config.disk_spool.path", path)
+	cfg.SetWithoutSource("logs_config.disk_spool.max_size_in_bytes", 100)
+
+	spool := newDiskSpool(cfg, "logs_0_reliable_0")
+	require.NotNil(t, spool)
+	assert.Equal(t, int64(100), spool.maxSizeBytes)
+	assert.Equal(t, 6*time.Hour, spool.maxAge)
+	assert.True(t, spool.isEmpty())
+}
+
+func TestDiskSpoolReplay(t *testing.T) {
+	cfg := configmock.New(t)
+	cfg.SetWithoutSource("logs_config.disk_spool.path", t.TempDir())
+	cfg.SetWithoutSource("logs_config.disk_spool.max_size_in_bytes", 100)
+	spool := newDiskSpool(cfg, "test")
+	require.NotNil(t, spool)
+	for i, content := range []string{"first", "second"} {
+		payload := newTestPayload(content)
+		payload.Encoding = "gzip"
+		payload.MessageMetas[0].Origin = message.NewOrigin(sources.NewLogSource("", &config.LogsConfig{TailingMode: "beginning"}))
+		payload.MessageMetas[0].Origin.Identifier = "file:/var/log/app.log"
+		payload.MessageMetas[0].Origin.Offset = strconv.Itoa(i)
+		payload.MessageMetas[0].IngestionTimestamp = int64(i)
+		require.True(t, spool.store(payload))
+	}
+	require.True(t, spool.store(newTestPayload("third")))
+	// the payloads whose offsets are lost are sent without committing them
+	require.NoError(t, os.Remove(spoolOffsetsFile(spool.entries[2].file)))
+
+	// the payloads stored by a previous run are loaded in order, with their encoding and offsets
+	spool = newDiskSpool(cfg, "test")
+	require.NotNil(t, spool)
+	assert.Equal(t, int64(16), spool.sizeBytes)
+	assert.Equal(t, uint64(3), spool.nextID)
+	for i, expected := range []struct{ content, encoding string }{{"first", "gzip"}, {"second", "gzip"}, {"third", ""}} {
+		payload, entry := spool.oldest()
+		require.NotNil(t, payload)
+		assert.Equal(t, expected.content, string(payload.Encoded))
+		assert.Equal(t, expected.encoding, payload.Encoding)
+		if i < 2 {
+			require.Len(t, payload.MessageMetas, 1)
+			meta := payload.MessageMetas[0]
+			assert.Equal(t, "file:/var/log/app.log", meta.Origin.Identifier)
+			assert.Equal(t, strconv.Itoa(i), meta.Origin.Offset)
+			assert.Equal(t, "beginning", meta.Origin.LogSource.Config.TailingMode)
+			assert.Equal(t, int64(i), meta.IngestionTimestamp)
+		} else {
+			assert.Empty(t, payload.MessageMetas)
+		}
+		spool.remove(entry)
+		assert.NoFileExists(t, spoolOffsetsFile(entry.file))
+	}
+	assert.True(t, spool.isEmpty())
+
+	// and new payloads are stored after them
+	require.True(t, spool.store(newTestPayload("fourth")))
+	assert.FileExists(t, filepath.Join(spool.path, "00000000000000000003"+spoolFileExtension))
+	assert.FileExists(t, filepath.Join(spool.path, "00000000000000000003"+spoolOffsetsExtension))
+}
+
+func TestDiskSpoolOrderAndLimits(t *testing.T) {
+	cfg := configmock.New(t)
+	cfg.SetWithoutSource("logs_config.disk_spool.path", t.TempDir())
+	cfg.SetWithoutSource("logs_config.disk_spool.max_size_in_bytes", 10)
+	cfg.SetWithoutSource("logs_config.disk_spool.max_age", "1m")
+	spool := newDiskSpool(cfg, "test")
+	require.NotNil(t, spool)
+	now := time.Now()
+	spool.now = func() time.Time { return now }
+
+	assert.True(t, spool.store(newTestPayload("first")))
+	now = now.Add(time.Minute)
+	assert.True(t, spool.store(newTestPayload("two")))
+	assert.False(t, spool.store(newTestPayload("three")))
+	assert.Equal(t, int64(8), spool.sizeBytes)
+
+	payload, entry := spool.oldest()
+	require.NotNil(t, payload)
+	assert.Equal(t, "first", string(payload.Encoded))
+	assert.Equal(t, int64(1), payload.Count())
+	spool.remove(entry)
+	assert.NoFileExists(t, entry.file)
+
+	// payloads older than the maximum age are dropped
+	now = now.Add(30 * time.Second)
+	assert.True(t, spool.store(newTestPayload("four")))
+	now = now.Add(31 * time.Second)
+	payload, _ = spool.oldest()
+	assert.Equal(t, "four", string(payload.Encoded))
+	assert.Equal(t, int64(4), spool.sizeBytes)
+}
+
+func TestDestinationDrainsSpool(t *testing.T) {
+	cfg := configmock.New(t)
+	cfg.SetWithoutSource("logs_config.disk_spool.path", t.TempDir())
+	cfg.SetWithoutSource("logs_config.disk_spool.max_size_in_bytes", 1000)
+	server := NewTestServer(500, cfg)
+	defer server.Stop()
+
+	destMeta := client.NewDestinationMetadata("spooltest", "0", "reliable", "0")
+	dest := NewDestination(server.Endpoint, JSONContentType, server.DestCtx, true, destMeta, cfg, 1, 1, metrics.NewNoopPipelineMonitor(""))
+	require.NotNil(t, dest.spool)
+
+	input := make(chan *message.Payload)
+	output := make(chan *message.Payload, 10)
+	isRetrying := make(chan bool, 1)
+	stop := dest.Start(input, output, isRetrying)
+
+	// the payloads are stored while the intake is unreachable, without blocking the input
+	for _, content := range []string{"a", "b", "c"} {
+		input <- newTestPayload(content)
+	}
+	assert.Eventually(t, func() bool {
+		dest.spool.mu.Lock()
+		defer dest.spool.mu.Unlock()
+		return len(dest.spool.entries) == 3
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Empty(t, output)
+	assert.Empty(t, isRetrying)
+
+	// and sent in order once it recovers
+	server.ChangeStatus(200)
+	for _, content := range []string{"a", "b", "c"} {
+		select {
+		case payload := <-output:
+			assert.Equal(t, content, string(payload.Encoded))
+		case <-time.After(10 * time.Second):
+			require.Fail(t, "the spooled payloads were not sent")
+		}
+	}
+	assert.True(t, dest.spool.isEmpty())
+
+	close(input)
+	<-stop
+}
+
+func TestDestinationSpoolDropsWithoutCommitting(t *testing.T) {
+	cfg := configmock.New(t)
+	cfg.SetWithoutSource("logs_config.disk_spool.path", t.TempDir())
+	cfg.SetWithoutSource("logs_config.disk_spool.max_size_in_bytes", 1000)
+	server := NewTestServer(200, cfg)
+	defer server.Stop()
+
+	destMeta := client.NewDestinationMetadata("spooltest", "0", "reliable", "0")
+	dest := NewDestination(server.Endpoint, JSONContentType, server.DestCtx, true, destMeta, cfg, 1, 1, metrics.NewNoopPipelineMonitor(""))
+	require.NotNil(t, dest.spool)
+	for _, content := range []string{"expired", "unreadable", "sent"} {
+		require.True(t, dest.spool.store(newTestPayload(content)))
+	}
+
+	// the first payload is older than the maximum age, the second one can't be read
+	dest.spool.entries[0].created = time.Now().Add(-7 * time.Hour)
+	require.NoError(t, os.Remove(dest.spool.entries[1].file))
+	require.NoError(t, os.Mkdir(dest.spool.entries[1].file, 0700))
+
+	input := make(chan *message.Payload)
+	output := make(chan *message.Payload, 10)
+	stop := dest.Start(input, output, make(chan bool, 1))
+
+	// only the payload sent to the intake is forwarded to the auditor
+	select {
+	case payload := <-output:
+		assert.Equal(t, "sent", string(payload.Encoded))
+	case <-time.After(10 * time.Second):
+		require.Fail(t, "the spooled payload was not sent")
+	}
+	assert.True(t, dest.spool.isEmpty())
+	assert.Empty(t, output)
+
+	close(input)
+	<-stop
+}
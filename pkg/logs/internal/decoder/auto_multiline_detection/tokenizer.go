@@ -123,6 +123,25 @@ func (t *Tokenizer) tokenize(input []byte) ([]tokens.Token, []int) {
 	return ts, indicies
 }
 
+// MaskDigits returns a copy of the input where each run of digits, which is a
+// single token for the tokenizer, is replaced by a single 0.
+func MaskDigits(input []byte) []byte {
+	ts, indicies := NewTokenizer(len(input)).tokenize(input)
+	masked := make([]byte, 0, len(input))
+	for i, token := range ts {
+		end := len(input)
+		if i+1 < len(indicies) {
+			end = indicies[i+1]
+		}
+		if token >= tokens.D1 && token <= tokens.D10 {
+			masked = append(masked, '0')
+		} else {
+			masked = append(masked, input[indicies[i]:end]...)
+		}
+	}
+	return masked
+}
+
 // getToken returns a single token from a single byte.
 func getToken(char byte) tokens.Token {
 	if unicode.IsDigit(rune(char)) {
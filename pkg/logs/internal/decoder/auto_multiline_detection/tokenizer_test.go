@@ -67,6 +67,13 @@ func TestTokenizerMaxDigitRun(t *testing.T) {
 	assert.Equal(t, []int{0}, indicies)
 }
 
+func TestMaskDigits(t *testing.T) {
+	assert.Equal(t, "", string(MaskDigits(nil)))
+	assert.Equal(t, "request 0 took 0ms", string(MaskDigits([]byte("request 12 took 345ms"))))
+	assert.Equal(t, "0-0-0T0:0Z", string(MaskDigits([]byte("2024-01-02T12:34Z"))))
+	assert.Equal(t, "id=0 at Mon  0PM", string(MaskDigits([]byte("id=123456789012345 at Mon  2PM"))))
+}
+
 func TestAllSymbolsAreHandled(t *testing.T) {
 	for i := tokens.Space; i < tokens.D1; i++ {
 		str := tokenToString(i)
@@ -14,6 +14,7 @@ import (
 	pkgconfigsetup "github.com/DataDog/datadog-agent/pkg/config/setup"
 	"github.com/DataDog/datadog-agent/pkg/logs/internal/framer"
 	"github.com/DataDog/datadog-agent/pkg/logs/internal/parsers"
+	"github.com/DataDog/datadog-agent/pkg/logs/internal/parsers/jsonlog"
 	"github.com/DataDog/datadog-agent/pkg/logs/internal/parsers/noop"
 	"github.com/DataDog/datadog-agent/pkg/logs/message"
 	"github.com/DataDog/datadog-agent/pkg/logs/sources"
@@ -115,8 +116,37 @@ func NewDecoderWithFraming(source *sources.ReplaceableSource, parser parsers.Par
 	return New(inputChan, outputChan, framer, lineParser, lineHandler, detectedPattern)
 }
 
+// buildSourceParser returns the parser configured for the source, nil if none.
+func buildSourceParser(source *sources.ReplaceableSource) parsers.Parser {
+	if source.Config().Parser != config.JSONParser {
+		return nil
+	}
+	var fields jsonlog.Fields
+	if jsonFields := source.Config().JSONFields; jsonFields != nil {
+		fields = jsonlog.Fields{
+			Timestamp: jsonFields.Timestamp,
+			Level:     jsonFields.Level,
+			Message:   jsonFields.Message,
+			Service:   jsonFields.Service,
+			TraceID:   jsonFields.TraceID,
+		}
+	}
+	return jsonlog.New(noop.New(), fields)
+}
+
 func buildLineHandler(source *sources.ReplaceableSource, multiLinePattern *regexp.Regexp, tailerInfo *status.InfoRegistry, outputChan chan *message.Message, detectedPattern *DetectedPattern) LineHandler {
 	outputFn := func(m *message.Message) { outputChan <- m }
+	if sourceParser := buildSourceParser(source); sourceParser != nil {
+		// the source parser applies to the messages once their lines are
+		// aggregated by the line handler
+		outputFn = func(m *message.Message) {
+			parsed, err := sourceParser.Parse(m)
+			if err != nil {
+				log.Debug(err)
+			}
+			outputChan <- parsed
+		}
+	}
 	maxContentSize := config.MaxMessageSizeBytes(pkgconfigsetup.Datadog())
 
 	// construct the lineHandler
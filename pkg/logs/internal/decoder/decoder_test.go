@@ -8,6 +8,7 @@ package decoder
 import (
 	"regexp"
 	"testing"
+	"time"
 
 	"github.com/DataDog/datadog-agent/comp/logs/agent/config"
 	"github.com/DataDog/datadog-agent/pkg/logs/internal/framer"
@@ -341,3 +342,88 @@ func TestDecoderWithMultilineKubernetes(t *testing.T) {
 	assert.Equal(t, message.StatusError, output.Status)
 	assert.Equal(t, "2019-06-06T16:35:55.930852913Z", output.ParsingExtra.Timestamp)
 }
+
+func TestDecoderWithJSONParser(t *testing.T) {
+	source := sources.NewLogSource("", &config.LogsConfig{Parser: config.JSONParser, JSONFields: &config.JSONFields{Message: "msg"}})
+	d := InitializeDecoderForTest(source, kubernetes.New())
+	d.Start()
+	defer d.Stop()
+
+	line := []byte(`2019-06-06T16:35:55.930852911Z stdout F {"msg":"user logged in","level":"debug","user":"foo"}` + "\n")
+	d.InputChan <- NewInput(line)
+
+	output := <-d.OutputChan
+	assert.Equal(t, message.StateStructured, output.State)
+	assert.Equal(t, []byte("user logged in"), output.GetContent())
+	assert.Equal(t, message.StatusDebug, output.Status)
+	assert.Equal(t, len(line), output.RawDataLen)
+	assert.Equal(t, "2019-06-06T16:35:55.930852911Z", output.ParsingExtra.Timestamp)
+	rendered, err := output.Render()
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"message":"user logged in","user":"foo"}`, string(rendered))
+
+	line = []byte("2019-06-06T16:35:55.930852911Z stderr F not json\n")
+	d.InputChan <- NewInput(line)
+
+	output = <-d.OutputChan
+	assert.Equal(t, message.StateUnstructured, output.State)
+	assert.Equal(t, []byte("not json"), output.GetContent())
+	assert.Equal(t, message.StatusError, output.Status)
+}
+
+func TestDecoderWithJSONParserPartialLines(t *testing.T) {
+	source := sources.NewLogSource("", &config.LogsConfig{Parser: config.JSONParser})
+	d := InitializeDecoderForTest(source, kubernetes.New())
+	d.Start()
+	defer d.Stop()
+
+	first := []byte(`2019-06-06T16:35:55.930852911Z stdout P {"message":"user logged in",` + "\n")
+	last := []byte(`2019-06-06T16:35:55.930852911Z stdout F "level":"warn"}` + "\n")
+	d.InputChan <- NewInput(first)
+	d.InputChan <- NewInput(last)
+
+	output := <-d.OutputChan
+	assert.Equal(t, message.StateStructured, output.State)
+	assert.Equal(t, []byte("user logged in"), output.GetContent())
+	assert.Equal(t, message.StatusWarning, output.Status)
+	assert.Equal(t, len(first)+len(last), output.RawDataLen)
+}
+
+func TestDecoderWithJSONParserMultiLine(t *testing.T) {
+	source := sources.NewLogSource("", &config.LogsConfig{
+		Parser: config.JSONParser,
+		ProcessingRules: []*config.ProcessingRule{
+			{
+				Type:  config.MultiLine,
+				Regex: regexp.MustCompile(`^\{`),
+			},
+		},
+	})
+	d := InitializeDecoderForTest(source, noop.New())
+	d.Start()
+	defer d.Stop()
+
+	// the attributes of the first line are kept once the lines are aggregated
+	lines := []string{
+		`{"timestamp":"2024-03-01T12:00:00Z","level":"error","message":"boom",` + "\n",
+		`  "user":"foo"}` + "\n",
+		`{"message":"next"}` + "\n",
+	}
+	for _, line := range lines {
+		d.InputChan <- NewInput([]byte(line))
+	}
+
+	output := <-d.OutputChan
+	assert.Equal(t, message.StateStructured, output.State)
+	assert.Equal(t, []byte("boom"), output.GetContent())
+	assert.Equal(t, message.StatusError, output.Status)
+	assert.Equal(t, time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC), output.ServerlessExtra.Timestamp)
+	assert.Equal(t, len(lines[0])+len(lines[1]), output.RawDataLen)
+	rendered, err := output.Render()
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"message":"boom","user":"foo"}`, string(rendered))
+
+	output = <-d.OutputChan
+	assert.Equal(t, message.StateStructured, output.State)
+	assert.Equal(t, []byte("next"), output.GetContent())
+}
@@ -43,6 +43,10 @@ const (
 	// headers are included in the log frame.  The size in those headers is not
 	// consulted.  The result does not include the trailing newlines.
 	DockerStream
+
+	// Syslog messages over a stream transport (RFC 6587), either octet-counted
+	// or newline-terminated.  The framing method is detected for each message.
+	Syslog
 )
 
 // Framer gets chunks of bytes (via Process(..)) and uses an
@@ -100,6 +104,8 @@ func NewFramer(
 		matcher = &oneByteNewLineMatcher{contentLenLimit}
 	case DockerStream:
 		matcher = &dockerStreamMatcher{contentLenLimit}
+	case Syslog:
+		matcher = &syslogMatcher{contentLenLimit: contentLenLimit}
 	case NoFraming:
 		matcher = &noFramingMatcher{}
 	default:
@@ -115,6 +115,17 @@ func TestLineBreaking(t *testing.T) {
 		t.Run("one-byte chunks", test(framing, chunk(utf16, 1), lines, lens))
 	})
 
+	t.Run("Syslog", func(t *testing.T) {
+		input := []byte("<13>1 - - - - - - line1\n23 <13>1 - - - - - - line2<13>1 - - - - - - line3\n24 <13>1 - - - - - - line\n4")
+		lines := []string{"<13>1 - - - - - - line1", "<13>1 - - - - - - line2", "<13>1 - - - - - - line3", "<13>1 - - - - - - line\n4"}
+		lens := []int{24, 26, 24, 27}
+		framing := Syslog
+		t.Run("one chunk", test(framing, chunk(input, len(input)), lines, lens))
+		for size := 0; size < 30; size++ {
+			t.Run(fmt.Sprintf("%d-byte chunks", size), test(framing, chunk(input, size), lines, lens))
+		}
+	})
+
 	dockerChunk := func(stream byte, data []byte) []byte {
 		header := [8]byte{stream}
 		binary.BigEndian.PutUint32(header[4:8], uint32(len(data)))
@@ -264,6 +275,43 @@ func TestContentLenLimit(t *testing.T) {
 	})
 }
 
+func TestContentLenLimitSyslog(t *testing.T) {
+	test := func(chunks [][]byte, lines []string, rawLens []int) func(*testing.T) {
+		return func(t *testing.T) {
+			gotContent := []string{}
+			gotLens := []int{}
+			outputFn := func(msg *message.Message, rawDataLen int) {
+				gotContent = append(gotContent, string(msg.GetContent()))
+				gotLens = append(gotLens, rawDataLen)
+			}
+			fr := NewFramer(outputFn, Syslog, 16)
+			for _, chunk := range chunks {
+				fr.Process(message.NewMessage(chunk, nil, "", 0))
+			}
+			require.Equal(t, lines, gotContent)
+			require.Equal(t, rawLens, gotLens)
+		}
+	}
+
+	// the octet-counted messages longer than the limit are broken into frames
+	// of at most 16 bytes, then the following message is framed as usual
+	input := []byte("42 <13>1 - - - - - - abcdabcdabcdabcdabcdabcd13 <13>1 - - - -<13>1 - - - - - - a\n")
+	lines := []string{"<13>1 - - - -", " - - abcdabcdabc", "dabcdabcdabcd", "<13>1 - - - -", "<13>1 - - - - - ", "- a"}
+	lens := []int{16, 16, 13, 16, 16, 4}
+	t.Run("one chunk", test(chunk(input, len(input)), lines, lens))
+	for size := 1; size < 20; size++ {
+		t.Run(fmt.Sprintf("%d-byte chunks", size), test(chunk(input, size), lines, lens))
+	}
+
+	// a huge MSG-LEN doesn't make the framer buffer the whole message
+	huge := []byte("999999999 " + strings.Repeat("a", 64))
+	var gotLens []int
+	fr := NewFramer(func(_ *message.Message, rawDataLen int) { gotLens = append(gotLens, rawDataLen) }, Syslog, 16)
+	fr.Process(message.NewMessage(huge, nil, "", 0))
+	assert.Equal(t, []int{16, 16, 16, 16}, gotLens)
+	assert.Equal(t, 10, fr.buffer.Len())
+}
+
 func TestLineBreakIncomingData(t *testing.T) {
 	outputFn, outputChan := framerOutput()
 	framer := NewFramer(outputFn, UTF8Newline, contentLenLimit)
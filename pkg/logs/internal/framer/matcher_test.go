@@ -94,3 +94,22 @@ func TestTwoByteNewlineMatcher_FindFrame_Misaligned(t *testing.T) {
 	}
 	testFindFrame(t, &twoByteNewLineMatcher{contentLenLimit: 100, newline: Utf16leEOL}, input, 16, 18)
 }
+
+func TestSyslogMatcher_FindFrame_octetCounted(t *testing.T) {
+	content, rawDataLen := (&syslogMatcher{contentLenLimit: 100}).FindFrame([]byte("11 <13>1 - - -\n12 <13>1 - - - -"), 0)
+	assert.Equal(t, []byte("<13>1 - - -"), content)
+	assert.Equal(t, 14, rawDataLen)
+}
+
+func TestSyslogMatcher_FindFrame_newline(t *testing.T) {
+	testFindFrame(t, &syslogMatcher{contentLenLimit: 100}, []byte("<13>Oct 11 22:14:15 host app: 12 bytes\n<13>"), 38, 39)
+}
+
+func TestSyslogMatcher_FindFrame_incomplete(t *testing.T) {
+	m := &syslogMatcher{contentLenLimit: 100}
+	for _, input := range []string{"1", "12", "12 ", "12 <13>1 -", "<13>1 - - - - -"} {
+		content, rawDataLen := m.FindFrame([]byte(input), 0)
+		assert.Nil(t, content, "for input=%q", input)
+		assert.Equal(t, 0, rawDataLen, "for input=%q", input)
+	}
+}
@@ -0,0 +1,108 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package framer
+
+import (
+	"strconv"
+)
+
+// maxOctetCountDigits is the maximum number of digits accepted in the MSG-LEN
+// prefix of an octet-counted syslog frame.
+const maxOctetCountDigits = 9
+
+// syslogMatcher implements FrameMatcher for syslog messages transported over a
+// stream, as described in RFC 6587. Both framing methods are supported:
+//
+//   - octet counting: `MSG-LEN SP SYSLOG-MSG`, where MSG-LEN is the length in
+//     bytes of SYSLOG-MSG,
+//   - non-transparent framing: SYSLOG-MSG terminated by a newline.
+//
+// The method is detected for each frame: a syslog message always starts with
+// its PRI part (`<`), so a frame starting with a digit is octet-counted.
+//
+// As for the newline-terminated frames, an octet-counted message longer than
+// contentLenLimit is broken into frames of at most contentLenLimit bytes, so
+// that the framer stays in sync with the declared MSG-LEN.
+type syslogMatcher struct {
+	// contentLenLimit is the maximum content length that will be returned.
+	contentLenLimit int
+	// remaining is the number of bytes of the current octet-counted
+	// SYSLOG-MSG which have not been returned yet.
+	remaining int
+}
+
+// FindFrame implements EndLineMatcher#FindFrame.
+func (s *syslogMatcher) FindFrame(buf []byte, seen int) ([]byte, int) {
+	if s.remaining > 0 {
+		return s.findRemainingFrame(buf)
+	}
+	if len(buf) > 0 && buf[0] >= '1' && buf[0] <= '9' {
+		content, rawDataLen, ok := s.findOctetCountedFrame(buf)
+		if ok {
+			return content, rawDataLen
+		}
+	}
+	return s.findNewlineFrame(buf, seen)
+}
+
+// findOctetCountedFrame returns the content of an octet-counted frame found at
+// the beginning of buf. ok is false if buf doesn't start with a valid MSG-LEN,
+// in which case the frame should be considered as newline-terminated.
+func (s *syslogMatcher) findOctetCountedFrame(buf []byte) (content []byte, rawDataLen int, ok bool) {
+	for i := 1; i < len(buf) && i <= maxOctetCountDigits; i++ {
+		if buf[i] == ' ' {
+			msgLen, err := strconv.Atoi(string(buf[:i]))
+			if err != nil {
+				return nil, 0, false
+			}
+			start := i + 1
+			frameLen := msgLen
+			if start+msgLen > s.contentLenLimit {
+				// the framer breaks the buffer at contentLenLimit bytes, so the
+				// first frame of a longer message can't exceed it either
+				frameLen = max(s.contentLenLimit-start, 0)
+			}
+			if start+frameLen > len(buf) {
+				// wait for the rest of the frame
+				return nil, 0, true
+			}
+			s.remaining = msgLen - frameLen
+			return buf[start : start+frameLen], start + frameLen, true
+		}
+		if buf[i] < '0' || buf[i] > '9' {
+			return nil, 0, false
+		}
+	}
+	if len(buf) <= maxOctetCountDigits {
+		// the MSG-LEN prefix may not be complete yet
+		return nil, 0, true
+	}
+	return nil, 0, false
+}
+
+// findRemainingFrame returns the next frame of an octet-counted SYSLOG-MSG
+// longer than contentLenLimit.
+func (s *syslogMatcher) findRemainingFrame(buf []byte) ([]byte, int) {
+	frameLen := min(s.remaining, s.contentLenLimit)
+	if frameLen > len(buf) {
+		return nil, 0
+	}
+	s.remaining -= frameLen
+	return buf[:frameLen], frameLen
+}
+
+// findNewlineFrame returns the content of a newline-terminated frame.
+func (s *syslogMatcher) findNewlineFrame(buf []byte, seen int) ([]byte, int) {
+	for i := seen; i < len(buf); i++ {
+		if buf[i] == '\n' {
+			if i > s.contentLenLimit {
+				return buf[:s.contentLenLimit], s.contentLenLimit
+			}
+			return buf[:i], i + 1
+		}
+	}
+	return nil, 0
+}
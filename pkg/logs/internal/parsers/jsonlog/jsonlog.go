@@ -0,0 +1,262 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+// Package jsonlog implements a parser for structured JSON logs, decoding each
+// line and promoting some of its fields into the message metadata.
+package jsonlog
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/DataDog/datadog-agent/pkg/logs/internal/parsers"
+	"github.com/DataDog/datadog-agent/pkg/logs/message"
+)
+
+// traceIDAttribute is the attribute used by Datadog to correlate logs and traces.
+const traceIDAttribute = "dd.trace_id"
+
+// Fields holds the keys of the fields promoted into the message metadata.
+// Nested fields are given with a dot-separated key, e.g. "log.level".
+type Fields struct {
+	Timestamp string
+	Level     string
+	Message   string
+	Service   string
+	TraceID   string
+}
+
+// DefaultFields are the keys used when no key is configured for a field.
+var DefaultFields = Fields{
+	Timestamp: "timestamp",
+	Level:     "level",
+	Message:   "message",
+	Service:   "service",
+	TraceID:   "trace_id",
+}
+
+// New creates a new parser decoding the JSON objects of the lines produced by
+// the given parser.
+//
+// The message field becomes the content of a structured message, which keeps
+// the other fields as attributes.  The level is mapped to the message status,
+// the timestamp overrides the time of the log, the service is used when the
+// log source doesn't define one, and the trace ID is stored in the dd.trace_id
+// attribute.  Lines which are not JSON objects or don't have a message field
+// are left untouched, as well as the lines still partial, so the parser should
+// be applied once the partial lines of the given parser are aggregated. The
+// objects spanning several lines aggregated by a multi-line rule are decoded too.
+//
+// For example: `{"ts": "2024-03-01T12:00:00Z", "level": "WARN", "message": "disk almost full", "disk": "/dev/sda1"}`
+func New(parser parsers.Parser, fields Fields) parsers.Parser {
+	return &jsonParser{
+		parser: parser,
+		fields: fields.withDefaults(),
+	}
+}
+
+type jsonParser struct {
+	parser parsers.Parser
+	fields Fields
+
+	// partial is true while the lines of the parser are partial, the last
+	// part of a line can't be decoded on its own.
+	partial bool
+}
+
+// Parse implements Parser#Parse
+func (p *jsonParser) Parse(msg *message.Message) (*message.Message, error) {
+	msg, err := p.parser.Parse(msg)
+	if err != nil {
+		return msg, err
+	}
+	wasPartial := p.partial
+	p.partial = msg.ParsingExtra.IsPartial
+	if wasPartial || msg.ParsingExtra.IsPartial || msg.State != message.StateUnstructured {
+		return msg, nil
+	}
+
+	content := bytes.TrimSpace(msg.GetContent())
+	if len(content) == 0 || content[0] != '{' {
+		return msg, nil
+	}
+	data, ok := decode(content)
+	if !ok && bytes.Contains(content, message.EscapedLineFeed) {
+		// the lines of an object aggregated by a multi-line rule are joined
+		// by escaped line feeds, which are whitespace between its tokens
+		data, ok = decode(bytes.ReplaceAll(content, message.EscapedLineFeed, []byte{'\n'}))
+	}
+	if !ok {
+		// not a JSON log, keep it as is
+		return msg, nil
+	}
+
+	value, ok := pop(data, p.fields.Message)
+	if !ok {
+		return msg, nil
+	}
+	content = []byte(toString(value))
+
+	structured := message.NewStructuredMessage(
+		&message.BasicStructuredContent{Data: data},
+		msg.Origin,
+		msg.Status,
+		msg.IngestionTimestamp,
+	)
+	structured.ParsingExtra = msg.ParsingExtra
+	structured.ServerlessExtra = msg.ServerlessExtra
+	structured.Hostname = msg.Hostname
+	structured.RawDataLen = msg.RawDataLen
+	structured.SetContent(content)
+
+	if value, ok := lookup(data, p.fields.Level); ok {
+		if status, ok := message.LevelToStatus(toString(value)); ok {
+			structured.Status = status
+			pop(data, p.fields.Level)
+		}
+	}
+	if value, ok := lookup(data, p.fields.Timestamp); ok {
+		if ts, ok := parseTimestamp(value); ok {
+			structured.ServerlessExtra.Timestamp = ts
+			pop(data, p.fields.Timestamp)
+		}
+	}
+	if value, ok := lookup(data, p.fields.Service); ok {
+		if service, ok := value.(string); ok && service != "" {
+			structured.ParsingExtra.Service = service
+			pop(data, p.fields.Service)
+		}
+	}
+	if value, ok := pop(data, p.fields.TraceID); ok {
+		structured.SetAttribute(traceIDAttribute, toString(value))
+	}
+	return structured, nil
+}
+
+// decode decodes content if it holds a single JSON object.
+func decode(content []byte) (map[string]interface{}, bool) {
+	var data map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	decoder.UseNumber()
+	if err := decoder.Decode(&data); err != nil || decoder.More() {
+		return nil, false
+	}
+	return data, true
+}
+
+// SupportsPartialLine implements Parser#SupportsPartialLine
+func (p *jsonParser) SupportsPartialLine() bool {
+	return p.parser.SupportsPartialLine()
+}
+
+func (f Fields) withDefaults() Fields {
+	if f.Timestamp == "" {
+		f.Timestamp = DefaultFields.Timestamp
+	}
+	if f.Level == "" {
+		f.Level = DefaultFields.Level
+	}
+	if f.Message == "" {
+		f.Message = DefaultFields.Message
+	}
+	if f.Service == "" {
+		f.Service = DefaultFields.Service
+	}
+	if f.TraceID == "" {
+		f.TraceID = DefaultFields.TraceID
+	}
+	return f
+}
+
+// lookup returns the value of a field, given either as a top-level key or as a
+// dot-separated path to a nested field.
+func lookup(data map[string]interface{}, key string) (interface{}, bool) {
+	if value, ok := data[key]; ok {
+		return value, true
+	}
+	path := strings.Split(key, ".")
+	for i, k := range path {
+		value, ok := data[k]
+		if !ok {
+			return nil, false
+		}
+		if i == len(path)-1 {
+			return value, true
+		}
+		if data, ok = value.(map[string]interface{}); !ok {
+			return nil, false
+		}
+	}
+	return nil, false
+}
+
+// pop removes a field and returns its value, see lookup.
+func pop(data map[string]interface{}, key string) (interface{}, bool) {
+	if value, ok := data[key]; ok {
+		delete(data, key)
+		return value, true
+	}
+	path := strings.Split(key, ".")
+	parent := data
+	for _, k := range path[:len(path)-1] {
+		nested, ok := parent[k].(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		parent = nested
+	}
+	value, ok := parent[path[len(path)-1]]
+	if ok {
+		delete(parent, path[len(path)-1])
+	}
+	return value, ok
+}
+
+// toString returns the string of a string value, and the JSON encoding of
+// any other value.
+func toString(value interface{}) string {
+	if s, ok := value.(string); ok {
+		return s
+	}
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return ""
+	}
+	return string(encoded)
+}
+
+// parseTimestamp parses an RFC 3339 timestamp, or a Unix timestamp whose unit
+// (seconds, milliseconds, microseconds or nanoseconds) is guessed from its magnitude.
+func parseTimestamp(value interface{}) (time.Time, bool) {
+	switch v := value.(type) {
+	case string:
+		ts, err := time.Parse(time.RFC3339Nano, v)
+		if err != nil {
+			return time.Time{}, false
+		}
+		return ts.UTC(), true
+	case json.Number:
+		f, err := v.Float64()
+		if err != nil || f <= 0 {
+			return time.Time{}, false
+		}
+		switch {
+		case f < 1e11:
+			return time.Unix(0, int64(f*1e9)).UTC(), true
+		case f < 1e14:
+			return time.UnixMilli(int64(f)).UTC(), true
+		case f < 1e17:
+			return time.UnixMicro(int64(f)).UTC(), true
+		default:
+			if n, err := v.Int64(); err == nil {
+				return time.Unix(0, n).UTC(), true
+			}
+			return time.Unix(0, int64(f)).UTC(), true
+		}
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,137 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package jsonlog
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DataDog/datadog-agent/pkg/logs/internal/parsers/noop"
+	"github.com/DataDog/datadog-agent/pkg/logs/message"
+)
+
+func render(t *testing.T, msg *message.Message) map[string]interface{} {
+	rendered, err := msg.Render()
+	require.NoError(t, err)
+	var data map[string]interface{}
+	require.NoError(t, json.Unmarshal(rendered, &data))
+	return data
+}
+
+func TestJSONParserDefaultFields(t *testing.T) {
+	parser := New(noop.New(), Fields{})
+	msg, err := parser.Parse(message.NewMessage([]byte(`{"timestamp":"2024-03-01T12:00:00.5Z","level":"WARNING","message":"disk almost full","service":"storage","trace_id":1234,"disk":{"name":"/dev/sda1"}}`), nil, message.StatusInfo, 0))
+	require.NoError(t, err)
+
+	require.Equal(t, message.StateStructured, msg.State)
+	assert.Equal(t, "disk almost full", string(msg.GetContent()))
+	assert.Equal(t, message.StatusWarning, msg.Status)
+	assert.Equal(t, time.Date(2024, time.March, 1, 12, 0, 0, 500000000, time.UTC), msg.ServerlessExtra.Timestamp)
+	assert.Equal(t, "storage", msg.ParsingExtra.Service)
+	assert.Equal(t, map[string]interface{}{
+		"message": "disk almost full",
+		"disk":    map[string]interface{}{"name": "/dev/sda1"},
+		"dd":      map[string]interface{}{"trace_id": "1234"},
+	}, render(t, msg))
+}
+
+func TestJSONParserCustomFields(t *testing.T) {
+	parser := New(noop.New(), Fields{Timestamp: "ts", Level: "log.level", Message: "msg"})
+	msg, err := parser.Parse(message.NewMessage([]byte(`{"ts":1709294400123,"log":{"level":"err","logger":"main"},"msg":{"text":"failed"},"level":"ignored"}`), nil, message.StatusInfo, 0))
+	require.NoError(t, err)
+
+	assert.Equal(t, `{"text":"failed"}`, string(msg.GetContent()))
+	assert.Equal(t, message.StatusError, msg.Status)
+	assert.Equal(t, time.UnixMilli(1709294400123).UTC(), msg.ServerlessExtra.Timestamp)
+	assert.Equal(t, map[string]interface{}{
+		"message": `{"text":"failed"}`,
+		"log":     map[string]interface{}{"logger": "main"},
+		"level":   "ignored",
+	}, render(t, msg))
+}
+
+func TestJSONParserAggregatedLines(t *testing.T) {
+	parser := New(noop.New(), Fields{})
+	content := `{\n  "level": "error",\n  "message": "boom",\n  "user": "foo"\n}`
+	msg, err := parser.Parse(message.NewMessage([]byte(content), nil, message.StatusInfo, 0))
+	require.NoError(t, err)
+
+	require.Equal(t, message.StateStructured, msg.State)
+	assert.Equal(t, "boom", string(msg.GetContent()))
+	assert.Equal(t, message.StatusError, msg.Status)
+	assert.Equal(t, "foo", render(t, msg)["user"])
+}
+
+func TestJSONParserKeepsUnknownValues(t *testing.T) {
+	parser := New(noop.New(), Fields{})
+	msg, err := parser.Parse(message.NewMessage([]byte(`{"message":"hello","level":"loud","timestamp":"yesterday"}`), nil, message.StatusInfo, 0))
+	require.NoError(t, err)
+
+	assert.Equal(t, message.StatusInfo, msg.Status)
+	assert.True(t, msg.ServerlessExtra.Timestamp.IsZero())
+	assert.Equal(t, map[string]interface{}{"message": "hello", "level": "loud", "timestamp": "yesterday"}, render(t, msg))
+}
+
+func TestJSONParserLeavesOtherLinesUntouched(t *testing.T) {
+	parser := New(noop.New(), Fields{})
+	for _, content := range []string{
+		`plain text log`,
+		`{"message": "truncated"`,
+		`{"msg":"no message field"}`,
+		`["not", "an", "object"]`,
+		`{"message":"a"} {"message":"b"}`,
+	} {
+		msg, err := parser.Parse(message.NewMessage([]byte(content), nil, message.StatusInfo, 0))
+		require.NoError(t, err)
+		assert.Equal(t, message.StateUnstructured, msg.State, content)
+		assert.Equal(t, content, string(msg.GetContent()))
+	}
+}
+
+func TestJSONParserSkipsPartialLines(t *testing.T) {
+	parser := New(noop.New(), Fields{})
+
+	partial := message.NewMessage([]byte(`{"message":"first part`), nil, message.StatusInfo, 0)
+	partial.ParsingExtra.IsPartial = true
+	msg, err := parser.Parse(partial)
+	require.NoError(t, err)
+	assert.Equal(t, message.StateUnstructured, msg.State)
+
+	// the end of the line can't be decoded on its own
+	msg, err = parser.Parse(message.NewMessage([]byte(`{"message":"end"}`), nil, message.StatusInfo, 0))
+	require.NoError(t, err)
+	assert.Equal(t, message.StateUnstructured, msg.State)
+
+	msg, err = parser.Parse(message.NewMessage([]byte(`{"message":"next line"}`), nil, message.StatusInfo, 0))
+	require.NoError(t, err)
+	assert.Equal(t, message.StateStructured, msg.State)
+}
+
+func TestParseTimestamp(t *testing.T) {
+	expected := time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)
+	for _, value := range []interface{}{
+		"2024-03-01T12:00:00Z",
+		"2024-03-01T13:00:00+01:00",
+		json.Number("1709294400"),
+		json.Number("1709294400.0"),
+		json.Number("1709294400000"),
+		json.Number("1709294400000000"),
+		json.Number("1709294400000000000"),
+	} {
+		ts, ok := parseTimestamp(value)
+		assert.True(t, ok, value)
+		assert.Equal(t, expected, ts, value)
+	}
+
+	for _, value := range []interface{}{"2024-03-01", json.Number("-1"), true} {
+		_, ok := parseTimestamp(value)
+		assert.False(t, ok, value)
+	}
+}
@@ -0,0 +1,322 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+// Package syslog implements a parser for syslog messages, following either
+// RFC 5424 or the BSD syslog format described in RFC 3164.
+package syslog
+
+import (
+	"bytes"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/DataDog/datadog-agent/pkg/logs/internal/parsers"
+	"github.com/DataDog/datadog-agent/pkg/logs/message"
+)
+
+const (
+	// nilValue is used by RFC 5424 for header fields and structured data without value.
+	nilValue = "-"
+
+	// rfc3164TimestampLayout is the `Mmm dd hh:mm:ss` timestamp of RFC 3164,
+	// where the day is space-padded.
+	rfc3164TimestampLayout = "Jan _2 15:04:05"
+)
+
+var (
+	errNoPriority       = errors.New("syslog: missing or invalid PRI part")
+	errInvalidHeader    = errors.New("syslog: invalid RFC 5424 header")
+	errInvalidStructure = errors.New("syslog: invalid RFC 5424 structured data")
+
+	// byteOrderMark can prefix the MSG part of an RFC 5424 message to indicate UTF-8 content.
+	byteOrderMark = []byte{0xEF, 0xBB, 0xBF}
+)
+
+// New creates a new parser that parses syslog messages (RFC 5424 or RFC 3164).
+//
+// The content of the resulting message is the MSG part of the syslog message,
+// while the header fields and the RFC 5424 structured data are stored in a
+// "syslog" attribute of a structured message.  The severity is mapped to the
+// message status, while the timestamp and the hostname, if any, override the
+// message timestamp and hostname.
+//
+// For example: `<165>1 2003-10-11T22:14:15.003Z mymachine.example.com evntslog - ID47 [exampleSDID@32473 iut="3"] An application event`
+func New() parsers.Parser {
+	return &syslogFormat{now: time.Now}
+}
+
+type syslogFormat struct {
+	// now is used to guess the year of RFC 3164 timestamps.
+	now func() time.Time
+}
+
+// Parse implements Parser#Parse
+func (p *syslogFormat) Parse(msg *message.Message) (*message.Message, error) {
+	content := msg.GetContent()
+
+	pri, rest, err := parsePriority(content)
+	if err != nil {
+		return msg, err
+	}
+	severity := pri % 8
+
+	attributes := map[string]interface{}{
+		"facility": pri / 8,
+		"severity": severity,
+	}
+	var hdr header
+	var body []byte
+	if len(rest) > 1 && rest[0] == '1' && rest[1] == ' ' {
+		attributes["version"] = 1
+		hdr, body, err = parseRFC5424(rest[2:])
+	} else {
+		hdr, body = parseRFC3164(rest, p.now())
+	}
+	if err != nil {
+		// keep the raw content, but still honor the severity
+		msg.Status = message.SyslogSeverityToStatus(severity)
+		return msg, err
+	}
+	hdr.addTo(attributes)
+
+	structured := message.NewStructuredMessage(
+		&message.BasicStructuredContent{
+			Data: map[string]interface{}{
+				"message": string(body),
+				"syslog":  attributes,
+			},
+		},
+		msg.Origin,
+		message.SyslogSeverityToStatus(severity),
+		msg.IngestionTimestamp,
+	)
+	structured.ParsingExtra = msg.ParsingExtra
+	structured.ServerlessExtra = msg.ServerlessExtra
+	structured.Hostname = msg.Hostname
+	if hdr.hostname != "" {
+		structured.Hostname = hdr.hostname
+	}
+	if !hdr.parsedTimestamp.IsZero() {
+		structured.ServerlessExtra.Timestamp = hdr.parsedTimestamp.UTC()
+	}
+	return structured, nil
+}
+
+// SupportsPartialLine implements Parser#SupportsPartialLine
+func (p *syslogFormat) SupportsPartialLine() bool {
+	return false
+}
+
+// header contains the header fields of a syslog message, empty when absent.
+type header struct {
+	// parsedTimestamp is the parsed timestamp, zero when absent.
+	parsedTimestamp time.Time
+	timestamp       string
+	hostname        string
+	appname         string
+	procid          string
+	msgid           string
+	structuredData  map[string]map[string]string
+}
+
+func (h header) addTo(attributes map[string]interface{}) {
+	for key, value := range map[string]string{
+		"timestamp": h.timestamp,
+		"hostname":  h.hostname,
+		"appname":   h.appname,
+		"procid":    h.procid,
+		"msgid":     h.msgid,
+	} {
+		if value != "" {
+			attributes[key] = value
+		}
+	}
+	if len(h.structuredData) > 0 {
+		attributes["structured_data"] = h.structuredData
+	}
+}
+
+// parsePriority parses the `<PRIVAL>` part which starts every syslog message.
+func parsePriority(content []byte) (int, []byte, error) {
+	if len(content) < 3 || content[0] != '<' {
+		return 0, nil, errNoPriority
+	}
+	end := bytes.IndexByte(content[:min(len(content), 5)], '>')
+	if end < 2 {
+		return 0, nil, errNoPriority
+	}
+	pri, err := strconv.Atoi(string(content[1:end]))
+	if err != nil || pri < 0 || pri > 191 {
+		return 0, nil, errNoPriority
+	}
+	return pri, content[end+1:], nil
+}
+
+// parseRFC5424 parses what follows the `<PRIVAL>1 ` prefix of an RFC 5424 message:
+// TIMESTAMP SP HOSTNAME SP APP-NAME SP PROCID SP MSGID SP STRUCTURED-DATA [SP MSG]
+func parseRFC5424(content []byte) (header, []byte, error) {
+	var hdr header
+	fields := make([]string, 5)
+	for i := range fields {
+		end := bytes.IndexByte(content, ' ')
+		if end <= 0 {
+			return hdr, nil, errInvalidHeader
+		}
+		if value := string(content[:end]); value != nilValue {
+			fields[i] = value
+		}
+		content = content[end+1:]
+	}
+	hdr.timestamp, hdr.hostname, hdr.appname, hdr.procid, hdr.msgid = fields[0], fields[1], fields[2], fields[3], fields[4]
+	if hdr.timestamp != "" {
+		ts, err := time.Parse(time.RFC3339Nano, hdr.timestamp)
+		if err != nil {
+			return hdr, nil, errInvalidHeader
+		}
+		hdr.parsedTimestamp = ts
+	}
+
+	structuredData, rest, err := parseStructuredData(content)
+	if err != nil {
+		return hdr, nil, err
+	}
+	hdr.structuredData = structuredData
+
+	if len(rest) > 0 && rest[0] == ' ' {
+		rest = rest[1:]
+	}
+	return hdr, bytes.TrimPrefix(rest, byteOrderMark), nil
+}
+
+// parseStructuredData parses the STRUCTURED-DATA part of an RFC 5424 message,
+// which is either the nil value or a sequence of SD-ELEMENTs:
+// [SD-ID *(SP PARAM-NAME="PARAM-VALUE")]
+func parseStructuredData(content []byte) (map[string]map[string]string, []byte, error) {
+	if len(content) == 0 {
+		return nil, content, nil
+	}
+	if content[0] != '[' {
+		if content[0] == '-' && (len(content) == 1 || content[1] == ' ') {
+			return nil, content[1:], nil
+		}
+		return nil, nil, errInvalidStructure
+	}
+
+	elements := make(map[string]map[string]string)
+	for len(content) > 0 && content[0] == '[' {
+		content = content[1:]
+		end := bytes.IndexAny(content, " ]")
+		if end <= 0 {
+			return nil, nil, errInvalidStructure
+		}
+		params := make(map[string]string)
+		elements[string(content[:end])] = params
+		content = content[end:]
+
+		for len(content) > 0 && content[0] == ' ' {
+			content = content[1:]
+			eq := bytes.Index(content, []byte(`="`))
+			if eq <= 0 {
+				return nil, nil, errInvalidStructure
+			}
+			name := string(content[:eq])
+			value, rest, err := parseParamValue(content[eq+2:])
+			if err != nil {
+				return nil, nil, err
+			}
+			params[name] = value
+			content = rest
+		}
+		if len(content) == 0 || content[0] != ']' {
+			return nil, nil, errInvalidStructure
+		}
+		content = content[1:]
+	}
+	return elements, content, nil
+}
+
+// parseParamValue parses a PARAM-VALUE up to its closing quote, unescaping `\"`, `\\` and `\]`.
+func parseParamValue(content []byte) (string, []byte, error) {
+	var value []byte
+	for i := 0; i < len(content); i++ {
+		switch content[i] {
+		case '\\':
+			if i+1 < len(content) && (content[i+1] == '"' || content[i+1] == '\\' || content[i+1] == ']') {
+				i++
+			}
+			value = append(value, content[i])
+		case '"':
+			return string(value), content[i+1:], nil
+		default:
+			value = append(value, content[i])
+		}
+	}
+	return "", nil, errInvalidStructure
+}
+
+// parseRFC3164 parses what follows the `<PRIVAL>` part of a BSD syslog message:
+// [TIMESTAMP SP HOSTNAME SP] [TAG[PID]: ]MSG
+// Since the format is loosely followed by senders, every field is optional and
+// the parsing never fails: in the worst case, everything is considered as MSG.
+func parseRFC3164(content []byte, now time.Time) (header, []byte) {
+	var hdr header
+
+	if ts, ok := parseRFC3164Timestamp(content, now); ok {
+		hdr.parsedTimestamp, hdr.timestamp = ts, ts.Format(time.RFC3339)
+		content = content[len(rfc3164TimestampLayout):]
+	} else if sp := bytes.IndexByte(content, ' '); sp > 0 {
+		// some senders use RFC 3339 timestamps
+		if ts, err := time.Parse(time.RFC3339Nano, string(content[:sp])); err == nil {
+			hdr.parsedTimestamp, hdr.timestamp = ts, ts.Format(time.RFC3339Nano)
+			content = content[sp:]
+		}
+	}
+
+	if hdr.timestamp != "" {
+		content = bytes.TrimLeft(content, " ")
+		// the hostname is only expected after a timestamp
+		if sp := bytes.IndexByte(content, ' '); sp > 0 && !isTag(content[:sp]) {
+			hdr.hostname = string(content[:sp])
+			content = content[sp+1:]
+		}
+	}
+
+	if sp := bytes.IndexByte(content, ' '); sp > 0 && isTag(content[:sp]) {
+		tag := content[:sp-1]
+		if open := bytes.IndexByte(tag, '['); open > 0 && tag[len(tag)-1] == ']' {
+			hdr.procid = string(tag[open+1 : len(tag)-1])
+			tag = tag[:open]
+		}
+		hdr.appname = string(tag)
+		content = content[sp+1:]
+	}
+
+	return hdr, content
+}
+
+// parseRFC3164Timestamp parses a `Mmm dd hh:mm:ss` timestamp which doesn't
+// contain any year: the year is guessed so that the timestamp is not in the future.
+func parseRFC3164Timestamp(content []byte, now time.Time) (time.Time, bool) {
+	if len(content) < len(rfc3164TimestampLayout) {
+		return time.Time{}, false
+	}
+	ts, err := time.ParseInLocation(rfc3164TimestampLayout, string(content[:len(rfc3164TimestampLayout)]), now.Location())
+	if err != nil {
+		return time.Time{}, false
+	}
+	ts = ts.AddDate(now.Year(), 0, 0)
+	// allow some clock skew before considering that the message is from last year
+	if ts.After(now.Add(24 * time.Hour)) {
+		ts = ts.AddDate(-1, 0, 0)
+	}
+	return ts, true
+}
+
+// isTag returns true if the given word looks like a TAG, i.e. an application
+// name optionally followed by a PID between brackets, terminated by a colon.
+func isTag(word []byte) bool {
+	return len(word) > 1 && word[len(word)-1] == ':'
+}
@@ -0,0 +1,127 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package syslog
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DataDog/datadog-agent/pkg/logs/message"
+)
+
+func parse(t *testing.T, content string) (*message.Message, map[string]interface{}) {
+	parser := &syslogFormat{now: func() time.Time { return time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC) }}
+	msg, err := parser.Parse(message.NewMessage([]byte(content), nil, "", 0))
+	require.NoError(t, err)
+	require.Equal(t, message.StateStructured, msg.State)
+
+	rendered, err := msg.Render()
+	require.NoError(t, err)
+	var data map[string]interface{}
+	require.NoError(t, json.Unmarshal(rendered, &data))
+	return msg, data
+}
+
+func TestSyslogParserRFC5424(t *testing.T) {
+	msg, data := parse(t, `<165>1 2003-10-11T22:14:15.003Z mymachine.example.com evntslog 1234 ID47 [exampleSDID@32473 iut="3" eventSource="Appli\"cation"][examplePriority@32473 class="high"] An application event`)
+
+	assert.Equal(t, "An application event", string(msg.GetContent()))
+	assert.Equal(t, message.StatusNotice, msg.Status)
+	assert.Equal(t, "mymachine.example.com", msg.Hostname)
+	assert.Equal(t, time.Date(2003, time.October, 11, 22, 14, 15, 3000000, time.UTC), msg.ServerlessExtra.Timestamp)
+	assert.Equal(t, map[string]interface{}{
+		"facility":  float64(20),
+		"severity":  float64(5),
+		"version":   float64(1),
+		"timestamp": "2003-10-11T22:14:15.003Z",
+		"hostname":  "mymachine.example.com",
+		"appname":   "evntslog",
+		"procid":    "1234",
+		"msgid":     "ID47",
+		"structured_data": map[string]interface{}{
+			"exampleSDID@32473":     map[string]interface{}{"iut": "3", "eventSource": `Appli"cation`},
+			"examplePriority@32473": map[string]interface{}{"class": "high"},
+		},
+	}, data["syslog"])
+}
+
+func TestSyslogParserRFC5424NilValues(t *testing.T) {
+	msg, data := parse(t, "<11>1 - - - - - -")
+
+	assert.Equal(t, "", string(msg.GetContent()))
+	assert.Equal(t, message.StatusError, msg.Status)
+	assert.Equal(t, "", msg.Hostname)
+	assert.True(t, msg.ServerlessExtra.Timestamp.IsZero())
+	assert.Equal(t, map[string]interface{}{
+		"facility": float64(1),
+		"severity": float64(3),
+		"version":  float64(1),
+	}, data["syslog"])
+}
+
+func TestSyslogParserRFC5424ByteOrderMark(t *testing.T) {
+	msg, _ := parse(t, "<14>1 2003-10-11T22:14:15Z host app - - - \xEF\xBB\xBFhello")
+	assert.Equal(t, "hello", string(msg.GetContent()))
+}
+
+func TestSyslogParserRFC3164(t *testing.T) {
+	msg, data := parse(t, "<34>Oct  1 22:14:15 mymachine su[42]: 'su root' failed for lonvick on /dev/pts/8")
+
+	assert.Equal(t, "'su root' failed for lonvick on /dev/pts/8", string(msg.GetContent()))
+	assert.Equal(t, message.StatusCritical, msg.Status)
+	assert.Equal(t, "mymachine", msg.Hostname)
+	assert.Equal(t, time.Date(2023, time.October, 1, 22, 14, 15, 0, time.UTC), msg.ServerlessExtra.Timestamp)
+	assert.Equal(t, map[string]interface{}{
+		"facility":  float64(4),
+		"severity":  float64(2),
+		"timestamp": "2023-10-01T22:14:15Z",
+		"hostname":  "mymachine",
+		"appname":   "su",
+		"procid":    "42",
+	}, data["syslog"])
+}
+
+func TestSyslogParserRFC3164WithoutHeader(t *testing.T) {
+	msg, data := parse(t, "<13>kernel: something happened")
+
+	assert.Equal(t, "something happened", string(msg.GetContent()))
+	assert.Equal(t, message.StatusNotice, msg.Status)
+	assert.Equal(t, map[string]interface{}{
+		"facility": float64(1),
+		"severity": float64(5),
+		"appname":  "kernel",
+	}, data["syslog"])
+}
+
+func TestSyslogParserRFC3164WithRFC3339Timestamp(t *testing.T) {
+	msg, data := parse(t, "<15>2024-02-29T10:00:00.5+01:00 host app: debugging")
+
+	assert.Equal(t, "debugging", string(msg.GetContent()))
+	assert.Equal(t, message.StatusDebug, msg.Status)
+	assert.Equal(t, "2024-02-29T10:00:00.5+01:00", data["syslog"].(map[string]interface{})["timestamp"])
+	assert.Equal(t, "host", msg.Hostname)
+	assert.Equal(t, time.Date(2024, time.February, 29, 9, 0, 0, 500000000, time.UTC), msg.ServerlessExtra.Timestamp)
+}
+
+func TestSyslogParserShouldFailWithInvalidInput(t *testing.T) {
+	for _, content := range []string{
+		"no priority",
+		"<abc>1 - - - - - -",
+		"<192>1 - - - - - -",
+		"<13>1 not-a-timestamp host app - - - msg",
+		"<13>1 - host app - - [unterminated",
+		"<13>1 - host app - - [id key=\"value] msg",
+	} {
+		msg, err := New().Parse(message.NewMessage([]byte(content), nil, "", 0))
+		assert.Error(t, err, "for %q", content)
+		assert.Equal(t, message.StateUnstructured, msg.State, "for %q", content)
+		assert.Equal(t, content, string(msg.GetContent()), "for %q", content)
+	}
+}
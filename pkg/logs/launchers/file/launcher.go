@@ -7,8 +7,12 @@
 package file
 
 import (
+	"io"
+	"os"
+	"path/filepath"
 	"regexp"
 	"slices"
+	"strings"
 	"time"
 
 	tagger "github.com/DataDog/datadog-agent/comp/core/tagger/def"
@@ -55,10 +59,19 @@ type Launcher struct {
 	scanPeriod             time.Duration
 	flarecontroller        *flareController.FlareController
 	tagger                 tagger.Component
+	// set to true to decompress and read the compressed files once instead of tailing them.
+	// Use `logs_config.tail_compressed_files`.
+	tailCompressedFiles bool
+	// rotatedOffsets holds the offsets reached by the finished rotated tailers, by path,
+	// to read the compressed rotations of these files from these offsets.
+	rotatedOffsets map[string]int64
+	// finishedCompressedFiles holds the modification time of the compressed files
+	// read entirely, by scan key, to not read them again until they are modified.
+	finishedCompressedFiles map[string]time.Time
 }
 
 // NewLauncher returns a new launcher.
-func NewLauncher(tailingLimit int, tailerSleepDuration time.Duration, validatePodContainerID bool, scanPeriod time.Duration, wildcardMode string, flarecontroller *flareController.FlareController, tagger tagger.Component) *Launcher {
+func NewLauncher(tailingLimit int, tailerSleepDuration time.Duration, validatePodContainerID bool, scanPeriod time.Duration, wildcardMode string, tailCompressedFiles bool, flarecontroller *flareController.FlareController, tagger tagger.Component) *Launcher {
 
 	var wildcardStrategy fileprovider.WildcardSelectionStrategy
 	switch wildcardMode {
@@ -71,19 +84,27 @@ func NewLauncher(tailingLimit int, tailerSleepDuration time.Duration, validatePo
 		wildcardStrategy = fileprovider.WildcardUseFileName
 	}
 
-	return &Launcher{
-		tailingLimit:           tailingLimit,
-		fileProvider:           fileprovider.NewFileProvider(tailingLimit, wildcardStrategy),
-		tailers:                tailers.NewTailerContainer[*tailer.Tailer](),
-		rotatedTailers:         []*tailer.Tailer{},
-		tailerSleepDuration:    tailerSleepDuration,
-		stop:                   make(chan struct{}),
-		done:                   make(chan struct{}),
-		validatePodContainerID: validatePodContainerID,
-		scanPeriod:             scanPeriod,
-		flarecontroller:        flarecontroller,
-		tagger:                 tagger,
+	launcher := &Launcher{
+		tailingLimit:            tailingLimit,
+		fileProvider:            fileprovider.NewFileProvider(tailingLimit, wildcardStrategy),
+		tailers:                 tailers.NewTailerContainer[*tailer.Tailer](),
+		rotatedTailers:          []*tailer.Tailer{},
+		tailerSleepDuration:     tailerSleepDuration,
+		stop:                    make(chan struct{}),
+		done:                    make(chan struct{}),
+		validatePodContainerID:  validatePodContainerID,
+		scanPeriod:              scanPeriod,
+		flarecontroller:         flarecontroller,
+		tagger:                  tagger,
+		tailCompressedFiles:     tailCompressedFiles,
+		rotatedOffsets:          make(map[string]int64),
+		finishedCompressedFiles: make(map[string]time.Time),
 	}
+	if tailCompressedFiles {
+		// the compressed files read entirely don't count against the limit
+		launcher.fileProvider.SetSkipFile(launcher.isCompressedFileFinished)
+	}
+	return launcher
 }
 
 // Start starts the Launcher
@@ -149,6 +170,7 @@ func (s *Launcher) cleanup() {
 // For instance, when a file is logrotated, its tailer will keep tailing the rotated file.
 // The Scanner needs to stop that previous tailer, and start a new one for the new file.
 func (s *Launcher) scan() {
+	s.stopFinishedCompressedTailers()
 	files := s.fileProvider.FilesToTail(s.validatePodContainerID, s.activeSources)
 	filesTailed := make(map[string]bool)
 	var allFiles []string
@@ -171,12 +193,18 @@ func (s *Launcher) scan() {
 		// tailer is tailing the file for the new container).
 		scanKey := file.GetScanKey()
 		tailer, isTailed := s.tailers.Get(scanKey)
+
 		if isTailed && tailer.IsFinished() {
 			// skip this tailer as it must be stopped
 			continue
 		}
 
 		// If the file is currently being tailed, check for rotation and handle it appropriately.
+		// Compressed files are read once and are not rotated.
+		if isTailed && tailer.IsCompressed() {
+			filesTailed[scanKey] = true
+			continue
+		}
 		if isTailed {
 			didRotate, err := tailer.DidRotate()
 			if err != nil {
@@ -213,10 +241,13 @@ func (s *Launcher) scan() {
 	tailersLen := s.tailers.Count()
 	log.Debugf("After stopping tailers, there are %d tailers running.\n", tailersLen)
 
-	for _, file := range files {
+	for _, file := range s.compressedFilesLast(files) {
 		scanKey := file.GetScanKey()
 		isTailed := s.tailers.Contains(scanKey)
 		if !isTailed && tailersLen < s.tailingLimit {
+			if s.isCompressedFileFinished(file) {
+				continue
+			}
 			// create a new tailer tailing from the beginning of the file if no offset has been recorded
 			succeeded := s.startNewTailer(file, config.Beginning)
 			if !succeeded {
@@ -237,12 +268,26 @@ func (s *Launcher) scan() {
 	}
 }
 
+// stopFinishedCompressedTailers stops the tailers which have read their
+// compressed file entirely, for their slots to be available to the next files
+// and for these files not to be selected again until they are modified.
+func (s *Launcher) stopFinishedCompressedTailers() {
+	for _, tailer := range s.tailers.All() {
+		if tailer.IsCompressed() && tailer.IsFinished() {
+			s.markCompressedFileFinished(tailer)
+			s.stopTailer(tailer)
+		}
+	}
+}
+
 // cleanUpRotatedTailers removes any rotated tailers that have stopped from the list
 func (s *Launcher) cleanUpRotatedTailers() {
 	pendingTailers := []*tailer.Tailer{}
 	for _, tailer := range s.rotatedTailers {
 		if !tailer.IsFinished() {
 			pendingTailers = append(pendingTailers, tailer)
+		} else if s.tailCompressedFiles && !tailer.IsCompressed() {
+			s.rotatedOffsets[tailer.Path()] = tailer.ForwardedOffset()
 		}
 	}
 	s.rotatedTailers = pendingTailers
@@ -277,12 +322,12 @@ func (s *Launcher) launchTailers(source *sources.LogSource) {
 		log.Warnf("Could not collect files: %v", err)
 		return
 	}
-	for _, file := range files {
+	for _, file := range s.compressedFilesLast(files) {
 		if s.tailers.Count() >= s.tailingLimit {
 			return
 		}
 
-		if fileprovider.ShouldIgnore(s.validatePodContainerID, file) {
+		if fileprovider.ShouldIgnore(s.validatePodContainerID, file) || s.isCompressedFileFinished(file) {
 			continue
 		}
 		if tailer, isTailed := s.tailers.Get(file.GetScanKey()); isTailed {
@@ -322,6 +367,19 @@ func (s *Launcher) startNewTailer(file *tailer.File, m config.TailingMode) bool
 	if err != nil {
 		log.Warnf("Could not recover offset for file with path %v: %v", file.Path, err)
 	}
+	if tailer.IsCompressed() && s.registry.GetOffset(tailer.Identifier()) == "" {
+		var ready bool
+		if offset, whence, ready = s.compressedFilePosition(file.Path, offset, whence); !ready {
+			// wait for the rotated tailer of the original file to stop
+			return false
+		}
+	}
+	if tailer.IsCompressed() && whence == io.SeekEnd {
+		// compressed files are not appended to, there is nothing to read from their end
+		log.Infof("Skipping %s, its logs have already been read", file.Path)
+		s.markCompressedFileFinished(tailer)
+		return false
+	}
 
 	log.Infof("Starting a new tailer for: %s (offset: %d, whence: %d) for tailer key %s", file.Path, offset, whence, file.GetScanKey())
 	err = tailer.Start(offset, whence)
@@ -396,6 +454,9 @@ func (s *Launcher) createTailer(file *tailer.File, outputChan chan *message.Mess
 		TagAdder:        s.tagger,
 		PipelineMonitor: pipelineMonitor,
 	}
+	if s.tailCompressedFiles {
+		tailerOptions.Compression = tailer.CompressionKind(file.Path)
+	}
 
 	return tailer.NewTailer(tailerOptions)
 }
@@ -406,6 +467,138 @@ func (s *Launcher) createRotatedTailer(t *tailer.Tailer, file *tailer.File, patt
 	return t.NewRotatedTailer(file, channel, monitor, decoder.NewDecoderFromSourceWithPattern(file.Source, pattern, tailerInfo), tailerInfo, s.tagger)
 }
 
+// compressedFilePosition returns the position from where a compressed file
+// without recorded offset should be read, given its position from the tailing
+// mode.  When the file is the latest compressed rotation of a tailed file, e.g.
+// app.log.1.gz for app.log, it is read from where the rotated tailer of the
+// original file stopped, to finish the file without sending logs twice, and
+// the older rotations are skipped.  It returns false while the rotated tailer
+// is still running.
+func (s *Launcher) compressedFilePosition(path string, offset int64, whence int) (int64, int, bool) {
+	for _, rotated := range s.rotatedTailers {
+		if !rotated.IsCompressed() && isCompressedRotation(path, rotated.Path()) && !rotated.IsFinished() {
+			return 0, 0, false
+		}
+	}
+
+	isRotation := false
+	for rotatedPath, rotatedOffset := range s.rotatedOffsets {
+		if !isCompressedRotation(path, rotatedPath) {
+			continue
+		}
+		if latestCompressedRotation(rotatedPath) == path {
+			delete(s.rotatedOffsets, rotatedPath)
+			log.Infof("Reading %s from offset %d, where the tailer of the rotated file %s stopped", path, rotatedOffset, rotatedPath)
+			return rotatedOffset, io.SeekStart, true
+		}
+		isRotation = true
+	}
+	for _, t := range s.tailers.All() {
+		if !t.IsCompressed() && isCompressedRotation(path, t.Path()) {
+			isRotation = true
+		}
+	}
+	if isRotation {
+		// the logs of this file have been read before it was rotated and compressed
+		return 0, io.SeekEnd, true
+	}
+	return offset, whence, true
+}
+
+// compressedFilesLast returns the files with the compressed files last, for
+// the tailers of the original files to be known when their compressed
+// rotations are started.
+func (s *Launcher) compressedFilesLast(files []*tailer.File) []*tailer.File {
+	if !s.tailCompressedFiles {
+		return files
+	}
+	sorted := make([]*tailer.File, 0, len(files))
+	var compressed []*tailer.File
+	for _, file := range files {
+		if tailer.CompressionKind(file.Path) != "" {
+			compressed = append(compressed, file)
+		} else {
+			sorted = append(sorted, file)
+		}
+	}
+	return append(sorted, compressed...)
+}
+
+// isCompressedRotation returns true if path is a compressed rotation of the
+// file original in the same directory: its name is the name of the original
+// file, optionally followed by a rotation suffix made of digits and separators
+// such as ".1" or "-20240301", and by the compression extension, e.g.
+// app.log.1.gz or app.log-20240301.zst for app.log, but not app.logger.gz.
+func isCompressedRotation(path string, original string) bool {
+	if tailer.CompressionKind(path) == "" || filepath.Dir(path) != filepath.Dir(original) {
+		return false
+	}
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	suffix, ok := strings.CutPrefix(name, filepath.Base(original))
+	if !ok {
+		return false
+	}
+	if suffix == "" {
+		return true
+	}
+	if len(suffix) < 2 || !strings.ContainsRune(".-_", rune(suffix[0])) {
+		return false
+	}
+	for _, c := range suffix[1:] {
+		if (c < '0' || c > '9') && !strings.ContainsRune(".-_", c) {
+			return false
+		}
+	}
+	return true
+}
+
+// latestCompressedRotation returns the most recently modified compressed
+// rotation of the given file, or an empty string.
+func latestCompressedRotation(path string) string {
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil {
+		return ""
+	}
+	var latest string
+	var latestModTime time.Time
+	for _, entry := range entries {
+		name := filepath.Join(filepath.Dir(path), entry.Name())
+		if entry.IsDir() || !isCompressedRotation(name, path) {
+			continue
+		}
+		fi, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		if latest == "" || fi.ModTime().After(latestModTime) {
+			latest, latestModTime = name, fi.ModTime()
+		}
+	}
+	return latest
+}
+
+// markCompressedFileFinished records that a compressed file has been read entirely.
+func (s *Launcher) markCompressedFileFinished(t *tailer.Tailer) {
+	if fi, err := os.Stat(t.Path()); err == nil {
+		s.finishedCompressedFiles[t.GetId()] = fi.ModTime()
+	}
+}
+
+// isCompressedFileFinished returns true if a compressed file has been read
+// entirely and has not been modified since.
+func (s *Launcher) isCompressedFileFinished(file *tailer.File) bool {
+	modTime, ok := s.finishedCompressedFiles[file.GetScanKey()]
+	if !ok {
+		return false
+	}
+	fi, err := os.Stat(file.Path)
+	if err != nil || !fi.ModTime().Equal(modTime) {
+		delete(s.finishedCompressedFiles, file.GetScanKey())
+		return false
+	}
+	return true
+}
+
 //nolint:revive // TODO(AML) Fix revive linter
 func CheckProcessTelemetry(stats *procfilestats.ProcessFileStats) {
 	ratio := float64(stats.AgentOpenFiles) / float64(stats.OsFileLimit)
@@ -8,12 +8,16 @@
 package file
 
 import (
+	"bytes"
+	"compress/gzip"
 	"fmt"
 	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
 
 	taggerfxmock "github.com/DataDog/datadog-agent/comp/core/tagger/fx-mock"
@@ -77,7 +81,7 @@ func (suite *LauncherTestSuite) SetupTest() {
 	suite.source = sources.NewLogSource("", &config.LogsConfig{Type: config.FileType, Identifier: suite.configID, Path: suite.testPath})
 	sleepDuration := 20 * time.Millisecond
 	fc := flareController.NewFlareController()
-	suite.s = NewLauncher(suite.openFilesLimit, sleepDuration, false, 10*time.Second, "by_name", fc, suite.tagger)
+	suite.s = NewLauncher(suite.openFilesLimit, sleepDuration, false, 10*time.Second, "by_name", false, fc, suite.tagger)
 	suite.s.pipelineProvider = suite.pipelineProvider
 	suite.s.registry = auditorMock.NewMockRegistry()
 	suite.s.activeSources = append(suite.s.activeSources, suite.source)
@@ -237,7 +241,7 @@ func TestLauncherScanStartNewTailer(t *testing.T) {
 		openFilesLimit := 2
 		sleepDuration := 20 * time.Millisecond
 		fc := flareController.NewFlareController()
-		launcher := NewLauncher(openFilesLimit, sleepDuration, false, 10*time.Second, "by_name", fc, fakeTagger)
+		launcher := NewLauncher(openFilesLimit, sleepDuration, false, 10*time.Second, "by_name", false, fc, fakeTagger)
 		launcher.pipelineProvider = mock.NewMockProvider()
 		launcher.registry = auditorMock.NewMockRegistry()
 		outputChan := launcher.pipelineProvider.NextPipelineChan()
@@ -277,7 +281,7 @@ func TestLauncherWithConcurrentContainerTailer(t *testing.T) {
 	openFilesLimit := 3
 	sleepDuration := 20 * time.Millisecond
 	fc := flareController.NewFlareController()
-	launcher := NewLauncher(openFilesLimit, sleepDuration, false, 10*time.Second, "by_name", fc, fakeTagger)
+	launcher := NewLauncher(openFilesLimit, sleepDuration, false, 10*time.Second, "by_name", false, fc, fakeTagger)
 	launcher.pipelineProvider = mock.NewMockProvider()
 	launcher.registry = auditorMock.NewMockRegistry()
 	outputChan := launcher.pipelineProvider.NextPipelineChan()
@@ -326,7 +330,7 @@ func TestLauncherTailFromTheBeginning(t *testing.T) {
 	openFilesLimit := 3
 	sleepDuration := 20 * time.Millisecond
 	fc := flareController.NewFlareController()
-	launcher := NewLauncher(openFilesLimit, sleepDuration, false, 10*time.Second, "by_name", fc, fakeTagger)
+	launcher := NewLauncher(openFilesLimit, sleepDuration, false, 10*time.Second, "by_name", false, fc, fakeTagger)
 	launcher.pipelineProvider = mock.NewMockProvider()
 	launcher.registry = auditorMock.NewMockRegistry()
 	outputChan := launcher.pipelineProvider.NextPipelineChan()
@@ -380,7 +384,7 @@ func TestLauncherSetTail(t *testing.T) {
 	openFilesLimit := 2
 	sleepDuration := 20 * time.Millisecond
 	fc := flareController.NewFlareController()
-	launcher := NewLauncher(openFilesLimit, sleepDuration, false, 10*time.Second, "by_name", fc, fakeTagger)
+	launcher := NewLauncher(openFilesLimit, sleepDuration, false, 10*time.Second, "by_name", false, fc, fakeTagger)
 	launcher.pipelineProvider = mock.NewMockProvider()
 	launcher.registry = auditorMock.NewMockRegistry()
 
@@ -405,7 +409,7 @@ func TestLauncherConfigIdentifier(t *testing.T) {
 	openFilesLimit := 2
 	sleepDuration := 20 * time.Millisecond
 	fc := flareController.NewFlareController()
-	launcher := NewLauncher(openFilesLimit, sleepDuration, false, 10*time.Second, "by_name", fc, fakeTagger)
+	launcher := NewLauncher(openFilesLimit, sleepDuration, false, 10*time.Second, "by_name", false, fc, fakeTagger)
 	launcher.pipelineProvider = mock.NewMockProvider()
 	launcher.registry = auditorMock.NewMockRegistry()
 
@@ -445,7 +449,7 @@ func TestLauncherScanWithTooManyFiles(t *testing.T) {
 	openFilesLimit := 2
 	sleepDuration := 20 * time.Millisecond
 	fc := flareController.NewFlareController()
-	launcher := NewLauncher(openFilesLimit, sleepDuration, false, 10*time.Second, "by_name", fc, fakeTagger)
+	launcher := NewLauncher(openFilesLimit, sleepDuration, false, 10*time.Second, "by_name", false, fc, fakeTagger)
 	launcher.pipelineProvider = mock.NewMockProvider()
 	launcher.registry = auditorMock.NewMockRegistry()
 	source := sources.NewLogSource("", &config.LogsConfig{Type: config.FileType, Path: path})
@@ -475,7 +479,7 @@ func TestLauncherUpdatesSourceForExistingTailer(t *testing.T) {
 	openFilesLimit := 2
 	sleepDuration := 20 * time.Millisecond
 	fc := flareController.NewFlareController()
-	launcher := NewLauncher(openFilesLimit, sleepDuration, false, 10*time.Second, "by_name", fc, fakeTagger)
+	launcher := NewLauncher(openFilesLimit, sleepDuration, false, 10*time.Second, "by_name", false, fc, fakeTagger)
 	launcher.pipelineProvider = mock.NewMockProvider()
 	launcher.registry = auditorMock.NewMockRegistry()
 
@@ -593,7 +597,7 @@ func TestLauncherScanRecentFilesWithNewFiles(t *testing.T) {
 	createLauncher := func() *Launcher {
 		sleepDuration := 20 * time.Millisecond
 		fc := flareController.NewFlareController()
-		launcher := NewLauncher(openFilesLimit, sleepDuration, false, 10*time.Second, "by_modification_time", fc, fakeTagger)
+		launcher := NewLauncher(openFilesLimit, sleepDuration, false, 10*time.Second, "by_modification_time", false, fc, fakeTagger)
 		launcher.pipelineProvider = mock.NewMockProvider()
 		launcher.registry = auditorMock.NewMockRegistry()
 		logDirectory := fmt.Sprintf("%s/*.log", testDir)
@@ -657,7 +661,7 @@ func TestLauncherFileRotation(t *testing.T) {
 	createLauncher := func() *Launcher {
 		sleepDuration := 20 * time.Millisecond
 		fc := flareController.NewFlareController()
-		launcher := NewLauncher(openFilesLimit, sleepDuration, false, 10*time.Second, "by_name", fc, fakeTagger)
+		launcher := NewLauncher(openFilesLimit, sleepDuration, false, 10*time.Second, "by_name", false, fc, fakeTagger)
 		launcher.pipelineProvider = mock.NewMockProvider()
 		launcher.registry = auditorMock.NewMockRegistry()
 		logDirectory := fmt.Sprintf("%s/*.log", testDir)
@@ -725,7 +729,7 @@ func TestLauncherFileDetectionSingleScan(t *testing.T) {
 	createLauncher := func() *Launcher {
 		sleepDuration := 20 * time.Millisecond
 		fc := flareController.NewFlareController()
-		launcher := NewLauncher(openFilesLimit, sleepDuration, false, 10*time.Second, "by_name", fc, fakeTagger)
+		launcher := NewLauncher(openFilesLimit, sleepDuration, false, 10*time.Second, "by_name", false, fc, fakeTagger)
 		launcher.pipelineProvider = mock.NewMockProvider()
 		launcher.registry = auditorMock.NewMockRegistry()
 		logDirectory := fmt.Sprintf("%s/*.log", testDir)
@@ -758,3 +762,129 @@ func TestLauncherFileDetectionSingleScan(t *testing.T) {
 func getScanKey(path string, source *sources.LogSource) string {
 	return filetailer.NewFile(path, source, false).GetScanKey()
 }
+
+func writeGzipFile(t *testing.T, path string, content string, modTime time.Time) {
+	var buf bytes.Buffer
+	writer := gzip.NewWriter(&buf)
+	_, err := writer.Write([]byte(content))
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+	require.NoError(t, os.WriteFile(path, buf.Bytes(), 0644))
+	require.NoError(t, os.Chtimes(path, modTime, modTime))
+}
+
+func TestLauncherFinishesCompressedRotation(t *testing.T) {
+	testDir := t.TempDir()
+	path := filepath.Join(testDir, "app.log")
+	fakeTagger := taggerfxmock.SetupFakeTagger(t)
+
+	fc := flareController.NewFlareController()
+	launcher := NewLauncher(10, 20*time.Millisecond, false, 10*time.Second, "by_name", true, fc, fakeTagger)
+	launcher.pipelineProvider = mock.NewMockProvider()
+	launcher.registry = auditorMock.NewMockRegistry()
+	outputChan := launcher.pipelineProvider.NextPipelineChan()
+	source := sources.NewLogSource("", &config.LogsConfig{Type: config.FileType, Path: path + "*", TailingMode: "beginning"})
+	launcher.activeSources = append(launcher.activeSources, source)
+	defer launcher.cleanup()
+
+	// an older rotation, already read
+	now := time.Now()
+	writeGzipFile(t, path+".2.gz", "zero\n", now.Add(-time.Hour))
+	require.NoError(t, os.WriteFile(path, []byte("one\ntwo\n"), 0644))
+	launcher.scan()
+	assert.Equal(t, "one", string((<-outputChan).GetContent()))
+	assert.Equal(t, "two", string((<-outputChan).GetContent()))
+
+	// the older rotation is marked as read without being decompressed
+	assert.False(t, launcher.tailers.Contains(path+".2.gz"))
+	assert.Contains(t, launcher.finishedCompressedFiles, path+".2.gz")
+
+	// the file is rotated and compressed while its tailer is stopped before
+	// reading the last line
+	tailer, _ := launcher.tailers.Get(path)
+	tailer.Stop()
+	launcher.tailers.Remove(tailer)
+	launcher.rotatedTailers = append(launcher.rotatedTailers, tailer)
+	require.NoError(t, os.Remove(path))
+	writeGzipFile(t, path+".1.gz", "one\ntwo\nthree\n", now)
+
+	launcher.cleanUpRotatedTailers()
+	launcher.scan()
+	msg := <-outputChan
+	assert.Equal(t, "three", string(msg.GetContent()))
+	assert.Equal(t, "file:"+path+".1.gz", msg.Origin.Identifier)
+	assert.Equal(t, "14", msg.Origin.Offset)
+
+	// the compressed files are read once
+	assert.Contains(t, launcher.finishedCompressedFiles, path+".2.gz")
+	compressedTailer, ok := launcher.tailers.Get(path + ".1.gz")
+	require.True(t, ok)
+	assert.Eventually(t, compressedTailer.IsFinished, 5*time.Second, 10*time.Millisecond)
+	launcher.scan()
+	launcher.scan()
+	assert.Equal(t, 0, launcher.tailers.Count())
+	assert.Len(t, launcher.finishedCompressedFiles, 2)
+	select {
+	case msg := <-outputChan:
+		assert.Fail(t, "unexpected log", string(msg.GetContent()))
+	default:
+	}
+}
+
+func TestIsCompressedRotation(t *testing.T) {
+	dir := t.TempDir()
+	original := filepath.Join(dir, "app.log")
+	for name, expected := range map[string]bool{
+		"app.log.gz":            true,
+		"app.log.1.gz":          true,
+		"app.log-20240301.zst":  true,
+		"app.log.2024-03-01.gz": true,
+		"app.log.1":             false,
+		"app.logger.gz":         false,
+		"app.log.old.gz":        false,
+		"app.log..gz":           false,
+		"other.log.1.gz":        false,
+	} {
+		assert.Equal(t, expected, isCompressedRotation(filepath.Join(dir, name), original), name)
+	}
+	assert.False(t, isCompressedRotation(filepath.Join(dir, "sub", "app.log.1.gz"), original))
+}
+
+func TestLauncherReleasesFinishedCompressedTailers(t *testing.T) {
+	testDir := t.TempDir()
+	fakeTagger := taggerfxmock.SetupFakeTagger(t)
+
+	fc := flareController.NewFlareController()
+	launcher := NewLauncher(1, 20*time.Millisecond, false, 10*time.Second, "by_name", true, fc, fakeTagger)
+	launcher.pipelineProvider = mock.NewMockProvider()
+	launcher.registry = auditorMock.NewMockRegistry()
+	outputChan := launcher.pipelineProvider.NextPipelineChan()
+	source := sources.NewLogSource("", &config.LogsConfig{Type: config.FileType, Path: filepath.Join(testDir, "*.gz"), TailingMode: "beginning"})
+	launcher.activeSources = append(launcher.activeSources, source)
+	defer launcher.cleanup()
+
+	now := time.Now()
+	writeGzipFile(t, filepath.Join(testDir, "a.log.gz"), "first\n", now)
+	writeGzipFile(t, filepath.Join(testDir, "b.log.gz"), "second\n", now)
+
+	// a single file can be opened at a time, the second compressed file is
+	// read once the tailer of the first one is finished
+	launcher.scan()
+	require.Equal(t, 1, launcher.tailers.Count())
+	first := launcher.tailers.All()[0]
+	msg := <-outputChan
+	assert.Equal(t, "file:"+first.Path(), msg.Origin.Identifier)
+	assert.Eventually(t, first.IsFinished, 5*time.Second, 10*time.Millisecond)
+
+	launcher.scan()
+	require.Equal(t, 1, launcher.tailers.Count())
+	second := launcher.tailers.All()[0]
+	assert.NotEqual(t, first.Path(), second.Path())
+	msg = <-outputChan
+	assert.Equal(t, "file:"+second.Path(), msg.Origin.Identifier)
+	assert.Eventually(t, second.IsFinished, 5*time.Second, 10*time.Millisecond)
+
+	launcher.scan()
+	assert.Equal(t, 0, launcher.tailers.Count())
+	assert.Len(t, launcher.finishedCompressedFiles, 2)
+}
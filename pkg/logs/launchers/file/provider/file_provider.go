@@ -80,6 +80,9 @@ type FileProvider struct {
 	selectionMode       selectionStrategy
 	shouldLogErrors     bool
 	reachedNumFileLimit bool
+	// skipFile returns true for the files which must not be tailed, nil when
+	// all the files can be tailed.
+	skipFile func(file *tailer.File) bool
 }
 
 // NewFileProvider returns a new Provider
@@ -132,7 +135,7 @@ func (p *FileProvider) addFilesToTailList(validatePodContainerID bool, inputFile
 	// Add each file one by one up to the limit
 	for j := 0; j < len(inputFiles) && len(filesToTail) < p.filesLimit; j++ {
 		file := inputFiles[j]
-		if ShouldIgnore(validatePodContainerID, file) {
+		if ShouldIgnore(validatePodContainerID, file) || (p.skipFile != nil && p.skipFile(file)) {
 			continue
 		}
 		filesToTail = append(filesToTail, file)
@@ -156,6 +159,12 @@ func (p *FileProvider) addFilesToTailList(validatePodContainerID bool, inputFile
 	return filesToTail
 }
 
+// SetSkipFile sets the function returning true for the files which must not
+// be returned by FilesToTail, e.g. the compressed files already read.
+func (p *FileProvider) SetSkipFile(skipFile func(file *tailer.File) bool) {
+	p.skipFile = skipFile
+}
+
 // FilesToTail returns all the Files matching paths in sources,
 // it cannot return more than filesLimit Files.
 // Files are collected according to the fileProvider's wildcardOrder and selectionMode
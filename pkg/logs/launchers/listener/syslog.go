@@ -0,0 +1,42 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package listener
+
+import (
+	"net"
+
+	"github.com/DataDog/datadog-agent/comp/logs/agent/config"
+	"github.com/DataDog/datadog-agent/pkg/logs/message"
+	"github.com/DataDog/datadog-agent/pkg/logs/pipeline"
+	"github.com/DataDog/datadog-agent/pkg/logs/sources"
+	tailer "github.com/DataDog/datadog-agent/pkg/logs/tailers/socket"
+	"github.com/DataDog/datadog-agent/pkg/util/startstop"
+)
+
+// tailerFactory creates the tailer reading from a connection accepted by a listener.
+type tailerFactory func(*sources.LogSource, net.Conn, chan *message.Message, func(*tailer.Tailer) ([]byte, string, error)) *tailer.Tailer
+
+// NewSyslogListener returns a listener receiving syslog messages over the
+// transport configured for the source: TCP, UDP or a unix stream socket.
+// Messages are framed and parsed by syslog tailers.
+func NewSyslogListener(pipelineProvider pipeline.Provider, source *sources.LogSource, frameSize int) startstop.StartStoppable {
+	switch source.Config.SyslogProtocol() {
+	case config.SyslogProtocolUDP:
+		listener := NewUDPListener(pipelineProvider, source, frameSize)
+		listener.newTailer = tailer.NewSyslogTailer
+		return listener
+	case config.SyslogProtocolUnix:
+		listener := NewTCPListener(pipelineProvider, source, frameSize)
+		listener.network = "unix"
+		listener.address = source.Config.Path
+		listener.newTailer = tailer.NewSyslogTailer
+		return listener
+	default:
+		listener := NewTCPListener(pipelineProvider, source, frameSize)
+		listener.newTailer = tailer.NewSyslogTailer
+		return listener
+	}
+}
@@ -0,0 +1,79 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package listener
+
+import (
+	"fmt"
+	"net"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DataDog/datadog-agent/comp/logs/agent/config"
+	"github.com/DataDog/datadog-agent/pkg/logs/message"
+	"github.com/DataDog/datadog-agent/pkg/logs/pipeline/mock"
+	"github.com/DataDog/datadog-agent/pkg/logs/sources"
+)
+
+func TestSyslogListenerOverTCP(t *testing.T) {
+	pp := mock.NewMockProvider()
+	msgChan := pp.NextPipelineChan()
+	listener := NewSyslogListener(pp, sources.NewLogSource("", &config.LogsConfig{Type: config.SyslogType, Port: tcpTestPort}), 9000).(*TCPListener)
+	listener.Start()
+	defer listener.Stop()
+
+	conn, err := net.Dial("tcp", listener.listener.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	fmt.Fprint(conn, "28 <12>1 - host app - - - first<12>1 - host app - - - second\n")
+	assertSyslogMessage(t, <-msgChan, "first", message.StatusWarning, "host")
+	assertSyslogMessage(t, <-msgChan, "second", message.StatusWarning, "host")
+}
+
+func TestSyslogListenerOverUDP(t *testing.T) {
+	pp := mock.NewMockProvider()
+	msgChan := pp.NextPipelineChan()
+	listener := NewSyslogListener(pp, sources.NewLogSource("", &config.LogsConfig{Type: config.SyslogType, Protocol: "udp", Port: udpTestPort}), 9000).(*UDPListener)
+	listener.Start()
+	defer listener.Stop()
+
+	conn, err := net.Dial("udp", listener.tailer.Conn.LocalAddr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	fmt.Fprint(conn, "<11>Oct 11 22:14:15 router kernel: link down")
+	assertSyslogMessage(t, <-msgChan, "link down", message.StatusError, "router")
+}
+
+func TestSyslogListenerOverUnixSocket(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("unix sockets are not supported on this platform")
+	}
+	path := filepath.Join(t.TempDir(), "syslog.sock")
+
+	pp := mock.NewMockProvider()
+	msgChan := pp.NextPipelineChan()
+	listener := NewSyslogListener(pp, sources.NewLogSource("", &config.LogsConfig{Type: config.SyslogType, Protocol: "unix", Path: path}), 9000).(*TCPListener)
+	listener.Start()
+	defer listener.Stop()
+
+	conn, err := net.Dial("unix", path)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	fmt.Fprint(conn, "<15>1 - - app - - - debugging\n")
+	assertSyslogMessage(t, <-msgChan, "debugging", message.StatusDebug, "")
+}
+
+func assertSyslogMessage(t *testing.T, msg *message.Message, content string, status string, hostname string) {
+	assert.Equal(t, content, string(msg.GetContent()))
+	assert.Equal(t, status, msg.Status)
+	assert.Equal(t, hostname, msg.Hostname)
+}
@@ -8,6 +8,7 @@ package listener
 import (
 	"fmt"
 	"net"
+	"os"
 	"slices"
 	"sync"
 	"time"
@@ -26,6 +27,9 @@ type TCPListener struct {
 	source           *sources.LogSource
 	idleTimeout      time.Duration
 	frameSize        int
+	network          string
+	address          string
+	newTailer        tailerFactory
 	listener         net.Listener
 	tailers          []*tailer.Tailer
 	mu               sync.Mutex
@@ -49,6 +53,9 @@ func NewTCPListener(pipelineProvider pipeline.Provider, source *sources.LogSourc
 		source:           source,
 		idleTimeout:      idleTimeout,
 		frameSize:        frameSize,
+		network:          "tcp",
+		address:          fmt.Sprintf(":%d", source.Config.Port),
+		newTailer:        tailer.NewTailer,
 		tailers:          []*tailer.Tailer{},
 		stop:             make(chan struct{}, 1),
 	}
@@ -56,10 +63,10 @@ func NewTCPListener(pipelineProvider pipeline.Provider, source *sources.LogSourc
 
 // Start starts the listener to accepts new incoming connections.
 func (l *TCPListener) Start() {
-	log.Infof("Starting TCP forwarder on port %d, with read buffer size: %d", l.source.Config.Port, l.frameSize)
+	log.Infof("Starting TCP forwarder on %s, with read buffer size: %d", l.endpoint(), l.frameSize)
 	err := l.startListener()
 	if err != nil {
-		log.Errorf("Can't start TCP forwarder on port %d: %v", l.source.Config.Port, err)
+		log.Errorf("Can't start TCP forwarder on %s: %v", l.endpoint(), err)
 		l.source.Status.Error(err)
 		return
 	}
@@ -69,7 +76,7 @@ func (l *TCPListener) Start() {
 
 // Stop stops the listener from accepting new connections and all the activer tailers.
 func (l *TCPListener) Stop() {
-	log.Infof("Stopping TCP forwarder on port %d", l.source.Config.Port)
+	log.Infof("Stopping TCP forwarder on %s", l.endpoint())
 	l.mu.Lock()
 	defer l.mu.Unlock()
 	l.stop <- struct{}{}
@@ -101,11 +108,11 @@ func (l *TCPListener) run() {
 				return
 			case err != nil:
 				// an error occurred, restart the listener.
-				log.Warnf("Can't listen on port %d, restarting a listener: %v", l.source.Config.Port, err)
+				log.Warnf("Can't listen on %s, restarting a listener: %v", l.endpoint(), err)
 				l.listener.Close()
 				err := l.startListener()
 				if err != nil {
-					log.Errorf("Can't restart listener on port %d: %v", l.source.Config.Port, err)
+					log.Errorf("Can't restart listener on %s: %v", l.endpoint(), err)
 					l.source.Status.Error(err)
 					return
 				}
@@ -121,7 +128,13 @@ func (l *TCPListener) run() {
 
 // startListener starts a new listener, returns an error if it failed.
 func (l *TCPListener) startListener() error {
-	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", l.source.Config.Port))
+	if l.network == "unix" {
+		// remove the socket file left behind by a previous run, if any
+		if fi, err := os.Stat(l.address); err == nil && fi.Mode()&os.ModeSocket != 0 {
+			os.Remove(l.address)
+		}
+	}
+	listener, err := net.Listen(l.network, l.address)
 	if err != nil {
 		return err
 	}
@@ -141,18 +154,30 @@ func (l *TCPListener) read(tailer *tailer.Tailer) ([]byte, string, error) {
 		go l.stopTailer(tailer)
 		return nil, "", err
 	}
-	return frame[:n], tailer.Conn.RemoteAddr().String(), nil
+	var remoteAddr string
+	if addr := tailer.Conn.RemoteAddr(); addr != nil {
+		remoteAddr = addr.String()
+	}
+	return frame[:n], remoteAddr, nil
 }
 
 // startTailer creates and starts a new tailer that reads from the connection.
 func (l *TCPListener) startTailer(conn net.Conn) {
 	l.mu.Lock()
 	defer l.mu.Unlock()
-	tailer := tailer.NewTailer(l.source, conn, l.pipelineProvider.NextPipelineChan(), l.read)
+	tailer := l.newTailer(l.source, conn, l.pipelineProvider.NextPipelineChan(), l.read)
 	l.tailers = append(l.tailers, tailer)
 	tailer.Start()
 }
 
+// endpoint returns a description of where the listener accepts connections, for logging.
+func (l *TCPListener) endpoint() string {
+	if l.network == "unix" {
+		return "unix socket " + l.address
+	}
+	return fmt.Sprintf("port %d", l.source.Config.Port)
+}
+
 // stopTailer stops the tailer.
 func (l *TCPListener) stopTailer(tailer *tailer.Tailer) {
 	l.mu.Lock()
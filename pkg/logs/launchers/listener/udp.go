@@ -32,6 +32,7 @@ type UDPListener struct {
 	pipelineProvider pipeline.Provider
 	source           *sources.LogSource
 	frameSize        int
+	newTailer        tailerFactory
 	tailer           *tailer.Tailer
 	Conn             net.UDPConn
 }
@@ -42,6 +43,7 @@ func NewUDPListener(pipelineProvider pipeline.Provider, source *sources.LogSourc
 		pipelineProvider: pipelineProvider,
 		source:           source,
 		frameSize:        frameSize,
+		newTailer:        tailer.NewTailer,
 	}
 }
 
@@ -71,7 +73,7 @@ func (l *UDPListener) startNewTailer() error {
 	if err != nil {
 		return err
 	}
-	l.tailer = tailer.NewTailer(l.source, conn, l.pipelineProvider.NextPipelineChan(), l.read)
+	l.tailer = l.newTailer(l.source, conn, l.pipelineProvider.NextPipelineChan(), l.read)
 	l.tailer.Start()
 	return nil
 }
@@ -9,6 +9,7 @@ package message
 import (
 	"encoding/json"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/DataDog/datadog-agent/pkg/logs/sources"
@@ -38,6 +39,23 @@ type Payload struct {
 	Encoding string
 	// The size of the unencoded payload
 	UnencodedSize int
+	// The payloads to be sent to the endpoints using another format or
+	// compression than the main endpoint, or receiving a subset of the logs,
+	// keyed by the encoding key of the endpoints
+	Variants map[string]*PayloadVariant
+}
+
+// PayloadVariant is a payload encoded for the endpoints of a same encoding key
+type PayloadVariant struct {
+	// The encoded bytes to be sent to the endpoints
+	Encoded []byte
+	// The content encoding
+	Encoding string
+	// The size of the unencoded payload
+	UnencodedSize int
+	// The number of messages encoded in the payload, the endpoints don't
+	// receive the payload when zero
+	Count int
 }
 
 func NewPayload(messages []*Message, encoded []byte, encoding string, unencodedSize int) *Payload {
@@ -118,7 +136,8 @@ type MessageMetadata struct {
 // In `StateStructured`, `Content` is empty and the log information are in `StructuredContent`.
 // In `StateRendered`, `Content` contains rendered data (from raw/structured logs to something
 // ready to be encoded), the rest should not be used.
-// In `StateEncoded`, `Content` contains the encoded data, the rest should not be used.
+// In `StateEncoded`, `Content` contains the encoded data, the rendered data is kept for the
+// senders formatting the message for other intakes (see `GetRenderedContent`).
 //
 // Note that there is no state distinction between parsed and unparsed content as none was needed
 // for the current implementation, but it is a potential future change with a `StateParsed` state.
@@ -127,7 +146,9 @@ type MessageContent struct { //nolint:revive
 	content []byte
 	// structured content
 	structuredContent StructuredContent
-	State             MessageContentState
+	// rendered content, kept once the message is encoded
+	rendered []byte
+	State    MessageContentState
 }
 
 // MessageContentState is used to represent the MessageContent state.
@@ -186,6 +207,21 @@ func (m *MessageContent) SetContent(content []byte) {
 	}
 }
 
+// GetRenderedContent returns the content of the message as it was before being
+// encoded, for an encoded message, and its content otherwise.
+func (m *MessageContent) GetRenderedContent() []byte {
+	if m.State == StateEncoded {
+		return m.rendered
+	}
+	return m.content
+}
+
+// GetStructuredContent returns the structured content of the message, nil for an
+// unstructured message. It is kept once the message is rendered and encoded.
+func (m *MessageContent) GetStructuredContent() StructuredContent {
+	return m.structuredContent
+}
+
 // SetRendered sets the content for the MessageContent and sets MessageContent state to rendered.
 func (m *MessageContent) SetRendered(content []byte) {
 	m.content = content
@@ -193,11 +229,55 @@ func (m *MessageContent) SetRendered(content []byte) {
 }
 
 // SetEncoded sets the content for the MessageContent and sets MessageContent state to encoded.
+// The rendered content is kept, see `GetRenderedContent`.
 func (m *MessageContent) SetEncoded(content []byte) {
+	if m.State == StateRendered {
+		m.rendered = m.content
+	}
 	m.content = content
 	m.State = StateEncoded
 }
 
+// SetAttribute stores a structured attribute alongside the message content.
+// Nested attributes are given with a dot-separated key, e.g. "http.status_code".
+// An unstructured message is turned into a structured one, rendered as a JSON
+// object holding the content in its "message" key and the attributes.
+// Returns false if the attribute can't be stored: the "message" key is reserved
+// and only structured messages built on BasicStructuredContent support attributes.
+func (m *MessageContent) SetAttribute(key string, value interface{}) bool {
+	if key == "" || key == "message" {
+		return false
+	}
+	switch m.State {
+	case StateUnstructured:
+		m.structuredContent = &BasicStructuredContent{
+			Data: map[string]interface{}{"message": string(m.content)},
+		}
+		m.content = nil
+		m.State = StateStructured
+	case StateStructured:
+	default:
+		return false
+	}
+	content, ok := m.structuredContent.(*BasicStructuredContent)
+	if !ok {
+		return false
+	}
+
+	data := content.Data
+	path := strings.Split(key, ".")
+	for _, k := range path[:len(path)-1] {
+		nested, ok := data[k].(map[string]interface{})
+		if !ok {
+			nested = make(map[string]interface{})
+			data[k] = nested
+		}
+		data = nested
+	}
+	data[path[len(path)-1]] = value
+	return true
+}
+
 // ParsingExtra ships extra information parsers want to make available
 // to the rest of the pipeline.
 // E.g. Timestamp is used by the docker parsers to transmit a tailing offset.
@@ -208,12 +288,15 @@ type ParsingExtra struct {
 	IsTruncated bool
 	IsMultiLine bool
 	Tags        []string
+	// Used by the JSON parser to transmit the service of the log, used when
+	// the log source doesn't define one.
+	Service string
 }
 
 // ServerlessExtra ships extra information from logs processing in serverless envs.
 type ServerlessExtra struct {
 	// Optional. Must be UTC. If not provided, time.Now().UTC() will be used
-	// Used in the Serverless Agent
+	// Used in the Serverless Agent, and by the JSON parser
 	Timestamp time.Time
 	// Optional.
 	// Used in the Serverless Agent
@@ -25,6 +25,17 @@ func TestMessage(t *testing.T) {
 	assert.Equal(t, StatusInfo, message.GetStatus())
 }
 
+func TestEncodedMessageKeepsRenderedContent(t *testing.T) {
+	message := NewMessage([]byte("hello"), nil, "", 0)
+	assert.Equal(t, "hello", string(message.GetRenderedContent()))
+
+	message.SetRendered([]byte("hello world"))
+	message.SetEncoded([]byte(`{"message":"hello world"}`))
+	assert.Equal(t, `{"message":"hello world"}`, string(message.GetContent()))
+	assert.Equal(t, "hello world", string(message.GetRenderedContent()))
+	assert.Nil(t, message.GetStructuredContent())
+}
+
 func TestNewPayload(t *testing.T) {
 	messages := []*Message{
 		NewMessage([]byte("hello"), nil, "", 0),
@@ -118,3 +129,29 @@ func TestPayloadAllowsMessageContentGC(t *testing.T) {
 	assert.Equal(t, 1, len(payload.MessageMetas))
 	assert.Equal(t, int64(2), payload.MessageMetas[0].IngestionTimestamp)
 }
+
+func TestSetAttribute(t *testing.T) {
+	msg := NewMessage([]byte("GET /index.html 200"), nil, StatusInfo, 0)
+
+	assert.True(t, msg.SetAttribute("http.method", "GET"))
+	assert.True(t, msg.SetAttribute("http.status_code", 200))
+	assert.True(t, msg.SetAttribute("duration", 1.5))
+	assert.False(t, msg.SetAttribute("message", "overridden"))
+
+	assert.Equal(t, StateStructured, msg.State)
+	assert.Equal(t, "GET /index.html 200", string(msg.GetContent()))
+
+	rendered, err := msg.Render()
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"message":"GET /index.html 200","http":{"method":"GET","status_code":200},"duration":1.5}`, string(rendered))
+
+	// structured content not supporting attributes
+	msg = NewStructuredMessage(&testStructuredContent{}, nil, StatusInfo, 0)
+	assert.False(t, msg.SetAttribute("foo", "bar"))
+}
+
+type testStructuredContent struct{}
+
+func (c *testStructuredContent) Render() ([]byte, error) { return nil, nil }
+func (c *testStructuredContent) GetContent() []byte      { return nil }
+func (c *testStructuredContent) SetContent([]byte)       {}
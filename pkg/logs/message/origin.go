@@ -19,6 +19,8 @@ type Origin struct {
 	service    string
 	source     string
 	tags       []string
+	// serviceOverride takes precedence over the service of the configuration
+	serviceOverride string
 }
 
 // NewOrigin returns a new Origin
@@ -111,9 +113,18 @@ func (o *Origin) SetService(service string) {
 	o.service = service
 }
 
-// Service returns the service of the configuration if set or the service of the message,
-// if none are defined, returns an empty string by default.
+// OverrideService sets a service taking precedence over the service of the configuration,
+// e.g. the service extracted from the content of the message by a processing rule.
+func (o *Origin) OverrideService(service string) {
+	o.serviceOverride = service
+}
+
+// Service returns the overridden service if set, then the service of the configuration if set
+// or the service of the message, if none are defined, returns an empty string by default.
 func (o *Origin) Service() string {
+	if o.serviceOverride != "" {
+		return o.serviceOverride
+	}
 	if o.LogSource.Config.Service != "" {
 		return o.LogSource.Config.Service
 	}
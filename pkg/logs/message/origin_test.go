@@ -121,3 +121,10 @@ func TestDefaultServiceValueIsServiceFromConfig(t *testing.T) {
 	origin.SetService("bar")
 	assert.Equal(t, "bar", origin.Service())
 }
+
+func TestOverriddenServiceTakesPrecedence(t *testing.T) {
+	origin := NewOrigin(sources.NewLogSource("", &config.LogsConfig{Service: "foo"}))
+	origin.SetService("bar")
+	origin.OverrideService("baz")
+	assert.Equal(t, "baz", origin.Service())
+}
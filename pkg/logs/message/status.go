@@ -5,6 +5,8 @@
 
 package message
 
+import "strings"
+
 // Status values
 const (
 	StatusEmergency = "emergency"
@@ -48,3 +50,49 @@ func StatusToSeverity(status string) []byte {
 	}
 	return SevInfo
 }
+
+// syslogSeverityStatuses maps the numerical syslog severity levels (RFC 5424, 0 to 7)
+// to their statuses.
+var syslogSeverityStatuses = []string{
+	StatusEmergency,
+	StatusAlert,
+	StatusCritical,
+	StatusError,
+	StatusWarning,
+	StatusNotice,
+	StatusInfo,
+	StatusDebug,
+}
+
+// SyslogSeverityToStatus transforms a numerical syslog severity into a status.
+func SyslogSeverityToStatus(severity int) string {
+	if severity >= 0 && severity < len(syslogSeverityStatuses) {
+		return syslogSeverityStatuses[severity]
+	}
+	return StatusInfo
+}
+
+// LevelToStatus transforms a log level, as commonly written by logging
+// libraries (e.g. "WARNING", "err", "fatal"), into a status.
+// Returns false if the level is not recognized.
+func LevelToStatus(level string) (string, bool) {
+	switch strings.ToLower(strings.TrimSpace(level)) {
+	case "emerg", "emergency", "panic":
+		return StatusEmergency, true
+	case "alert":
+		return StatusAlert, true
+	case "crit", "critical", "fatal", "severe":
+		return StatusCritical, true
+	case "err", "error", "eror":
+		return StatusError, true
+	case "warn", "warning":
+		return StatusWarning, true
+	case "notice":
+		return StatusNotice, true
+	case "info", "information", "informational":
+		return StatusInfo, true
+	case "debug", "trace", "verbose":
+		return StatusDebug, true
+	}
+	return "", false
+}
@@ -33,3 +33,31 @@ func TestStatusToSeverity(t *testing.T) {
 	// default value should be "info"
 	assert.Equal(t, 0, bytes.Compare(SevInfo, StatusToSeverity("foo")))
 }
+
+func TestSyslogSeverityToStatus(t *testing.T) {
+	assert.Equal(t, StatusEmergency, SyslogSeverityToStatus(0))
+	assert.Equal(t, StatusError, SyslogSeverityToStatus(3))
+	assert.Equal(t, StatusWarning, SyslogSeverityToStatus(4))
+	assert.Equal(t, StatusDebug, SyslogSeverityToStatus(7))
+
+	// default value should be "info"
+	assert.Equal(t, StatusInfo, SyslogSeverityToStatus(-1))
+	assert.Equal(t, StatusInfo, SyslogSeverityToStatus(8))
+}
+
+func TestLevelToStatus(t *testing.T) {
+	for level, expected := range map[string]string{
+		"FATAL":   StatusCritical,
+		"err":     StatusError,
+		"Warning": StatusWarning,
+		" info ":  StatusInfo,
+		"trace":   StatusDebug,
+	} {
+		status, ok := LevelToStatus(level)
+		assert.True(t, ok, level)
+		assert.Equal(t, expected, status, level)
+	}
+
+	_, ok := LevelToStatus("foo")
+	assert.False(t, ok)
+}
@@ -85,6 +85,12 @@ var (
 	// TlmLogsDiscardedFromSDSBuffer how many messages were dropped when waiting for an SDS configuration because the buffer is full
 	TlmLogsDiscardedFromSDSBuffer = telemetry.NewCounter("logs", "sds__dropped_from_buffer", nil, "Count of messages dropped from the buffer while waiting for an SDS configuration")
 
+	// TlmLogsDeduplicated is the number of duplicated logs collapsed by the processor.
+	TlmLogsDeduplicated = telemetry.NewCounter("logs", "deduplicated", nil, "Count of duplicated logs collapsed by the processor")
+
+	// TlmGeneratedMetricsDropped is the number of metrics generated from logs which were dropped as the processor has no metric sender.
+	TlmGeneratedMetricsDropped = telemetry.NewCounter("logs", "generated_metrics_dropped", nil, "Count of metrics generated from logs dropped because no metric sender is available")
+
 	// TlmUtilizationRatio is the utilization ratio of a component.
 	// Utilization ratio is calculated as the ratio of time spent in use to the total time.
 	// This metric is internally sampled and exposed as an ewma in order to produce a useable value.
@@ -0,0 +1,18 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+// Package patterns normalizes the logs the way the auto multiline detection
+// tokenizes them, for the components outside of pkg/logs.
+package patterns
+
+import (
+	automultilinedetection "github.com/DataDog/datadog-agent/pkg/logs/internal/decoder/auto_multiline_detection"
+)
+
+// MaskDigits returns a copy of the content where each run of digits is
+// replaced by a single 0. It is the digit masker of the logs deduplication.
+func MaskDigits(content []byte) []byte {
+	return automultilinedetection.MaskDigits(content)
+}
@@ -41,6 +41,9 @@ func NewPipeline(
 	hostname hostnameinterface.Component,
 	cfg pkgconfigmodel.Reader,
 	compression logscompression.Component,
+	metricSender processor.MetricSender,
+	digitMasker processor.DigitMasker,
+	samplers *processor.Samplers,
 ) *Pipeline {
 	strategyInput := make(chan *message.Message, pkgconfigsetup.Datadog().GetInt("logs_config.message_channel_size"))
 	flushChan := make(chan struct{})
@@ -59,7 +62,7 @@ func NewPipeline(
 
 	inputChan := make(chan *message.Message, pkgconfigsetup.Datadog().GetInt("logs_config.message_channel_size"))
 	processor := processor.New(cfg, inputChan, strategyInput, processingRules,
-		encoder, diagnosticMessageReceiver, hostname, senderImpl.PipelineMonitor())
+		encoder, diagnosticMessageReceiver, hostname, senderImpl.PipelineMonitor(), metricSender, digitMasker, samplers)
 
 	return &Pipeline{
 		InputChan:       inputChan,
@@ -105,7 +108,7 @@ func getStrategy(
 			encoder = compressor.NewCompressor(endpoints.Main.CompressionKind, endpoints.Main.CompressionLevel)
 		}
 
-		return sender.NewBatchStrategy(inputChan, outputChan, flushChan, serverlessMeta, sender.ArraySerializer, endpoints.BatchWait, endpoints.BatchMaxSize, endpoints.BatchMaxContentSize, "logs", encoder, pipelineMonitor)
+		return sender.NewBatchStrategy(inputChan, outputChan, flushChan, serverlessMeta, sender.ArraySerializer, sender.EndpointEncodings(endpoints, compressor), endpoints.BatchWait, endpoints.BatchMaxSize, endpoints.BatchMaxContentSize, "logs", encoder, pipelineMonitor)
 	}
 	return sender.NewStreamStrategy(inputChan, outputChan, compressor.NewCompressor(compressioncommon.NoneKind, 0))
 }
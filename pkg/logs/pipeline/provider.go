@@ -21,6 +21,7 @@ import (
 	"github.com/DataDog/datadog-agent/pkg/logs/diagnostic"
 	"github.com/DataDog/datadog-agent/pkg/logs/message"
 	"github.com/DataDog/datadog-agent/pkg/logs/metrics"
+	"github.com/DataDog/datadog-agent/pkg/logs/processor"
 	"github.com/DataDog/datadog-agent/pkg/logs/sds"
 	"github.com/DataDog/datadog-agent/pkg/logs/sender"
 	httpsender "github.com/DataDog/datadog-agent/pkg/logs/sender/http"
@@ -70,9 +71,13 @@ type provider struct {
 	currentPipelineIndex *atomic.Uint32
 	serverlessMeta       sender.ServerlessMeta
 
-	hostname    hostnameinterface.Component
-	cfg         pkgconfigmodel.Reader
-	compression logscompression.Component
+	hostname     hostnameinterface.Component
+	cfg          pkgconfigmodel.Reader
+	compression  logscompression.Component
+	metricSender processor.MetricSender
+	digitMasker  processor.DigitMasker
+	// samplers holds the state of the sample rules, shared by the pipelines.
+	samplers *processor.Samplers
 }
 
 // NewProvider returns a new Provider
@@ -87,6 +92,8 @@ func NewProvider(
 	hostname hostnameinterface.Component,
 	cfg pkgconfigmodel.Reader,
 	compression logscompression.Component,
+	metricSender processor.MetricSender,
+	digitMasker processor.DigitMasker,
 	legacyMode bool,
 	serverless bool,
 ) Provider {
@@ -107,6 +114,8 @@ func NewProvider(
 		hostname,
 		cfg,
 		compression,
+		metricSender,
+		digitMasker,
 		serverlessMeta,
 		senderImpl,
 	)
@@ -213,6 +222,8 @@ func newProvider(
 	hostname hostnameinterface.Component,
 	cfg pkgconfigmodel.Reader,
 	compression logscompression.Component,
+	metricSender processor.MetricSender,
+	digitMasker processor.DigitMasker,
 	serverlessMeta sender.ServerlessMeta,
 	senderImpl sender.PipelineComponent,
 ) Provider {
@@ -228,6 +239,9 @@ func newProvider(
 		hostname:                  hostname,
 		cfg:                       cfg,
 		compression:               compression,
+		metricSender:              metricSender,
+		digitMasker:               digitMasker,
+		samplers:                  processor.NewSamplers(),
 	}
 }
 
@@ -245,6 +259,9 @@ func (p *provider) Start() {
 			p.hostname,
 			p.cfg,
 			p.compression,
+			p.metricSender,
+			p.digitMasker,
+			p.samplers,
 		)
 		pipeline.Start()
 		p.pipelines = append(p.pipelines, pipeline)
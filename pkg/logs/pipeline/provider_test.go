@@ -230,6 +230,8 @@ func TestProviderConfigurations(t *testing.T) {
 				nil, // hostname
 				cfg,
 				compression,
+				nil, // metricSender
+				nil, // digitMasker
 				tc.legacyMode,
 				tc.serverless,
 			)
@@ -298,6 +300,8 @@ func TestPipelineChannelDistribution(t *testing.T) {
 				nil, // hostname
 				cfg,
 				compression,
+				nil,   // metricSender
+				nil,   // digitMasker
 				false, // legacy mode
 				false, // serverless
 			)
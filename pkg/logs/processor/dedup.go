@@ -0,0 +1,135 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package processor
+
+import (
+	"hash/fnv"
+	"time"
+
+	pkgconfigmodel "github.com/DataDog/datadog-agent/pkg/config/model"
+	"github.com/DataDog/datadog-agent/pkg/logs/message"
+	"github.com/DataDog/datadog-agent/pkg/logs/metrics"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+const (
+	// repeatCountAttribute is the attribute holding the number of identical
+	// logs a deduplicated log stands for.
+	repeatCountAttribute = "repeat_count"
+
+	// dedupFlushInterval is the interval at which the expired windows are flushed.
+	dedupFlushInterval = time.Second
+)
+
+// DigitMasker masks the digits of the contents fingerprinted by the
+// deduplication when logs_config.dedup.mask_digits is set, so that the logs
+// are normalized the same way the auto multiline detection tokenizes them.
+type DigitMasker func(content []byte) []byte
+
+// deduplicator collapses the identical logs of an origin received within a
+// window into a single log: the logs are held until the window started by their
+// first occurrence expires, and the last of them is then sent, with the number
+// of identical logs in its repeat_count attribute when there are several. The
+// last log is sent so that the auditor commits the offset of all of them.
+// The logs which can't be tracked because of max_entries are sent right away.
+// A deduplicator is not thread safe.
+type deduplicator struct {
+	window time.Duration
+	// maskDigits masks the digits of the contents, nil when they are not masked.
+	maskDigits DigitMasker
+	maxEntries int
+	now        func() time.Time
+	entries    map[uint64]*dedupEntry
+}
+
+// dedupEntry tracks the identical logs within a window.
+type dedupEntry struct {
+	expiry time.Time
+	last   *message.Message
+	count  int
+}
+
+// newDeduplicator returns a deduplicator configured with the logs_config.dedup
+// settings, or nil if the deduplication is disabled.
+func newDeduplicator(cfg pkgconfigmodel.Reader, digitMasker DigitMasker) *deduplicator {
+	if cfg == nil || !cfg.GetBool("logs_config.dedup.enabled") {
+		return nil
+	}
+	window := cfg.GetDuration("logs_config.dedup.window")
+	if window <= 0 {
+		return nil
+	}
+	var maskDigits DigitMasker
+	if cfg.GetBool("logs_config.dedup.mask_digits") {
+		if maskDigits = digitMasker; maskDigits == nil {
+			log.Warn("logs_config.dedup.mask_digits is not supported by this agent, logs are deduplicated without masking their digits")
+		}
+	}
+	return &deduplicator{
+		window:     window,
+		maskDigits: maskDigits,
+		maxEntries: cfg.GetInt("logs_config.dedup.max_entries"),
+		now:        time.Now,
+		entries:    make(map[uint64]*dedupEntry),
+	}
+}
+
+// add returns true if the message should be sent right away, and false if it
+// is held until the end of its window.
+func (d *deduplicator) add(msg *message.Message) bool {
+	fingerprint := d.fingerprint(msg)
+	if entry, found := d.entries[fingerprint]; found {
+		entry.last = msg
+		entry.count++
+		metrics.TlmLogsDeduplicated.Inc()
+		return false
+	}
+	if d.maxEntries > 0 && len(d.entries) >= d.maxEntries {
+		return true
+	}
+	d.entries[fingerprint] = &dedupEntry{expiry: d.now().Add(d.window), last: msg, count: 1}
+	return false
+}
+
+// flush returns the logs standing for the expired windows, or for all the
+// windows when force is true, and forgets about these windows.
+func (d *deduplicator) flush(force bool) []*message.Message {
+	var msgs []*message.Message
+	now := d.now()
+	for fingerprint, entry := range d.entries {
+		if !force && now.Before(entry.expiry) {
+			continue
+		}
+		delete(d.entries, fingerprint)
+		if entry.count > 1 {
+			entry.last.SetAttribute(repeatCountAttribute, entry.count)
+		}
+		msgs = append(msgs, entry.last)
+	}
+	return msgs
+}
+
+// fingerprint hashes the origin, status and content of a message, whose digits
+// are masked when maskDigits is set.
+func (d *deduplicator) fingerprint(msg *message.Message) uint64 {
+	h := fnv.New64a()
+	if msg.Origin != nil {
+		h.Write([]byte(msg.Origin.Identifier))
+		if msg.Origin.LogSource != nil {
+			h.Write([]byte(msg.Origin.LogSource.Name))
+		}
+	}
+	h.Write([]byte{0})
+	h.Write([]byte(msg.Status))
+	h.Write([]byte{0})
+
+	content := msg.GetContent()
+	if d.maskDigits != nil {
+		content = d.maskDigits(content)
+	}
+	h.Write(content)
+	return h.Sum64()
+}
@@ -0,0 +1,167 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package processor
+
+import (
+	"encoding/json"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DataDog/datadog-agent/comp/core/hostname/hostnameinterface"
+	"github.com/DataDog/datadog-agent/comp/logs/agent/config"
+	configmock "github.com/DataDog/datadog-agent/pkg/config/mock"
+	"github.com/DataDog/datadog-agent/pkg/logs/diagnostic"
+	"github.com/DataDog/datadog-agent/pkg/logs/message"
+	"github.com/DataDog/datadog-agent/pkg/logs/metrics"
+	"github.com/DataDog/datadog-agent/pkg/logs/sources"
+)
+
+// testDigitMasker stands for the masker of the auto multiline tokenizer.
+func testDigitMasker(content []byte) []byte {
+	return regexp.MustCompile(`[0-9]+`).ReplaceAll(content, []byte("0"))
+}
+
+func newTestDeduplicator(maskDigits bool, now *time.Time) *deduplicator {
+	var masker DigitMasker
+	if maskDigits {
+		masker = testDigitMasker
+	}
+	return &deduplicator{
+		window:     10 * time.Second,
+		maskDigits: masker,
+		maxEntries: 10,
+		now:        func() time.Time { return *now },
+		entries:    make(map[uint64]*dedupEntry),
+	}
+}
+
+func TestNewDeduplicator(t *testing.T) {
+	cfg := configmock.New(t)
+	assert.Nil(t, newDeduplicator(cfg, testDigitMasker))
+	assert.Nil(t, newDeduplicator(nil, testDigitMasker))
+
+	cfg.SetWithoutSource("logs_config.dedup.enabled", true)
+	cfg.SetWithoutSource("logs_config.dedup.window", "30s")
+	d := newDeduplicator(cfg, testDigitMasker)
+	require.NotNil(t, d)
+	assert.Equal(t, 30*time.Second, d.window)
+	assert.Nil(t, d.maskDigits)
+	assert.Equal(t, 10000, d.maxEntries)
+
+	// the digits are masked by the given masker
+	cfg.SetWithoutSource("logs_config.dedup.mask_digits", true)
+	d = newDeduplicator(cfg, testDigitMasker)
+	require.NotNil(t, d)
+	assert.NotNil(t, d.maskDigits)
+	d = newDeduplicator(cfg, nil)
+	require.NotNil(t, d)
+	assert.Nil(t, d.maskDigits)
+}
+
+func TestDeduplicator(t *testing.T) {
+	now := time.Now()
+	d := newTestDeduplicator(false, &now)
+	source := sources.NewLogSource("", &config.LogsConfig{})
+
+	// all the logs are held until the end of their window
+	assert.False(t, d.add(newMessage([]byte("panic: boom"), source, message.StatusError)))
+	info := newMessage([]byte("panic: boom"), source, message.StatusInfo)
+	assert.False(t, d.add(info))
+	other := newMessage([]byte("panic: 42"), source, message.StatusError)
+	assert.False(t, d.add(other))
+	assert.False(t, d.add(newMessage([]byte("panic: boom"), source, message.StatusError)))
+	last := newMessage([]byte("panic: boom"), source, message.StatusError)
+	assert.False(t, d.add(last))
+
+	assert.Empty(t, d.flush(false))
+	now = now.Add(10 * time.Second)
+	msgs := d.flush(false)
+	assert.Empty(t, d.entries)
+
+	// each burst is sent as a single log, the last one, with the number of identical logs
+	require.Len(t, msgs, 3)
+	assert.ElementsMatch(t, []*message.Message{last, info, other}, msgs)
+	assertRepeatCount(t, last, 3)
+	assert.Equal(t, message.StateUnstructured, info.State)
+	assert.Equal(t, "panic: boom", string(info.GetContent()))
+	assert.Equal(t, message.StateUnstructured, other.State)
+
+	// a new window starts with the next occurrence
+	assert.False(t, d.add(newMessage([]byte("panic: boom"), source, message.StatusError)))
+	assert.Len(t, d.flush(true), 1)
+}
+
+func TestDeduplicatorMaskDigits(t *testing.T) {
+	now := time.Now()
+	d := newTestDeduplicator(true, &now)
+	source := sources.NewLogSource("", &config.LogsConfig{})
+
+	assert.False(t, d.add(newMessage([]byte("request 1 took 12ms"), source, "")))
+	last := newMessage([]byte("request 2 took 345ms"), source, "")
+	assert.False(t, d.add(last))
+	assert.False(t, d.add(newMessage([]byte("request a took 345ms"), source, "")))
+
+	msgs := d.flush(true)
+	require.Len(t, msgs, 2)
+	assert.Contains(t, msgs, last)
+	assertRepeatCount(t, last, 2)
+}
+
+func TestDeduplicatorMaxEntries(t *testing.T) {
+	now := time.Now()
+	d := newTestDeduplicator(false, &now)
+	d.maxEntries = 1
+	source := sources.NewLogSource("", &config.LogsConfig{})
+
+	// the logs which can't be tracked are sent right away
+	assert.False(t, d.add(newMessage([]byte("a"), source, "")))
+	assert.True(t, d.add(newMessage([]byte("b"), source, "")))
+	assert.True(t, d.add(newMessage([]byte("b"), source, "")))
+	assert.False(t, d.add(newMessage([]byte("a"), source, "")))
+}
+
+func TestProcessorDeduplication(t *testing.T) {
+	now := time.Now()
+	hostnameComponent, _ := hostnameinterface.NewMock("testHostname")
+	pm := metrics.NewNoopPipelineMonitor("")
+	p := &Processor{
+		encoder:                   RawEncoder,
+		outputChan:                make(chan *message.Message, 10),
+		diagnosticMessageReceiver: diagnostic.NewBufferedMessageReceiver(nil, hostnameComponent),
+		hostname:                  hostnameComponent,
+		dedup:                     newTestDeduplicator(false, &now),
+		pipelineMonitor:           pm,
+		utilization:               pm.MakeUtilizationMonitor("processor"),
+	}
+	source := sources.NewLogSource("", &config.LogsConfig{})
+
+	for i := 0; i < 3; i++ {
+		p.processMessage(newMessage([]byte("crashed"), source, ""))
+	}
+	p.processMessage(newMessage([]byte("restarted"), source, ""))
+	assert.Empty(t, p.outputChan)
+
+	p.flushDuplicates(false)
+	assert.Empty(t, p.outputChan)
+	now = now.Add(10 * time.Second)
+	p.flushDuplicates(false)
+	require.Len(t, p.outputChan, 2)
+	contents := string((<-p.outputChan).GetContent()) + "\n" + string((<-p.outputChan).GetContent())
+	assert.Contains(t, contents, ` {"message":"crashed","repeat_count":3}`)
+	assert.Contains(t, contents, " restarted")
+}
+
+func assertRepeatCount(t *testing.T, msg *message.Message, repeats int) {
+	rendered, err := msg.Render()
+	require.NoError(t, err)
+	var data map[string]interface{}
+	require.NoError(t, json.Unmarshal(rendered, &data))
+	assert.Equal(t, float64(repeats), data[repeatCountAttribute])
+}
@@ -0,0 +1,104 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package processor
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/DataDog/datadog-agent/comp/logs/agent/config"
+	"github.com/DataDog/datadog-agent/pkg/logs/message"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+// messageField is the name of the captured field replacing the content of the message.
+const messageField = "message"
+
+// defaultTimestampLayouts are the layouts tried when an extract_fields rule doesn't specify any timestamp format.
+var defaultTimestampLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04:05.999999999",
+	"02/Jan/2006:15:04:05 -0700",
+	time.RFC1123Z,
+	time.RFC1123,
+}
+
+// extractedField is a field captured by an extract_fields rule, attached to the
+// message as a structured attribute once all the processing rules are applied.
+type extractedField struct {
+	name  string
+	value []byte
+}
+
+// extractFields applies an extract_fields rule whose regex matched the content:
+// the named captures are appended to fields and may override the status,
+// service and timestamp of the message. A field named "message" replaces the
+// content of the message, which is returned.
+func extractFields(rule *config.ProcessingRule, msg *message.Message, content []byte, matches [][]byte, fields []extractedField) ([]byte, []extractedField) {
+	for i, name := range rule.Regex.SubexpNames() {
+		if name == "" || matches[i] == nil {
+			continue
+		}
+		field := config.FieldName(name)
+		value := string(matches[i])
+
+		if field == messageField {
+			content = []byte(value)
+		} else {
+			fields = append(fields, extractedField{name: field, value: []byte(value)})
+		}
+
+		if field == rule.StatusField {
+			if status, ok := message.LevelToStatus(value); ok {
+				msg.Status = status
+			}
+		}
+		if field == rule.ServiceField && value != "" {
+			msg.Origin.OverrideService(value)
+		}
+		if field == rule.TimestampField {
+			if ts, ok := parseTimestamp(value, rule.TimestampFormat); ok {
+				msg.ServerlessExtra.Timestamp = ts.UTC()
+			} else {
+				log.Debugf("processing rule %s: can't parse timestamp %q", rule.Name, value)
+			}
+		}
+	}
+	return content, fields
+}
+
+// attachFields attaches the extracted fields to the message as structured attributes.
+func attachFields(msg *message.Message, fields []extractedField) {
+	for _, field := range fields {
+		if !msg.SetAttribute(field.name, string(field.value)) {
+			log.Debugf("can't attach field %s to the message", field.name)
+		}
+	}
+}
+
+// parseTimestamp parses a captured timestamp using the given layout, or
+// common layouts and epoch timestamps (in seconds or milliseconds) if empty.
+func parseTimestamp(value string, layout string) (time.Time, bool) {
+	if layout != "" {
+		ts, err := time.Parse(layout, value)
+		return ts, err == nil
+	}
+	for _, layout := range defaultTimestampLayouts {
+		if ts, err := time.Parse(layout, value); err == nil {
+			return ts, true
+		}
+	}
+	if epoch, err := strconv.ParseFloat(value, 64); err == nil && epoch > 0 {
+		// consider values too big to be seconds as milliseconds
+		if epoch >= 1e11 {
+			return time.UnixMilli(int64(epoch)), true
+		}
+		return time.UnixMilli(int64(epoch * 1000)), true
+	}
+	return time.Time{}, false
+}
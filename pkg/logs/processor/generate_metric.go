@@ -0,0 +1,90 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package processor
+
+import (
+	"strconv"
+
+	"github.com/DataDog/datadog-agent/comp/logs/agent/config"
+	"github.com/DataDog/datadog-agent/pkg/logs/message"
+	"github.com/DataDog/datadog-agent/pkg/logs/metrics"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+// MetricSender submits the metrics generated from logs by the generate_metric rules.
+// *telemetry.StatsTelemetryProvider implements it.
+type MetricSender interface {
+	Count(metric string, value float64, tags []string)
+	Distribution(metric string, value float64, tags []string)
+}
+
+// generateMetrics submits the metrics of the generate_metric rules matching the
+// content, and returns the first of these rules requiring to drop the log, if any.
+func (p *Processor) generateMetrics(rules []*config.ProcessingRule, msg *message.Message, content []byte) *config.ProcessingRule {
+	var dropRule *config.ProcessingRule
+	for _, rule := range rules {
+		if rule.Type != config.GenerateMetric {
+			continue
+		}
+		matches := rule.Regex.FindSubmatch(content)
+		p.traceRule(msg, rule, matches != nil, content)
+		if matches != nil {
+			p.generateMetric(rule, msg, matches)
+			if rule.DropLog && dropRule == nil {
+				dropRule = rule
+			}
+		}
+	}
+	return dropRule
+}
+
+// generateMetric submits the metric of a generate_metric rule for a matching log,
+// tagged with the source and the service of the log and the configured captured fields.
+func (p *Processor) generateMetric(rule *config.ProcessingRule, msg *message.Message, matches [][]byte) {
+	if p.metricSender == nil {
+		metrics.TlmGeneratedMetricsDropped.Inc()
+		p.noMetricSenderWarning.Do(func() {
+			log.Warnf("processing rule %s: the metrics generated from logs are dropped, as no metric sender is available", rule.Name)
+		})
+		return
+	}
+
+	fields := make(map[string]string)
+	for i, name := range rule.Regex.SubexpNames() {
+		if name != "" && matches[i] != nil {
+			fields[config.FieldName(name)] = string(matches[i])
+		}
+	}
+
+	value := 1.0
+	if rule.ValueField != "" {
+		var err error
+		value, err = strconv.ParseFloat(fields[rule.ValueField], 64)
+		if err != nil {
+			log.Debugf("processing rule %s: can't parse value %q of field %s", rule.Name, fields[rule.ValueField], rule.ValueField)
+			return
+		}
+	}
+
+	tags := make([]string, 0, len(rule.TagFields)+2)
+	if source := msg.Origin.Source(); source != "" {
+		tags = append(tags, "source:"+source)
+	}
+	if service := msg.Origin.Service(); service != "" {
+		tags = append(tags, "service:"+service)
+	}
+	for _, field := range rule.TagFields {
+		if value := fields[field]; value != "" {
+			tags = append(tags, field+":"+value)
+		}
+	}
+
+	if rule.MetricType == config.MetricTypeDistribution {
+		p.metricSender.Distribution(rule.MetricName, value, tags)
+	} else {
+		p.metricSender.Count(rule.MetricName, value, tags)
+	}
+}
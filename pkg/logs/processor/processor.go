@@ -10,6 +10,7 @@ import (
 	"context"
 	"regexp"
 	"sync"
+	"time"
 
 	"github.com/DataDog/datadog-agent/comp/core/hostname/hostnameinterface"
 	"github.com/DataDog/datadog-agent/comp/logs/agent/config"
@@ -40,6 +41,20 @@ type Processor struct {
 	mu                        sync.Mutex
 	hostname                  hostnameinterface.Component
 
+	// metricSender submits the metrics generated from logs by generate_metric rules,
+	// they are dropped when nil.
+	metricSender          MetricSender
+	noMetricSenderWarning sync.Once
+
+	// samplers holds the state of the sample rules, shared by all the processors.
+	samplers *Samplers
+
+	// dedup collapses the duplicated logs, nil when the deduplication is disabled.
+	dedup *deduplicator
+
+	// tracer is notified of the decisions taken on each message, nil when disabled.
+	tracer Tracer
+
 	sds sdsProcessor
 
 	// Telemetry
@@ -63,7 +78,7 @@ type sdsProcessor struct {
 // New returns an initialized Processor.
 func New(cfg pkgconfigmodel.Reader, inputChan, outputChan chan *message.Message, processingRules []*config.ProcessingRule,
 	encoder Encoder, diagnosticMessageReceiver diagnostic.MessageReceiver, hostname hostnameinterface.Component,
-	pipelineMonitor metrics.PipelineMonitor) *Processor {
+	pipelineMonitor metrics.PipelineMonitor, metricSender MetricSender, digitMasker DigitMasker, samplers *Samplers) *Processor {
 
 	waitForSDSConfig := sds.ShouldBufferUntilSDSConfiguration(cfg)
 	maxBufferSize := sds.WaitForConfigurationBufferMaxSize(cfg)
@@ -77,6 +92,9 @@ func New(cfg pkgconfigmodel.Reader, inputChan, outputChan chan *message.Message,
 		done:                      make(chan struct{}),
 		diagnosticMessageReceiver: diagnosticMessageReceiver,
 		hostname:                  hostname,
+		metricSender:              metricSender,
+		samplers:                  samplers,
+		dedup:                     newDeduplicator(cfg, digitMasker),
 		pipelineMonitor:           pipelineMonitor,
 		utilization:               pipelineMonitor.MakeUtilizationMonitor("processor"),
 
@@ -89,6 +107,12 @@ func New(cfg pkgconfigmodel.Reader, inputChan, outputChan chan *message.Message,
 	}
 }
 
+// SetTracer sets the tracer notified of the decisions taken on each message.
+// It must be called before starting the Processor.
+func (p *Processor) SetTracer(tracer Tracer) {
+	p.tracer = tracer
+}
+
 // Start starts the Processor.
 func (p *Processor) Start() {
 	go p.run()
@@ -118,6 +142,7 @@ func (p *Processor) Flush(ctx context.Context) {
 			return
 		default:
 			if len(p.inputChan) == 0 {
+				p.flushDuplicates(true)
 				return
 			}
 			msg := <-p.inputChan
@@ -126,12 +151,32 @@ func (p *Processor) Flush(ctx context.Context) {
 	}
 }
 
+// flushDuplicates sends the logs standing for the identical logs collapsed
+// during the expired windows, or during all the windows when force is true.
+func (p *Processor) flushDuplicates(force bool) {
+	if p.dedup == nil {
+		return
+	}
+	for _, msg := range p.dedup.flush(force) {
+		p.utilization.Start()
+		p.sendMessage(msg)
+		p.utilization.Stop()
+	}
+}
+
 // run starts the processing of the inputChan
 func (p *Processor) run() {
 	defer func() {
 		p.done <- struct{}{}
 	}()
 
+	var dedupFlush <-chan time.Time
+	if p.dedup != nil {
+		ticker := time.NewTicker(dedupFlushInterval)
+		defer ticker.Stop()
+		dedupFlush = ticker.C
+	}
+
 	for {
 		select {
 		// Processing, usual main loop
@@ -139,6 +184,7 @@ func (p *Processor) run() {
 
 		case msg, ok := <-p.inputChan:
 			if !ok { // channel has been closed
+				p.flushDuplicates(true)
 				return
 			}
 
@@ -163,6 +209,14 @@ func (p *Processor) run() {
 			p.mu.Lock()
 			p.applySDSReconfiguration(order)
 			p.mu.Unlock()
+
+		// Deduplication windows
+		// ---------------------
+
+		case <-dedupFlush:
+			p.mu.Lock()
+			p.flushDuplicates(false)
+			p.mu.Unlock()
 		}
 	}
 }
@@ -233,34 +287,53 @@ func (p *Processor) processMessage(msg *message.Message) {
 		metrics.LogsProcessed.Add(1)
 		metrics.TlmLogsProcessed.Inc()
 
-		// render the message
-		rendered, err := msg.Render()
-		if err != nil {
-			log.Error("can't render the msg", err)
+		// hold the duplicates until the end of their deduplication window
+		if p.dedup != nil && !p.dedup.add(msg) {
 			return
 		}
-		msg.SetRendered(rendered)
 
-		// report this message to diagnostic receivers (e.g. `stream-logs` command)
-		p.diagnosticMessageReceiver.HandleMessage(msg, rendered, "")
+		p.sendMessage(msg)
+	}
+}
 
-		// encode the message to its final format, it is done in-place
-		if err := p.encoder.Encode(msg, p.GetHostname(msg)); err != nil {
-			log.Error("unable to encode msg ", err)
-			return
-		}
+// sendMessage renders and encodes a processed message, and forwards it to the strategy.
+func (p *Processor) sendMessage(msg *message.Message) {
+	// render the message
+	rendered, err := msg.Render()
+	if err != nil {
+		log.Error("can't render the msg", err)
+		return
+	}
+	msg.SetRendered(rendered)
+
+	// report this message to diagnostic receivers (e.g. `stream-logs` command)
+	p.diagnosticMessageReceiver.HandleMessage(msg, rendered, "")
 
-		p.utilization.Stop() // Explicitly call stop here to avoid counting writing on the output channel as processing time
-		p.outputChan <- msg
-		p.pipelineMonitor.ReportComponentIngress(msg, "strategy")
+	// resolve the hostname once, the senders format the message for the
+	// other intakes with the same one
+	msg.Hostname = p.GetHostname(msg)
+
+	// encode the message to its final format, it is done in-place
+	if err := p.encoder.Encode(msg, msg.Hostname); err != nil {
+		log.Error("unable to encode msg ", err)
+		return
+	}
+	if p.tracer != nil {
+		p.tracer.Sent(msg)
 	}
 
+	p.utilization.Stop() // Explicitly call stop here to avoid counting writing on the output channel as processing time
+	p.outputChan <- msg
+	p.pipelineMonitor.ReportComponentIngress(msg, "strategy")
 }
 
 // applyRedactingRules returns given a message if we should process it or not,
 // it applies the change directly on the Message content.
 func (p *Processor) applyRedactingRules(msg *message.Message) bool {
 	var content []byte = msg.GetContent()
+	// the fields extracted by the extract_fields rules go through the same masks
+	// and scanning as the content before being attached to the message
+	var fields []extractedField
 
 	// Use the internal scrubbing implementation of the Agent
 	// ---------------------------
@@ -270,21 +343,57 @@ func (p *Processor) applyRedactingRules(msg *message.Message) bool {
 		switch rule.Type {
 		case config.ExcludeAtMatch:
 			// if this message matches, we ignore it
-			if rule.Regex.Match(content) {
+			matched := rule.Regex.Match(content)
+			p.traceRule(msg, rule, matched, content)
+			if matched {
+				p.traceDrop(msg, rule)
 				return false
 			}
 		case config.IncludeAtMatch:
 			// if this message doesn't match, we ignore it
-			if !rule.Regex.Match(content) {
+			matched := rule.Regex.Match(content)
+			p.traceRule(msg, rule, matched, content)
+			if !matched {
+				p.traceDrop(msg, rule)
 				return false
 			}
 		case config.MaskSequences:
+			original := content
 			if isMatchingLiteralPrefix(rule.Regex, content) {
 				content = rule.Regex.ReplaceAll(content, rule.Placeholder)
 			}
+			for i := range fields {
+				if isMatchingLiteralPrefix(rule.Regex, fields[i].value) {
+					fields[i].value = rule.Regex.ReplaceAll(fields[i].value, rule.Placeholder)
+				}
+			}
+			if p.tracer != nil {
+				p.traceRule(msg, rule, !bytes.Equal(original, content), content)
+			}
+		case config.ExtractFields:
+			matches := rule.Regex.FindSubmatch(content)
+			if matches != nil {
+				content, fields = extractFields(rule, msg, content, matches, fields)
+			}
+			p.traceRule(msg, rule, matches != nil, content)
 		}
 	}
 
+	// Generate metrics from the logs which passed the exclusion and inclusion rules
+	// ---------------------------
+
+	if dropRule := p.generateMetrics(rules, msg, content); dropRule != nil {
+		p.traceDrop(msg, dropRule)
+		return false
+	}
+
+	// Sample the logs after generating the metrics so that they account for all the logs
+	// ---------------------------
+
+	if !p.sample(rules, msg, content) {
+		return false
+	}
+
 	// Use the SDS implementation
 	// --------------------------
 
@@ -296,8 +405,22 @@ func (p *Processor) applyRedactingRules(msg *message.Message) bool {
 		} else if mutated {
 			content = evtProcessed
 		}
+		for i := range fields {
+			mutated, processed, rules, err := p.sds.scanner.ScanEvent(fields[i].value)
+			if err != nil {
+				log.Error("while using SDS to scan the log fields:", err)
+				continue
+			}
+			for _, rule := range rules {
+				msg.ProcessingTags = append(msg.ProcessingTags, rule.Tags...)
+			}
+			if mutated {
+				fields[i].value = processed
+			}
+		}
 	}
 
+	attachFields(msg, fields)
 	msg.SetContent(content)
 	return true // we want to send this message
 }
@@ -7,6 +7,7 @@ package processor
 
 import (
 	"regexp"
+	"strconv"
 	"sync/atomic"
 	"testing"
 	"time"
@@ -20,6 +21,7 @@ import (
 	"github.com/DataDog/datadog-agent/pkg/logs/metrics"
 	"github.com/DataDog/datadog-agent/pkg/logs/sds"
 	"github.com/DataDog/datadog-agent/pkg/logs/sources"
+	"github.com/DataDog/datadog-agent/pkg/telemetry"
 )
 
 type processorTestCase struct {
@@ -279,6 +281,244 @@ func TestMask(t *testing.T) {
 	}
 }
 
+func TestExtractFields(t *testing.T) {
+	rule := &config.ProcessingRule{
+		Type:           config.ExtractFields,
+		Name:           "extract",
+		Pattern:        `^%{TIMESTAMP_ISO8601:ts} %{LOGLEVEL:level} \[%{WORD:svc}\] %{GREEDYDATA:message} status=%{INT:http.status_code}$`,
+		StatusField:    "level",
+		ServiceField:   "svc",
+		TimestampField: "ts",
+	}
+	assert.NoError(t, config.CompileProcessingRules([]*config.ProcessingRule{rule}))
+	source := sources.LogSource{Config: &config.LogsConfig{ProcessingRules: []*config.ProcessingRule{rule}}}
+	p := &Processor{}
+
+	// matching message
+	msg := newMessage([]byte("2024-03-01T12:00:00Z WARN [billing] payment failed status=502"), &source, message.StatusInfo)
+	assert.True(t, p.applyRedactingRules(msg))
+	assert.Equal(t, "payment failed", string(msg.GetContent()))
+	assert.Equal(t, message.StatusWarning, msg.Status)
+	assert.Equal(t, "billing", msg.Origin.Service())
+	assert.Equal(t, time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC), msg.ServerlessExtra.Timestamp)
+	rendered, err := msg.Render()
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{
+		"message": "payment failed",
+		"ts": "2024-03-01T12:00:00Z",
+		"level": "WARN",
+		"svc": "billing",
+		"http": {"status_code": "502"}
+	}`, string(rendered))
+
+	// non-matching message is left untouched
+	msg = newMessage([]byte("unrelated line"), &source, message.StatusInfo)
+	assert.True(t, p.applyRedactingRules(msg))
+	assert.Equal(t, message.StateUnstructured, msg.State)
+	assert.Equal(t, "unrelated line", string(msg.GetContent()))
+	assert.Equal(t, message.StatusInfo, msg.Status)
+
+	// the extracted service takes precedence over the service of the source
+	source.Config.Service = "payments"
+	msg = newMessage([]byte("2024-03-01T12:00:00Z WARN [billing] payment failed status=502"), &source, message.StatusInfo)
+	assert.True(t, p.applyRedactingRules(msg))
+	assert.Equal(t, "billing", msg.Origin.Service())
+	msg = newMessage([]byte("unrelated line"), &source, message.StatusInfo)
+	assert.True(t, p.applyRedactingRules(msg))
+	assert.Equal(t, "payments", msg.Origin.Service())
+}
+
+func TestExtractFieldsMasked(t *testing.T) {
+	rules := []*config.ProcessingRule{
+		{Type: config.ExtractFields, Name: "extract", Pattern: `user=%{NOTSPACE:user} card=%{NOTSPACE:card}`},
+		{Type: config.MaskSequences, Name: "mask_cards", Pattern: `\d{4}-\d{4}-\d{4}-\d{4}`, ReplacePlaceholder: "[masked_card]"},
+	}
+	assert.NoError(t, config.CompileProcessingRules(rules))
+	source := sources.LogSource{Config: &config.LogsConfig{ProcessingRules: rules}}
+	p := &Processor{}
+
+	// the masks following the extraction apply to the extracted fields
+	msg := newMessage([]byte("user=jane card=1234-5678-1234-5678"), &source, message.StatusInfo)
+	assert.True(t, p.applyRedactingRules(msg))
+	rendered, err := msg.Render()
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{
+		"message": "user=jane card=[masked_card]",
+		"user": "jane",
+		"card": "[masked_card]"
+	}`, string(rendered))
+}
+
+func TestParseTimestamp(t *testing.T) {
+	expected := time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)
+	for _, test := range []struct{ value, layout string }{
+		{"2024-03-01T12:00:00Z", ""},
+		{"2024-03-01 12:00:00", ""},
+		{"01/Mar/2024:12:00:00 +0000", ""},
+		{"1709294400", ""},
+		{"1709294400000", ""},
+		{"2024/03/01 12h00", "2006/01/02 15h04"},
+	} {
+		ts, ok := parseTimestamp(test.value, test.layout)
+		assert.True(t, ok, test.value)
+		assert.True(t, expected.Equal(ts), test.value)
+	}
+
+	_, ok := parseTimestamp("yesterday", "")
+	assert.False(t, ok)
+}
+
+func TestGenerateMetric(t *testing.T) {
+	rules := []*config.ProcessingRule{
+		{Type: config.ExcludeAtMatch, Name: "exclude", Pattern: "healthcheck"},
+		{Type: config.GenerateMetric, Name: "requests", Pattern: `status=%{INT:status}`, MetricName: "app.requests", TagFields: []string{"status"}},
+		{Type: config.GenerateMetric, Name: "latency", Pattern: `took %{NUMBER:duration}ms`, MetricName: "app.latency", MetricType: config.MetricTypeDistribution, ValueField: "duration", DropLog: true},
+	}
+	assert.NoError(t, config.CompileProcessingRules(rules))
+	source := sources.LogSource{Config: &config.LogsConfig{Source: "nginx", Service: "web", ProcessingRules: rules}}
+	sender := &testMetricSender{}
+	p := &Processor{metricSender: telemetry.NewStatsTelemetryProvider(sender)}
+
+	msg := newMessage([]byte("GET / status=200"), &source, "")
+	assert.True(t, p.applyRedactingRules(msg))
+
+	msg = newMessage([]byte("GET /healthcheck status=200"), &source, "")
+	assert.False(t, p.applyRedactingRules(msg))
+
+	msg = newMessage([]byte("GET / status=500 took 12.5ms"), &source, "")
+	assert.False(t, p.applyRedactingRules(msg))
+
+	assert.Equal(t, []testMetric{
+		{"count", "app.requests", 1, []string{"source:nginx", "service:web", "status:200"}},
+		{"count", "app.requests", 1, []string{"source:nginx", "service:web", "status:500"}},
+		{"distribution", "app.latency", 12.5, []string{"source:nginx", "service:web"}},
+	}, sender.metrics)
+
+	// without metric sender, the generated metrics are dropped and counted
+	dropped := metrics.TlmGeneratedMetricsDropped.WithValues().Get()
+	p = &Processor{}
+	msg = newMessage([]byte("GET / status=200 took 3ms"), &source, "")
+	assert.False(t, p.applyRedactingRules(msg))
+	assert.Equal(t, dropped+2, metrics.TlmGeneratedMetricsDropped.WithValues().Get())
+}
+
+func TestSample(t *testing.T) {
+	now := time.Now()
+	rules := []*config.ProcessingRule{
+		{Type: config.Sample, Name: "debug", Pattern: `DEBUG`, SampleRate: 0.25},
+		{Type: config.Sample, Name: "users", Pattern: `user=%{WORD:user}`, MaxPerSecond: 2, KeyField: "user"},
+	}
+	assert.NoError(t, config.CompileProcessingRules(rules))
+	source := sources.LogSource{Config: &config.LogsConfig{ProcessingRules: rules}}
+	p := &Processor{samplers: NewSamplers()}
+	p.samplers.now = func() time.Time { return now }
+	debugSampler := p.samplers.get(rules[0])
+	debugSampler.random = func() float64 { return 0.5 }
+
+	msg := newMessage([]byte("DEBUG starting"), &source, "")
+	assert.False(t, p.applyRedactingRules(msg))
+	debugSampler.random = func() float64 { return 0.1 }
+	msg = newMessage([]byte("DEBUG starting"), &source, "")
+	assert.True(t, p.applyRedactingRules(msg))
+	assert.Equal(t, []string{"sample_rate:0.2500"}, msg.ProcessingTags)
+
+	kept := map[string]int{}
+	for i := 0; i < 5; i++ {
+		for _, user := range []string{"alice", "bob"} {
+			msg = newMessage([]byte("login user="+user), &source, "")
+			if p.applyRedactingRules(msg) {
+				kept[user]++
+				assert.Empty(t, msg.ProcessingTags)
+			}
+		}
+	}
+	assert.Equal(t, map[string]int{"alice": 2, "bob": 2}, kept)
+
+	// the rate of the previous second is reported once the limit is refilled
+	now = now.Add(time.Second)
+	msg = newMessage([]byte("login user=alice"), &source, "")
+	assert.True(t, p.applyRedactingRules(msg))
+	assert.Equal(t, []string{"sample_rate:0.4000"}, msg.ProcessingTags)
+
+	msg = newMessage([]byte("unsampled"), &source, "")
+	assert.True(t, p.applyRedactingRules(msg))
+	assert.Empty(t, msg.ProcessingTags)
+}
+
+func TestSamplerKeyTag(t *testing.T) {
+	rule := &config.ProcessingRule{Type: config.Sample, Name: "pods", Pattern: `.*`, MaxPerSecond: 1, KeyTag: "pod_name"}
+	assert.NoError(t, config.CompileProcessingRules([]*config.ProcessingRule{rule}))
+
+	newPodMessage := func(pod string) *message.Message {
+		source := sources.NewLogSource("", &config.LogsConfig{ProcessingRules: []*config.ProcessingRule{rule}})
+		msg := newMessage([]byte("hello"), source, "")
+		msg.Origin.SetTags([]string{"pod_name:" + pod})
+		return msg
+	}
+
+	p := &Processor{samplers: NewSamplers()}
+	assert.True(t, p.applyRedactingRules(newPodMessage("a")))
+	assert.False(t, p.applyRedactingRules(newPodMessage("a")))
+	assert.True(t, p.applyRedactingRules(newPodMessage("b")))
+}
+
+func TestSamplersSharedByProcessors(t *testing.T) {
+	rule := &config.ProcessingRule{Type: config.Sample, Name: "all", Pattern: `.*`, MaxPerSecond: 1}
+	assert.NoError(t, config.CompileProcessingRules([]*config.ProcessingRule{rule}))
+	source := sources.LogSource{Config: &config.LogsConfig{ProcessingRules: []*config.ProcessingRule{rule}}}
+
+	// the limit applies to the logs of all the pipelines
+	shared := NewSamplers()
+	p1 := &Processor{samplers: shared}
+	p2 := &Processor{samplers: shared}
+	assert.True(t, p1.applyRedactingRules(newMessage([]byte("hello"), &source, "")))
+	assert.False(t, p2.applyRedactingRules(newMessage([]byte("hello"), &source, "")))
+}
+
+func TestSamplerPrune(t *testing.T) {
+	now := time.Now()
+	s := newSampler(&config.ProcessingRule{MaxPerSecond: 1})
+	s.now = func() time.Time { return now }
+
+	s.keep("a")
+	now = now.Add(samplerPruneInterval)
+	s.keep("b")
+	assert.Len(t, s.buckets, 1)
+	assert.Contains(t, s.buckets, "b")
+}
+
+func TestSamplerMaxKeys(t *testing.T) {
+	now := time.Now()
+	s := newSampler(&config.ProcessingRule{MaxPerSecond: 1})
+	s.now = func() time.Time { return now }
+
+	for i := 0; i < maxSampleKeys; i++ {
+		keep, _ := s.keep(strconv.Itoa(i))
+		assert.True(t, keep)
+	}
+	// the new keys share a single limit once the maximum is reached
+	keep, _ := s.keep("new-1")
+	assert.True(t, keep)
+	keep, _ = s.keep("new-2")
+	assert.False(t, keep)
+	assert.Len(t, s.buckets, maxSampleKeys)
+}
+
+func TestSamplersPrune(t *testing.T) {
+	now := time.Now()
+	p := &Processor{samplers: NewSamplers()}
+	p.samplers.now = func() time.Time { return now }
+	reloaded := &config.ProcessingRule{Name: "reloaded", MaxPerSecond: 1}
+	p.samplers.get(reloaded)
+
+	// the samplers of the rules which don't match anymore are removed
+	now = now.Add(samplerPruneInterval)
+	rule := &config.ProcessingRule{Name: "rule", MaxPerSecond: 1}
+	p.samplers.get(rule)
+	assert.Len(t, p.samplers.byRule, 1)
+	assert.Contains(t, p.samplers.byRule, rule)
+}
+
 func TestTruncate(t *testing.T) {
 	p := &Processor{}
 	source := sources.NewLogSource("", &config.LogsConfig{})
@@ -496,6 +736,33 @@ func newStructuredMessage(content []byte, source *sources.LogSource, status stri
 	return msg
 }
 
+type testMetric struct {
+	metricType string
+	name       string
+	value      float64
+	tags       []string
+}
+
+type testMetricSender struct {
+	metrics []testMetric
+}
+
+func (s *testMetricSender) Count(metric string, value float64, _ string, tags []string) {
+	s.metrics = append(s.metrics, testMetric{"count", metric, value, tags})
+}
+
+func (s *testMetricSender) Gauge(metric string, value float64, _ string, tags []string) {
+	s.metrics = append(s.metrics, testMetric{"gauge", metric, value, tags})
+}
+
+func (s *testMetricSender) GaugeNoIndex(metric string, value float64, _ string, tags []string) {
+	s.metrics = append(s.metrics, testMetric{"gauge", metric, value, tags})
+}
+
+func (s *testMetricSender) Distribution(metric string, value float64, _ string, tags []string) {
+	s.metrics = append(s.metrics, testMetric{"distribution", metric, value, tags})
+}
+
 func BenchmarkMaskSequences(b *testing.B) {
 	processor := &Processor{
 		processingRules: []*config.ProcessingRule{
@@ -0,0 +1,229 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package processor
+
+import (
+	"math"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/DataDog/datadog-agent/comp/logs/agent/config"
+	"github.com/DataDog/datadog-agent/pkg/logs/message"
+)
+
+const (
+	// sampleRateTag is the tag added to the logs kept by a sample rule.
+	sampleRateTag = "sample_rate:"
+
+	// samplerPruneInterval is the interval after which the keys which didn't
+	// receive any log are removed from a sampler, and the samplers of the rules
+	// which didn't match any log are removed from the processor.
+	samplerPruneInterval = 10 * time.Second
+
+	// maxSampleKeys is the maximum number of keys tracked by a sampler. The logs
+	// of the keys seen once it is reached share a single limit.
+	maxSampleKeys = 10000
+)
+
+// sample applies the sample rules matching the content, and returns false if
+// the log should be dropped. Kept logs are tagged with the lowest sample rate applied.
+func (p *Processor) sample(rules []*config.ProcessingRule, msg *message.Message, content []byte) bool {
+	rate := 1.0
+	for _, rule := range rules {
+		if rule.Type != config.Sample {
+			continue
+		}
+		matches := rule.Regex.FindSubmatch(content)
+		p.traceRule(msg, rule, matches != nil, content)
+		if matches == nil {
+			continue
+		}
+		keep, ruleRate := p.samplers.keep(rule, sampleKey(rule, msg, matches))
+		if !keep {
+			p.traceDrop(msg, rule)
+			return false
+		}
+		rate = math.Min(rate, ruleRate)
+	}
+	if rate < 1 {
+		msg.ProcessingTags = append(msg.ProcessingTags, sampleRateTag+strconv.FormatFloat(rate, 'f', 4, 64))
+	}
+	return true
+}
+
+// sampleKey returns the key of a log for a sample rule, the empty string
+// when the limit applies to all the matching logs.
+func sampleKey(rule *config.ProcessingRule, msg *message.Message, matches [][]byte) string {
+	switch {
+	case rule.KeyField != "":
+		for i, name := range rule.Regex.SubexpNames() {
+			if name != "" && config.FieldName(name) == rule.KeyField {
+				return string(matches[i])
+			}
+		}
+	case rule.KeyTag != "":
+		prefix := rule.KeyTag + ":"
+		for _, tag := range msg.Tags() {
+			if strings.HasPrefix(tag, prefix) {
+				return tag[len(prefix):]
+			}
+		}
+	}
+	return ""
+}
+
+// Samplers holds the state of the sample rules. It is shared by the processors
+// of the logs pipelines, so that the limits apply to all of them together.
+// The samplers of the rules which didn't match any log for samplerPruneInterval
+// are removed, so that the rules of the sources which are reloaded don't leak.
+type Samplers struct {
+	mu        sync.Mutex
+	now       func() time.Time
+	byRule    map[*config.ProcessingRule]*sampler
+	lastPrune time.Time
+}
+
+// keep returns whether a log with the given key should be kept by the rule,
+// along with the sample rate applied.
+// NewSamplers returns the state of the sample rules to share between processors.
+func NewSamplers() *Samplers {
+	return &Samplers{
+		now:    time.Now,
+		byRule: make(map[*config.ProcessingRule]*sampler),
+	}
+}
+
+func (s *Samplers) keep(rule *config.ProcessingRule, key string) (bool, float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.get(rule).keep(key)
+}
+
+// get returns the sampler of the rule, creating it if needed. The caller must
+// hold the lock.
+func (s *Samplers) get(rule *config.ProcessingRule) *sampler {
+	now := s.now()
+	if now.Sub(s.lastPrune) >= samplerPruneInterval {
+		for r, sampler := range s.byRule {
+			if now.Sub(sampler.lastUsed) >= samplerPruneInterval {
+				delete(s.byRule, r)
+			}
+		}
+		s.lastPrune = now
+	}
+
+	sampler, found := s.byRule[rule]
+	if !found {
+		sampler = newSampler(rule)
+		sampler.now = s.now
+		s.byRule[rule] = sampler
+	}
+	sampler.lastUsed = now
+	return sampler
+}
+
+// sampler keeps a ratio of the logs, or limits their rate for each key using
+// token buckets.
+type sampler struct {
+	sampleRate   float64
+	maxPerSecond float64
+	random       func() float64
+	now          func() time.Time
+	buckets      map[string]*sampleBucket
+	// overflow is the bucket shared by the keys seen once maxSampleKeys is reached.
+	overflow  *sampleBucket
+	lastPrune time.Time
+	lastUsed  time.Time
+}
+
+// sampleBucket is the token bucket of a key, which also counts the logs seen
+// and kept every second to compute the sample rate applied.
+type sampleBucket struct {
+	tokens      float64
+	updated     time.Time
+	windowStart time.Time
+	seen        int
+	kept        int
+	rate        float64
+}
+
+func newSampler(rule *config.ProcessingRule) *sampler {
+	return &sampler{
+		sampleRate:   rule.SampleRate,
+		maxPerSecond: rule.MaxPerSecond,
+		random:       rand.Float64,
+		now:          time.Now,
+		buckets:      make(map[string]*sampleBucket),
+	}
+}
+
+// keep returns whether a log with the given key should be kept, along with the
+// sample rate applied.
+func (s *sampler) keep(key string) (bool, float64) {
+	if s.sampleRate > 0 {
+		return s.random() < s.sampleRate, s.sampleRate
+	}
+
+	now := s.now()
+	s.prune(now)
+
+	burst := math.Max(1, s.maxPerSecond)
+	bucket := s.bucket(key, now, burst)
+
+	bucket.tokens = math.Min(burst, bucket.tokens+now.Sub(bucket.updated).Seconds()*s.maxPerSecond)
+	bucket.updated = now
+	if now.Sub(bucket.windowStart) >= time.Second {
+		if bucket.seen > 0 {
+			bucket.rate = float64(bucket.kept) / float64(bucket.seen)
+		}
+		bucket.windowStart, bucket.seen, bucket.kept = now, 0, 0
+	}
+
+	bucket.seen++
+	if bucket.tokens < 1 {
+		return false, bucket.rate
+	}
+	bucket.tokens--
+	bucket.kept++
+	return true, bucket.rate
+}
+
+// bucket returns the bucket of the key, or the overflow bucket if the key is
+// new and maxSampleKeys is reached.
+func (s *sampler) bucket(key string, now time.Time, burst float64) *sampleBucket {
+	if bucket, found := s.buckets[key]; found {
+		return bucket
+	}
+	bucket := &sampleBucket{tokens: burst, updated: now, windowStart: now, rate: 1}
+	if len(s.buckets) < maxSampleKeys {
+		s.buckets[key] = bucket
+		return bucket
+	}
+	if s.overflow == nil {
+		s.overflow = bucket
+	}
+	return s.overflow
+}
+
+// prune removes the buckets of the keys without any recent log, so that the
+// memory used by the sampler doesn't grow with the number of keys seen.
+func (s *sampler) prune(now time.Time) {
+	if now.Sub(s.lastPrune) < samplerPruneInterval {
+		return
+	}
+	for key, bucket := range s.buckets {
+		if now.Sub(bucket.updated) >= samplerPruneInterval {
+			delete(s.buckets, key)
+		}
+	}
+	if s.overflow != nil && now.Sub(s.overflow.updated) >= samplerPruneInterval {
+		s.overflow = nil
+	}
+	s.lastPrune = now
+}
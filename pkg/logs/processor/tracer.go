@@ -0,0 +1,40 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package processor
+
+import (
+	"github.com/DataDog/datadog-agent/comp/logs/agent/config"
+	"github.com/DataDog/datadog-agent/pkg/logs/message"
+)
+
+// Tracer is notified of the decisions taken by a processor on each message, to
+// explain why a log was transformed or dropped (e.g. `agent analyze-logs --verbose`).
+// Its methods are called from the processor goroutine.
+type Tracer interface {
+	// RuleApplied is called for each processing rule evaluated on a message,
+	// with the content of the message once the rule has been applied.
+	RuleApplied(msg *message.Message, rule *config.ProcessingRule, matched bool, content []byte)
+
+	// Dropped is called when a message is dropped by a processing rule.
+	Dropped(msg *message.Message, rule *config.ProcessingRule)
+
+	// Sent is called when a message has been encoded, before it is sent to the output.
+	Sent(msg *message.Message)
+}
+
+// traceRule notifies the tracer, if any, of the evaluation of a rule.
+func (p *Processor) traceRule(msg *message.Message, rule *config.ProcessingRule, matched bool, content []byte) {
+	if p.tracer != nil {
+		p.tracer.RuleApplied(msg, rule, matched, content)
+	}
+}
+
+// traceDrop notifies the tracer, if any, that a message is dropped by a rule.
+func (p *Processor) traceDrop(msg *message.Message, rule *config.ProcessingRule) {
+	if p.tracer != nil {
+		p.tracer.Dropped(msg, rule)
+	}
+}
@@ -0,0 +1,94 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package processor
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/DataDog/datadog-agent/comp/logs/agent/config"
+	"github.com/DataDog/datadog-agent/pkg/logs/message"
+	"github.com/DataDog/datadog-agent/pkg/logs/sources"
+)
+
+type testTracer struct {
+	events []string
+}
+
+func (t *testTracer) RuleApplied(_ *message.Message, rule *config.ProcessingRule, matched bool, content []byte) {
+	t.events = append(t.events, fmt.Sprintf("%s %s %v %s", rule.Type, rule.Name, matched, content))
+}
+
+func (t *testTracer) Dropped(_ *message.Message, rule *config.ProcessingRule) {
+	t.events = append(t.events, "dropped by "+rule.Name)
+}
+
+func (t *testTracer) Sent(msg *message.Message) {
+	t.events = append(t.events, "sent "+string(msg.GetContent()))
+}
+
+func TestTracer(t *testing.T) {
+	rules := []*config.ProcessingRule{
+		{Type: config.ExcludeAtMatch, Name: "no_debug", Pattern: "DEBUG"},
+		{Type: config.MaskSequences, Name: "mask_user", Pattern: `user=\w+`, ReplacePlaceholder: "user=***"},
+		{Type: config.IncludeAtMatch, Name: "only_login", Pattern: "login"},
+	}
+	assert.NoError(t, config.CompileProcessingRules(rules))
+	source := sources.LogSource{Config: &config.LogsConfig{ProcessingRules: rules}}
+	tracer := &testTracer{}
+	p := &Processor{}
+	p.SetTracer(tracer)
+
+	assert.True(t, p.applyRedactingRules(newMessage([]byte("login user=alice"), &source, "")))
+	assert.Equal(t, []string{
+		"exclude_at_match no_debug false login user=alice",
+		"mask_sequences mask_user true login user=***",
+		"include_at_match only_login true login user=***",
+	}, tracer.events)
+
+	tracer.events = nil
+	assert.False(t, p.applyRedactingRules(newMessage([]byte("DEBUG login"), &source, "")))
+	assert.Equal(t, []string{
+		"exclude_at_match no_debug true DEBUG login",
+		"dropped by no_debug",
+	}, tracer.events)
+
+	tracer.events = nil
+	assert.False(t, p.applyRedactingRules(newMessage([]byte("logout"), &source, "")))
+	assert.Equal(t, []string{
+		"exclude_at_match no_debug false logout",
+		"mask_sequences mask_user false logout",
+		"include_at_match only_login false logout",
+		"dropped by only_login",
+	}, tracer.events)
+}
+
+func TestTracerSampleDrops(t *testing.T) {
+	rules := []*config.ProcessingRule{
+		{Type: config.Sample, Name: "limit_errors", Pattern: "ERROR", MaxPerSecond: 1},
+	}
+	assert.NoError(t, config.CompileProcessingRules(rules))
+	source := sources.LogSource{Config: &config.LogsConfig{ProcessingRules: rules}}
+	tracer := &testTracer{}
+	now := time.Now()
+	p := &Processor{samplers: NewSamplers()}
+	p.samplers.now = func() time.Time { return now }
+	p.SetTracer(tracer)
+
+	assert.True(t, p.applyRedactingRules(newMessage([]byte("ERROR first"), &source, "")))
+	assert.Equal(t, []string{"sample limit_errors true ERROR first"}, tracer.events)
+
+	// the rate limit is reached, the log is reported as dropped by the sample rule
+	tracer.events = nil
+	assert.False(t, p.applyRedactingRules(newMessage([]byte("ERROR second"), &source, "")))
+	assert.Equal(t, []string{
+		"sample limit_errors true ERROR second",
+		"dropped by limit_errors",
+	}, tracer.events)
+}
@@ -398,6 +398,39 @@ func (s *Scanner) Scan(event []byte, msg *message.Message) (bool, []byte, error)
 	return scanResult.Mutated, scanResult.Event, err
 }
 
+// ScanEvent scans the given event without any logs message attached to it,
+// e.g. a value extracted from another kind of payload. It returns whether the
+// event has been mutated, the processed event and the configuration of the
+// rules which matched, each rule being returned once.
+// This method is thread safe, a reconfiguration can't happen at the same time.
+func (s *Scanner) ScanEvent(event []byte) (bool, []byte, []RuleConfig, error) {
+	s.Lock()
+	defer s.Unlock()
+	start := time.Now()
+
+	if s.Scanner == nil {
+		return false, nil, nil, fmt.Errorf("can't Scan with an unitialized scanner")
+	}
+
+	scanResult, err := s.Scanner.Scan(event)
+	var rules []RuleConfig
+	seen := make(map[uint32]struct{}, len(scanResult.Matches))
+	for _, match := range scanResult.Matches {
+		if _, ok := seen[match.RuleIdx]; ok {
+			continue
+		}
+		seen[match.RuleIdx] = struct{}{}
+		if rc, err := s.GetRuleByIdx(match.RuleIdx); err != nil {
+			log.Warnf("can't retrieve the matching rule: %v", err)
+		} else {
+			rules = append(rules, rc)
+		}
+	}
+
+	tlmSDSProcessingLatency.Observe(float64(time.Since(start) / 1000))
+	return scanResult.Mutated, scanResult.Event, rules, err
+}
+
 // GetRuleByIdx returns the configured rule by its idx, referring to the idx
 // that the SDS scanner writes in its internal response.
 func (s *Scanner) GetRuleByIdx(idx uint32) (RuleConfig, error) {
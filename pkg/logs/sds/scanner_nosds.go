@@ -48,3 +48,8 @@ func (s *Scanner) IsReady() bool { return false }
 func (s *Scanner) Scan(_ []byte, _ *message.Message) (bool, []byte, error) {
 	return false, nil, nil
 }
+
+// ScanEvent mocks the ScanEvent function.
+func (s *Scanner) ScanEvent(_ []byte) (bool, []byte, []RuleConfig, error) {
+	return false, nil, nil, nil
+}
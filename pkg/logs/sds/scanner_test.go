@@ -473,6 +473,71 @@ func TestScan(t *testing.T) {
 	}
 }
 
+func TestScanEvent(t *testing.T) {
+	require := require.New(t)
+
+	standardRules := []byte(`
+        {"priority":1,"rules":[
+            {
+                "id":"zero-0",
+                "description":"zero desc",
+                "name":"zero",
+                "definitions": [{"version":1, "pattern":"zero"}]
+            },{
+                "id":"one-1",
+                "description":"one desc",
+                "name":"one",
+                "definitions": [{"version":1, "pattern":"one"}]
+            }
+        ]}
+    `)
+	agentConfig := []byte(`
+        {"is_enabled":true,"rules":[
+            {
+                "id":"random-00000",
+                "definition":{"standard_rule_id":"zero-0"},
+                "name":"zero",
+                "match_action":{"type":"Redact","placeholder":"[redacted]"},
+                "is_enabled":true
+            },{
+                "id":"random-11111",
+                "definition":{"standard_rule_id":"one-1"},
+                "name":"one",
+                "match_action":{"type":"Redact","placeholder":"[REDACTED]"},
+                "is_enabled":true
+            }
+        ]}
+    `)
+
+	s := CreateScanner("")
+	require.NotNil(s, "the returned scanner should not be nil")
+
+	_, _, _, err := s.ScanEvent([]byte("one"))
+	require.Error(err, "scanning with an unconfigured scanner should fail")
+
+	_, _ = s.Reconfigure(ReconfigureOrder{
+		Type:   StandardRules,
+		Config: standardRules,
+	})
+	isActive, _ := s.Reconfigure(ReconfigureOrder{
+		Type:   AgentConfig,
+		Config: agentConfig,
+	})
+	require.True(isActive, "rules are configured, the scanner should be active")
+
+	mutated, processed, rules, err := s.ScanEvent([]byte("one, one and zero"))
+	require.NoError(err)
+	require.True(mutated)
+	require.Equal("[REDACTED], [REDACTED] and [redacted]", string(processed))
+	require.Len(rules, 2, "each matching rule should be returned once")
+	require.ElementsMatch([]string{"zero", "one"}, []string{rules[0].Name, rules[1].Name})
+
+	mutated, _, rules, err = s.ScanEvent([]byte("and so we go"))
+	require.NoError(err)
+	require.False(mutated)
+	require.Empty(rules)
+}
+
 // TestCloseCycleScan validates that the close cycle works well (not blocking, not racing).
 // by trying hard to reproduce a possible race on close.
 func TestCloseCycleScan(t *testing.T) {
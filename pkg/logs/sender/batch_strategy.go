@@ -34,10 +34,12 @@ type batchStrategy struct {
 	// pipelineName provides a name for the strategy to differentiate it from other instances in other internal pipelines
 	pipelineName string
 	serializer   Serializer
-	batchWait    time.Duration
-	compression  compression.Compressor
-	stopChan     chan struct{} // closed when the goroutine has finished
-	clock        clock.Clock
+	// encodings are the encodings of the endpoints which don't use the main payloads
+	encodings   []EndpointEncoding
+	batchWait   time.Duration
+	compression compression.Compressor
+	stopChan    chan struct{} // closed when the goroutine has finished
+	clock       clock.Clock
 
 	// Telemtry
 	pipelineMonitor metrics.PipelineMonitor
@@ -50,13 +52,14 @@ func NewBatchStrategy(inputChan chan *message.Message,
 	flushChan chan struct{},
 	serverlessMeta ServerlessMeta,
 	serializer Serializer,
+	encodings []EndpointEncoding,
 	batchWait time.Duration,
 	maxBatchSize int,
 	maxContentSize int,
 	pipelineName string,
 	compression compression.Compressor,
 	pipelineMonitor metrics.PipelineMonitor) Strategy {
-	return newBatchStrategyWithClock(inputChan, outputChan, flushChan, serverlessMeta, serializer, batchWait, maxBatchSize, maxContentSize, pipelineName, clock.New(), compression, pipelineMonitor)
+	return newBatchStrategyWithClock(inputChan, outputChan, flushChan, serverlessMeta, serializer, encodings, batchWait, maxBatchSize, maxContentSize, pipelineName, clock.New(), compression, pipelineMonitor)
 }
 
 func newBatchStrategyWithClock(inputChan chan *message.Message,
@@ -64,6 +67,7 @@ func newBatchStrategyWithClock(inputChan chan *message.Message,
 	flushChan chan struct{},
 	serverlessMeta ServerlessMeta,
 	serializer Serializer,
+	encodings []EndpointEncoding,
 	batchWait time.Duration,
 	maxBatchSize int,
 	maxContentSize int,
@@ -79,6 +83,7 @@ func newBatchStrategyWithClock(inputChan chan *message.Message,
 		serverlessMeta:  serverlessMeta,
 		buffer:          NewMessageBuffer(maxBatchSize, maxContentSize),
 		serializer:      serializer,
+		encodings:       encodings,
 		batchWait:       batchWait,
 		compression:     compression,
 		stopChan:        make(chan struct{}),
@@ -195,6 +200,7 @@ func (s *batchStrategy) sendMessages(messages []*message.Message, outputChan cha
 	}
 
 	p := message.NewPayload(messages, encodedPayload.Bytes(), s.compression.ContentEncoding(), unencodedSize)
+	p.Variants = s.encodeVariants(messages)
 
 	s.utilization.Stop()
 	outputChan <- p
@@ -202,6 +208,57 @@ func (s *batchStrategy) sendMessages(messages []*message.Message, outputChan cha
 	s.pipelineMonitor.ReportComponentIngress(p, "sender")
 }
 
+// encodeVariants encodes the messages included by each endpoint encoding. A
+// variant which can't be encoded is left out, the destinations using it drop
+// the payload.
+func (s *batchStrategy) encodeVariants(messages []*message.Message) map[string]*message.PayloadVariant {
+	if len(s.encodings) == 0 {
+		return nil
+	}
+	variants := make(map[string]*message.PayloadVariant, len(s.encodings))
+	for _, encoding := range s.encodings {
+		included := messages
+		if encoding.Include != nil {
+			included = make([]*message.Message, 0, len(messages))
+			for _, m := range messages {
+				if encoding.Include(m) {
+					included = append(included, m)
+				}
+			}
+		}
+		if len(included) == 0 {
+			variants[encoding.Key] = &message.PayloadVariant{}
+			continue
+		}
+
+		serializer := encoding.Serializer
+		if serializer == nil {
+			serializer = s.serializer
+		}
+		var encoded bytes.Buffer
+		compressor := encoding.Compressor.NewStreamCompressor(&encoded)
+		if compressor == nil {
+			compressor = &compression.NoopStreamCompressor{Writer: &encoded}
+		}
+		wc := newWriterWithCounter(compressor)
+		if err := serializer.Serialize(included, wc); err != nil {
+			log.Warnf("Encoding for endpoints %s failed for pipeline %s: %v", encoding.Key, s.pipelineName, err)
+			continue
+		}
+		if err := compressor.Close(); err != nil {
+			log.Warnf("Encoding for endpoints %s failed for pipeline %s: %v", encoding.Key, s.pipelineName, err)
+			continue
+		}
+		variants[encoding.Key] = &message.PayloadVariant{
+			Encoded:       encoded.Bytes(),
+			Encoding:      encoding.Compressor.ContentEncoding(),
+			UnencodedSize: wc.getWrittenBytes(),
+			Count:         len(included),
+		}
+	}
+	return variants
+}
+
 // writerCounter is a simple io.Writer that counts the number of bytes written to it
 type writerCounter struct {
 	io.Writer
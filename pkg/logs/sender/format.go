@@ -0,0 +1,313 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package sender
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/DataDog/datadog-agent/comp/logs/agent/config"
+	logscompression "github.com/DataDog/datadog-agent/comp/serializer/logscompression/def"
+	"github.com/DataDog/datadog-agent/pkg/logs/message"
+	"github.com/DataDog/datadog-agent/pkg/util/compression"
+)
+
+var (
+	// NDJSONSerializer is a shared newline-delimited JSON serializer.
+	NDJSONSerializer Serializer = &ndjsonSerializer{}
+	// OTLPSerializer is a shared OTLP/HTTP logs request serializer.
+	OTLPSerializer Serializer = &otlpSerializer{}
+)
+
+// EndpointEncoding is an encoding of the payloads for the endpoints of a same
+// encoding key, see config.Endpoint.EncodingKey.
+type EndpointEncoding struct {
+	Key string
+	// Serializer is nil for the default format, the payloads are then
+	// serialized as the main ones
+	Serializer Serializer
+	Compressor compression.Compressor
+	// Include returns true if the message is sent to the endpoints
+	Include func(*message.Message) bool
+}
+
+// EndpointEncodings returns the encodings of the endpoints which don't use the
+// payloads of the main endpoint: another format, another compression, or a
+// subset of the logs. The batch strategy encodes the payloads in these encodings
+// along with the main one, so that the destinations don't have to convert them
+// on every send.
+func EndpointEncodings(endpoints *config.Endpoints, compressor logscompression.Component) []EndpointEncoding {
+	var encodings []EndpointEncoding
+	seen := make(map[string]bool)
+	for _, endpoint := range endpoints.Endpoints {
+		key := endpoint.EncodingKey()
+		if key == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		var serializer Serializer
+		switch endpoint.Format {
+		case config.NDJSONIntakeFormat:
+			serializer = NDJSONSerializer
+		case config.OTLPHTTPIntakeFormat:
+			serializer = OTLPSerializer
+		}
+		encoder := compressor.NewCompressor(compression.NoneKind, 0)
+		if endpoint.UseCompression {
+			encoder = compressor.NewCompressor(endpoint.CompressionKind, endpoint.CompressionLevel)
+		}
+		endpoint := endpoint
+		encodings = append(encodings, EndpointEncoding{
+			Key:        key,
+			Serializer: serializer,
+			Compressor: encoder,
+			Include: func(m *message.Message) bool {
+				if m.Origin == nil {
+					return true
+				}
+				return endpoint.IncludesLog(m.Origin.Source(), m.Origin.Service())
+			},
+		})
+	}
+	return encodings
+}
+
+// ndjsonSerializer transforms a message array into newline-delimited JSON.
+type ndjsonSerializer struct{}
+
+// Serialize writes each message followed by a new line character,
+// for example:
+// "{"message":"content1"}", "{"message":"content2"}"
+// returns, "{"message":"content1"}\n{"message":"content2"}\n"
+func (s *ndjsonSerializer) Serialize(messages []*message.Message, writer io.Writer) error {
+	for _, message := range messages {
+		if _, err := writer.Write(message.GetContent()); err != nil {
+			return err
+		}
+		if _, err := writer.Write([]byte{'\n'}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// OTLP/HTTP logs request, using the JSON encoding of the OTLP protobuf messages.
+// See https://opentelemetry.io/docs/specs/otlp/#json-protobuf-encoding
+type otlpLogsRequest struct {
+	ResourceLogs []otlpResourceLogs `json:"resourceLogs"`
+}
+
+type otlpResourceLogs struct {
+	Resource  otlpResource    `json:"resource"`
+	ScopeLogs []otlpScopeLogs `json:"scopeLogs"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpScopeLogs struct {
+	Scope      otlpScope       `json:"scope"`
+	LogRecords []otlpLogRecord `json:"logRecords"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpLogRecord struct {
+	TimeUnixNano         string         `json:"timeUnixNano,omitempty"`
+	ObservedTimeUnixNano string         `json:"observedTimeUnixNano,omitempty"`
+	SeverityNumber       int            `json:"severityNumber,omitempty"`
+	SeverityText         string         `json:"severityText,omitempty"`
+	Body                 otlpAnyValue   `json:"body"`
+	Attributes           []otlpKeyValue `json:"attributes,omitempty"`
+}
+
+type otlpKeyValue struct {
+	Key   string       `json:"key"`
+	Value otlpAnyValue `json:"value"`
+}
+
+// otlpAnyValue holds exactly one of its values, the 64-bit integers are
+// encoded as strings.
+type otlpAnyValue struct {
+	StringValue *string           `json:"stringValue,omitempty"`
+	BoolValue   *bool             `json:"boolValue,omitempty"`
+	IntValue    string            `json:"intValue,omitempty"`
+	DoubleValue *float64          `json:"doubleValue,omitempty"`
+	ArrayValue  *otlpArrayValue   `json:"arrayValue,omitempty"`
+	KvlistValue *otlpKeyValueList `json:"kvlistValue,omitempty"`
+}
+
+type otlpArrayValue struct {
+	Values []otlpAnyValue `json:"values"`
+}
+
+type otlpKeyValueList struct {
+	Values []otlpKeyValue `json:"values"`
+}
+
+// otlpSeverityNumbers maps the statuses of the logs to the OTLP severity numbers.
+var otlpSeverityNumbers = map[string]int{
+	message.StatusDebug:     5,
+	message.StatusInfo:      9,
+	message.StatusNotice:    10,
+	message.StatusWarning:   13,
+	message.StatusError:     17,
+	message.StatusCritical:  21,
+	message.StatusAlert:     22,
+	message.StatusEmergency: 23,
+}
+
+// otlpSerializer transforms a message array into an OTLP/HTTP logs request.
+// The logs of a same host, service and source share the same resource.
+type otlpSerializer struct{}
+
+// Serialize writes the messages as a JSON encoded OTLP/HTTP logs request. The
+// records are built from the metadata and the rendered content of the messages,
+// the structured content being sent as a key-value list body.
+func (s *otlpSerializer) Serialize(messages []*message.Message, writer io.Writer) error {
+	request := otlpLogsRequest{}
+	resources := make(map[[3]string]int)
+	for _, m := range messages {
+		var service, source string
+		if m.Origin != nil {
+			service, source = m.Origin.Service(), m.Origin.Source()
+		}
+
+		key := [3]string{m.Hostname, service, source}
+		idx, ok := resources[key]
+		if !ok {
+			idx = len(request.ResourceLogs)
+			resources[key] = idx
+			request.ResourceLogs = append(request.ResourceLogs, otlpResourceLogs{
+				Resource: otlpResource{Attributes: otlpAttributes(
+					"host.name", m.Hostname,
+					"service.name", service,
+					"datadog.source", source,
+				)},
+				ScopeLogs: []otlpScopeLogs{{Scope: otlpScope{Name: "datadog-agent"}}},
+			})
+		}
+
+		status := m.GetStatus()
+		record := otlpLogRecord{
+			SeverityNumber: otlpSeverityNumbers[status],
+			SeverityText:   status,
+			Body:           otlpBody(m),
+		}
+		if !m.ServerlessExtra.Timestamp.IsZero() {
+			record.TimeUnixNano = strconv.FormatInt(m.ServerlessExtra.Timestamp.UnixNano(), 10)
+		}
+		if m.IngestionTimestamp > 0 {
+			record.ObservedTimeUnixNano = strconv.FormatInt(m.IngestionTimestamp, 10)
+		}
+		if m.Origin != nil {
+			record.Attributes = otlpTagAttributes(m.Tags())
+		}
+		scope := &request.ResourceLogs[idx].ScopeLogs[0]
+		scope.LogRecords = append(scope.LogRecords, record)
+	}
+
+	return json.NewEncoder(writer).Encode(request)
+}
+
+// otlpBody returns the body of the record of the given message: the data of its
+// structured content, or its rendered content.
+func otlpBody(m *message.Message) otlpAnyValue {
+	if content, ok := m.GetStructuredContent().(*message.BasicStructuredContent); ok {
+		return otlpValue(content.Data)
+	}
+	return otlpString(string(m.GetRenderedContent()))
+}
+
+// otlpValue returns the OTLP value of a structured content value.
+func otlpValue(value interface{}) otlpAnyValue {
+	switch v := value.(type) {
+	case string:
+		return otlpString(v)
+	case bool:
+		return otlpAnyValue{BoolValue: &v}
+	case int:
+		return otlpAnyValue{IntValue: strconv.Itoa(v)}
+	case int64:
+		return otlpAnyValue{IntValue: strconv.FormatInt(v, 10)}
+	case float64:
+		return otlpAnyValue{DoubleValue: &v}
+	case []interface{}:
+		values := make([]otlpAnyValue, 0, len(v))
+		for _, item := range v {
+			values = append(values, otlpValue(item))
+		}
+		return otlpAnyValue{ArrayValue: &otlpArrayValue{Values: values}}
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		values := make([]otlpKeyValue, 0, len(v))
+		for _, key := range keys {
+			values = append(values, otlpKeyValue{Key: key, Value: otlpValue(v[key])})
+		}
+		return otlpAnyValue{KvlistValue: &otlpKeyValueList{Values: values}}
+	case nil:
+		return otlpAnyValue{}
+	default:
+		return otlpString(fmt.Sprint(v))
+	}
+}
+
+func otlpString(value string) otlpAnyValue {
+	return otlpAnyValue{StringValue: &value}
+}
+
+// otlpTagAttributes returns an attribute per tag key, holding the value of the
+// tag, or the array of its values for a key used by several tags.
+func otlpTagAttributes(tags []string) []otlpKeyValue {
+	var keys []string
+	values := make(map[string][]string)
+	for _, tag := range tags {
+		key, value, _ := strings.Cut(tag, ":")
+		if _, ok := values[key]; !ok {
+			keys = append(keys, key)
+		}
+		values[key] = append(values[key], value)
+	}
+
+	attributes := make([]otlpKeyValue, 0, len(keys))
+	for _, key := range keys {
+		if len(values[key]) == 1 {
+			attributes = append(attributes, otlpKeyValue{Key: key, Value: otlpString(values[key][0])})
+			continue
+		}
+		array := make([]otlpAnyValue, 0, len(values[key]))
+		for _, value := range values[key] {
+			array = append(array, otlpString(value))
+		}
+		attributes = append(attributes, otlpKeyValue{Key: key, Value: otlpAnyValue{ArrayValue: &otlpArrayValue{Values: array}}})
+	}
+	return attributes
+}
+
+// otlpAttributes returns the attributes of the given key and value pairs, the
+// empty values are skipped.
+func otlpAttributes(keyValues ...string) []otlpKeyValue {
+	attributes := make([]otlpKeyValue, 0, len(keyValues)/2)
+	for i := 0; i+1 < len(keyValues); i += 2 {
+		if keyValues[i+1] == "" {
+			continue
+		}
+		attributes = append(attributes, otlpKeyValue{Key: keyValues[i], Value: otlpString(keyValues[i+1])})
+	}
+	return attributes
+}
@@ -0,0 +1,173 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package sender
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DataDog/datadog-agent/comp/logs/agent/config"
+	compressionfx "github.com/DataDog/datadog-agent/comp/serializer/logscompression/fx-mock"
+	logscompressionimpl "github.com/DataDog/datadog-agent/comp/serializer/logscompression/impl"
+	"github.com/DataDog/datadog-agent/pkg/logs/message"
+	"github.com/DataDog/datadog-agent/pkg/logs/metrics"
+	"github.com/DataDog/datadog-agent/pkg/logs/sources"
+	"github.com/DataDog/datadog-agent/pkg/util/compression"
+	gzipimpl "github.com/DataDog/datadog-agent/pkg/util/compression/impl-gzip"
+)
+
+func testDatadogMessages() []*message.Message {
+	return []*message.Message{
+		message.NewMessage([]byte(`{"message":"first","status":"error","timestamp":1700000000000,"hostname":"host","service":"web","ddsource":"nginx","ddtags":"env:prod"}`), nil, "", 0),
+		message.NewMessage([]byte(`{"message":"second","status":"info","timestamp":1700000000001,"hostname":"host","service":"web","ddsource":"nginx","ddtags":""}`), nil, "", 0),
+	}
+}
+
+func TestNDJSONSerializer(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, NDJSONSerializer.Serialize(testDatadogMessages(), &buf))
+	assert.Equal(t, `{"message":"first","status":"error","timestamp":1700000000000,"hostname":"host","service":"web","ddsource":"nginx","ddtags":"env:prod"}
+{"message":"second","status":"info","timestamp":1700000000001,"hostname":"host","service":"web","ddsource":"nginx","ddtags":""}
+`, buf.String())
+}
+
+func TestOTLPSerializer(t *testing.T) {
+	first := testOriginMessage("", "nginx", "web")
+	first.Hostname = "host"
+	first.Status = message.StatusError
+	first.IngestionTimestamp = 1700000000000000000
+	first.ServerlessExtra.Timestamp = time.Unix(1699999999, 0)
+	first.Origin.SetTags([]string{"env:prod", "team:a", "team:b", "canary"})
+	first.SetRendered([]byte("first"))
+	first.SetEncoded([]byte(`{"message":"first"}`))
+
+	second := testOriginMessage("GET / 200", "nginx", "web")
+	second.Hostname = "host"
+	second.SetAttribute("http.status_code", 200)
+	second.SetAttribute("http.method", "GET")
+	rendered, err := second.Render()
+	require.NoError(t, err)
+	second.SetRendered(rendered)
+	second.SetEncoded([]byte(`{"message":"second"}`))
+
+	var buf bytes.Buffer
+	require.NoError(t, OTLPSerializer.Serialize([]*message.Message{first, second}, &buf))
+
+	var request otlpLogsRequest
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &request))
+	require.Len(t, request.ResourceLogs, 1)
+	assert.Equal(t, otlpAttributes("host.name", "host", "service.name", "web", "datadog.source", "nginx"), request.ResourceLogs[0].Resource.Attributes)
+
+	records := request.ResourceLogs[0].ScopeLogs[0].LogRecords
+	require.Len(t, records, 2)
+	assert.Equal(t, otlpLogRecord{
+		TimeUnixNano:         "1699999999000000000",
+		ObservedTimeUnixNano: "1700000000000000000",
+		SeverityNumber:       17,
+		SeverityText:         "error",
+		Body:                 otlpString("first"),
+		Attributes: []otlpKeyValue{
+			{Key: "env", Value: otlpString("prod")},
+			{Key: "team", Value: otlpAnyValue{ArrayValue: &otlpArrayValue{Values: []otlpAnyValue{otlpString("a"), otlpString("b")}}}},
+			{Key: "canary", Value: otlpString("")},
+		},
+	}, records[0])
+
+	assert.Equal(t, otlpLogRecord{
+		SeverityNumber: 9,
+		SeverityText:   "info",
+		Body: otlpAnyValue{KvlistValue: &otlpKeyValueList{Values: []otlpKeyValue{
+			{Key: "http", Value: otlpAnyValue{KvlistValue: &otlpKeyValueList{Values: []otlpKeyValue{
+				{Key: "method", Value: otlpString("GET")},
+				{Key: "status_code", Value: otlpAnyValue{IntValue: "200"}},
+			}}}},
+			{Key: "message", Value: otlpString("GET / 200")},
+		}}},
+	}, records[1])
+	assert.Contains(t, buf.String(), `"intValue":"200"`)
+}
+
+func TestEndpointEncodings(t *testing.T) {
+	compressor := logscompressionimpl.NewComponent()
+	main := config.NewEndpoint("", "", "host", 443, false)
+	ndjson := main
+	ndjson.Format = config.NDJSONIntakeFormat
+	otlp := main
+	otlp.Format = config.OTLPHTTPIntakeFormat
+	filtered := main
+	filtered.Services = []string{"db"}
+
+	assert.Nil(t, EndpointEncodings(config.NewEndpoints(main, nil, false, true), compressor))
+
+	encodings := EndpointEncodings(config.NewEndpoints(main, []config.Endpoint{ndjson, otlp, ndjson, filtered}, false, true), compressor)
+	require.Len(t, encodings, 3)
+	assert.Equal(t, ndjson.EncodingKey(), encodings[0].Key)
+	assert.Equal(t, NDJSONSerializer, encodings[0].Serializer)
+	assert.Equal(t, otlp.EncodingKey(), encodings[1].Key)
+	assert.Equal(t, OTLPSerializer, encodings[1].Serializer)
+	assert.Equal(t, filtered.EncodingKey(), encodings[2].Key)
+	assert.Nil(t, encodings[2].Serializer)
+
+	assert.True(t, encodings[2].Include(message.NewMessage(nil, nil, "", 0)))
+	assert.True(t, encodings[2].Include(testOriginMessage("", "postgres", "db")))
+	assert.False(t, encodings[2].Include(testOriginMessage("", "nginx", "web")))
+}
+
+func testOriginMessage(content string, source string, service string) *message.Message {
+	origin := message.NewOrigin(sources.NewLogSource("", &config.LogsConfig{}))
+	origin.SetSource(source)
+	origin.SetService(service)
+	return message.NewMessage([]byte(content), origin, "", 0)
+}
+
+func TestBatchStrategyEncodesVariants(t *testing.T) {
+	input := make(chan *message.Message)
+	output := make(chan *message.Payload)
+	flushChan := make(chan struct{})
+
+	gzipCompressor := gzipimpl.New(gzipimpl.Requires{Level: 6})
+	noneCompressor := compressionfx.NewMockCompressor().NewCompressor(compression.NoneKind, 0)
+	encodings := []EndpointEncoding{
+		{Key: "ndjson", Serializer: NDJSONSerializer, Compressor: gzipCompressor},
+		{Key: "otlp", Serializer: OTLPSerializer, Compressor: noneCompressor},
+		{Key: "web", Compressor: noneCompressor, Include: func(m *message.Message) bool { return m.Origin.Service() == "web" }},
+		{Key: "db", Compressor: noneCompressor, Include: func(m *message.Message) bool { return m.Origin.Service() == "db" }},
+	}
+	s := NewBatchStrategy(input, output, flushChan, NewMockServerlessMeta(false), ArraySerializer, encodings, time.Hour, 2, 1000, "test", noneCompressor, metrics.NewNoopPipelineMonitor(""))
+	s.Start()
+	defer s.Stop()
+
+	first := testOriginMessage(`{"message":"first","service":"web"}`, "nginx", "web")
+	second := testOriginMessage(`{"message":"second","service":"api"}`, "nginx", "api")
+	input <- first
+	input <- second
+	payload := <-output
+	require.Len(t, payload.Variants, 4)
+	assert.Equal(t, `[{"message":"first","service":"web"},{"message":"second","service":"api"}]`, string(payload.Encoded))
+
+	variant := payload.Variants["ndjson"]
+	assert.Equal(t, "gzip", variant.Encoding)
+	assert.Equal(t, 2, variant.Count)
+	decoded, err := gzipCompressor.Decompress(variant.Encoded)
+	require.NoError(t, err)
+	assert.Equal(t, `{"message":"first","service":"web"}`+"\n"+`{"message":"second","service":"api"}`+"\n", string(decoded))
+	assert.Equal(t, len(decoded), variant.UnencodedSize)
+
+	assert.Equal(t, 2, payload.Variants["otlp"].Count)
+	assert.Contains(t, string(payload.Variants["otlp"].Encoded), `"resourceLogs"`)
+
+	variant = payload.Variants["web"]
+	assert.Equal(t, 1, variant.Count)
+	assert.Equal(t, noneCompressor.ContentEncoding(), variant.Encoding)
+	assert.Equal(t, `[{"message":"first","service":"web"}]`, string(variant.Encoded))
+
+	assert.Equal(t, &message.PayloadVariant{}, payload.Variants["db"])
+}
@@ -173,6 +173,13 @@ func (b *Builder) toDictionary(c *config.LogsConfig) map[string]interface{} {
 	switch c.Type {
 	case config.TCPType, config.UDPType:
 		dictionary["Port"] = c.Port
+	case config.SyslogType:
+		dictionary["Protocol"] = c.SyslogProtocol()
+		if c.SyslogProtocol() == config.SyslogProtocolUnix {
+			dictionary["Path"] = c.Path
+		} else {
+			dictionary["Port"] = c.Port
+		}
 	case config.FileType:
 		dictionary["Path"] = c.Path
 		dictionary["TailingMode"] = c.TailingMode
@@ -357,6 +357,13 @@ func (t *Tailer) forwardMessages() {
 			tags = append(tags, output.ParsingExtra.Tags...)
 			tags = append(tags, t.tagProvider.GetTags()...)
 			origin.SetTags(tags)
+			origin.SetService(output.ParsingExtra.Service)
+			if output.State == message.StateStructured {
+				// structured messages (e.g. JSON logs) carry their attributes
+				output.Origin = origin
+				t.outputChan <- output
+				continue
+			}
 			// XXX(remy): is it OK recreating a message here?
 			t.outputChan <- message.NewMessage(output.GetContent(), origin, output.Status, output.IngestionTimestamp)
 		}
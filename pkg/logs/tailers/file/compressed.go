@@ -0,0 +1,128 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package file
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+
+	"github.com/DataDog/datadog-agent/pkg/logs/internal/decoder"
+	"github.com/DataDog/datadog-agent/pkg/util/compression"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+// compressedFileExtensions maps the extensions of the compressed files to their compression kind.
+var compressedFileExtensions = map[string]string{
+	".gz":  compression.GzipKind,
+	".zst": compression.ZstdKind,
+}
+
+// CompressionKind returns the compression kind of a file, guessed from its
+// extension, or an empty string if the file is not compressed.
+func CompressionKind(path string) string {
+	return compressedFileExtensions[strings.ToLower(filepath.Ext(path))]
+}
+
+// newDecompressingReader returns a reader of the decompressed content of r.
+func newDecompressingReader(kind string, r io.Reader) (io.ReadCloser, error) {
+	switch kind {
+	case compression.GzipKind:
+		return gzip.NewReader(r)
+	case compression.ZstdKind:
+		decoder, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		return decoder.IOReadCloser(), nil
+	}
+	return nil, fmt.Errorf("unsupported compression %q", kind)
+}
+
+// setupCompressed opens the file to tail. The offsets of a compressed file are
+// offsets in its decompressed content, the content before the position is
+// decompressed and discarded by the tailer goroutine, see skipCompressed.
+func (t *Tailer) setupCompressed(offset int64, whence int) error {
+	if whence != io.SeekStart && whence != io.SeekEnd {
+		return fmt.Errorf("unsupported whence %d", whence)
+	}
+	fullpath, err := filepath.Abs(t.file.Path)
+	if err != nil {
+		return err
+	}
+	t.fullpath = fullpath
+
+	// adds metadata to enable users to filter logs by filename
+	t.tags = t.buildTailerTags()
+
+	log.Info("Opening compressed file", t.file.Path, "for tailer key", t.file.GetScanKey())
+	f, err := os.Open(fullpath)
+	if err != nil {
+		return err
+	}
+	decompressed, err := newDecompressingReader(t.compression, bufio.NewReader(f))
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("can't decompress %q: %w", t.file.Path, err)
+	}
+
+	t.osFile = f
+	t.decompressed = decompressed
+	t.compressedOffset = offset
+	t.compressedWhence = whence
+	return nil
+}
+
+// skipCompressed decompresses and discards the content of the file before the
+// position it is read from.
+func (t *Tailer) skipCompressed() error {
+	var skipped int64
+	var err error
+	if t.compressedWhence == io.SeekEnd {
+		skipped, err = io.Copy(io.Discard, t.decompressed)
+	} else {
+		skipped, err = io.CopyN(io.Discard, t.decompressed, t.compressedOffset)
+		if err == io.EOF {
+			// the file is shorter than the offset, there is nothing left to read
+			err = nil
+		}
+	}
+	if err != nil {
+		err = fmt.Errorf("can't decompress %q: %w", t.file.Path, err)
+		t.file.Source.Status().Error(err)
+		log.Warn(err)
+		return err
+	}
+
+	t.lastReadOffset.Store(skipped)
+	t.decodedOffset.Store(skipped)
+	t.forwardedOffset.Store(skipped)
+	return nil
+}
+
+// readCompressed reads the decompressed content of the file, it returns io.EOF
+// once the whole content has been read as compressed files are not appended to.
+func (t *Tailer) readCompressed() (int, error) {
+	inBuf := make([]byte, 4096)
+	n, err := t.decompressed.Read(inBuf)
+	if err != nil && err != io.EOF {
+		// an unexpected error occurred, e.g. a truncated or corrupted file, stop the tailer
+		t.file.Source.Status().Error(err)
+		log.Errorf("Unexpected error occurred while decompressing file %q: %v", t.file.Path, err)
+	}
+	if n == 0 && err != nil {
+		return 0, err
+	}
+	t.lastReadOffset.Add(int64(n))
+	t.decoder.InputChan <- decoder.NewInput(inBuf[:n])
+	return n, nil
+}
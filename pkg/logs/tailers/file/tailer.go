@@ -51,6 +51,10 @@ type Tailer struct {
 	// ends.
 	decodedOffset *atomic.Int64
 
+	// forwardedOffset is the offset in the file at which the latest forwarded
+	// message ends, unlike decodedOffset it keeps increasing after a rotation.
+	forwardedOffset *atomic.Int64
+
 	// file contains the logs configuration for the file to parse (path, source, ...)
 	// If you are looking for the os.file use to read on the FS, see osFile.
 	file *File
@@ -62,6 +66,19 @@ type Tailer struct {
 	// is platform-specific, and not every platform will have a non-nil value here.
 	osFile *os.File
 
+	// compression is the compression kind of the file, empty if the file is
+	// read as a plain file.
+	compression string
+
+	// decompressed reads the decompressed content of a compressed file, which
+	// is read once instead of being tailed.
+	decompressed io.ReadCloser
+
+	// compressedOffset and compressedWhence are the position from where the
+	// compressed file is read, reached by the tailer goroutine before reading.
+	compressedOffset int64
+	compressedWhence int
+
 	// tags are the tags to be attached to each log message, excluding tags provided
 	// by the tag provider.
 	tags []string
@@ -130,6 +147,7 @@ type TailerOptions struct {
 	Decoder         *decoder.Decoder        // Required
 	Info            *status.InfoRegistry    // Required
 	Rotated         bool                    // Optional
+	Compression     string                  // Optional
 	TagAdder        tag.EntityTagAdder      // Required
 	PipelineMonitor metrics.PipelineMonitor // Required
 }
@@ -167,11 +185,13 @@ func NewTailer(opts *TailerOptions) *Tailer {
 
 	t := &Tailer{
 		file:                   opts.File,
+		compression:            opts.Compression,
 		outputChan:             opts.OutputChan,
 		decoder:                opts.Decoder,
 		tagProvider:            tagProvider,
 		lastReadOffset:         atomic.NewInt64(0),
 		decodedOffset:          atomic.NewInt64(0),
+		forwardedOffset:        atomic.NewInt64(0),
 		sleepDuration:          opts.SleepDuration,
 		closeTimeout:           closeTimeout,
 		windowsOpenFileTimeout: windowsOpenFileTimeout,
@@ -211,6 +231,7 @@ func (t *Tailer) NewRotatedTailer(file *File, outputChan chan *message.Message,
 		Decoder:         decoder,
 		Info:            info,
 		Rotated:         true,
+		Compression:     t.compression,
 		TagAdder:        tagAdder,
 		PipelineMonitor: pipelineMonitor,
 	}
@@ -232,7 +253,12 @@ func (t *Tailer) Identifier() string {
 
 // Start begins the tailer's operation in a dedicated goroutine.
 func (t *Tailer) Start(offset int64, whence int) error {
-	err := t.setup(offset, whence)
+	var err error
+	if t.IsCompressed() {
+		err = t.setupCompressed(offset, whence)
+	} else {
+		err = t.setup(offset, whence)
+	}
 	if err != nil {
 		t.file.Source.Status().Error(err)
 		return err
@@ -292,6 +318,9 @@ func (t *Tailer) StopAfterFileRotation() {
 // until it is closed or the tailer is stopped.
 func (t *Tailer) readForever() {
 	defer func() {
+		if t.decompressed != nil {
+			t.decompressed.Close()
+		}
 		if t.osFile != nil {
 			t.osFile.Close()
 		}
@@ -299,8 +328,20 @@ func (t *Tailer) readForever() {
 		log.Info("Closed", t.file.Path, "for tailer key", t.file.GetScanKey(), "read", t.Source().BytesRead.Get(), "bytes and", t.decoder.GetLineCount(), "lines")
 	}()
 
+	if t.decompressed != nil {
+		if err := t.skipCompressed(); err != nil {
+			return
+		}
+	}
+
 	for {
-		n, err := t.read()
+		var n int
+		var err error
+		if t.decompressed != nil {
+			n, err = t.readCompressed()
+		} else {
+			n, err = t.read()
+		}
 		if err != nil {
 			return
 		}
@@ -332,6 +373,22 @@ func (t *Tailer) buildTailerTags() []string {
 	return tags
 }
 
+// IsCompressed returns true if the tailer reads a compressed file.
+func (t *Tailer) IsCompressed() bool {
+	return t.compression != ""
+}
+
+// ForwardedOffset returns the offset in the file at which the latest forwarded
+// message ends, it keeps increasing after a rotation.
+func (t *Tailer) ForwardedOffset() int64 {
+	return t.forwardedOffset.Load()
+}
+
+// Path returns the path of the tailed file.
+func (t *Tailer) Path() string {
+	return t.file.Path
+}
+
 // IsFinished returns true if the tailer has flushed all messages to the output
 // channel, either because it has been stopped or because of an error reading from
 // the input file.
@@ -347,6 +404,7 @@ func (t *Tailer) forwardMessages() {
 		close(t.done)
 	}()
 	for output := range t.decoder.OutputChan {
+		t.forwardedOffset.Add(int64(output.RawDataLen))
 		offset := t.decodedOffset.Load() + int64(output.RawDataLen)
 		identifier := t.Identifier()
 		if t.didFileRotate.Load() {
@@ -363,12 +421,20 @@ func (t *Tailer) forwardMessages() {
 		tags = append(tags, t.tagProvider.GetTags()...)
 		tags = append(tags, output.ParsingExtra.Tags...)
 		origin.SetTags(tags)
+		origin.SetService(output.ParsingExtra.Service)
 		// Ignore empty lines once the registry offset is updated
 		if len(output.GetContent()) == 0 {
 			continue
 		}
 
-		msg := message.NewMessage(output.GetContent(), origin, output.Status, output.IngestionTimestamp)
+		var msg *message.Message
+		if output.State == message.StateStructured {
+			// structured messages (e.g. JSON logs) carry their attributes
+			output.Origin = origin
+			msg = output
+		} else {
+			msg = message.NewMessage(output.GetContent(), origin, output.Status, output.IngestionTimestamp)
+		}
 		// Make the write to the output chan cancellable to be able to stop the tailer
 		// after a file rotation when it is stuck on it.
 		// We don't return directly to keep the same shutdown sequence that in the
@@ -37,6 +37,7 @@ func (t *Tailer) setup(offset int64, whence int) error {
 	ret, _ := f.Seek(offset, whence)
 	t.lastReadOffset.Store(ret)
 	t.decodedOffset.Store(ret)
+	t.forwardedOffset.Store(ret)
 
 	return nil
 }
@@ -15,6 +15,8 @@ import (
 	"testing"
 	"time"
 
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
 
 	"github.com/DataDog/datadog-agent/comp/logs/agent/config"
@@ -25,6 +27,9 @@ import (
 	"github.com/DataDog/datadog-agent/pkg/logs/metrics"
 	"github.com/DataDog/datadog-agent/pkg/logs/sources"
 	status "github.com/DataDog/datadog-agent/pkg/logs/status/utils"
+	"github.com/DataDog/datadog-agent/pkg/util/compression"
+	implgzip "github.com/DataDog/datadog-agent/pkg/util/compression/impl-gzip"
+	implzstd "github.com/DataDog/datadog-agent/pkg/util/compression/impl-zstd-nocgo"
 )
 
 var chanSize = 10
@@ -163,6 +168,37 @@ func (suite *TailerTestSuite) TestTailFromBeginning() {
 	suite.Equal(len(lines[0])+len(lines[1])+len(lines[2]), int(suite.tailer.decodedOffset.Load()))
 }
 
+func (suite *TailerTestSuite) TestTailJSONLogs() {
+	jsonSource := sources.NewReplaceableSource(sources.NewLogSource("", &config.LogsConfig{
+		Type:   config.FileType,
+		Path:   suite.testPath,
+		Parser: config.JSONParser,
+	}))
+	info := status.NewInfoRegistry()
+
+	tailerOptions := &TailerOptions{
+		OutputChan:      suite.outputChan,
+		File:            NewFile(suite.testPath, jsonSource.UnderlyingSource(), false),
+		SleepDuration:   10 * time.Millisecond,
+		Decoder:         decoder.NewDecoderFromSource(jsonSource, info),
+		Info:            info,
+		PipelineMonitor: metrics.NewNoopPipelineMonitor(""),
+	}
+	suite.tailer = NewTailer(tailerOptions)
+
+	line := `{"message":"hello world","level":"error","service":"web","user":"foo"}` + "\n"
+	_, err := suite.testFile.WriteString(line)
+	suite.Nil(err)
+	suite.tailer.StartFromBeginning()
+
+	msg := <-suite.outputChan
+	suite.Equal(message.StateStructured, msg.State)
+	suite.Equal("hello world", string(msg.GetContent()))
+	suite.Equal(message.StatusError, msg.Status)
+	suite.Equal("web", msg.Origin.Service())
+	suite.Equal(len(line), toInt(msg.Origin.Offset))
+}
+
 func (suite *TailerTestSuite) TestTailFromEnd() {
 	lines := []string{"hello world\n", "hello again\n", "good bye\n"}
 
@@ -468,3 +504,105 @@ func toInt(str string) int {
 	}
 	return 0
 }
+
+func TestTailCompressedFile(t *testing.T) {
+	compressors := map[string]compression.Compressor{
+		compression.GzipKind: implgzip.New(implgzip.Requires{}),
+		compression.ZstdKind: implzstd.New(implzstd.Requires{}),
+	}
+	for kind, ext := range map[string]string{compression.GzipKind: ".gz", compression.ZstdKind: ".zst"} {
+		t.Run(kind, func(t *testing.T) {
+			compressed, err := compressors[kind].Compress([]byte("first\nsecond\nthird\n"))
+			require.NoError(t, err)
+			path := filepath.Join(t.TempDir(), "app.log.1"+ext)
+			require.NoError(t, os.WriteFile(path, compressed, 0644))
+			require.Equal(t, kind, CompressionKind(path))
+
+			source := sources.NewLogSource("", &config.LogsConfig{Type: config.FileType, Path: path})
+			info := status.NewInfoRegistry()
+			outputChan := make(chan *message.Message, chanSize)
+			tailer := NewTailer(&TailerOptions{
+				OutputChan:      outputChan,
+				File:            NewFile(path, source, false),
+				SleepDuration:   10 * time.Millisecond,
+				Decoder:         decoder.NewDecoderFromSource(sources.NewReplaceableSource(source), info),
+				Info:            info,
+				Compression:     CompressionKind(path),
+				PipelineMonitor: metrics.NewNoopPipelineMonitor(""),
+			})
+
+			// the offset is an offset in the decompressed content
+			require.NoError(t, tailer.Start(6, io.SeekStart))
+			msg := <-outputChan
+			assert.Equal(t, "second", string(msg.GetContent()))
+			assert.Equal(t, "13", msg.Origin.Offset)
+			msg = <-outputChan
+			assert.Equal(t, "third", string(msg.GetContent()))
+			assert.Equal(t, "19", msg.Origin.Offset)
+
+			// the tailer stops once the whole file has been read
+			assert.Eventually(t, tailer.IsFinished, 5*time.Second, 10*time.Millisecond)
+			tailer.Stop()
+
+			// nothing is left to read from the end of the file
+			tailer = NewTailer(&TailerOptions{
+				OutputChan:      outputChan,
+				File:            NewFile(path, source, false),
+				SleepDuration:   10 * time.Millisecond,
+				Decoder:         decoder.NewDecoderFromSource(sources.NewReplaceableSource(source), info),
+				Info:            info,
+				Compression:     CompressionKind(path),
+				PipelineMonitor: metrics.NewNoopPipelineMonitor(""),
+			})
+			require.NoError(t, tailer.Start(0, io.SeekEnd))
+			assert.Eventually(t, tailer.IsFinished, 5*time.Second, 10*time.Millisecond)
+			assert.Empty(t, outputChan)
+			tailer.Stop()
+		})
+	}
+}
+
+func TestTailCorruptedCompressedFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log.gz")
+	require.NoError(t, os.WriteFile(path, []byte("not gzip"), 0644))
+
+	source := sources.NewLogSource("", &config.LogsConfig{Type: config.FileType, Path: path})
+	info := status.NewInfoRegistry()
+	tailer := NewTailer(&TailerOptions{
+		OutputChan:      make(chan *message.Message, chanSize),
+		File:            NewFile(path, source, false),
+		SleepDuration:   10 * time.Millisecond,
+		Decoder:         decoder.NewDecoderFromSource(sources.NewReplaceableSource(source), info),
+		Info:            info,
+		Compression:     CompressionKind(path),
+		PipelineMonitor: metrics.NewNoopPipelineMonitor(""),
+	})
+	assert.Error(t, tailer.StartFromBeginning())
+}
+
+func TestTailTruncatedCompressedFile(t *testing.T) {
+	compressed, err := implgzip.New(implgzip.Requires{}).Compress([]byte("first\nsecond\n"))
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "app.log.gz")
+	// the trailer of the gzip stream is missing
+	require.NoError(t, os.WriteFile(path, compressed[:len(compressed)-8], 0644))
+
+	source := sources.NewLogSource("", &config.LogsConfig{Type: config.FileType, Path: path})
+	info := status.NewInfoRegistry()
+	tailer := NewTailer(&TailerOptions{
+		OutputChan:      make(chan *message.Message, chanSize),
+		File:            NewFile(path, source, false),
+		SleepDuration:   10 * time.Millisecond,
+		Decoder:         decoder.NewDecoderFromSource(sources.NewReplaceableSource(source), info),
+		Info:            info,
+		Compression:     CompressionKind(path),
+		PipelineMonitor: metrics.NewNoopPipelineMonitor(""),
+	})
+	require.NoError(t, tailer.StartFromBeginning())
+
+	// the tailer stops and reports the error in the status of the source
+	assert.Eventually(t, tailer.IsFinished, 5*time.Second, 10*time.Millisecond)
+	assert.True(t, source.Status.IsError())
+	assert.Contains(t, source.Status.GetError(), "unexpected EOF")
+	tailer.Stop()
+}
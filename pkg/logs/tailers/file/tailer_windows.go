@@ -39,6 +39,7 @@ func (t *Tailer) setup(offset int64, whence int) error {
 
 	t.lastReadOffset.Store(filePos)
 	t.decodedOffset.Store(filePos)
+	t.forwardedOffset.Store(filePos)
 
 	return nil
 }
@@ -14,7 +14,9 @@ import (
 
 	pkgconfigsetup "github.com/DataDog/datadog-agent/pkg/config/setup"
 	"github.com/DataDog/datadog-agent/pkg/logs/internal/decoder"
+	"github.com/DataDog/datadog-agent/pkg/logs/internal/framer"
 	"github.com/DataDog/datadog-agent/pkg/logs/internal/parsers/noop"
+	"github.com/DataDog/datadog-agent/pkg/logs/internal/parsers/syslog"
 	"github.com/DataDog/datadog-agent/pkg/logs/message"
 	"github.com/DataDog/datadog-agent/pkg/logs/sources"
 	status "github.com/DataDog/datadog-agent/pkg/logs/status/utils"
@@ -35,15 +37,26 @@ type Tailer struct {
 
 // NewTailer returns a new Tailer
 func NewTailer(source *sources.LogSource, conn net.Conn, outputChan chan *message.Message, read func(*Tailer) ([]byte, string, error)) *Tailer {
+	// tailer info is currently unused for this tailer type.
+	return newTailer(source, conn, outputChan, read, decoder.InitializeDecoder(sources.NewReplaceableSource(source), noop.New(), status.NewInfoRegistry()))
+}
+
+// NewSyslogTailer returns a new Tailer framing and parsing syslog messages
+// (RFC 5424 or RFC 3164), octet-counted or newline-terminated.
+func NewSyslogTailer(source *sources.LogSource, conn net.Conn, outputChan chan *message.Message, read func(*Tailer) ([]byte, string, error)) *Tailer {
+	// tailer info is currently unused for this tailer type.
+	return newTailer(source, conn, outputChan, read, decoder.NewDecoderWithFraming(sources.NewReplaceableSource(source), syslog.New(), framer.Syslog, nil, status.NewInfoRegistry()))
+}
+
+func newTailer(source *sources.LogSource, conn net.Conn, outputChan chan *message.Message, read func(*Tailer) ([]byte, string, error), decoder *decoder.Decoder) *Tailer {
 	return &Tailer{
 		source:     source,
 		Conn:       conn,
 		outputChan: outputChan,
 		read:       read,
-		// tailer info is currently unused for this tailer type.
-		decoder: decoder.InitializeDecoder(sources.NewReplaceableSource(source), noop.New(), status.NewInfoRegistry()),
-		stop:    make(chan struct{}, 1),
-		done:    make(chan struct{}, 1),
+		decoder:    decoder,
+		stop:       make(chan struct{}, 1),
+		done:       make(chan struct{}, 1),
 	}
 }
 
@@ -68,9 +81,14 @@ func (t *Tailer) forwardMessages() {
 		t.done <- struct{}{}
 	}()
 	for output := range t.decoder.OutputChan {
-		if len(output.GetContent()) > 0 {
-			origin := message.NewOrigin(t.source)
-			origin.SetTags(output.ParsingExtra.Tags)
+		origin := message.NewOrigin(t.source)
+		origin.SetTags(output.ParsingExtra.Tags)
+		origin.SetService(output.ParsingExtra.Service)
+		if output.State == message.StateStructured {
+			// structured messages (e.g. syslog, JSON logs) carry their attributes, even without any content
+			output.Origin = origin
+			t.outputChan <- output
+		} else if len(output.GetContent()) > 0 {
 			t.outputChan <- message.NewMessage(output.GetContent(), origin, output.Status, output.IngestionTimestamp)
 		}
 	}
@@ -103,6 +103,32 @@ func TestSourceHostTagFlagDisabled(t *testing.T) {
 	tailer.Stop()
 }
 
+func TestSyslogTailerForwardsStructuredMessages(t *testing.T) {
+	msgChan := make(chan *message.Message)
+	r, w := net.Pipe()
+	tailer := NewSyslogTailer(sources.NewLogSource("", &config.LogsConfig{}), r, msgChan, read)
+	tailer.Start()
+
+	var msg *message.Message
+
+	// should frame and parse an octet-counted message
+	w.Write([]byte("52 <11>1 2003-10-11T22:14:15.003Z host app - - - error!"))
+	msg = <-msgChan
+	assert.Equal(t, "error!", string(msg.GetContent()))
+	assert.Equal(t, message.StatusError, msg.Status)
+	assert.Equal(t, "host", msg.Hostname)
+	assert.NotNil(t, msg.Origin)
+
+	// should frame and parse a newline-terminated message
+	w.Write([]byte("<14>Oct 11 22:14:15 other app: info\n"))
+	msg = <-msgChan
+	assert.Equal(t, "info", string(msg.GetContent()))
+	assert.Equal(t, message.StatusInfo, msg.Status)
+	assert.Equal(t, "other", msg.Hostname)
+
+	tailer.Stop()
+}
+
 func read(tailer *Tailer) ([]byte, string, error) {
 	inBuf := make([]byte, 4096)
 	n, err := tailer.Conn.Read(inBuf)
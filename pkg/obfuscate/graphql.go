@@ -0,0 +1,250 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package obfuscate
+
+import (
+	"strings"
+)
+
+// graphqlCacheKeyPrefix prefixes the keys of the obfuscated GraphQL documents in the query cache.
+const graphqlCacheKeyPrefix = "graphql:"
+
+// ObfuscateGraphQLString obfuscates the GraphQL document query. The literals of the
+// arguments and of the default values of the variables are replaced by "?", while the
+// names, the variables, the types and the selection sets are kept, so that documents
+// with the same shape are obfuscated to the same string. Comments are removed.
+func (o *Obfuscator) ObfuscateGraphQLString(query string) string {
+	if query == "" {
+		return query
+	}
+	cacheKey := graphqlCacheKeyPrefix + query
+	if v, ok := o.queryCache.Get(cacheKey); ok {
+		return v.(string)
+	}
+	out := obfuscateGraphQL(query)
+	o.queryCache.Set(cacheKey, out, cachedStringCost(out))
+	return out
+}
+
+// cachedStringCost returns the cost of a string stored in the query cache: the length
+// of the string and the 16 bytes of its header.
+func cachedStringCost(s string) int64 {
+	return int64(len(s)) + 16
+}
+
+type graphqlTokenKind int
+
+const (
+	graphqlPunctuator graphqlTokenKind = iota
+	graphqlName
+	graphqlNumber
+	graphqlString
+	graphqlComment
+	graphqlUnknown
+)
+
+type graphqlToken struct {
+	kind graphqlTokenKind
+	// start and end are the offsets of the token in the document.
+	start, end int
+}
+
+// tokenizeGraphQL splits a GraphQL document into tokens. Whitespaces, line terminators
+// and commas are insignificant in GraphQL and are not returned. Unterminated strings
+// extend to the end of the document.
+func tokenizeGraphQL(doc string) []graphqlToken {
+	var toks []graphqlToken
+	for i := 0; i < len(doc); {
+		c := doc[i]
+		start := i
+		kind := graphqlUnknown
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == ',':
+			i++
+			continue
+		case c == '#':
+			kind = graphqlComment
+			for i < len(doc) && doc[i] != '\n' && doc[i] != '\r' {
+				i++
+			}
+		case c == '"':
+			kind = graphqlString
+			if strings.HasPrefix(doc[i:], `"""`) {
+				i = scanGraphQLBlockString(doc, i+3)
+			} else {
+				i = scanQuotedString(doc, i+1, '"')
+			}
+		case c == '-' || isDigit(rune(c)):
+			kind = graphqlNumber
+			i++
+			for i < len(doc) && (isDigit(rune(doc[i])) || doc[i] == '.' || doc[i] == 'e' || doc[i] == 'E' ||
+				((doc[i] == '+' || doc[i] == '-') && (doc[i-1] == 'e' || doc[i-1] == 'E'))) {
+				i++
+			}
+		case isGraphQLNameStart(c):
+			kind = graphqlName
+			for i < len(doc) && (isGraphQLNameStart(doc[i]) || isDigit(rune(doc[i]))) {
+				i++
+			}
+		case strings.HasPrefix(doc[i:], "..."):
+			kind = graphqlPunctuator
+			i += 3
+		case strings.IndexByte("!$&()/:=@[]{}|", c) != -1:
+			kind = graphqlPunctuator
+			i++
+		default:
+			i++
+		}
+		toks = append(toks, graphqlToken{kind: kind, start: start, end: i})
+	}
+	return toks
+}
+
+// scanGraphQLBlockString returns the offset following the block string whose content
+// starts at offset i.
+func scanGraphQLBlockString(doc string, i int) int {
+	for i < len(doc) {
+		switch {
+		case strings.HasPrefix(doc[i:], `\"""`):
+			i += 4
+		case strings.HasPrefix(doc[i:], `"""`):
+			return i + 3
+		default:
+			i++
+		}
+	}
+	return i
+}
+
+// scanQuotedString returns the offset following the string delimited by quote whose
+// content starts at offset i.
+func scanQuotedString(s string, i int, quote byte) int {
+	for i < len(s) {
+		switch s[i] {
+		case '\\':
+			i += 2
+		case quote:
+			return i + 1
+		default:
+			i++
+		}
+	}
+	return len(s)
+}
+
+func isGraphQLNameStart(c byte) bool {
+	return c == '_' || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z')
+}
+
+// graphqlObfuscator walks the tokens of a GraphQL document and marks the literals
+// found at the positions of the values.
+type graphqlObfuscator struct {
+	doc      string
+	toks     []graphqlToken
+	literals map[int]bool // indexes of the tokens replaced by "?"
+}
+
+func obfuscateGraphQL(doc string) string {
+	g := &graphqlObfuscator{doc: doc, toks: tokenizeGraphQL(doc), literals: make(map[int]bool)}
+	for i := 0; i < len(g.toks); {
+		if g.is(i, "(") {
+			i = g.parens(i)
+		} else {
+			i++
+		}
+	}
+
+	var out strings.Builder
+	out.Grow(len(doc))
+	last := 0
+	for i, tok := range g.toks {
+		if tok.kind == graphqlComment {
+			out.WriteString(strings.TrimRight(doc[last:tok.start], " \t"))
+			last = tok.end
+			continue
+		}
+		out.WriteString(doc[last:tok.start])
+		if g.literals[i] {
+			out.WriteByte('?')
+		} else {
+			out.WriteString(doc[tok.start:tok.end])
+		}
+		last = tok.end
+	}
+	out.WriteString(doc[last:])
+	return strings.TrimSpace(out.String())
+}
+
+// is reports whether the token at index i is the punctuator p.
+func (g *graphqlObfuscator) is(i int, p string) bool {
+	return i < len(g.toks) && g.toks[i].kind == graphqlPunctuator && g.doc[g.toks[i].start:g.toks[i].end] == p
+}
+
+// parens walks the arguments or the variable definitions opened at index i, and
+// returns the index following the closing parenthesis. In both cases, the values
+// follow a colon, except the default values of the variables which follow an equal
+// sign; the types of the variables follow a colon as well, but they are not values.
+func (g *graphqlObfuscator) parens(i int) int {
+	definitions := g.is(i+1, "$")
+	for i++; i < len(g.toks) && !g.is(i, ")"); {
+		switch {
+		case g.is(i, "("):
+			// arguments of a directive of a variable
+			i = g.parens(i)
+		case g.is(i, ":") && !definitions, g.is(i, "="):
+			i = g.value(i + 1)
+		default:
+			i++
+		}
+	}
+	return i + 1
+}
+
+// value walks the value starting at index i, and returns the index following it.
+func (g *graphqlObfuscator) value(i int) int {
+	if i >= len(g.toks) {
+		return i
+	}
+	switch tok := g.toks[i]; {
+	case g.is(i, "$"):
+		// variables are kept
+		return i + 2
+	case g.is(i, "["):
+		for i++; i < len(g.toks) && !g.is(i, "]"); {
+			if g.is(i, ")") || g.is(i, "}") {
+				// malformed document
+				return i
+			}
+			if next := g.value(i); next > i {
+				i = next
+			} else {
+				i++
+			}
+		}
+		return i + 1
+	case g.is(i, "{"):
+		for i++; i < len(g.toks) && !g.is(i, "}"); {
+			if g.is(i, ")") || g.is(i, "]") {
+				// malformed document
+				return i
+			}
+			if g.is(i, ":") {
+				i = g.value(i + 1)
+			} else {
+				i++
+			}
+		}
+		return i + 1
+	case tok.kind == graphqlName, tok.kind == graphqlNumber, tok.kind == graphqlString:
+		// booleans, null and enum values are replaced as well
+		g.literals[i] = true
+		return i + 1
+	case tok.kind == graphqlComment:
+		return g.value(i + 1)
+	default:
+		return i
+	}
+}
@@ -0,0 +1,105 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package obfuscate
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestObfuscateGraphQL(t *testing.T) {
+	for _, tt := range []struct {
+		in, out string
+	}{
+		{
+			`{ user(id: 42) { name } }`,
+			`{ user(id: ?) { name } }`,
+		},
+		{
+			`query GetUser($id: ID!, $limit: Int = 10) { user(id: $id) { friends(first: $limit) { name } } }`,
+			`query GetUser($id: ID!, $limit: Int = ?) { user(id: $id) { friends(first: $limit) { name } } }`,
+		},
+		{
+			`mutation { login(email: "jane@example.com", password: "hunter2", remember: true) { token } }`,
+			`mutation { login(email: ?, password: ?, remember: ?) { token } }`,
+		},
+		{
+			// objects, lists, enums, floats and nulls
+			`{ search(filter: {name: "jane", tags: ["a", "b"], score: -1.5e3, status: ACTIVE, owner: null}) { id } }`,
+			`{ search(filter: {name: ?, tags: [?, ?], score: ?, status: ?, owner: ?}) { id } }`,
+		},
+		{
+			// variables are kept in objects and lists
+			`{ search(filter: {ids: [$a, 2], name: $name}) { id } }`,
+			`{ search(filter: {ids: [$a, ?], name: $name}) { id } }`,
+		},
+		{
+			// directives, aliases and fragments
+			`query ($skip: Boolean = false) { me: user(id: "1") @skip(if: $skip) { ...F } } fragment F on User { avatar(size: 64) @include(if: true) }`,
+			`query ($skip: Boolean = ?) { me: user(id: ?) @skip(if: $skip) { ...F } } fragment F on User { avatar(size: ?) @include(if: ?) }`,
+		},
+		{
+			// directives of the variables
+			`query ($a: Int = 1 @deprecated(reason: "old"), $b: [String!] = ["x"]) { f(a: $a, b: $b) }`,
+			`query ($a: Int = ? @deprecated(reason: ?), $b: [String!] = [?]) { f(a: $a, b: $b) }`,
+		},
+		{
+			// block strings and comments
+			"{\n  post(body: \"\"\"secret \\\"\"\" text\"\"\") { id } # the secret post\n}",
+			"{\n  post(body: ?) { id }\n}",
+		},
+		{
+			// the separators are kept
+			`{ a(x: 1,y: "2") { b } }`,
+			`{ a(x: ?,y: ?) { b } }`,
+		},
+		{
+			// documents without arguments are not modified
+			`query Me { me { name } }`,
+			`query Me { me { name } }`,
+		},
+		{
+			// malformed documents do not crash the obfuscator
+			`{ a(x: [1, "unterminated) }`,
+			`{ a(x: [?, ?`,
+		},
+		{
+			`{ a(x: {y: 1 ) { b(z: 2) } }`,
+			`{ a(x: {y: ? ) { b(z: ?) } }`,
+		},
+		{
+			`{ a(x: `,
+			`{ a(x:`,
+		},
+	} {
+		t.Run("", func(t *testing.T) {
+			o := NewObfuscator(Config{})
+			assert.Equal(t, tt.out, o.ObfuscateGraphQLString(tt.in))
+		})
+	}
+}
+
+func TestObfuscateGraphQLCache(t *testing.T) {
+	o := NewObfuscator(Config{Cache: CacheConfig{Enabled: true, MaxSize: 1_000_000}})
+	defer o.Stop()
+
+	in := `{ user(id: 42) { name } }`
+	out := o.ObfuscateGraphQLString(in)
+	o.queryCache.Wait()
+	assert.Equal(t, out, o.ObfuscateGraphQLString(in))
+	assert.Equal(t, uint64(1), o.queryCache.Metrics.Hits())
+}
+
+func BenchmarkObfuscateGraphQL(b *testing.B) {
+	o := NewObfuscator(Config{})
+	query := `query GetUser($id: ID!, $limit: Int = 10) { user(id: $id) { name friends(first: $limit, filter: {status: ACTIVE, tags: ["a", "b"]}) { name } } }`
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		o.ObfuscateGraphQLString(query)
+	}
+}
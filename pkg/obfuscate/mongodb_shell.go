@@ -0,0 +1,303 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package obfuscate
+
+import (
+	"strings"
+)
+
+// mongoShellCacheKeyPrefix prefixes the keys of the obfuscated MongoDB shell commands in the query cache.
+const mongoShellCacheKeyPrefix = "mongodb_shell:"
+
+// mongoShellKeepArgs lists the methods whose arguments are names, which are not obfuscated.
+var mongoShellKeepArgs = map[string]bool{
+	"getCollection": true,
+	"getSiblingDB":  true,
+}
+
+// ObfuscateMongoDBShellString obfuscates the MongoDB command cmd written in the shell
+// syntax, such as `db.users.find({name: "jane"}).limit(10)`. The literals are replaced
+// by "?" the same way ObfuscateMongoDBString replaces the values of JSON commands: the
+// database and collection names, the methods, the keys and the constructors such as
+// ObjectId are kept, as well as the values of the keys configured to be kept. The
+// comments are removed.
+func (o *Obfuscator) ObfuscateMongoDBShellString(cmd string) string {
+	if o.mongo == nil || cmd == "" {
+		// obfuscator is disabled or string is empty
+		return cmd
+	}
+	cacheKey := mongoShellCacheKeyPrefix + cmd
+	if v, ok := o.queryCache.Get(cacheKey); ok {
+		return v.(string)
+	}
+	out := obfuscateMongoShell(cmd, o.mongo.keepKeys)
+	o.queryCache.Set(cacheKey, out, cachedStringCost(out))
+	return out
+}
+
+type mongoShellTokenKind int
+
+const (
+	mongoShellPunctuator mongoShellTokenKind = iota
+	mongoShellIdentifier
+	mongoShellNumber
+	mongoShellString
+	mongoShellRegexp
+	mongoShellComment
+	mongoShellUnknown
+)
+
+type mongoShellToken struct {
+	kind mongoShellTokenKind
+	// start and end are the offsets of the token in the command.
+	start, end int
+}
+
+// tokenizeMongoShell splits a MongoDB shell command into tokens, skipping the whitespaces.
+// Unterminated strings, regular expressions and comments extend to the end of the command.
+func tokenizeMongoShell(cmd string) []mongoShellToken {
+	var toks []mongoShellToken
+	for i := 0; i < len(cmd); {
+		c := cmd[i]
+		start := i
+		kind := mongoShellUnknown
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+			continue
+		case strings.HasPrefix(cmd[i:], "//"):
+			kind = mongoShellComment
+			for i < len(cmd) && cmd[i] != '\n' {
+				i++
+			}
+		case strings.HasPrefix(cmd[i:], "/*"):
+			kind = mongoShellComment
+			if n := strings.Index(cmd[i+2:], "*/"); n != -1 {
+				i += n + 4
+			} else {
+				i = len(cmd)
+			}
+		case c == '/' && (len(toks) == 0 || isMongoShellValueStart(cmd, toks[len(toks)-1])):
+			kind = mongoShellRegexp
+			i = scanQuotedString(cmd, i+1, '/')
+			for i < len(cmd) && isLetter(rune(cmd[i])) {
+				// flags
+				i++
+			}
+		case c == '"' || c == '\'' || c == '`':
+			kind = mongoShellString
+			i = scanQuotedString(cmd, i+1, c)
+		case isDigit(rune(c)) || (c == '.' && i+1 < len(cmd) && isDigit(rune(cmd[i+1]))):
+			kind = mongoShellNumber
+			for i < len(cmd) && (isDigit(rune(cmd[i])) || isLetter(rune(cmd[i])) || cmd[i] == '.' ||
+				((cmd[i] == '+' || cmd[i] == '-') && (cmd[i-1] == 'e' || cmd[i-1] == 'E'))) {
+				// includes the exponents, the hexadecimal digits and the BigInt suffix
+				i++
+			}
+		case isLetter(rune(c)) || c == '$':
+			kind = mongoShellIdentifier
+			for i < len(cmd) && (isLetter(rune(cmd[i])) || isDigit(rune(cmd[i])) || cmd[i] == '$') {
+				i++
+			}
+		case strings.IndexByte("()[]{}.,:;-+=", c) != -1:
+			kind = mongoShellPunctuator
+			i++
+		default:
+			i++
+		}
+		toks = append(toks, mongoShellToken{kind: kind, start: start, end: i})
+	}
+	return toks
+}
+
+// isMongoShellValueStart reports whether a value can start after tok, in which case a
+// slash starts a regular expression rather than being a division.
+func isMongoShellValueStart(cmd string, tok mongoShellToken) bool {
+	if tok.kind != mongoShellPunctuator {
+		return false
+	}
+	switch cmd[tok.start] {
+	case '(', '[', '{', ',', ':', '=':
+		return true
+	}
+	return false
+}
+
+// mongoShellObfuscator walks the tokens of a MongoDB shell command and marks the
+// literals found at the positions of the values.
+type mongoShellObfuscator struct {
+	cmd      string
+	toks     []mongoShellToken
+	keepKeys map[string]bool
+	literals map[int]bool // indexes of the tokens replaced by "?"
+	removed  map[int]bool // indexes of the tokens removed, such as the signs of the numbers
+}
+
+func obfuscateMongoShell(cmd string, keepKeys map[string]bool) string {
+	m := &mongoShellObfuscator{
+		cmd:      cmd,
+		toks:     tokenizeMongoShell(cmd),
+		keepKeys: keepKeys,
+		literals: make(map[int]bool),
+		removed:  make(map[int]bool),
+	}
+	for i := 0; i < len(m.toks); {
+		if m.is(i, "(") {
+			i = m.call(i)
+		} else {
+			i++
+		}
+	}
+
+	var out strings.Builder
+	out.Grow(len(cmd))
+	last := 0
+	for i, tok := range m.toks {
+		if tok.kind == mongoShellComment {
+			out.WriteString(strings.TrimRight(cmd[last:tok.start], " \t"))
+			last = tok.end
+			continue
+		}
+		out.WriteString(cmd[last:tok.start])
+		if m.removed[i] {
+			last = tok.end
+			continue
+		}
+		if m.literals[i] {
+			out.WriteByte('?')
+		} else {
+			out.WriteString(cmd[tok.start:tok.end])
+		}
+		last = tok.end
+	}
+	out.WriteString(cmd[last:])
+	return strings.TrimSpace(out.String())
+}
+
+// is reports whether the token at index i is the punctuator p.
+func (m *mongoShellObfuscator) is(i int, p string) bool {
+	return i < len(m.toks) && m.toks[i].kind == mongoShellPunctuator && m.cmd[m.toks[i].start] == p[0]
+}
+
+// text returns the text of the token at index i.
+func (m *mongoShellObfuscator) text(i int) string {
+	if i < 0 || i >= len(m.toks) {
+		return ""
+	}
+	return m.cmd[m.toks[i].start:m.toks[i].end]
+}
+
+// call walks the arguments of the call opened at index i, and returns the index
+// following the closing parenthesis.
+func (m *mongoShellObfuscator) call(i int) int {
+	if mongoShellKeepArgs[m.text(i-1)] {
+		for i++; i < len(m.toks) && !m.is(i, ")"); i++ {
+		}
+		return i + 1
+	}
+	return m.values(i+1, ")")
+}
+
+// values walks the values separated by commas starting at index i, up to the
+// closing punctuator, and returns the index following it.
+func (m *mongoShellObfuscator) values(i int, closing string) int {
+	for i < len(m.toks) && !m.is(i, closing) {
+		if next := m.value(i); next > i {
+			i = next
+		} else {
+			i++
+		}
+	}
+	return i + 1
+}
+
+// value walks the value starting at index i, and returns the index following it.
+func (m *mongoShellObfuscator) value(i int) int {
+	if i >= len(m.toks) {
+		return i
+	}
+	switch tok := m.toks[i]; {
+	case m.is(i, "{"):
+		return m.object(i + 1)
+	case m.is(i, "["):
+		return m.values(i+1, "]")
+	case m.is(i, "-") || m.is(i, "+"):
+		if i+1 < len(m.toks) && m.toks[i+1].kind == mongoShellNumber {
+			m.removed[i] = true
+			m.literals[i+1] = true
+			return i + 2
+		}
+		return i + 1
+	case tok.kind == mongoShellNumber, tok.kind == mongoShellString, tok.kind == mongoShellRegexp:
+		m.literals[i] = true
+		return i + 1
+	case tok.kind == mongoShellIdentifier:
+		switch m.text(i) {
+		case "true", "false", "null", "undefined", "NaN", "Infinity":
+			m.literals[i] = true
+			return i + 1
+		case "new":
+			return m.value(i + 1)
+		}
+		// constructors such as ObjectId("...") or ISODate("...") are kept with
+		// their arguments obfuscated, and identifiers are kept as they are.
+		for i++; m.is(i, ".") && i+1 < len(m.toks) && m.toks[i+1].kind == mongoShellIdentifier; i += 2 {
+		}
+		if m.is(i, "(") {
+			return m.call(i)
+		}
+		return i
+	case tok.kind == mongoShellComment:
+		return m.value(i + 1)
+	default:
+		return i
+	}
+}
+
+// object walks the fields of the object starting at index i, and returns the index
+// following the closing brace.
+func (m *mongoShellObfuscator) object(i int) int {
+	for i < len(m.toks) && !m.is(i, "}") {
+		if m.is(i, ")") || m.is(i, "]") {
+			// malformed command
+			return i
+		}
+		if !m.is(i+1, ":") {
+			i++
+			continue
+		}
+		key := strings.Trim(m.text(i), "\"'`")
+		i += 2
+		if m.keepKeys[key] {
+			i = m.skipValue(i)
+		} else if next := m.value(i); next > i {
+			i = next
+		}
+	}
+	return i + 1
+}
+
+// skipValue returns the index following the value starting at index i, without
+// obfuscating it.
+func (m *mongoShellObfuscator) skipValue(i int) int {
+	depth := 0
+	for ; i < len(m.toks); i++ {
+		switch {
+		case m.is(i, "{"), m.is(i, "["), m.is(i, "("):
+			depth++
+		case m.is(i, "}"), m.is(i, "]"), m.is(i, ")"):
+			if depth == 0 {
+				return i
+			}
+			depth--
+		case m.is(i, ","):
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return i
+}
@@ -0,0 +1,100 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package obfuscate
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestObfuscateMongoDBShell(t *testing.T) {
+	for _, tt := range []struct {
+		in, out string
+	}{
+		{
+			`db.users.find({name: "jane", age: {$gt: 30}})`,
+			`db.users.find({name: ?, age: {$gt: ?}})`,
+		},
+		{
+			`db.users.find({'email': 'jane@example.com'}).sort({created: -1}).limit(10)`,
+			`db.users.find({'email': ?}).sort({created: ?}).limit(?)`,
+		},
+		{
+			// constructors are kept, their arguments are not
+			`db.orders.updateOne({_id: ObjectId("507f1f77bcf86cd799439011")}, {$set: {paid: true, at: new Date("2024-01-01"), total: NumberDecimal("9.99")}})`,
+			`db.orders.updateOne({_id: ObjectId(?)}, {$set: {paid: ?, at: new Date(?), total: NumberDecimal(?)}})`,
+		},
+		{
+			// lists, regular expressions and nulls
+			`db.users.find({tags: {$in: ["a", "b"]}, name: /^ja/i, deleted: null})`,
+			`db.users.find({tags: {$in: [?, ?]}, name: ?, deleted: ?})`,
+		},
+		{
+			// the names of the databases and collections are kept
+			`db.getSiblingDB("shop").getCollection("orders").deleteMany({status: "cancelled"})`,
+			`db.getSiblingDB("shop").getCollection("orders").deleteMany({status: ?})`,
+		},
+		{
+			// comments are removed
+			"db.users.find({ssn: \"123-45-6789\"}) // lookup\n/* by ssn */",
+			`db.users.find({ssn: ?})`,
+		},
+		{
+			// aggregation pipelines
+			`db.sales.aggregate([{$match: {year: 2024}}, {$group: {_id: "$region", total: {$sum: "$amount"}}}])`,
+			`db.sales.aggregate([{$match: {year: ?}}, {$group: {_id: ?, total: {$sum: ?}}}])`,
+		},
+		{
+			// commands without literals are not modified
+			`db.users.countDocuments()`,
+			`db.users.countDocuments()`,
+		},
+		{
+			// malformed commands do not crash the obfuscator
+			`db.users.find({name: "unterminated})`,
+			`db.users.find({name: ?`,
+		},
+		{
+			`db.users.find({a: 1, b: ]) }`,
+			`db.users.find({a: ?, b: ]) }`,
+		},
+	} {
+		t.Run("", func(t *testing.T) {
+			o := NewObfuscator(Config{Mongo: JSONConfig{Enabled: true}})
+			assert.Equal(t, tt.out, o.ObfuscateMongoDBShellString(tt.in))
+		})
+	}
+}
+
+func TestObfuscateMongoDBShellKeepValues(t *testing.T) {
+	o := NewObfuscator(Config{Mongo: JSONConfig{Enabled: true, KeepValues: []string{"document_id", "region"}}})
+	assert.Equal(t,
+		`db.docs.find({document_id: 42, "region": {$in: ["eu", "us"]}, owner: ?})`,
+		o.ObfuscateMongoDBShellString(`db.docs.find({document_id: 42, "region": {$in: ["eu", "us"]}, owner: "jane"})`),
+	)
+}
+
+func TestObfuscateMongoDBShellDisabled(t *testing.T) {
+	o := NewObfuscator(Config{})
+	in := `db.users.find({name: "jane"})`
+	assert.Equal(t, in, o.ObfuscateMongoDBShellString(in))
+}
+
+func TestObfuscateMongoDBShellCache(t *testing.T) {
+	o := NewObfuscator(Config{Mongo: JSONConfig{Enabled: true}, Cache: CacheConfig{Enabled: true, MaxSize: 1_000_000}})
+	defer o.Stop()
+
+	in := `db.users.find({name: "jane"})`
+	out := o.ObfuscateMongoDBShellString(in)
+	o.queryCache.Wait()
+	assert.Equal(t, out, o.ObfuscateMongoDBShellString(in))
+	assert.Equal(t, uint64(1), o.queryCache.Metrics.Hits())
+
+	// the GraphQL documents are cached under different keys
+	o.ObfuscateGraphQLString(in)
+	assert.Equal(t, uint64(1), o.queryCache.Metrics.Hits())
+}
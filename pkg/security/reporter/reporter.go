@@ -64,6 +64,8 @@ func newReporter(hostname string, stopper startstop.Stopper, sourceName, sourceT
 		hostnameimpl.NewHostnameService(),
 		cfg,
 		compression,
+		nil, // metricSender
+		nil, // digitMasker
 		cfg.GetBool("logs_config.disable_distributed_senders"),
 		false, // serverless
 	)
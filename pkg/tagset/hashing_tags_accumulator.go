@@ -92,6 +92,21 @@ func (h *HashingTagsAccumulator) Truncate(len int) {
 	h.hash = h.hash[0:len]
 }
 
+// RetainFunc retains the tags for which keep returns true, preserving their order,
+// without discarding the internal buffer.
+func (h *HashingTagsAccumulator) RetainFunc(keep func(tag string) bool) {
+	j := 0
+	for i := range h.data {
+		if !keep(h.data[i]) {
+			continue
+		}
+		h.data[j] = h.data[i]
+		h.hash[j] = h.hash[i]
+		j++
+	}
+	h.Truncate(j)
+}
+
 // Less implements sort.Interface.Less
 func (h *HashingTagsAccumulator) Less(i, j int) bool {
 	if h.hash[i] == h.hash[j] {
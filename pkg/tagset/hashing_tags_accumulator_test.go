@@ -70,6 +70,16 @@ func TestHashingTagsAccumulatorCopy(t *testing.T) {
 	assert.Equal(t, []string{"a", "b", "c"}, tb.data)
 }
 
+func TestHashingTagsAccumulatorRetainFunc(t *testing.T) {
+	tb := NewHashingTagsAccumulatorWithTags([]string{"a:1", "b:2", "a:3", "c"})
+	tb.RetainFunc(func(tag string) bool { return tag[0] != 'a' })
+	assert.Equal(t, NewHashingTagsAccumulatorWithTags([]string{"b:2", "c"}), tb)
+
+	tb.RetainFunc(func(string) bool { return false })
+	assert.Empty(t, tb.Get())
+	assert.Empty(t, tb.Hashes())
+}
+
 func TestRemoveSorted(t *testing.T) {
 	l := NewHashingTagsAccumulator()
 	r := NewHashingTagsAccumulator()
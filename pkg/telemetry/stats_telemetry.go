@@ -12,6 +12,7 @@ type StatsTelemetrySender interface {
 	Count(metric string, value float64, hostname string, tags []string)
 	Gauge(metric string, value float64, hostname string, tags []string)
 	GaugeNoIndex(metric string, value float64, hostname string, tags []string)
+	Distribution(metric string, value float64, hostname string, tags []string)
 }
 
 // StatsTelemetryProvider handles stats telemetry and passes it on to a sender
@@ -56,6 +57,11 @@ func (s *StatsTelemetryProvider) GaugeNoIndex(metric string, value float64, tags
 	s.send(func(sender StatsTelemetrySender) { sender.GaugeNoIndex(metric, value, "", tags) })
 }
 
+// Distribution reports a distribution metric to the sender
+func (s *StatsTelemetryProvider) Distribution(metric string, value float64, tags []string) {
+	s.send(func(sender StatsTelemetrySender) { sender.Distribution(metric, value, "", tags) })
+}
+
 func (s *StatsTelemetryProvider) send(senderFct func(sender StatsTelemetrySender)) {
 	s.m.RLock()
 	defer s.m.RUnlock()
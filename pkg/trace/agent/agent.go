@@ -10,6 +10,7 @@ import (
 	"context"
 	"reflect"
 	"runtime"
+	"slices"
 	"strconv"
 	"sync"
 	"time"
@@ -90,12 +91,15 @@ type Agent struct {
 	ClientStatsAggregator *stats.ClientStatsAggregator
 	Blacklister           *filters.Blacklister
 	Replacer              *filters.Replacer
+	SpanRules             *filters.SpanRules
 	PrioritySampler       *sampler.PrioritySampler
 	ErrorsSampler         *sampler.ErrorsSampler
 	RareSampler           *sampler.RareSampler
 	NoPrioritySampler     *sampler.NoPrioritySampler
 	ProbabilisticSampler  *sampler.ProbabilisticSampler
+	TailSampler           *sampler.TailSampler
 	SamplerMetrics        *sampler.Metrics
+	Inspector             *Inspector
 	EventProcessor        *event.Processor
 	TraceWriter           TraceWriter
 	StatsWriter           *writer.DatadogStatsWriter
@@ -113,6 +117,9 @@ type Agent struct {
 	// DiscardSpan will be called on all spans, if non-nil. If it returns true, the span will be deleted before processing.
 	DiscardSpan func(*pb.Span) bool
 
+	// SensitiveDataScanner, if non-nil, scans the spans for sensitive data once obfuscated.
+	SensitiveDataScanner SensitiveDataScanner
+
 	// SpanModifier will be called on all non-nil spans of received trace chunks.
 	// Note that any modification of the trace chunk could be overwritten by
 	// subsequent SpanModifier calls.
@@ -153,12 +160,15 @@ func NewAgent(ctx context.Context, conf *config.AgentConfig, telemetryCollector
 		ClientStatsAggregator: stats.NewClientStatsAggregator(conf, statsWriter, statsd),
 		Blacklister:           filters.NewBlacklister(conf.Ignore["resource"]),
 		Replacer:              filters.NewReplacer(conf.ReplaceTags),
+		SpanRules:             filters.NewSpanRules(conf.SpanRules),
 		PrioritySampler:       sampler.NewPrioritySampler(conf, dynConf),
 		ErrorsSampler:         sampler.NewErrorsSampler(conf),
 		RareSampler:           sampler.NewRareSampler(conf),
 		NoPrioritySampler:     sampler.NewNoPrioritySampler(conf),
 		ProbabilisticSampler:  sampler.NewProbabilisticSampler(conf),
+		TailSampler:           sampler.NewTailSampler(conf, statsd),
 		SamplerMetrics:        sampler.NewMetrics(statsd),
+		Inspector:             NewInspector(),
 		EventProcessor:        newEventProcessor(conf, statsd),
 		StatsWriter:           statsWriter,
 		obfuscatorConf:        &oconf,
@@ -173,10 +183,32 @@ func NewAgent(ctx context.Context, conf *config.AgentConfig, telemetryCollector
 	agnt.Receiver = api.NewHTTPReceiver(conf, dynConf, in, agnt, telemetryCollector, statsd, timing)
 	agnt.OTLPReceiver = api.NewOTLPReceiver(in, conf, statsd, timing)
 	agnt.RemoteConfigHandler = remoteconfighandler.New(conf, agnt.PrioritySampler, agnt.RareSampler, agnt.ErrorsSampler)
-	agnt.TraceWriter = writer.NewTraceWriter(conf, agnt.PrioritySampler, agnt.ErrorsSampler, agnt.RareSampler, telemetryCollector, statsd, timing, comp)
+	agnt.TraceWriter = newTraceWriter(conf, agnt.PrioritySampler, agnt.ErrorsSampler, agnt.RareSampler, telemetryCollector, statsd, timing, comp)
 	return agnt
 }
 
+// newTraceWriter returns the writer of the sampled traces: the OTLP trace writer if OTLP export
+// is enabled, or the writer sending them to Datadog otherwise.
+func newTraceWriter(
+	conf *config.AgentConfig,
+	prioritySampler *sampler.PrioritySampler,
+	errorsSampler *sampler.ErrorsSampler,
+	rareSampler *sampler.RareSampler,
+	telemetryCollector telemetry.TelemetryCollector,
+	statsd statsd.ClientInterface,
+	timing timing.Reporter,
+	comp compression.Component,
+) TraceWriter {
+	if conf.OTLPExporter.Enabled {
+		w, err := writer.NewOTLPTraceWriter(conf, statsd, timing)
+		if err == nil {
+			return w
+		}
+		log.Errorf("Error creating the OTLP trace writer, sending the traces to Datadog instead: %v", err)
+	}
+	return writer.NewTraceWriter(conf, prioritySampler, errorsSampler, rareSampler, telemetryCollector, statsd, timing, comp)
+}
+
 // Run starts routers routines and individual pieces then stop them when the exit order is received.
 func (a *Agent) Run() {
 	a.Timing.Start()
@@ -186,6 +218,7 @@ func (a *Agent) Run() {
 		a.Concentrator,
 		a.ClientStatsAggregator,
 		a.SamplerMetrics,
+		a.TailSampler,
 		a.EventProcessor,
 		a.OTLPReceiver,
 		a.RemoteConfigHandler,
@@ -265,8 +298,10 @@ func (a *Agent) loop() {
 		a.TraceWriter,
 		a.StatsWriter,
 		a.SamplerMetrics,
+		a.TailSampler,
 		a.EventProcessor,
 		a.obfuscator,
+		a.Inspector,
 		a.DebugServer,
 	} {
 		// Fun with golang nil checks
@@ -334,10 +369,29 @@ func (a *Agent) Process(p *api.Payload) {
 		if err != nil {
 			log.Debugf("Dropping invalid trace: %s", err)
 			ts.SpansDropped.Add(tracen)
+			a.recordUnobfuscated(p.TracerPayload.Env, chunk.Spans, InspectDecisionInvalid, err.Error())
 			p.RemoveChunk(i)
 			continue
 		}
 
+		var inspected []*pb.Span
+		if a.Inspector.Active() {
+			// the span rules filter the spans in place
+			inspected = slices.Clone(chunk.Spans)
+		}
+		chunk.Spans = a.SpanRules.Apply(chunk.Spans)
+		if dropped := tracen - int64(len(chunk.Spans)); dropped > 0 {
+			ts.SpansFiltered.Add(dropped)
+			a.recordUnobfuscated(p.TracerPayload.Env, removedSpans(inspected, chunk.Spans), InspectDecisionFiltered, inspectReasonSpanRules)
+			if len(chunk.Spans) == 0 {
+				log.Debugf("Trace rejected as all its spans were dropped by the span rules")
+				ts.TracesFiltered.Inc()
+				p.RemoveChunk(i)
+				continue
+			}
+			tracen = int64(len(chunk.Spans))
+		}
+
 		// Root span is used to carry some trace-level metadata, such as sampling rate and priority.
 		root := traceutil.GetRoot(chunk.Spans)
 		setChunkAttributes(chunk, root)
@@ -345,6 +399,7 @@ func (a *Agent) Process(p *api.Payload) {
 			log.Debugf("Trace rejected by ignore resources rules. root: %v matching rule: \"%s\"", root, denyingRule.String())
 			ts.TracesFiltered.Inc()
 			ts.SpansFiltered.Add(tracen)
+			a.recordUnobfuscated(p.TracerPayload.Env, chunk.Spans, InspectDecisionFiltered, inspectReasonIgnoreResources)
 			p.RemoveChunk(i)
 			continue
 		}
@@ -353,6 +408,7 @@ func (a *Agent) Process(p *api.Payload) {
 			log.Debugf("Trace rejected as it fails to meet tag requirements. root: %v", root)
 			ts.TracesFiltered.Inc()
 			ts.SpansFiltered.Add(tracen)
+			a.recordUnobfuscated(p.TracerPayload.Env, chunk.Spans, InspectDecisionFiltered, inspectReasonFilterTags)
 			p.RemoveChunk(i)
 			continue
 		}
@@ -370,6 +426,7 @@ func (a *Agent) Process(p *api.Payload) {
 				a.SpanModifier.ModifySpan(chunk, span)
 			}
 			a.obfuscateSpan(span)
+			a.scanSensitiveData(span)
 			a.Truncate(span)
 			if p.ClientComputedTopLevel {
 				traceutil.UpdateTracerTopLevel(span)
@@ -391,7 +448,33 @@ func (a *Agent) Process(p *api.Payload) {
 			statsInput.Traces = append(statsInput.Traces, *pt.Clone())
 		}
 
-		keep, numEvents := a.sample(now, ts, pt)
+		// The tail sampler holds the chunk as received, before the samplers modify it.
+		var tailChunk *pb.TraceChunk
+		if a.TailSampler.Enabled() {
+			tailChunk = pt.TraceChunk.ShallowCopy()
+		}
+		if a.Inspector.Active() {
+			// single span sampling replaces the spans of the dropped chunks
+			inspected = slices.Clone(pt.TraceChunk.Spans)
+		}
+		keep, numEvents, samplerName := a.sample(now, ts, pt)
+		decidedBy := samplerName.String()
+		if tailChunk != nil {
+			priority, _ := sampler.GetSamplingPriority(tailChunk)
+			var written []*pb.Span
+			if !keep {
+				// the spans kept by single span sampling or as analytics events
+				written = pt.TraceChunk.Spans
+			}
+			tailKeep, released := a.TailSampler.Sample(now, tailChunk, p.TracerPayload, priority, keep, written)
+			if tailKeep && !keep {
+				keep = true
+				pt.TraceChunk = tailChunk
+				decidedBy = inspectSamplerTail
+			}
+			a.writeTailSampledChunks(released)
+		}
+		a.Inspector.recordSampling(pt.TracerEnv, inspected, keep, decidedBy, pt.TraceChunk.Spans)
 		if !keep && len(pt.TraceChunk.Spans) == 0 {
 			// The entire trace was dropped and no spans were kept.
 			p.RemoveChunk(i)
@@ -430,6 +513,19 @@ func (a *Agent) Process(p *api.Payload) {
 	}
 }
 
+// writeTailSampledChunks writes the chunks released by the tail sampler.
+func (a *Agent) writeTailSampledChunks(chunks []sampler.TailSampledChunk) {
+	for _, c := range chunks {
+		a.Inspector.record(c.TracerPayload.Env, c.Chunk.Spans, InspectDecisionKept, inspectSamplerTail, inspectReasonTailReleased)
+		c.TracerPayload.Chunks = []*pb.TraceChunk{c.Chunk}
+		a.TraceWriter.WriteChunks(&writer.SampledChunks{
+			TracerPayload: c.TracerPayload,
+			Size:          c.Chunk.Msgsize(),
+			SpanCount:     int64(len(c.Chunk.Spans)),
+		})
+	}
+}
+
 func (a *Agent) setPayloadAttributes(p *api.Payload, root *pb.Span, chunk *pb.TraceChunk) {
 	if p.TracerPayload.Hostname == "" {
 		// Older tracers set tracer hostname in the root span.
@@ -567,13 +663,13 @@ func (a *Agent) ProcessStats(in *pb.ClientStatsPayload, lang, tracerVersion, con
 	a.ClientStatsAggregator.In <- a.processStats(in, lang, tracerVersion, containerID, obfuscationVersion)
 }
 
-// sample performs all sampling on the processedTrace modifying it as needed and returning if the trace should be kept
-// and the number of events in the trace
-func (a *Agent) sample(now time.Time, ts *info.TagStats, pt *traceutil.ProcessedTrace) (keep bool, numEvents int) {
+// sample performs all sampling on the processedTrace modifying it as needed and returning if the trace should be kept,
+// the number of events in the trace and the name of the sampler which decided it
+func (a *Agent) sample(now time.Time, ts *info.TagStats, pt *traceutil.ProcessedTrace) (keep bool, numEvents int, samplerName sampler.Name) {
 	// We have a `keep` that is different from pt's `DroppedTrace` field as `DroppedTrace` will be sent to intake.
 	// For example: We want to maintain the overall trace level sampling decision for a trace with Analytics Events
 	// where a trace might be marked as DroppedTrace true, but we still sent analytics events in that ProcessedTrace.
-	keep, checkAnalyticsEvents := a.traceSampling(now, ts, pt)
+	keep, checkAnalyticsEvents, samplerName := a.traceSampling(now, ts, pt)
 
 	var events []*pb.Span
 	if checkAnalyticsEvents {
@@ -591,7 +687,7 @@ func (a *Agent) sample(now time.Time, ts *info.TagStats, pt *traceutil.Processed
 		}
 	}
 
-	return keep, len(events)
+	return keep, len(events), samplerName
 }
 
 // isManualUserDrop returns true if and only if the ProcessedTrace is marked as Priority User Drop
@@ -612,11 +708,12 @@ func isManualUserDrop(pt *traceutil.ProcessedTrace) bool {
 	return dm == manualSampling
 }
 
-// traceSampling reports whether the chunk should be kept as a trace, setting "DroppedTrace" on the chunk
-func (a *Agent) traceSampling(now time.Time, ts *info.TagStats, pt *traceutil.ProcessedTrace) (keep bool, checkAnalyticsEvents bool) {
-	sampled, check := a.runSamplers(now, ts, *pt)
+// traceSampling reports whether the chunk should be kept as a trace, setting "DroppedTrace" on the chunk,
+// and the name of the sampler which decided it.
+func (a *Agent) traceSampling(now time.Time, ts *info.TagStats, pt *traceutil.ProcessedTrace) (keep bool, checkAnalyticsEvents bool, samplerName sampler.Name) {
+	sampled, check, samplerName := a.runSamplers(now, ts, *pt)
 	pt.TraceChunk.DroppedTrace = !sampled
-	return sampled, check
+	return sampled, check, samplerName
 }
 
 // getAnalyzedEvents returns any sampled analytics events in the ProcessedTrace
@@ -628,7 +725,7 @@ func (a *Agent) getAnalyzedEvents(pt *traceutil.ProcessedTrace, ts *info.TagStat
 }
 
 // runSamplers runs the agent's configured samplers on pt and returns the sampling decision along
-// with the sampling rate.
+// with whether the analytics events should be checked and the name of the deciding sampler.
 //
 // If the agent is set as Error Tracking Standalone, only the ErrorSampler is run (other samplers are bypassed).
 // Otherwise, the rare sampler is run first, catching all rare traces early. If the probabilistic sampler is
@@ -636,8 +733,7 @@ func (a *Agent) getAnalyzedEvents(pt *traceutil.ProcessedTrace, ts *info.TagStat
 // priority set, the sampling priority is used with the Priority Sampler. When there is no priority
 // set, the NoPrioritySampler is run. Finally, if the trace has not been sampled by the other
 // samplers, the error sampler is run.
-func (a *Agent) runSamplers(now time.Time, ts *info.TagStats, pt traceutil.ProcessedTrace) (keep bool, checkAnalyticsEvents bool) {
-	samplerName := sampler.NameUnknown
+func (a *Agent) runSamplers(now time.Time, ts *info.TagStats, pt traceutil.ProcessedTrace) (keep bool, checkAnalyticsEvents bool, samplerName sampler.Name) {
 	samplingPriority := sampler.PriorityNone
 	defer func() {
 		a.SamplerMetrics.RecordMetricsKey(keep, sampler.NewMetricsKey(pt.Root.Service, pt.TracerEnv, samplerName, samplingPriority))
@@ -647,9 +743,9 @@ func (a *Agent) runSamplers(now time.Time, ts *info.TagStats, pt traceutil.Proce
 		samplerName = sampler.NameError
 		if traceContainsError(pt.TraceChunk.Spans, true) {
 			pt.TraceChunk.Tags["_dd.error_tracking_standalone.error"] = "true"
-			return a.ErrorsSampler.Sample(now, pt.TraceChunk.Spans, pt.Root, pt.TracerEnv), false
+			return a.ErrorsSampler.Sample(now, pt.TraceChunk.Spans, pt.Root, pt.TracerEnv), false, samplerName
 		}
-		return false, false
+		return false, false, samplerName
 	}
 
 	// Run this early to make sure the signature gets counted by the RareSampler.
@@ -659,17 +755,17 @@ func (a *Agent) runSamplers(now time.Time, ts *info.TagStats, pt traceutil.Proce
 		samplerName = sampler.NameProbabilistic
 		if rare {
 			samplerName = sampler.NameRare
-			return true, true
+			return true, true, samplerName
 		}
 		if a.ProbabilisticSampler.Sample(pt.Root) {
 			pt.TraceChunk.Tags[tagDecisionMaker] = probabilitySampling
-			return true, true
+			return true, true, samplerName
 		}
 		if traceContainsError(pt.TraceChunk.Spans, false) {
 			samplerName = sampler.NameError
-			return a.ErrorsSampler.Sample(now, pt.TraceChunk.Spans, pt.Root, pt.TracerEnv), true
+			return a.ErrorsSampler.Sample(now, pt.TraceChunk.Spans, pt.Root, pt.TracerEnv), true, samplerName
 		}
-		return false, true
+		return false, true, samplerName
 	}
 
 	priority, hasPriority := sampler.GetSamplingPriority(pt.TraceChunk)
@@ -689,33 +785,33 @@ func (a *Agent) runSamplers(now time.Time, ts *info.TagStats, pt traceutil.Proce
 		// Note that we DON'T skip single span sampling. We only do this for historical
 		// reasons and analytics events are deprecated so hopefully this can all go away someday.
 		if isManualUserDrop(&pt) {
-			return false, false
+			return false, false, samplerName
 		}
 	} else { // This path to be deleted once manualUserDrop detection is available on all tracers for P < 1.
 		if priority < 0 {
-			return false, false
+			return false, false, samplerName
 		}
 	}
 
 	if rare {
 		samplerName = sampler.NameRare
-		return true, true
+		return true, true, samplerName
 	}
 
 	if hasPriority {
 		if a.PrioritySampler.Sample(now, pt.TraceChunk, pt.Root, pt.TracerEnv, pt.ClientDroppedP0sWeight) {
-			return true, true
+			return true, true, samplerName
 		}
 	} else if a.NoPrioritySampler.Sample(now, pt.TraceChunk.Spans, pt.Root, pt.TracerEnv) {
-		return true, true
+		return true, true, samplerName
 	}
 
 	if traceContainsError(pt.TraceChunk.Spans, false) {
 		samplerName = sampler.NameError
-		return a.ErrorsSampler.Sample(now, pt.TraceChunk.Spans, pt.Root, pt.TracerEnv), true
+		return a.ErrorsSampler.Sample(now, pt.TraceChunk.Spans, pt.Root, pt.TracerEnv), true, samplerName
 	}
 
-	return false, true
+	return false, true, samplerName
 }
 
 func traceContainsError(trace pb.Trace, considerExceptionEvents bool) bool {
@@ -1564,7 +1564,7 @@ func TestSampling(t *testing.T) {
 			statsdClient := mockStatsd.NewMockClientInterface(ctrl)
 			a := configureAgent(tt.agentConfig, statsdClient)
 			for _, tc := range tt.testCases {
-				sampled, _ := a.traceSampling(time.Now(), &info.TagStats{}, &tc.trace)
+				sampled, _, _ := a.traceSampling(time.Now(), &info.TagStats{}, &tc.trace)
 				assert.EqualValues(t, tc.wantSampled, sampled)
 				require.NotNil(t, tc.expectStatsd)
 				tc.expectStatsd(statsdClient)
@@ -1820,14 +1820,14 @@ func TestSampleTrace(t *testing.T) {
 			}
 			a.SamplerMetrics.Add(a.NoPrioritySampler, a.ErrorsSampler, a.PrioritySampler, a.RareSampler)
 			tt.expectStatsd(statsd)
-			keep, _ := a.traceSampling(now, info.NewReceiverStats().GetTagStats(info.Tags{}), &tt.trace)
+			keep, _, _ := a.traceSampling(now, info.NewReceiverStats().GetTagStats(info.Tags{}), &tt.trace)
 			metrics.Report()
 			assert.Equal(t, tt.keep, keep)
 			assert.Equal(t, !tt.keep, tt.trace.TraceChunk.DroppedTrace)
 			cfg.Features["error_rare_sample_tracer_drop"] = struct{}{}
 			defer delete(cfg.Features, "error_rare_sample_tracer_drop")
 			tt.expectStatsdWithFeature(statsd)
-			keep, _ = a.traceSampling(now, info.NewReceiverStats().GetTagStats(info.Tags{}), &tt.trace)
+			keep, _, _ = a.traceSampling(now, info.NewReceiverStats().GetTagStats(info.Tags{}), &tt.trace)
 			metrics.Report()
 			assert.Equal(t, tt.keepWithFeature, keep)
 			assert.Equal(t, !tt.keepWithFeature, tt.trace.TraceChunk.DroppedTrace)
@@ -1952,12 +1952,12 @@ func TestSample(t *testing.T) {
 			conf:              cfg,
 		}
 		t.Run(name, func(t *testing.T) {
-			keep, _ := a.sample(now, info.NewReceiverStats().GetTagStats(info.Tags{}), &tt.trace)
+			keep, _, _ := a.sample(now, info.NewReceiverStats().GetTagStats(info.Tags{}), &tt.trace)
 			assert.Equal(t, tt.keep, keep)
 			assert.Equal(t, !tt.keep, tt.trace.TraceChunk.DroppedTrace)
 			cfg.Features["error_rare_sample_tracer_drop"] = struct{}{}
 			defer delete(cfg.Features, "error_rare_sample_tracer_drop")
-			keep, _ = a.sample(now, info.NewReceiverStats().GetTagStats(info.Tags{}), &tt.trace)
+			keep, _, _ = a.sample(now, info.NewReceiverStats().GetTagStats(info.Tags{}), &tt.trace)
 			assert.Equal(t, tt.keepWithFeature, keep)
 			assert.Equal(t, !tt.keepWithFeature, tt.trace.TraceChunk.DroppedTrace)
 		})
@@ -1989,11 +1989,150 @@ func TestSampleManualUserDropNoAnalyticsEvents(t *testing.T) {
 		SamplerMetrics:    sampler.NewMetrics(statsd),
 		conf:              cfg,
 	}
-	keep, _ := a.sample(now, info.NewReceiverStats().GetTagStats(info.Tags{}), &pt)
+	keep, _, _ := a.sample(now, info.NewReceiverStats().GetTagStats(info.Tags{}), &pt)
 	assert.False(t, keep)
 	assert.Empty(t, pt.Root.Metrics["_dd.analyzed"])
 }
 
+func TestTailSampling(t *testing.T) {
+	cfg := config.New()
+	cfg.Endpoints[0].APIKey = "test"
+	cfg.TailSamplerEnabled = true
+	cfg.TailSamplerPolicies = []*config.TailSamplerPolicy{{Name: "slow-db", Service: "db", MinDurationMs: 500}}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	agnt := NewTestAgent(ctx, cfg, telemetry.NewNoopCollector())
+
+	process := func(service string, duration time.Duration) {
+		chunk := testutil.TraceChunkWithSpan(&pb.Span{
+			TraceID:  42,
+			SpanID:   uint64(len(service)),
+			Service:  service,
+			Name:     "request",
+			Resource: "GET /",
+			Start:    time.Now().UnixNano(),
+			Duration: duration.Nanoseconds(),
+		})
+		chunk.Priority = int32(sampler.PriorityAutoDrop)
+		tp := testutil.TracerPayloadWithChunk(chunk)
+		tp.Env = "prod"
+		agnt.Process(&api.Payload{
+			TracerPayload: tp,
+			Source:        agnt.Receiver.Stats.GetTagStats(info.Tags{}),
+		})
+	}
+
+	// the chunk dropped by the priority sampler is held
+	process("web", 10*time.Millisecond)
+	assert.Empty(t, agnt.TraceWriter.(*mockTraceWriter).payloads)
+
+	// the slow span of the next chunk selects the trace
+	process("db", time.Second)
+	payloads := agnt.TraceWriter.(*mockTraceWriter).payloads
+	require.Len(t, payloads, 2)
+	services := make([]string, 0, 2)
+	for _, p := range payloads {
+		require.Len(t, p.TracerPayload.Chunks, 1)
+		chunk := p.TracerPayload.Chunks[0]
+		assert.False(t, chunk.DroppedTrace)
+		assert.Equal(t, "slow-db", chunk.Tags["_dd.tail_sampler.policy"])
+		assert.Equal(t, "prod", p.TracerPayload.Env)
+		services = append(services, chunk.Spans[0].Service)
+	}
+	assert.ElementsMatch(t, []string{"web", "db"}, services)
+}
+
+func TestTailSamplingSpanSampling(t *testing.T) {
+	cfg := config.New()
+	cfg.Endpoints[0].APIKey = "test"
+	cfg.TailSamplerEnabled = true
+	cfg.TailSamplerPolicies = []*config.TailSamplerPolicy{{Name: "slow-db", Service: "db", MinDurationMs: 500}}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	agnt := NewTestAgent(ctx, cfg, telemetry.NewNoopCollector())
+
+	process := func(spans ...*pb.Span) {
+		for _, span := range spans {
+			span.TraceID = 42
+			span.Name = "request"
+			span.Resource = "GET /"
+			span.Start = time.Now().UnixNano()
+		}
+		chunk := testutil.TraceChunkWithSpans(spans)
+		chunk.Priority = int32(sampler.PriorityAutoDrop)
+		agnt.Process(&api.Payload{
+			TracerPayload: testutil.TracerPayloadWithChunk(chunk),
+			Source:        agnt.Receiver.Stats.GetTagStats(info.Tags{}),
+		})
+	}
+
+	// the span kept by single span sampling is written, the rest of the chunk is held
+	process(
+		&pb.Span{SpanID: 1, Service: "web", Duration: 1, Metrics: map[string]float64{sampler.KeySpanSamplingMechanism: 8}},
+		&pb.Span{SpanID: 2, ParentID: 1, Service: "web", Duration: 1},
+	)
+	payloads := agnt.TraceWriter.(*mockTraceWriter).payloads
+	require.Len(t, payloads, 1)
+	require.Len(t, payloads[0].TracerPayload.Chunks[0].Spans, 1)
+	assert.EqualValues(t, 1, payloads[0].TracerPayload.Chunks[0].Spans[0].SpanID)
+
+	// the held spans are released once, without the span already written
+	process(&pb.Span{SpanID: 3, Service: "db", Duration: time.Second.Nanoseconds()})
+	written := make(map[uint64]int)
+	for _, p := range agnt.TraceWriter.(*mockTraceWriter).payloads {
+		for _, chunk := range p.TracerPayload.Chunks {
+			for _, span := range chunk.Spans {
+				written[span.SpanID]++
+			}
+		}
+	}
+	assert.Equal(t, map[uint64]int{1: 1, 2: 1, 3: 1}, written)
+}
+
+func TestSpanRules(t *testing.T) {
+	cfg := config.New()
+	cfg.Endpoints[0].APIKey = "test"
+	cfg.SpanRules = []*config.SpanRule{
+		{
+			Name:       "drop-health",
+			Conditions: []config.SpanRuleCondition{{Key: "span.resource", Pattern: "^GET /health$"}},
+			Actions:    []config.SpanRuleAction{{Action: "drop"}},
+		},
+		{
+			Name:    "scrub",
+			Actions: []config.SpanRuleAction{{Action: "delete", Key: "password"}, {Action: "set", Key: "span.service", Value: "Renamed"}},
+		},
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	agnt := NewTestAgent(ctx, cfg, telemetry.NewNoopCollector())
+
+	now := time.Now().UnixNano()
+	span := func(spanID, parentID uint64, resource string) *pb.Span {
+		return &pb.Span{TraceID: 1, SpanID: spanID, ParentID: parentID, Service: "web", Name: "request", Resource: resource, Start: now, Duration: 1, Meta: map[string]string{"password": "hunter2"}}
+	}
+	chunk := testutil.TraceChunkWithSpans([]*pb.Span{span(1, 0, "GET /"), span(2, 1, "GET /health"), span(3, 2, "SELECT")})
+	chunk.Priority = int32(sampler.PriorityUserKeep)
+	ts := agnt.Receiver.Stats.GetTagStats(info.Tags{})
+	agnt.Process(&api.Payload{
+		TracerPayload: testutil.TracerPayloadWithChunk(chunk),
+		Source:        ts,
+	})
+
+	payloads := agnt.TraceWriter.(*mockTraceWriter).payloads
+	require.Len(t, payloads, 1)
+	require.Len(t, payloads[0].TracerPayload.Chunks, 1)
+	spans := payloads[0].TracerPayload.Chunks[0].Spans
+	require.Len(t, spans, 2)
+	assert.EqualValues(t, 3, spans[1].SpanID)
+	assert.EqualValues(t, 1, spans[1].ParentID)
+	for _, s := range spans {
+		assert.Equal(t, "renamed", s.Service)
+		assert.NotContains(t, s.Meta, "password")
+	}
+	assert.EqualValues(t, 1, ts.SpansFiltered.Load())
+}
+
 func TestPartialSamplingFree(t *testing.T) {
 	cfg := &config.AgentConfig{RareSamplerEnabled: false, BucketInterval: 10 * time.Second}
 	dynConf := sampler.NewDynamicConfig()
@@ -2838,7 +2977,7 @@ func TestSampleWithPriorityNone(t *testing.T) {
 	}
 	// before := traceutil.CopyTraceChunk(pt.TraceChunk)
 	before := pt.TraceChunk.ShallowCopy()
-	keep, numEvents := agnt.sample(time.Now(), info.NewReceiverStats().GetTagStats(info.Tags{}), &pt)
+	keep, numEvents, _ := agnt.sample(time.Now(), info.NewReceiverStats().GetTagStats(info.Tags{}), &pt)
 	assert.True(t, keep) // Score Sampler should keep the trace.
 	assert.False(t, pt.TraceChunk.DroppedTrace)
 	assert.Equal(t, before, pt.TraceChunk)
@@ -3197,7 +3336,7 @@ func TestSingleSpanPlusAnalyticsEvents(t *testing.T) {
 	var b bytes.Buffer
 	oldLogger := log.SetLogger(log.NewBufferLogger(&b))
 	defer func() { log.SetLogger(oldLogger) }()
-	keep, numEvents := traceAgent.sample(time.Now(), info.NewReceiverStats().GetTagStats(info.Tags{}), payload)
+	keep, numEvents, _ := traceAgent.sample(time.Now(), info.NewReceiverStats().GetTagStats(info.Tags{}), payload)
 	assert.Equal(t, "[WARN] Detected both analytics events AND single span sampling in the same trace. Single span sampling wins because App Analytics is deprecated.", b.String())
 	assert.False(t, keep) //The sampling decision was FALSE but the trace itself is marked as not dropped
 	assert.False(t, payload.TraceChunk.DroppedTrace)
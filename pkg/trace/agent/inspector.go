@@ -0,0 +1,329 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.uber.org/atomic"
+	"google.golang.org/protobuf/proto"
+
+	pb "github.com/DataDog/datadog-agent/pkg/proto/pbgo/trace"
+	"github.com/DataDog/datadog-agent/pkg/trace/log"
+)
+
+// The decisions taken by the agent on the inspected spans.
+const (
+	// InspectDecisionKept is the decision of the spans sent to the backend.
+	InspectDecisionKept = "kept"
+	// InspectDecisionDropped is the decision of the spans dropped by the samplers.
+	InspectDecisionDropped = "dropped"
+	// InspectDecisionFiltered is the decision of the spans dropped by the filters, such as
+	// the ignored resources, the required and rejected tags or the span rules.
+	InspectDecisionFiltered = "filtered"
+	// InspectDecisionInvalid is the decision of the spans whose trace failed to be normalized.
+	InspectDecisionInvalid = "invalid"
+)
+
+// The reasons of the inspected decisions, along with the normalization errors.
+const (
+	inspectReasonIgnoreResources = "ignore_resources"
+	inspectReasonFilterTags      = "filter_tags"
+	inspectReasonSpanRules       = "span_rules"
+	inspectReasonSpanSampling    = "span_sampling"
+	inspectReasonTailReleased    = "tail_released"
+)
+
+// inspectSamplerTail is the sampler of the chunks kept by the tail sampler.
+const inspectSamplerTail = "tail"
+
+// inspectBufferSize is the number of spans buffered for each inspecting client. The
+// spans are dropped when a client does not keep up.
+const inspectBufferSize = 1024
+
+// InspectedSpan is a span processed by the agent, after its normalization and obfuscation,
+// along with the decision taken on it.
+type InspectedSpan struct {
+	// Time is the time the span was processed at.
+	Time     time.Time          `json:"time"`
+	Env      string             `json:"env"`
+	TraceID  uint64             `json:"trace_id"`
+	SpanID   uint64             `json:"span_id"`
+	ParentID uint64             `json:"parent_id"`
+	Service  string             `json:"service"`
+	Name     string             `json:"name"`
+	Resource string             `json:"resource"`
+	Type     string             `json:"type,omitempty"`
+	Start    int64              `json:"start"`
+	Duration int64              `json:"duration"`
+	Error    int32              `json:"error"`
+	Meta     map[string]string  `json:"meta,omitempty"`
+	Metrics  map[string]float64 `json:"metrics,omitempty"`
+	// Decision is one of the InspectDecision constants.
+	Decision string `json:"decision"`
+	// Sampler is the sampler which took the decision, if any.
+	Sampler string `json:"sampler,omitempty"`
+	// Reason details the decision, such as the filter which dropped the span.
+	Reason string `json:"reason,omitempty"`
+}
+
+// inspectFilter selects the spans streamed to a client.
+type inspectFilter struct {
+	service  string
+	resource string
+	traceID  uint64
+}
+
+// matches reports whether s is selected by the filter.
+func (f *inspectFilter) matches(s *pb.Span) bool {
+	return (f.service == "" || s.Service == f.service) &&
+		(f.resource == "" || s.Resource == f.resource) &&
+		(f.traceID == 0 || s.TraceID == f.traceID)
+}
+
+type inspectSubscriber struct {
+	filter  inspectFilter
+	spans   chan *InspectedSpan
+	dropped *atomic.Int64
+}
+
+// Inspector streams the spans processed by the agent, along with their sampling decision,
+// to the clients of its HTTP handler. Nothing is recorded while no client is connected.
+type Inspector struct {
+	active *atomic.Int32 // number of subscribers
+
+	mu          sync.RWMutex // guards below
+	subscribers map[*inspectSubscriber]struct{}
+	stopped     bool
+}
+
+// NewInspector returns a new Inspector.
+func NewInspector() *Inspector {
+	return &Inspector{
+		active:      atomic.NewInt32(0),
+		subscribers: make(map[*inspectSubscriber]struct{}),
+	}
+}
+
+// Active reports whether spans are being inspected.
+func (i *Inspector) Active() bool {
+	return i != nil && i.active.Load() > 0
+}
+
+// record sends the spans, processed for env, to the matching subscribers.
+func (i *Inspector) record(env string, spans []*pb.Span, decision, sampler, reason string) {
+	if !i.Active() {
+		return
+	}
+	now := time.Now()
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	for sub := range i.subscribers {
+		for _, s := range spans {
+			if !sub.filter.matches(s) {
+				continue
+			}
+			select {
+			case sub.spans <- newInspectedSpan(now, env, s, decision, sampler, reason):
+			default:
+				sub.dropped.Inc()
+			}
+		}
+	}
+}
+
+// recordSampling records the spans of a chunk processed for env, which was kept if keep
+// is true, by sampler. The spans in kept are sent to the backend even if the chunk was
+// dropped, because of single span sampling or analytics events.
+func (i *Inspector) recordSampling(env string, spans []*pb.Span, keep bool, sampler string, kept []*pb.Span) {
+	if !i.Active() {
+		return
+	}
+	if keep {
+		i.record(env, spans, InspectDecisionKept, sampler, "")
+		return
+	}
+	sampled := make(map[*pb.Span]struct{}, len(kept))
+	for _, s := range kept {
+		sampled[s] = struct{}{}
+	}
+	for _, s := range spans {
+		if _, ok := sampled[s]; ok {
+			i.record(env, []*pb.Span{s}, InspectDecisionKept, sampler, inspectReasonSpanSampling)
+		} else {
+			i.record(env, []*pb.Span{s}, InspectDecisionDropped, sampler, "")
+		}
+	}
+}
+
+// recordUnobfuscated records the spans processed for env, dropped before their
+// obfuscation. Copies of the spans are obfuscated and scanned for sensitive data
+// before being recorded, so that the inspector never streams the raw values.
+func (a *Agent) recordUnobfuscated(env string, spans []*pb.Span, decision, reason string) {
+	if !a.Inspector.Active() {
+		return
+	}
+	obfuscated := make([]*pb.Span, len(spans))
+	for i, s := range spans {
+		obfuscated[i] = proto.Clone(s).(*pb.Span)
+		a.obfuscateSpan(obfuscated[i])
+		a.scanSensitiveData(obfuscated[i])
+	}
+	a.Inspector.record(env, obfuscated, decision, "", reason)
+}
+
+func newInspectedSpan(now time.Time, env string, s *pb.Span, decision, sampler, reason string) *InspectedSpan {
+	return &InspectedSpan{
+		Time:     now,
+		Env:      env,
+		TraceID:  s.TraceID,
+		SpanID:   s.SpanID,
+		ParentID: s.ParentID,
+		Service:  s.Service,
+		Name:     s.Name,
+		Resource: s.Resource,
+		Type:     s.Type,
+		Start:    s.Start,
+		Duration: s.Duration,
+		Error:    s.Error,
+		// the spans keep being modified and read once recorded
+		Meta:     maps.Clone(s.Meta),
+		Metrics:  maps.Clone(s.Metrics),
+		Decision: decision,
+		Sampler:  sampler,
+		Reason:   reason,
+	}
+}
+
+// removedSpans returns the spans of before which are not in after.
+func removedSpans(before, after []*pb.Span) []*pb.Span {
+	if len(before) == 0 {
+		return nil
+	}
+	remaining := make(map[*pb.Span]struct{}, len(after))
+	for _, s := range after {
+		remaining[s] = struct{}{}
+	}
+	var removed []*pb.Span
+	for _, s := range before {
+		if _, ok := remaining[s]; !ok {
+			removed = append(removed, s)
+		}
+	}
+	return removed
+}
+
+// subscribe adds a subscriber receiving the spans selected by filter. It returns nil
+// if the inspector is stopped.
+func (i *Inspector) subscribe(filter inspectFilter) *inspectSubscriber {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if i.stopped {
+		return nil
+	}
+	sub := &inspectSubscriber{
+		filter:  filter,
+		spans:   make(chan *InspectedSpan, inspectBufferSize),
+		dropped: atomic.NewInt64(0),
+	}
+	i.subscribers[sub] = struct{}{}
+	i.active.Inc()
+	return sub
+}
+
+// unsubscribe removes the subscriber sub.
+func (i *Inspector) unsubscribe(sub *inspectSubscriber) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if _, ok := i.subscribers[sub]; ok {
+		delete(i.subscribers, sub)
+		i.active.Dec()
+	}
+	if n := sub.dropped.Load(); n > 0 {
+		log.Debugf("Span inspection client dropped %d spans it did not read fast enough.", n)
+	}
+}
+
+// Stop ends the streams of all the clients.
+func (i *Inspector) Stop() {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.stopped = true
+	for sub := range i.subscribers {
+		close(sub.spans)
+		delete(i.subscribers, sub)
+	}
+	i.active.Store(0)
+}
+
+// ServeHTTP implements http.Handler. It streams the processed spans as JSON lines, until
+// the client disconnects or the number of spans given by the "limit" query parameter is
+// reached. The spans can be selected with the "service", "resource" and "trace_id"
+// query parameters.
+func (i *Inspector) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		http.Error(w, fmt.Sprintf("%s method not allowed, only %s", req.Method, http.MethodGet), http.StatusMethodNotAllowed)
+		return
+	}
+	q := req.URL.Query()
+	filter := inspectFilter{
+		service:  q.Get("service"),
+		resource: q.Get("resource"),
+	}
+	if v := q.Get("trace_id"); v != "" {
+		id, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			http.Error(w, "trace_id must be a decimal 64-bit trace ID", http.StatusBadRequest)
+			return
+		}
+		filter.traceID = id
+	}
+	limit := 0
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			http.Error(w, "limit must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	sub := i.subscribe(filter)
+	if sub == nil {
+		http.Error(w, "the agent is stopping", http.StatusServiceUnavailable)
+		return
+	}
+	defer i.unsubscribe(sub)
+
+	rc := http.NewResponseController(w)
+	// the stream outlives the write timeout of the server
+	_ = rc.SetWriteDeadline(time.Time{})
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+	_ = rc.Flush()
+
+	enc := json.NewEncoder(w)
+	for n := 0; limit == 0 || n < limit; n++ {
+		select {
+		case s, ok := <-sub.spans:
+			if !ok {
+				return
+			}
+			if err := enc.Encode(s); err != nil {
+				return
+			}
+			_ = rc.Flush()
+		case <-req.Context().Done():
+			return
+		}
+	}
+}
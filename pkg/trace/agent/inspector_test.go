@@ -0,0 +1,224 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package agent
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	pb "github.com/DataDog/datadog-agent/pkg/proto/pbgo/trace"
+	"github.com/DataDog/datadog-agent/pkg/trace/api"
+	"github.com/DataDog/datadog-agent/pkg/trace/config"
+	"github.com/DataDog/datadog-agent/pkg/trace/info"
+	"github.com/DataDog/datadog-agent/pkg/trace/telemetry"
+	"github.com/DataDog/datadog-agent/pkg/trace/testutil"
+)
+
+// inspect starts streaming the spans of the agent from the given query, and returns
+// a function reading the next n spans.
+func inspect(t *testing.T, agnt *Agent, query string) func(n int) []InspectedSpan {
+	server := httptest.NewServer(agnt.Inspector)
+	t.Cleanup(server.Close)
+	resp, err := http.Get(server.URL + "/debug/traces?" + query)
+	require.NoError(t, err)
+	t.Cleanup(func() { resp.Body.Close() })
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "application/x-ndjson", resp.Header.Get("Content-Type"))
+	require.True(t, agnt.Inspector.Active())
+
+	scanner := bufio.NewScanner(resp.Body)
+	return func(n int) []InspectedSpan {
+		var spans []InspectedSpan
+		for len(spans) < n && scanner.Scan() {
+			var s InspectedSpan
+			require.NoError(t, json.Unmarshal(scanner.Bytes(), &s))
+			spans = append(spans, s)
+		}
+		return spans
+	}
+}
+
+func TestInspector(t *testing.T) {
+	newAgent := func(t *testing.T) *Agent {
+		cfg := config.New()
+		cfg.Endpoints[0].APIKey = "test"
+		cfg.Ignore["resource"] = []string{"GET /health"}
+		ctx, cancel := context.WithCancel(context.Background())
+		t.Cleanup(cancel)
+		return NewTestAgent(ctx, cfg, telemetry.NewNoopCollector())
+	}
+	now := time.Now()
+	newSpan := func(traceID uint64, service, resource string) *pb.Span {
+		return &pb.Span{
+			TraceID:  traceID,
+			SpanID:   traceID,
+			Service:  service,
+			Name:     "http.request",
+			Resource: resource,
+			Start:    now.Add(-time.Second).UnixNano(),
+			Duration: (500 * time.Millisecond).Nanoseconds(),
+			Meta:     map[string]string{"http.method": "GET"},
+		}
+	}
+	process := func(agnt *Agent, chunks ...*pb.TraceChunk) {
+		tp := testutil.TracerPayloadWithChunks(chunks)
+		tp.Env = "prod"
+		agnt.Process(&api.Payload{
+			TracerPayload: tp,
+			Source:        info.NewReceiverStats().GetTagStats(info.Tags{}),
+		})
+	}
+
+	t.Run("decisions", func(t *testing.T) {
+		agnt := newAgent(t)
+		next := inspect(t, agnt, "service=web")
+
+		process(agnt,
+			testutil.TraceChunkWithSpanAndPriority(newSpan(1, "web", "GET /users"), 2),
+			testutil.TraceChunkWithSpanAndPriority(newSpan(2, "web", "GET /health"), 2),
+			testutil.TraceChunkWithSpanAndPriority(newSpan(3, "web", "GET /users"), -1),
+			testutil.TraceChunkWithSpanAndPriority(newSpan(4, "db", "SELECT"), 2),
+			testutil.TraceChunkWithSpanAndPriority(newSpan(5, "", ""), 2),
+		)
+
+		spans := next(3)
+		require.Len(t, spans, 3)
+		assert.Equal(t, uint64(1), spans[0].TraceID)
+		assert.Equal(t, "web", spans[0].Service)
+		assert.Equal(t, "GET /users", spans[0].Resource)
+		assert.Equal(t, "prod", spans[0].Env)
+		assert.Equal(t, InspectDecisionKept, spans[0].Decision)
+		assert.Equal(t, "priority", spans[0].Sampler)
+		assert.Equal(t, "GET", spans[0].Meta["http.method"])
+
+		assert.Equal(t, uint64(2), spans[1].TraceID)
+		assert.Equal(t, InspectDecisionFiltered, spans[1].Decision)
+		assert.Equal(t, "ignore_resources", spans[1].Reason)
+
+		assert.Equal(t, uint64(3), spans[2].TraceID)
+		assert.Equal(t, InspectDecisionDropped, spans[2].Decision)
+		assert.Equal(t, "priority", spans[2].Sampler)
+	})
+
+	t.Run("trace_id", func(t *testing.T) {
+		agnt := newAgent(t)
+		next := inspect(t, agnt, "trace_id=4&resource=SELECT")
+
+		process(agnt,
+			testutil.TraceChunkWithSpanAndPriority(newSpan(1, "web", "GET /users"), 2),
+			testutil.TraceChunkWithSpanAndPriority(newSpan(4, "db", "SELECT"), 2),
+		)
+
+		spans := next(1)
+		require.Len(t, spans, 1)
+		assert.Equal(t, uint64(4), spans[0].TraceID)
+		assert.Equal(t, InspectDecisionKept, spans[0].Decision)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		agnt := newAgent(t)
+		next := inspect(t, agnt, "")
+
+		span := newSpan(1, "web", "GET /users")
+		span.TraceID = 0
+		process(agnt, testutil.TraceChunkWithSpanAndPriority(span, 2))
+
+		spans := next(1)
+		require.Len(t, spans, 1)
+		assert.Equal(t, InspectDecisionInvalid, spans[0].Decision)
+		assert.Contains(t, spans[0].Reason, "TraceID")
+	})
+
+	t.Run("filtered-obfuscated", func(t *testing.T) {
+		agnt := newAgent(t)
+		agnt.conf.RejectTags = []*config.Tag{{K: "healthcheck"}}
+		next := inspect(t, agnt, "service=db")
+
+		span := newSpan(1, "db", "SELECT * FROM users WHERE id = 42")
+		span.Type = "sql"
+		span.Meta["healthcheck"] = "true"
+		process(agnt, testutil.TraceChunkWithSpanAndPriority(span, 2))
+
+		// the spans dropped before their obfuscation are streamed obfuscated
+		spans := next(1)
+		require.Len(t, spans, 1)
+		assert.Equal(t, InspectDecisionFiltered, spans[0].Decision)
+		assert.Equal(t, "filter_tags", spans[0].Reason)
+		assert.Equal(t, "SELECT * FROM users WHERE id = ?", spans[0].Resource)
+	})
+
+	t.Run("limit", func(t *testing.T) {
+		agnt := newAgent(t)
+		next := inspect(t, agnt, "limit=1")
+
+		process(agnt,
+			testutil.TraceChunkWithSpanAndPriority(newSpan(1, "web", "GET /users"), 2),
+			testutil.TraceChunkWithSpanAndPriority(newSpan(2, "web", "GET /users"), 2),
+		)
+
+		// the stream ends after the first span
+		assert.Len(t, next(2), 1)
+		assert.Eventually(t, func() bool { return !agnt.Inspector.Active() }, time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("stop", func(t *testing.T) {
+		agnt := newAgent(t)
+		next := inspect(t, agnt, "")
+
+		agnt.Inspector.Stop()
+		assert.Empty(t, next(1))
+		assert.False(t, agnt.Inspector.Active())
+
+		rec := httptest.NewRecorder()
+		agnt.Inspector.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/traces", nil))
+		assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	})
+
+	t.Run("bad-request", func(t *testing.T) {
+		agnt := newAgent(t)
+		for _, query := range []string{"trace_id=abc", "limit=-1"} {
+			rec := httptest.NewRecorder()
+			agnt.Inspector.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/traces?"+query, nil))
+			assert.Equal(t, http.StatusBadRequest, rec.Code, query)
+		}
+		rec := httptest.NewRecorder()
+		agnt.Inspector.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/debug/traces", nil))
+		assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+		assert.False(t, agnt.Inspector.Active())
+	})
+
+	t.Run("inactive", func(t *testing.T) {
+		var i *Inspector
+		assert.False(t, i.Active())
+		i = NewInspector()
+		assert.False(t, i.Active())
+		// no-op while no client is connected
+		i.record("env", []*pb.Span{newSpan(1, "web", "GET /")}, InspectDecisionKept, "priority", "")
+	})
+}
+
+func TestInspectorSpanSampling(t *testing.T) {
+	i := NewInspector()
+	sub := i.subscribe(inspectFilter{})
+	defer i.unsubscribe(sub)
+
+	spans := []*pb.Span{{SpanID: 1}, {SpanID: 2}}
+	i.recordSampling("env", spans, false, "priority", spans[1:])
+	require.Len(t, sub.spans, 2)
+	dropped, kept := <-sub.spans, <-sub.spans
+	assert.Equal(t, InspectDecisionDropped, dropped.Decision)
+	assert.Equal(t, InspectDecisionKept, kept.Decision)
+	assert.Equal(t, "span_sampling", kept.Reason)
+	assert.Equal(t, []*pb.Span{spans[0]}, removedSpans(spans, spans[1:]))
+}
@@ -7,6 +7,7 @@ package agent
 
 import (
 	"strconv"
+	"strings"
 	"sync"
 
 	"github.com/DataDog/datadog-agent/pkg/obfuscate"
@@ -19,6 +20,7 @@ const (
 	tagRedisRawCommand  = transform.TagRedisRawCommand
 	tagMemcachedCommand = transform.TagMemcachedCommand
 	tagMongoDBQuery     = transform.TagMongoDBQuery
+	tagGraphQLSource    = transform.TagGraphQLSource
 	tagElasticBody      = transform.TagElasticBody
 	tagOpenSearchBody   = transform.TagOpenSearchBody
 	tagSQLQuery         = transform.TagSQLQuery
@@ -92,7 +94,23 @@ func (a *Agent) obfuscateSpan(span *pb.Span) {
 		if span.Meta == nil || span.Meta[tagMongoDBQuery] == "" {
 			return
 		}
-		span.Meta[tagMongoDBQuery] = o.ObfuscateMongoDBString(span.Meta[tagMongoDBQuery])
+		if q := strings.TrimSpace(span.Meta[tagMongoDBQuery]); strings.HasPrefix(q, "{") || strings.HasPrefix(q, "[") {
+			span.Meta[tagMongoDBQuery] = o.ObfuscateMongoDBString(span.Meta[tagMongoDBQuery])
+		} else {
+			// commands in the shell syntax, such as db.users.find({name: "jane"})
+			span.Meta[tagMongoDBQuery] = o.ObfuscateMongoDBShellString(span.Meta[tagMongoDBQuery])
+		}
+	case "graphql":
+		if !a.conf.Obfuscation.GraphQL.Enabled {
+			return
+		}
+		// some tracers set the resource to the GraphQL document, it is left
+		// unchanged by the obfuscation when it holds the operation name.
+		span.Resource = o.ObfuscateGraphQLString(span.Resource)
+		if span.Meta == nil || span.Meta[tagGraphQLSource] == "" {
+			return
+		}
+		span.Meta[tagGraphQLSource] = o.ObfuscateGraphQLString(span.Meta[tagGraphQLSource])
 	case "elasticsearch", "opensearch":
 		if span.Meta == nil {
 			return
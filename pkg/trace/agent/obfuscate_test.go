@@ -296,6 +296,47 @@ func TestObfuscateConfig(t *testing.T) {
 		&config.ObfuscationConfig{},
 	))
 
+	t.Run("mongodb/shell", testConfig(
+		"mongodb",
+		"mongodb.query",
+		`db.users.find({name: "jane"}).limit(10)`,
+		`db.users.find({name: ?}).limit(?)`,
+		&config.ObfuscationConfig{Mongo: obfuscate.JSONConfig{Enabled: true}},
+	))
+
+	t.Run("graphql/enabled", testConfig(
+		"graphql",
+		"graphql.source",
+		`query { user(id: "42") { name } }`,
+		`query { user(id: ?) { name } }`,
+		&config.ObfuscationConfig{GraphQL: obfuscate.GraphQLConfig{Enabled: true}},
+	))
+
+	t.Run("graphql/resource", func(t *testing.T) {
+		ctx, cancelFunc := context.WithCancel(context.Background())
+		defer cancelFunc()
+		cfg := config.New()
+		cfg.Endpoints[0].APIKey = "test"
+		cfg.Obfuscation = &config.ObfuscationConfig{GraphQL: obfuscate.GraphQLConfig{Enabled: true}}
+		agnt := NewAgent(ctx, cfg, telemetry.NewNoopCollector(), &statsd.NoOpClient{}, gzip.NewComponent())
+		for in, out := range map[string]string{
+			`query GetUser { user(id: 42) { name } }`: `query GetUser { user(id: ?) { name } }`,
+			`query GetUser`: `query GetUser`,
+		} {
+			span := &pb.Span{Type: "graphql", Resource: in}
+			agnt.obfuscateSpan(span)
+			assert.Equal(t, out, span.Resource)
+		}
+	})
+
+	t.Run("graphql/disabled", testConfig(
+		"graphql",
+		"graphql.source",
+		`query { user(id: "42") { name } }`,
+		`query { user(id: "42") { name } }`,
+		&config.ObfuscationConfig{},
+	))
+
 	t.Run("creditcard", func(t *testing.T) {
 		for _, tt := range []struct {
 			k, v string
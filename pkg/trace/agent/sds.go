@@ -0,0 +1,88 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package agent
+
+import (
+	"slices"
+	"strings"
+
+	pb "github.com/DataDog/datadog-agent/pkg/proto/pbgo/trace"
+	"github.com/DataDog/datadog-agent/pkg/trace/log"
+	"github.com/DataDog/datadog-agent/pkg/trace/traceutil"
+)
+
+// tagSDSMatchedRules is the span tag listing the Sensitive Data Scanner rules which matched
+// the span, separated by commas.
+const tagSDSMatchedRules = "sds.matched_rules"
+
+// SensitiveDataScanner scans values for sensitive data, applying the match actions
+// (redaction, hashing, partial masking) of its rules.
+type SensitiveDataScanner interface {
+	// Scan scans the given value. It returns whether the value has been mutated, the
+	// processed value and the names of the rules which matched.
+	Scan(value []byte) (mutated bool, processed []byte, rules []string, err error)
+}
+
+// scanSensitiveData runs the SensitiveDataScanner over the configured meta values and the
+// span events attributes of the span, and tags it with the rules which matched.
+func (a *Agent) scanSensitiveData(span *pb.Span) {
+	if a.SensitiveDataScanner == nil {
+		return
+	}
+	var matched []string
+	for _, k := range a.conf.SensitiveDataScanning.MetaKeys {
+		v, ok := span.Meta[k]
+		if !ok || v == "" {
+			continue
+		}
+		span.Meta[k], matched = a.scanSensitiveValue(v, matched)
+	}
+	if a.conf.SensitiveDataScanning.SpanEvents {
+		for _, event := range span.SpanEvents {
+			for _, attr := range event.Attributes {
+				switch attr.Type {
+				case pb.AttributeAnyValue_STRING_VALUE:
+					attr.StringValue, matched = a.scanSensitiveValue(attr.StringValue, matched)
+				case pb.AttributeAnyValue_ARRAY_VALUE:
+					if attr.ArrayValue == nil {
+						continue
+					}
+					for _, v := range attr.ArrayValue.Values {
+						if v.Type == pb.AttributeArrayValue_STRING_VALUE {
+							v.StringValue, matched = a.scanSensitiveValue(v.StringValue, matched)
+						}
+					}
+				}
+			}
+		}
+	}
+	if len(matched) == 0 {
+		return
+	}
+	slices.Sort(matched)
+	matched = slices.Compact(matched)
+	log.Debugf("Sensitive data found in span %d of service %s by rules: %v", span.SpanID, span.Service, matched)
+	traceutil.SetMeta(span, tagSDSMatchedRules, strings.Join(matched, ","))
+	_ = a.Statsd.Count("datadog.trace_agent.sds.spans_matched", 1, nil, 1)
+}
+
+// scanSensitiveValue scans v, returning its processed value and matched with the names of
+// the rules which matched appended. v is returned as is if it can't be scanned.
+func (a *Agent) scanSensitiveValue(v string, matched []string) (string, []string) {
+	if v == "" {
+		return v, matched
+	}
+	mutated, processed, rules, err := a.SensitiveDataScanner.Scan([]byte(v))
+	if err != nil {
+		log.Debugf("Error scanning span for sensitive data: %v", err)
+		return v, matched
+	}
+	matched = append(matched, rules...)
+	if !mutated {
+		return v, matched
+	}
+	return string(processed), matched
+}
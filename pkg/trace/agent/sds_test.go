@@ -0,0 +1,111 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package agent
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	pb "github.com/DataDog/datadog-agent/pkg/proto/pbgo/trace"
+)
+
+// testSensitiveDataScanner redacts the emails it is configured with, and fails on "error".
+type testSensitiveDataScanner struct {
+	emails []string
+	calls  int
+}
+
+func (s *testSensitiveDataScanner) Scan(value []byte) (bool, []byte, []string, error) {
+	s.calls++
+	v := string(value)
+	if v == "error" {
+		return false, nil, nil, errors.New("scan error")
+	}
+	var rules []string
+	for _, email := range s.emails {
+		if strings.Contains(v, email) {
+			v = strings.ReplaceAll(v, email, "[redacted]")
+			rules = append(rules, "email")
+		}
+	}
+	if strings.Contains(v, "4242") {
+		rules = append(rules, "card")
+	}
+	return v != string(value), []byte(v), rules, nil
+}
+
+func TestScanSensitiveData(t *testing.T) {
+	newSpan := func() *pb.Span {
+		return &pb.Span{
+			Service: "web",
+			Meta: map[string]string{
+				"http.url":   "/users?email=jane@example.com",
+				"user.email": "john@example.com",
+				"card":       "4242",
+				"error.msg":  "error",
+			},
+			SpanEvents: []*pb.SpanEvent{{
+				Name: "login",
+				Attributes: map[string]*pb.AttributeAnyValue{
+					"email": {Type: pb.AttributeAnyValue_STRING_VALUE, StringValue: "jane@example.com"},
+					"count": {Type: pb.AttributeAnyValue_INT_VALUE, IntValue: 1},
+					"emails": {Type: pb.AttributeAnyValue_ARRAY_VALUE, ArrayValue: &pb.AttributeArray{
+						Values: []*pb.AttributeArrayValue{
+							{Type: pb.AttributeArrayValue_STRING_VALUE, StringValue: "john@example.com"},
+							{Type: pb.AttributeArrayValue_INT_VALUE, IntValue: 2},
+						},
+					}},
+				},
+			}},
+		}
+	}
+
+	t.Run("disabled", func(t *testing.T) {
+		agnt, stop := agentWithDefaults()
+		defer stop()
+		span := newSpan()
+		agnt.scanSensitiveData(span)
+		assert.Equal(t, newSpan(), span)
+	})
+
+	t.Run("meta-and-events", func(t *testing.T) {
+		agnt, stop := agentWithDefaults()
+		defer stop()
+		agnt.conf.SensitiveDataScanning.MetaKeys = []string{"http.url", "card", "error.msg", "missing"}
+		agnt.SensitiveDataScanner = &testSensitiveDataScanner{emails: []string{"jane@example.com", "john@example.com"}}
+		span := newSpan()
+		agnt.scanSensitiveData(span)
+
+		assert.Equal(t, map[string]string{
+			"http.url":          "/users?email=[redacted]",
+			"user.email":        "john@example.com", // not a configured key
+			"card":              "4242",
+			"error.msg":         "error", // kept as is on scanning errors
+			"sds.matched_rules": "card,email",
+		}, span.Meta)
+		attrs := span.SpanEvents[0].Attributes
+		assert.Equal(t, "[redacted]", attrs["email"].StringValue)
+		assert.EqualValues(t, 1, attrs["count"].IntValue)
+		assert.Equal(t, "[redacted]", attrs["emails"].ArrayValue.Values[0].StringValue)
+		assert.EqualValues(t, 2, attrs["emails"].ArrayValue.Values[1].IntValue)
+	})
+
+	t.Run("no-span-events", func(t *testing.T) {
+		agnt, stop := agentWithDefaults()
+		defer stop()
+		agnt.conf.SensitiveDataScanning.SpanEvents = false
+		scanner := &testSensitiveDataScanner{emails: []string{"jane@example.com"}}
+		agnt.SensitiveDataScanner = scanner
+		span := newSpan()
+		agnt.scanSensitiveData(span)
+
+		assert.Equal(t, newSpan(), span)
+		assert.Zero(t, scanner.calls)
+	})
+}
@@ -100,6 +100,10 @@ type HTTPReceiver struct {
 	telemetryCollector telemetry.TelemetryCollector
 	telemetryForwarder *TelemetryForwarder
 
+	// otlp converts the spans received by the Zipkin and Jaeger endpoints,
+	// once translated to OTLP, and sends them down the out channel.
+	otlp *OTLPReceiver
+
 	rateLimiterResponse int // HTTP status code when refusing
 
 	wg   sync.WaitGroup // waits for all requests to be processed
@@ -156,6 +160,8 @@ func NewHTTPReceiver(
 		telemetryCollector: telemetryCollector,
 		telemetryForwarder: telemetryForwarder,
 
+		otlp: &OTLPReceiver{out: out, conf: conf, cidProvider: containerIDProvider, statsd: statsd, timing: timing},
+
 		rateLimiterResponse: rateLimiterResponse,
 
 		exit: make(chan struct{}),
@@ -0,0 +1,52 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package api
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/apache/thrift/lib/go/thrift"
+	model "github.com/jaegertracing/jaeger-idl/model/v1"
+	"github.com/jaegertracing/jaeger-idl/proto-gen/api_v2"
+	"github.com/jaegertracing/jaeger-idl/thrift-gen/jaeger"
+	jaegertranslator "github.com/open-telemetry/opentelemetry-collector-contrib/pkg/translator/jaeger"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+// jaegerEndpointVersion is the endpoint version of the payloads received by the Jaeger endpoint.
+const jaegerEndpointVersion = "jaeger"
+
+// jaegerDecoders holds the decoders of the media types accepted by the Jaeger
+// endpoint: Thrift batches, as sent to the HTTP endpoint of the Jaeger
+// collector, and protobuf PostSpansRequest messages, as sent to its gRPC endpoint.
+var jaegerDecoders = map[string]ptraceDecoder{
+	"application/x-thrift":                 decodeJaegerThrift,
+	"application/vnd.apache.thrift.binary": decodeJaegerThrift,
+	"application/x-protobuf":               decodeJaegerProto,
+	"application/protobuf":                 decodeJaegerProto,
+}
+
+// handleJaegerTraces handles the Jaeger batches sent to /api/traces.
+func (r *HTTPReceiver) handleJaegerTraces(w http.ResponseWriter, req *http.Request) {
+	r.handleTranslatedTraces(w, req, jaegerEndpointVersion, jaegerDecoders)
+}
+
+func decodeJaegerThrift(body []byte) (ptrace.Traces, error) {
+	batch := &jaeger.Batch{}
+	if err := thrift.NewTDeserializer().Read(context.Background(), batch, body); err != nil {
+		return ptrace.Traces{}, err
+	}
+	return jaegertranslator.ThriftToTraces(batch)
+}
+
+func decodeJaegerProto(body []byte) (ptrace.Traces, error) {
+	var in api_v2.PostSpansRequest
+	if err := in.Unmarshal(body); err != nil {
+		return ptrace.Traces{}, err
+	}
+	return jaegertranslator.ProtoToTraces([]*model.Batch{&in.Batch})
+}
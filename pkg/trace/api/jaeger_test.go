@@ -0,0 +1,108 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package api
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/apache/thrift/lib/go/thrift"
+	model "github.com/jaegertracing/jaeger-idl/model/v1"
+	"github.com/jaegertracing/jaeger-idl/proto-gen/api_v2"
+	"github.com/jaegertracing/jaeger-idl/thrift-gen/jaeger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func postJaegerTraces(t *testing.T, r *HTTPReceiver, contentType string, body []byte) {
+	req := httptest.NewRequest(http.MethodPost, "/api/traces", bytes.NewReader(body))
+	req.Header.Set("Content-Type", contentType)
+	rec := httptest.NewRecorder()
+	r.handleJaegerTraces(rec, req)
+	require.Equal(t, http.StatusAccepted, rec.Code, rec.Body.String())
+}
+
+func TestJaegerThrift(t *testing.T) {
+	conf := newTestTranslatedConfig(t)
+	conf.JaegerReceiverEnabled = true
+	r := newTestReceiverFromConfig(conf)
+
+	str := func(s string) *string { return &s }
+	start := time.Unix(1700000000, 0)
+	batch := &jaeger.Batch{
+		Process: &jaeger.Process{
+			ServiceName: "billing",
+			Tags:        []*jaeger.Tag{{Key: "hostname", VType: jaeger.TagType_STRING, VStr: str("billing-1")}},
+		},
+		Spans: []*jaeger.Span{{
+			TraceIdLow:    42,
+			SpanId:        1,
+			OperationName: "SELECT invoices",
+			StartTime:     start.UnixMicro(),
+			Duration:      (20 * time.Millisecond).Microseconds(),
+			Tags: []*jaeger.Tag{
+				{Key: "span.kind", VType: jaeger.TagType_STRING, VStr: str("client")},
+				{Key: "db.system", VType: jaeger.TagType_STRING, VStr: str("postgresql")},
+				{Key: "db.statement", VType: jaeger.TagType_STRING, VStr: str("SELECT * FROM invoices")},
+			},
+			References: []*jaeger.SpanRef{{RefType: jaeger.SpanRefType_FOLLOWS_FROM, TraceIdLow: 7, SpanId: 3}},
+		}},
+	}
+	body, err := thrift.NewTSerializer().Write(context.Background(), batch)
+	require.NoError(t, err)
+	postJaegerTraces(t, r, "application/x-thrift", body)
+
+	p := receivePayload(t, r)
+	assert.Equal(t, "jaeger", p.Source.EndpointVersion)
+	require.Len(t, p.TracerPayload.Chunks, 1)
+	require.Len(t, p.TracerPayload.Chunks[0].Spans, 1)
+	span := p.TracerPayload.Chunks[0].Spans[0]
+	assert.Equal(t, "billing", span.Service)
+	assert.Equal(t, "sql", span.Type)
+	assert.Equal(t, "SELECT * FROM invoices", span.Resource)
+	assert.EqualValues(t, 42, span.TraceID)
+	assert.EqualValues(t, 1, span.SpanID)
+	assert.Equal(t, start.UnixNano(), span.Start)
+	assert.EqualValues(t, 20*time.Millisecond, span.Duration)
+	assert.JSONEq(t, `[{"trace_id":"00000000000000000000000000000007","span_id":"0000000000000003","attributes":{"opentracing.ref_type":"follows_from"}}]`, span.Meta["_dd.span_links"])
+}
+
+func TestJaegerProto(t *testing.T) {
+	r := newTestReceiverFromConfig(newTestTranslatedConfig(t))
+
+	in := api_v2.PostSpansRequest{Batch: model.Batch{
+		Process: &model.Process{ServiceName: "checkout"},
+		Spans: []*model.Span{{
+			TraceID:       model.NewTraceID(0, 42),
+			SpanID:        model.NewSpanID(1),
+			OperationName: "POST /pay",
+			StartTime:     time.Unix(1700000000, 0),
+			Duration:      time.Second,
+			Tags: []model.KeyValue{
+				model.String("span.kind", "server"),
+				model.String("http.method", "POST"),
+				model.String("http.route", "/pay"),
+				model.Bool("error", true),
+			},
+		}},
+	}}
+	body, err := in.Marshal()
+	require.NoError(t, err)
+	postJaegerTraces(t, r, "application/x-protobuf", body)
+
+	p := receivePayload(t, r)
+	require.Len(t, p.TracerPayload.Chunks, 1)
+	require.Len(t, p.TracerPayload.Chunks[0].Spans, 1)
+	span := p.TracerPayload.Chunks[0].Spans[0]
+	assert.Equal(t, "checkout", span.Service)
+	assert.Equal(t, "web", span.Type)
+	assert.Equal(t, "POST /pay", span.Resource)
+	assert.EqualValues(t, 1, span.Error)
+}
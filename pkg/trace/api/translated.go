@@ -0,0 +1,95 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package api
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"go.opentelemetry.io/collector/pdata/ptrace"
+
+	"github.com/DataDog/datadog-agent/pkg/trace/api/apiutil"
+	"github.com/DataDog/datadog-agent/pkg/trace/log"
+)
+
+// ptraceDecoder decodes the body of a request into OTLP traces.
+type ptraceDecoder func(body []byte) (ptrace.Traces, error)
+
+// handleTranslatedTraces handles the requests of the endpoints receiving spans
+// in a third-party format, such as Zipkin or Jaeger. The body of the request is
+// decoded to OTLP traces by the decoder matching its media type, then the spans
+// are converted and sent to the out channel the same way the OTLP spans are.
+func (r *HTTPReceiver) handleTranslatedTraces(w http.ResponseWriter, req *http.Request, endpointVersion string, decoders map[string]ptraceDecoder) {
+	defer req.Body.Close()
+	if req.Method != http.MethodPost {
+		http.Error(w, fmt.Sprintf("method %s not allowed", req.Method), http.StatusMethodNotAllowed)
+		return
+	}
+	if req.Header.Get("Sec-Fetch-Site") == "cross-site" {
+		http.Error(w, "cross-site request rejected", http.StatusForbidden)
+		return
+	}
+	mediaType := getMediaType(req)
+	decode, ok := decoders[mediaType]
+	if !ok {
+		httpFormatError(w, Version(endpointVersion), fmt.Errorf("unsupported media type: %q", mediaType), r.statsd)
+		return
+	}
+
+	select {
+	// Wait for the semaphore to become available, allowing the handler to
+	// decode its payload, the same way the Datadog traces endpoints do.
+	case r.recvsem <- struct{}{}:
+	case <-time.After(time.Duration(r.conf.DecoderTimeout) * time.Millisecond):
+		log.Debugf("trace-agent is overwhelmed, a %s payload has been rejected", endpointVersion)
+		io.Copy(io.Discard, req.Body) //nolint:errcheck
+		w.WriteHeader(http.StatusTooManyRequests)
+		return
+	}
+	defer func() { <-r.recvsem }()
+
+	start := time.Now()
+	tags := []string{"endpoint_version:" + endpointVersion}
+	traces, err := readTranslatedTraces(req, r.conf.MaxRequestBytes, decode)
+	defer func(err error) {
+		tags := append(tags, fmt.Sprintf("success:%v", err == nil))
+		_ = r.statsd.Histogram("datadog.trace_agent.receiver.serve_traces_ms", float64(time.Since(start))/float64(time.Millisecond), tags, 1)
+	}(err)
+	if err != nil {
+		httpDecodingError(err, []string{"handler:traces", "v:" + endpointVersion}, w, r.statsd)
+		log.Errorf("Cannot decode %s traces payload: %v", endpointVersion, err)
+		return
+	}
+
+	rspans := traces.ResourceSpans()
+	for i := 0; i < rspans.Len(); i++ {
+		r.otlp.receiveResourceSpans(req.Context(), rspans.At(i), req.Header, nil, endpointVersion)
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// readTranslatedTraces reads the body of req, decompressing it if needed, and
+// decodes it. Both the compressed and the decompressed body are limited to
+// maxBytes.
+func readTranslatedTraces(req *http.Request, maxBytes int64, decode ptraceDecoder) (ptrace.Traces, error) {
+	var rd io.Reader = apiutil.NewLimitedReader(req.Body, maxBytes)
+	if req.Header.Get("Content-Encoding") == "gzip" {
+		gz, err := gzip.NewReader(rd)
+		if err != nil {
+			return ptrace.Traces{}, err
+		}
+		defer gz.Close()
+		rd = apiutil.NewLimitedReader(gz, maxBytes)
+	}
+	body, err := io.ReadAll(rd)
+	if err != nil {
+		return ptrace.Traces{}, err
+	}
+	return decode(body)
+}
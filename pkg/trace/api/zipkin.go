@@ -0,0 +1,29 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package api
+
+import (
+	"net/http"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/translator/zipkin/zipkinv2"
+)
+
+// zipkinEndpointVersion is the endpoint version of the payloads received by the Zipkin endpoint.
+const zipkinEndpointVersion = "zipkin_v2"
+
+// zipkinDecoders holds the decoders of the media types accepted by the Zipkin
+// endpoint. The string tags are kept as strings, they are not parsed to typed
+// attributes.
+var zipkinDecoders = map[string]ptraceDecoder{
+	"application/json":       zipkinv2.NewJSONTracesUnmarshaler(false).UnmarshalTraces,
+	"application/x-protobuf": zipkinv2.NewProtobufTracesUnmarshaler(false, false).UnmarshalTraces,
+	"application/protobuf":   zipkinv2.NewProtobufTracesUnmarshaler(false, false).UnmarshalTraces,
+}
+
+// handleZipkinSpans handles the Zipkin v2 spans, in JSON or protobuf, sent to /api/v2/spans.
+func (r *HTTPReceiver) handleZipkinSpans(w http.ResponseWriter, req *http.Request) {
+	r.handleTranslatedTraces(w, req, zipkinEndpointVersion, zipkinDecoders)
+}
@@ -0,0 +1,156 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package api
+
+import (
+	"bytes"
+	"compress/gzip"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DataDog/datadog-agent/pkg/trace/config"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/translator/zipkin/zipkinv2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const zipkinTestSpans = `[{
+	"traceId": "5982fe77008310cc80f1da5e10147517",
+	"id": "80f1da5e10147517",
+	"kind": "SERVER",
+	"name": "get /users/{id}",
+	"timestamp": 1700000000000000,
+	"duration": 150000,
+	"localEndpoint": {"serviceName": "frontend", "ipv4": "192.168.1.2"},
+	"tags": {"http.method": "GET", "http.route": "/users/{id}", "http.status_code": "500", "error": "boom"}
+}, {
+	"traceId": "5982fe77008310cc80f1da5e10147517",
+	"parentId": "80f1da5e10147517",
+	"id": "90f1da5e10147518",
+	"kind": "CLIENT",
+	"name": "select",
+	"timestamp": 1700000000010000,
+	"duration": 50000,
+	"localEndpoint": {"serviceName": "frontend"},
+	"tags": {"db.system": "postgresql", "db.statement": "SELECT * FROM users"}
+}]`
+
+func newTestTranslatedConfig(t *testing.T) *config.AgentConfig {
+	conf := NewTestConfig(t)
+	conf.DecoderTimeout = 10000
+	return conf
+}
+
+// receivePayload returns the next payload sent by the receiver.
+func receivePayload(t *testing.T, r *HTTPReceiver) *Payload {
+	select {
+	case p := <-r.out:
+		return p
+	case <-time.After(time.Second):
+		require.FailNow(t, "no payload received")
+		return nil
+	}
+}
+
+func TestZipkinSpans(t *testing.T) {
+	conf := newTestTranslatedConfig(t)
+	conf.ZipkinReceiverEnabled = true
+	r := newTestReceiverFromConfig(conf)
+
+	for name, body := range map[string]func() []byte{
+		"json": func() []byte { return []byte(zipkinTestSpans) },
+		"gzip": func() []byte {
+			var buf bytes.Buffer
+			gz := gzip.NewWriter(&buf)
+			_, err := gz.Write([]byte(zipkinTestSpans))
+			require.NoError(t, err)
+			require.NoError(t, gz.Close())
+			return buf.Bytes()
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/v2/spans", bytes.NewReader(body()))
+			req.Header.Set("Content-Type", "application/json")
+			if name == "gzip" {
+				req.Header.Set("Content-Encoding", "gzip")
+			}
+			rec := httptest.NewRecorder()
+			r.handleZipkinSpans(rec, req)
+			require.Equal(t, http.StatusAccepted, rec.Code, rec.Body.String())
+
+			p := receivePayload(t, r)
+			assert.Equal(t, "zipkin_v2", p.Source.EndpointVersion)
+			require.Len(t, p.TracerPayload.Chunks, 1)
+			spans := p.TracerPayload.Chunks[0].Spans
+			require.Len(t, spans, 2)
+
+			server, client := spans[0], spans[1]
+			if server.ParentID != 0 {
+				server, client = client, server
+			}
+			assert.Equal(t, "frontend", server.Service)
+			assert.Equal(t, "GET /users/{id}", server.Resource)
+			assert.Equal(t, "web", server.Type)
+			assert.EqualValues(t, 1, server.Error)
+			assert.EqualValues(t, 150*time.Millisecond, server.Duration)
+			assert.Equal(t, server.TraceID, client.TraceID)
+			assert.Equal(t, server.SpanID, client.ParentID)
+			assert.Equal(t, "frontend", client.Service)
+			assert.Equal(t, "sql", client.Type)
+			assert.Equal(t, "SELECT * FROM users", client.Resource)
+		})
+	}
+}
+
+func TestZipkinSpansProtobuf(t *testing.T) {
+	conf := newTestTranslatedConfig(t)
+	r := newTestReceiverFromConfig(conf)
+
+	traces, err := zipkinDecoders["application/json"]([]byte(zipkinTestSpans))
+	require.NoError(t, err)
+	body, err := zipkinv2.NewProtobufTracesMarshaler().MarshalTraces(traces)
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v2/spans", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	rec := httptest.NewRecorder()
+	r.handleZipkinSpans(rec, req)
+	require.Equal(t, http.StatusAccepted, rec.Code, rec.Body.String())
+
+	p := receivePayload(t, r)
+	require.Len(t, p.TracerPayload.Chunks, 1)
+	assert.Len(t, p.TracerPayload.Chunks[0].Spans, 2)
+}
+
+func TestZipkinSpansInvalidRequests(t *testing.T) {
+	conf := newTestTranslatedConfig(t)
+	conf.MaxRequestBytes = 64
+	r := newTestReceiverFromConfig(conf)
+
+	for name, tc := range map[string]struct {
+		method      string
+		contentType string
+		body        string
+		status      int
+	}{
+		"method":     {http.MethodGet, "application/json", "", http.StatusMethodNotAllowed},
+		"media-type": {http.MethodPost, "text/plain", "[]", http.StatusUnsupportedMediaType},
+		"decoding":   {http.MethodPost, "application/json", "{", http.StatusBadRequest},
+		"too-large":  {http.MethodPost, "application/json", zipkinTestSpans, http.StatusRequestEntityTooLarge},
+	} {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, "/api/v2/spans", strings.NewReader(tc.body))
+			req.Header.Set("Content-Type", tc.contentType)
+			rec := httptest.NewRecorder()
+			r.handleZipkinSpans(rec, req)
+			assert.Equal(t, tc.status, rec.Code)
+			assert.Empty(t, r.out)
+		})
+	}
+}
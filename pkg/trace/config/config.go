@@ -132,6 +132,10 @@ type ObfuscationConfig struct {
 	// for spans of type "memcached".
 	Memcached obfuscate.MemcachedConfig `mapstructure:"memcached"`
 
+	// GraphQL holds the configuration for obfuscating the resource and the "graphql.source"
+	// tag for spans of type "graphql".
+	GraphQL obfuscate.GraphQLConfig `mapstructure:"graphql"`
+
 	// CreditCards holds the configuration for obfuscating credit cards.
 	CreditCards obfuscate.CreditCardsConfig `mapstructure:"credit_cards"`
 
@@ -172,6 +176,7 @@ func (o *ObfuscationConfig) Export(conf *AgentConfig) obfuscate.Config {
 		Redis:                o.Redis,
 		Valkey:               o.Valkey,
 		Memcached:            o.Memcached,
+		GraphQL:              o.GraphQL,
 		CreditCard:           o.CreditCards,
 		Logger:               new(debugLogger),
 		Cache:                o.Cache,
@@ -213,6 +218,56 @@ type ReplaceRule struct {
 	Repl string `mapstructure:"repl"`
 }
 
+// SpanRule specifies a span-processing rule. Its actions are applied, in order,
+// to the spans matching all its conditions.
+type SpanRule struct {
+	// Name identifies the rule in the logs.
+	Name string `mapstructure:"name"`
+
+	// Conditions holds the conditions the spans must match. A rule without
+	// conditions applies to all the spans.
+	Conditions []SpanRuleCondition `mapstructure:"conditions"`
+
+	// Actions holds the actions applied to the matching spans.
+	Actions []SpanRuleAction `mapstructure:"actions"`
+}
+
+// SpanRuleCondition specifies a condition of a span rule.
+type SpanRuleCondition struct {
+	// Key specifies the tag that the condition addresses. The "span.service",
+	// "span.name", "span.resource" and "span.type" keys target the span fields.
+	Key string `mapstructure:"key"`
+
+	// Pattern specifies the regexp pattern the string value of Key must match.
+	// An empty pattern only requires the tag to be set.
+	Pattern string `mapstructure:"pattern"`
+
+	// Op specifies the comparison operator ("==", "!=", "<", "<=", ">" or ">=")
+	// used to compare the numeric value of Key to Value. When it is set, the
+	// condition addresses the span metrics instead of the span meta.
+	Op string `mapstructure:"op"`
+
+	// Value specifies the value the numeric value of Key is compared to.
+	Value float64 `mapstructure:"value"`
+}
+
+// SpanRuleAction specifies an action of a span rule.
+type SpanRuleAction struct {
+	// Action is one of "set", "rename", "delete", "hash", "truncate" or "drop".
+	Action string `mapstructure:"action"`
+
+	// Key specifies the tag that the action addresses. The "span.service",
+	// "span.name", "span.resource" and "span.type" keys target the span fields,
+	// which can only be set, hashed or truncated. Drop actions have no key.
+	Key string `mapstructure:"key"`
+
+	// Value specifies the value of set actions and the new key of rename actions.
+	Value string `mapstructure:"value"`
+
+	// Length specifies the maximum length, in bytes, kept by truncate actions.
+	Length int `mapstructure:"length"`
+}
+
 // WriterConfig specifies configuration for an API writer.
 type WriterConfig struct {
 	// ConnectionLimit specifies the maximum number of concurrent outgoing
@@ -248,6 +303,43 @@ type ProfilingProxyConfig struct {
 	AdditionalEndpoints map[string][]string
 }
 
+// OTLP export protocols.
+const (
+	// OTLPExportProtocolGRPC exports the traces with OTLP/gRPC.
+	OTLPExportProtocolGRPC = "grpc"
+	// OTLPExportProtocolHTTP exports the traces with OTLP/HTTP, encoded as protobuf.
+	OTLPExportProtocolHTTP = "http"
+)
+
+// SensitiveDataScanning holds the configuration of the scanning of spans for sensitive data,
+// using the Sensitive Data Scanner rules received by the logs agent through remote configuration.
+type SensitiveDataScanning struct {
+	// Enabled reports whether the spans are scanned.
+	Enabled bool
+	// MetaKeys lists the span meta keys whose values are scanned.
+	MetaKeys []string
+	// SpanEvents reports whether the string attributes of the span events are scanned.
+	SpanEvents bool
+}
+
+// OTLPExporter holds the configuration for exporting the sampled traces as OTLP, instead of
+// sending them to Datadog.
+type OTLPExporter struct {
+	// Enabled reports whether the sampled traces are exported as OTLP.
+	Enabled bool
+	// Protocol is the protocol used to export the traces, one of OTLPExportProtocolGRPC
+	// or OTLPExportProtocolHTTP.
+	Protocol string
+	// Endpoint is the host:port of the gRPC server, or the URL of the HTTP traces endpoint.
+	Endpoint string
+	// Headers are added to the export requests, as gRPC metadata or HTTP headers.
+	Headers map[string]string `json:"-"` // Never marshal this field, it may contain credentials
+	// Insecure disables TLS for the gRPC connections.
+	Insecure bool
+	// Timeout is the maximum time an export request can take.
+	Timeout time.Duration
+}
+
 // EVPProxy contains the settings for the EVPProxy proxy.
 type EVPProxy struct {
 	// Enabled reports whether EVPProxy is enabled (true by default).
@@ -343,12 +435,29 @@ type AgentConfig struct {
 	ComputeStatsBySpanKind bool          // enables/disables the computing of stats based on a span's `span.kind` field
 	PeerTags               []string      // additional tags to use for peer entity stats aggregation
 
+	// Span-derived metrics configuration
+	// SpanDerivedMetricsEnabled enables the hits, errors and latency metrics computed
+	// by the Concentrator and sent through DogStatsD.
+	SpanDerivedMetricsEnabled bool
+	// SpanDerivedMetricsDimensions lists the span tags, such as http.route, added as
+	// tags of the span-derived metrics.
+	SpanDerivedMetricsDimensions []string
+	// SpanDerivedMetricsMaxContexts caps the number of tag sets of the span-derived
+	// metrics in a stats bucket. 0 disables the limit.
+	SpanDerivedMetricsMaxContexts int
+
 	// Sampler configuration
 	ExtraSampleRate float64
 	TargetTPS       float64
 	ErrorTPS        float64
 	MaxEPS          float64
 	MaxRemoteTPS    float64
+	// TargetTPSBudgetEnabled makes the priority sampler divide TargetTPS between the
+	// services and envs proportionally to their traffic, instead of uniformly.
+	TargetTPSBudgetEnabled bool
+	// TargetTPSBudgetMinTPS is the TPS guaranteed to each service and env in budget mode,
+	// or their whole traffic if lower.
+	TargetTPSBudgetMinTPS float64
 
 	// Rare Sampler configuration
 	RareSamplerEnabled        bool
@@ -364,6 +473,16 @@ type AgentConfig struct {
 	// Error Tracking Standalone
 	ErrorTrackingStandalone bool
 
+	// Tail Sampler configuration
+	TailSamplerEnabled bool
+	// TailSamplerWindow is the time the dropped chunks are held, waiting for
+	// a chunk of their trace to match one of the TailSamplerPolicies.
+	TailSamplerWindow time.Duration
+	// TailSamplerMaxBufferBytes caps the size of the held chunks. The oldest
+	// traces are evicted when it is reached.
+	TailSamplerMaxBufferBytes int
+	TailSamplerPolicies       []*TailSamplerPolicy
+
 	// Receiver
 	ReceiverEnabled bool // specifies whether Receiver listeners are enabled. Unless OTLPReceiver is used, this should always be true.
 	ReceiverHost    string
@@ -377,6 +496,11 @@ type AgentConfig struct {
 	MaxConnections  int   // specifies the maximum number of concurrent incoming connections allowed.
 	DecoderTimeout  int   // specifies the maximum time in milliseconds that the decoders will wait for a turn to accept a payload before returning 429
 
+	// ZipkinReceiverEnabled enables the Zipkin v2 endpoint (/api/v2/spans) of the Receiver.
+	ZipkinReceiverEnabled bool
+	// JaegerReceiverEnabled enables the Jaeger collector endpoint (/api/traces) of the Receiver.
+	JaegerReceiverEnabled bool
+
 	WindowsPipeName        string
 	PipeBufferSize         int
 	PipeSecurityDescriptor string
@@ -394,6 +518,18 @@ type AgentConfig struct {
 	// case, the sender will drop failed payloads when it is unable to enqueue
 	// them for another retry.
 	MaxSenderRetries int
+	// SpoolEnabled enables persisting to SpoolDir the payloads which the senders can
+	// not queue, or send after MaxSenderRetries, to replay them once the intake recovers.
+	SpoolEnabled bool
+	// SpoolDir is the directory the payloads are spooled to.
+	SpoolDir string
+	// SpoolMaxBytes caps the size of the payloads spooled by each sender. The oldest
+	// payloads are evicted when it is reached.
+	SpoolMaxBytes int64
+	// SpoolMaxAge is the age after which spooled payloads are discarded. 0 disables it.
+	SpoolMaxAge time.Duration
+	// OTLPExporter holds the configuration for exporting the sampled traces as OTLP.
+	OTLPExporter OTLPExporter
 	// HTTP client used in writer connections. If nil, default client values will be used.
 	HTTPClientFunc func() *http.Client `json:"-"`
 	// HTTP Transport used in writer connections. If nil, default transport values will be used.
@@ -425,6 +561,9 @@ type AgentConfig struct {
 	// It maps tag keys to a set of replacements. Only supported in A6.
 	ReplaceTags []*ReplaceRule
 
+	// SpanRules lists the rules applied to each span, before the traces are filtered
+	SpanRules []*SpanRule
+
 	// GlobalTags list metadata that will be added to all spans
 	GlobalTags map[string]string
 
@@ -441,6 +580,10 @@ type AgentConfig struct {
 	// SQLObfuscationMode holds obfuscator mode.
 	SQLObfuscationMode string
 
+	// SensitiveDataScanning holds the configuration of the scanning of spans with the
+	// Sensitive Data Scanner rules of the logs.
+	SensitiveDataScanning SensitiveDataScanning
+
 	// MaxResourceLen the maximum length the resource can have
 	MaxResourceLen int
 
@@ -542,6 +685,23 @@ type TagRegex struct {
 	V *regexp.Regexp
 }
 
+// TailSamplerPolicy selects the traces kept by the tail sampler. A trace is
+// selected when one of its spans matches all the conditions of the policy.
+type TailSamplerPolicy struct {
+	// Name identifies the policy in the telemetry and in the kept chunks.
+	Name string `mapstructure:"name"`
+	// Service and Resource restrict the policy to the spans of a service and
+	// of a resource. Empty values match all the spans.
+	Service  string `mapstructure:"service"`
+	Resource string `mapstructure:"resource"`
+	// MinDurationMs selects the spans lasting at least this duration, in milliseconds.
+	MinDurationMs float64 `mapstructure:"min_duration_ms"`
+	// Error selects the spans with an error.
+	Error bool `mapstructure:"error"`
+	// Tags selects the spans with all these tag values.
+	Tags map[string]string `mapstructure:"tags"`
+}
+
 // New returns a configuration with the default values.
 func New() *AgentConfig {
 	return &AgentConfig{
@@ -554,11 +714,15 @@ func New() *AgentConfig {
 
 		BucketInterval: time.Duration(10) * time.Second,
 
-		ExtraSampleRate: 1.0,
-		TargetTPS:       10,
-		ErrorTPS:        10,
-		MaxEPS:          200,
-		MaxRemoteTPS:    100,
+		SpanDerivedMetricsEnabled:     false,
+		SpanDerivedMetricsMaxContexts: 10000,
+
+		ExtraSampleRate:       1.0,
+		TargetTPS:             10,
+		ErrorTPS:              10,
+		MaxEPS:                200,
+		MaxRemoteTPS:          100,
+		TargetTPSBudgetMinTPS: 1,
 
 		RareSamplerEnabled:        false,
 		RareSamplerTPS:            5,
@@ -567,6 +731,10 @@ func New() *AgentConfig {
 
 		ErrorTrackingStandalone: false,
 
+		TailSamplerEnabled:        false,
+		TailSamplerWindow:         30 * time.Second,
+		TailSamplerMaxBufferBytes: 64 * 1024 * 1024, // 64MB
+
 		ReceiverEnabled:        true,
 		ReceiverHost:           "localhost",
 		ReceiverPort:           8126,
@@ -579,6 +747,16 @@ func New() *AgentConfig {
 		TraceWriter:             new(WriterConfig),
 		ConnectionResetInterval: 0, // disabled
 		MaxSenderRetries:        4,
+		SpoolEnabled:            false,
+		SpoolMaxBytes:           128 * 1024 * 1024, // 128MB
+		SpoolMaxAge:             time.Hour,
+		OTLPExporter: OTLPExporter{
+			Protocol: OTLPExportProtocolGRPC,
+			Timeout:  10 * time.Second,
+		},
+		SensitiveDataScanning: SensitiveDataScanning{
+			SpanEvents: true,
+		},
 
 		StatsdHost:    "localhost",
 		StatsdPort:    8125,
@@ -0,0 +1,284 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package filters
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+
+	pb "github.com/DataDog/datadog-agent/pkg/proto/pbgo/trace"
+	"github.com/DataDog/datadog-agent/pkg/trace/config"
+	"github.com/DataDog/datadog-agent/pkg/trace/log"
+	"github.com/DataDog/datadog-agent/pkg/trace/traceutil/normalize"
+)
+
+// Keys addressing the span fields in the span rules.
+const (
+	spanServiceKey  = "span.service"
+	spanNameKey     = "span.name"
+	spanResourceKey = "span.resource"
+	spanTypeKey     = "span.type"
+)
+
+// SpanRules is a filter which applies the span-processing rules to the spans
+// of a trace: it modifies their tags and fields, and drops the spans matching
+// a rule with a drop action.
+type SpanRules struct {
+	rules []*spanRule
+}
+
+type spanRule struct {
+	name       string
+	conditions []spanCondition
+	actions    []config.SpanRuleAction
+}
+
+type spanCondition struct {
+	key string
+	// re is nil for numeric conditions, and for string conditions only
+	// requiring the tag to be set.
+	re    *regexp.Regexp
+	op    string
+	value float64
+}
+
+// NewSpanRules returns a new SpanRules which will use the given set of rules.
+// The invalid rules are logged and ignored.
+func NewSpanRules(rules []*config.SpanRule) *SpanRules {
+	f := &SpanRules{}
+	for i, r := range rules {
+		rule, err := compileSpanRule(r)
+		if err != nil {
+			name := r.Name
+			if name == "" {
+				name = fmt.Sprintf("#%d", i)
+			}
+			log.Errorf("Invalid span rule %s: %v", name, err)
+			continue
+		}
+		f.rules = append(f.rules, rule)
+	}
+	return f
+}
+
+func compileSpanRule(r *config.SpanRule) (*spanRule, error) {
+	rule := &spanRule{name: r.Name, actions: r.Actions}
+	for _, c := range r.Conditions {
+		if c.Key == "" {
+			return nil, errors.New(`all conditions must have a "key"`)
+		}
+		cond := spanCondition{key: c.Key, op: c.Op, value: c.Value}
+		switch c.Op {
+		case "":
+			if c.Pattern != "" {
+				re, err := regexp.Compile(c.Pattern)
+				if err != nil {
+					return nil, fmt.Errorf("key %q: %s", c.Key, err)
+				}
+				cond.re = re
+			}
+		case "==", "!=", "<", "<=", ">", ">=":
+			if isSpanField(c.Key) {
+				return nil, fmt.Errorf("key %q: numeric conditions only apply to tags", c.Key)
+			}
+		default:
+			return nil, fmt.Errorf("key %q: unknown operator %q", c.Key, c.Op)
+		}
+		rule.conditions = append(rule.conditions, cond)
+	}
+	if len(r.Actions) == 0 {
+		return nil, errors.New("rule without action")
+	}
+	for _, a := range r.Actions {
+		if a.Action != "drop" && a.Key == "" {
+			return nil, fmt.Errorf(`%s actions must have a "key"`, a.Action)
+		}
+		switch a.Action {
+		case "set", "hash", "drop":
+		case "rename", "delete":
+			if isSpanField(a.Key) {
+				return nil, fmt.Errorf("key %q: span fields can not be renamed or deleted", a.Key)
+			}
+			if a.Action == "rename" && a.Value == "" {
+				return nil, fmt.Errorf(`key %q: rename actions must have a "value"`, a.Key)
+			}
+		case "truncate":
+			if a.Length <= 0 {
+				return nil, fmt.Errorf(`key %q: truncate actions must have a positive "length"`, a.Key)
+			}
+		default:
+			return nil, fmt.Errorf("unknown action %q", a.Action)
+		}
+	}
+	return rule, nil
+}
+
+func isSpanField(key string) bool {
+	switch key {
+	case spanServiceKey, spanNameKey, spanResourceKey, spanTypeKey:
+		return true
+	}
+	return false
+}
+
+// Apply applies the rules to the spans of a trace, and returns the spans which
+// were not dropped. The children of the dropped spans are reparented to their
+// closest ancestor which was not dropped.
+func (f *SpanRules) Apply(spans []*pb.Span) []*pb.Span {
+	if f == nil || len(f.rules) == 0 {
+		return spans
+	}
+	var dropped map[uint64]uint64 // span ID -> parent ID
+	for _, s := range spans {
+		for _, rule := range f.rules {
+			if !rule.matches(s) {
+				continue
+			}
+			if !rule.apply(s) {
+				log.Debugf("Span dropped by span rule %q: %v", rule.name, s)
+				if dropped == nil {
+					dropped = make(map[uint64]uint64)
+				}
+				dropped[s.SpanID] = s.ParentID
+				break
+			}
+		}
+	}
+	if len(dropped) == 0 {
+		return spans
+	}
+
+	kept := spans[:0]
+	for _, s := range spans {
+		if _, ok := dropped[s.SpanID]; ok {
+			continue
+		}
+		// the loop ends on cycles, which would otherwise never end if the
+		// parent IDs of the dropped spans are corrupted
+		for i := 0; i < len(dropped); i++ {
+			parentID, ok := dropped[s.ParentID]
+			if !ok {
+				break
+			}
+			s.ParentID = parentID
+		}
+		kept = append(kept, s)
+	}
+	for i := len(kept); i < len(spans); i++ {
+		spans[i] = nil
+	}
+	return kept
+}
+
+func (r *spanRule) matches(s *pb.Span) bool {
+	for _, c := range r.conditions {
+		if !c.matches(s) {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *spanCondition) matches(s *pb.Span) bool {
+	if c.op != "" {
+		v, ok := s.Metrics[c.key]
+		if !ok {
+			return false
+		}
+		switch c.op {
+		case "==":
+			return v == c.value
+		case "!=":
+			return v != c.value
+		case "<":
+			return v < c.value
+		case "<=":
+			return v <= c.value
+		case ">":
+			return v > c.value
+		default:
+			return v >= c.value
+		}
+	}
+	v, ok := spanValue(s, c.key)
+	if !ok {
+		return false
+	}
+	return c.re == nil || c.re.MatchString(v)
+}
+
+// apply applies the actions of the rule to s. It returns false if s must be dropped.
+func (r *spanRule) apply(s *pb.Span) bool {
+	for _, a := range r.actions {
+		switch a.Action {
+		case "set":
+			setSpanValue(s, a.Key, a.Value)
+		case "rename":
+			if v, ok := s.Meta[a.Key]; ok {
+				delete(s.Meta, a.Key)
+				s.Meta[a.Value] = v
+			}
+			if v, ok := s.Metrics[a.Key]; ok {
+				delete(s.Metrics, a.Key)
+				s.Metrics[a.Value] = v
+			}
+		case "delete":
+			delete(s.Meta, a.Key)
+			delete(s.Metrics, a.Key)
+		case "hash":
+			if v, ok := spanValue(s, a.Key); ok {
+				sum := sha256.Sum256([]byte(v))
+				setSpanValue(s, a.Key, hex.EncodeToString(sum[:]))
+			}
+		case "truncate":
+			if v, ok := spanValue(s, a.Key); ok && len(v) > a.Length {
+				setSpanValue(s, a.Key, normalize.TruncateUTF8(v, a.Length))
+			}
+		case "drop":
+			return false
+		}
+	}
+	return true
+}
+
+// spanValue returns the value of a span field or of a string tag.
+func spanValue(s *pb.Span, key string) (string, bool) {
+	switch key {
+	case spanServiceKey:
+		return s.Service, true
+	case spanNameKey:
+		return s.Name, true
+	case spanResourceKey:
+		return s.Resource, true
+	case spanTypeKey:
+		return s.Type, true
+	}
+	v, ok := s.Meta[key]
+	return v, ok
+}
+
+// setSpanValue sets the value of a span field or of a string tag. As the rules
+// are applied to normalized spans, the service and the name are normalized.
+func setSpanValue(s *pb.Span, key, value string) {
+	switch key {
+	case spanServiceKey:
+		s.Service, _ = normalize.NormalizeService(value, "")
+	case spanNameKey:
+		s.Name, _ = normalize.NormalizeName(value)
+	case spanResourceKey:
+		s.Resource = value
+	case spanTypeKey:
+		s.Type = value
+	default:
+		if s.Meta == nil {
+			s.Meta = make(map[string]string)
+		}
+		s.Meta[key] = value
+	}
+}
@@ -0,0 +1,165 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package filters
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	pb "github.com/DataDog/datadog-agent/pkg/proto/pbgo/trace"
+	"github.com/DataDog/datadog-agent/pkg/trace/config"
+)
+
+func TestSpanRulesActions(t *testing.T) {
+	f := NewSpanRules([]*config.SpanRule{
+		{
+			Name:       "scrub",
+			Conditions: []config.SpanRuleCondition{{Key: "span.service", Pattern: "^web$"}},
+			Actions: []config.SpanRuleAction{
+				{Action: "hash", Key: "user.email"},
+				{Action: "delete", Key: "password"},
+				{Action: "delete", Key: "retries"},
+				{Action: "rename", Key: "usr.id", Value: "user.id"},
+				{Action: "truncate", Key: "sql.query", Length: 6},
+				{Action: "set", Key: "span.resource", Value: "GET /users"},
+				{Action: "set", Key: "span.service", Value: "Web Frontend"},
+				{Action: "set", Key: "team", Value: "identity"},
+			},
+		},
+		{
+			// rules apply in order, on the spans modified by the previous rules
+			Name:       "errors",
+			Conditions: []config.SpanRuleCondition{{Key: "span.service", Pattern: "^web_frontend$"}, {Key: "http.status_code", Op: ">=", Value: 500}},
+			Actions:    []config.SpanRuleAction{{Action: "set", Key: "alert", Value: "true"}},
+		},
+	})
+
+	span := &pb.Span{
+		Service:  "web",
+		Resource: "GET /users/42",
+		Meta: map[string]string{
+			"user.email": "jane@example.com",
+			"password":   "hunter2",
+			"usr.id":     "42",
+			"sql.query":  "SELECT * FROM users",
+		},
+		Metrics: map[string]float64{"retries": 2, "http.status_code": 503},
+	}
+	other := &pb.Span{Service: "db", Meta: map[string]string{"password": "hunter2"}}
+	spans := f.Apply([]*pb.Span{span, other})
+	require.Len(t, spans, 2)
+
+	assert.Equal(t, "web_frontend", span.Service)
+	assert.Equal(t, "GET /users", span.Resource)
+	assert.Equal(t, map[string]string{
+		"user.email": "8c87b489ce35cf2e2f39f80e282cb2e804932a56a213983eeeb428407d43b52d",
+		"user.id":    "42",
+		"sql.query":  "SELECT",
+		"team":       "identity",
+		"alert":      "true",
+	}, span.Meta)
+	assert.Equal(t, map[string]float64{"http.status_code": 503}, span.Metrics)
+	assert.Equal(t, map[string]string{"password": "hunter2"}, other.Meta)
+}
+
+func TestSpanRulesConditions(t *testing.T) {
+	span := &pb.Span{
+		Service:  "web",
+		Name:     "http.request",
+		Resource: "GET /users",
+		Type:     "web",
+		Meta:     map[string]string{"env": "prod", "empty": ""},
+		Metrics:  map[string]float64{"http.status_code": 404},
+	}
+	for _, tc := range []struct {
+		conditions []config.SpanRuleCondition
+		match      bool
+	}{
+		{nil, true},
+		{[]config.SpanRuleCondition{{Key: "span.name", Pattern: `^http\.`}}, true},
+		{[]config.SpanRuleCondition{{Key: "span.resource", Pattern: "POST"}}, false},
+		{[]config.SpanRuleCondition{{Key: "span.type", Pattern: "web"}, {Key: "env", Pattern: "^prod$"}}, true},
+		{[]config.SpanRuleCondition{{Key: "empty"}}, true},
+		{[]config.SpanRuleCondition{{Key: "missing"}}, false},
+		{[]config.SpanRuleCondition{{Key: "http.status_code", Op: "==", Value: 404}}, true},
+		{[]config.SpanRuleCondition{{Key: "http.status_code", Op: "!=", Value: 404}}, false},
+		{[]config.SpanRuleCondition{{Key: "http.status_code", Op: "<", Value: 500}}, true},
+		{[]config.SpanRuleCondition{{Key: "http.status_code", Op: ">", Value: 404}}, false},
+		{[]config.SpanRuleCondition{{Key: "missing", Op: "<=", Value: 404}}, false},
+	} {
+		rule, err := compileSpanRule(&config.SpanRule{Conditions: tc.conditions, Actions: []config.SpanRuleAction{{Action: "drop"}}})
+		require.NoError(t, err)
+		assert.Equal(t, tc.match, rule.matches(span), "%+v", tc.conditions)
+	}
+}
+
+func TestSpanRulesDrop(t *testing.T) {
+	f := NewSpanRules([]*config.SpanRule{{
+		Name:       "drop-cache",
+		Conditions: []config.SpanRuleCondition{{Key: "span.service", Pattern: "^cache$"}},
+		Actions:    []config.SpanRuleAction{{Action: "drop"}},
+	}})
+
+	//        1 web
+	//        |
+	//        2 cache
+	//       / \
+	// 3 cache  4 db
+	//     |
+	//     5 db
+	root := &pb.Span{SpanID: 1, Service: "web"}
+	db1 := &pb.Span{SpanID: 4, ParentID: 2, Service: "db"}
+	db2 := &pb.Span{SpanID: 5, ParentID: 3, Service: "db"}
+	spans := f.Apply([]*pb.Span{
+		root,
+		{SpanID: 2, ParentID: 1, Service: "cache"},
+		{SpanID: 3, ParentID: 2, Service: "cache"},
+		db1,
+		db2,
+	})
+	assert.Equal(t, []*pb.Span{root, db1, db2}, spans)
+	assert.EqualValues(t, 0, root.ParentID)
+	assert.EqualValues(t, 1, db1.ParentID)
+	assert.EqualValues(t, 1, db2.ParentID)
+
+	// children of a dropped root become roots
+	child := &pb.Span{SpanID: 2, ParentID: 1, Service: "web"}
+	spans = f.Apply([]*pb.Span{{SpanID: 1, Service: "cache"}, child})
+	assert.Equal(t, []*pb.Span{child}, spans)
+	assert.EqualValues(t, 0, child.ParentID)
+
+	// all the spans can be dropped
+	assert.Empty(t, f.Apply([]*pb.Span{{SpanID: 1, Service: "cache"}}))
+}
+
+func TestSpanRulesInvalid(t *testing.T) {
+	for _, rule := range []*config.SpanRule{
+		{},
+		{Actions: []config.SpanRuleAction{{Action: "explode", Key: "a"}}},
+		{Actions: []config.SpanRuleAction{{Action: "set"}}},
+		{Actions: []config.SpanRuleAction{{Action: "rename", Key: "a"}}},
+		{Actions: []config.SpanRuleAction{{Action: "delete", Key: "span.service"}}},
+		{Actions: []config.SpanRuleAction{{Action: "truncate", Key: "a"}}},
+		{Conditions: []config.SpanRuleCondition{{Pattern: "a"}}, Actions: []config.SpanRuleAction{{Action: "drop"}}},
+		{Conditions: []config.SpanRuleCondition{{Key: "a", Pattern: "("}}, Actions: []config.SpanRuleAction{{Action: "drop"}}},
+		{Conditions: []config.SpanRuleCondition{{Key: "a", Op: "~"}}, Actions: []config.SpanRuleAction{{Action: "drop"}}},
+		{Conditions: []config.SpanRuleCondition{{Key: "span.name", Op: ">"}}, Actions: []config.SpanRuleAction{{Action: "drop"}}},
+	} {
+		_, err := compileSpanRule(rule)
+		assert.Error(t, err, "%+v", rule)
+	}
+
+	// invalid rules are ignored
+	f := NewSpanRules([]*config.SpanRule{{}, {Actions: []config.SpanRuleAction{{Action: "drop"}}}})
+	assert.Len(t, f.rules, 1)
+
+	// a nil SpanRules keeps the spans as they are
+	var nilRules *SpanRules
+	spans := []*pb.Span{{Service: "web"}}
+	assert.Equal(t, spans, nilRules.Apply(spans))
+}
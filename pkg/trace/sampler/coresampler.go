@@ -49,6 +49,10 @@ type Sampler struct {
 	targetTPS *atomic.Float64
 	// extraRate is an extra raw sampling rate to apply on top of the sampler rate
 	extraRate float64
+	// budget reports whether the targetTPS is divided between the signatures proportionally
+	// to their traffic, guaranteeing each at least budgetMinTPS, instead of uniformly.
+	budget       bool
+	budgetMinTPS float64
 }
 
 // newSampler returns an initialized Sampler
@@ -127,13 +131,22 @@ func (s *Sampler) updateRates(previousBucket, newBucket int64) {
 	_, allSigsSeen := zeroAndGetMax(s.allSigsSeen, previousBucket, newBucket)
 	s.allSigsSeen = allSigsSeen
 
-	tpsPerSig := computeTPSPerSig(s.targetTPS.Load(), seenTPSs)
+	var tpsPerSig float64
+	var budgetTPSs []float64
+	if s.budget {
+		budgetTPSs = computeBudgetTPSPerSig(s.targetTPS.Load(), s.budgetMinTPS, seenTPSs)
+	} else {
+		tpsPerSig = computeTPSPerSig(s.targetTPS.Load(), seenTPSs)
+	}
 
 	s.muRates.Lock()
 	defer s.muRates.Unlock()
 	s.lowestRate = 1
 	for i, sig := range sigs {
 		seenTPS := seenTPSs[i]
+		if budgetTPSs != nil {
+			tpsPerSig = budgetTPSs[i]
+		}
 		rate := 1.0
 		if tpsPerSig < seenTPS && seenTPS > 0 {
 			rate = tpsPerSig / seenTPS
@@ -181,6 +194,36 @@ func computeTPSPerSig(targetTPS float64, seen []float64) float64 {
 	return sigTarget
 }
 
+// computeBudgetTPSPerSig divides the targetTPS budget between the signatures, returning the
+// TPS of each. Each signature gets at least minTPS, or its whole seen TPS if lower, and the
+// rest of the budget is spread proportionally to the seen TPS above these floors, so that a
+// high volume signature can not starve the others. If the budget does not cover the floors,
+// it is spread uniformly, as computeTPSPerSig does.
+func computeBudgetTPSPerSig(targetTPS, minTPS float64, seen []float64) []float64 {
+	tpss := make([]float64, len(seen))
+	var floors, excess float64
+	for i, c := range seen {
+		tpss[i] = min(c, minTPS)
+		floors += tpss[i]
+		excess += c - tpss[i]
+	}
+	if floors >= targetTPS {
+		tpsPerSig := computeTPSPerSig(targetTPS, seen)
+		for i, c := range seen {
+			tpss[i] = min(c, tpsPerSig)
+		}
+		return tpss
+	}
+	if excess == 0 {
+		return tpss
+	}
+	ratio := min((targetTPS-floors)/excess, 1)
+	for i, c := range seen {
+		tpss[i] += (c - tpss[i]) * ratio
+	}
+	return tpss
+}
+
 // zeroAndGetMax zeroes expired buckets and returns the max count
 func zeroAndGetMax(buckets [numBuckets]float32, previousBucket, newBucket int64) (float32, [numBuckets]float32) {
 	maxBucket := float32(0)
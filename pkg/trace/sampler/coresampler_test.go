@@ -263,6 +263,81 @@ func TestComputeTPSPerSig(t *testing.T) {
 	}
 }
 
+func TestComputeBudgetTPSPerSig(t *testing.T) {
+	tts := []struct {
+		name        string
+		targetTPS   float64
+		minTPS      float64
+		seenTPS     []float64
+		expectedTPS []float64
+	}{
+		{
+			name:        "zeroes",
+			targetTPS:   0,
+			minTPS:      1,
+			seenTPS:     []float64{0, 10},
+			expectedTPS: []float64{0, 0},
+		},
+		{
+			name:        "floors and proportional spread",
+			targetTPS:   10,
+			minTPS:      1,
+			seenTPS:     []float64{0, 2, 100, 0.5},
+			expectedTPS: []float64{0, 1.075, 8.425, 0.5},
+		},
+		{
+			name:        "budget above traffic",
+			targetTPS:   200,
+			minTPS:      1,
+			seenTPS:     []float64{10, 100, 3},
+			expectedTPS: []float64{10, 100, 3},
+		},
+		{
+			name:        "traffic below floors",
+			targetTPS:   10,
+			minTPS:      5,
+			seenTPS:     []float64{1, 2},
+			expectedTPS: []float64{1, 2},
+		},
+		{
+			name:        "floors above budget",
+			targetTPS:   2,
+			minTPS:      1,
+			seenTPS:     []float64{10, 100, 3},
+			expectedTPS: []float64{2.0 / 3, 2.0 / 3, 2.0 / 3},
+		},
+	}
+
+	for _, tc := range tts {
+		t.Run(tc.name, func(t *testing.T) {
+			tpss := computeBudgetTPSPerSig(tc.targetTPS, tc.minTPS, tc.seenTPS)
+			require.Len(t, tpss, len(tc.expectedTPS))
+			for i, expected := range tc.expectedTPS {
+				assert.InDelta(t, expected, tpss[i], 0.00000001, "signature %d", i)
+			}
+		})
+	}
+}
+
+func TestBudgetRates(t *testing.T) {
+	s := newSampler(1, 10)
+	s.budget = true
+	s.budgetMinTPS = 1
+
+	testTime := time.Now()
+	for i, c := range []float32{2, 100} {
+		s.countWeightedSig(testTime, Signature(i), c*float32(bucketDuration.Seconds()))
+	}
+	// trigger rate computation
+	s.countWeightedSig(testTime.Add(bucketDuration+time.Nanosecond), Signature(0), 0)
+
+	// each signature gets its floor of 1 TPS, and the remaining 8 TPS are spread
+	// proportionally to the traffic above the floors: 1 and 99 TPS.
+	rates, _ := s.getAllSignatureSampleRates()
+	assert.InEpsilon(t, 1.08/2, rates[Signature(0)], 0.00000001)
+	assert.InEpsilon(t, 8.92/100, rates[Signature(1)], 0.00000001)
+}
+
 func TestDefaultRate(t *testing.T) {
 	targetTPS := 10.0
 	s := newSampler(1, targetTPS)
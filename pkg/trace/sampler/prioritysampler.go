@@ -55,6 +55,11 @@ func NewPrioritySampler(conf *config.AgentConfig, dynConf *DynamicConfig) *Prior
 		rateByService: &dynConf.RateByService,
 		catalog:       newServiceLookup(conf.MaxCatalogEntries),
 	}
+	if conf.TargetTPSBudgetEnabled {
+		// the signatures of the sampler are the services and envs
+		s.sampler.budget = true
+		s.sampler.budgetMinTPS = conf.TargetTPSBudgetMinTPS
+	}
 	return s
 }
 
@@ -191,3 +191,32 @@ func TestPrioritySamplerTPSFeedbackLoop(t *testing.T) {
 		assert.InEpsilon(tc.expectedTPS, float64(sampledCount)/(float64(testDuration)*bucketDuration.Seconds()), tc.relativeError)
 	}
 }
+
+func TestPrioritySamplerBudget(t *testing.T) {
+	conf := &config.AgentConfig{
+		ExtraSampleRate:        1.0,
+		TargetTPS:              10,
+		TargetTPSBudgetEnabled: true,
+		TargetTPSBudgetMinTPS:  1,
+	}
+	s := NewPrioritySampler(conf, &DynamicConfig{})
+
+	sample := func(now time.Time, service string) {
+		root := &pb.Span{TraceID: randomTraceID(), SpanID: 1, Service: service, Metrics: map[string]float64{}}
+		s.Sample(now, &pb.TraceChunk{Priority: int32(PriorityAutoKeep), Spans: []*pb.Span{root}}, root, "prod", 0)
+	}
+	now := time.Now()
+	for service, tps := range map[string]int{"hot": 100, "cold": 2} {
+		for i := 0; i < tps*int(bucketDuration.Seconds()); i++ {
+			sample(now, service)
+		}
+	}
+	// trigger rate computation
+	sample(now.Add(bucketDuration), "cold")
+
+	// each service gets its floor of 1 TPS, and the remaining 8 TPS are spread
+	// proportionally to the traffic above the floors: 1 and 99 TPS.
+	rates := s.ratesByService()
+	assert.InEpsilon(t, 8.92/100, rates[ServiceSignature{Name: "hot", Env: "prod"}], 0.00001)
+	assert.InEpsilon(t, 1.08/2, rates[ServiceSignature{Name: "cold", Env: "prod"}], 0.00001)
+}
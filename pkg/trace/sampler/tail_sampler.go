@@ -0,0 +1,315 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package sampler
+
+import (
+	"container/list"
+	"fmt"
+	"sync"
+	"time"
+
+	pb "github.com/DataDog/datadog-agent/pkg/proto/pbgo/trace"
+	"github.com/DataDog/datadog-agent/pkg/trace/config"
+	"github.com/DataDog/datadog-agent/pkg/trace/log"
+	"github.com/DataDog/datadog-go/v5/statsd"
+)
+
+const (
+	// MetricsTailSamplerKept is the metric name for the number of chunks kept by the tail sampler.
+	MetricsTailSamplerKept = "datadog.trace_agent.sampler.tail.kept"
+	// MetricsTailSamplerEvicted is the metric name for the number of traces evicted from the tail
+	// sampler buffer before the end of their window.
+	MetricsTailSamplerEvicted = "datadog.trace_agent.sampler.tail.evicted"
+	// MetricsTailSamplerExpired is the metric name for the number of held traces dropped at the
+	// end of their window, as none of their chunks matched a policy.
+	MetricsTailSamplerExpired = "datadog.trace_agent.sampler.tail.expired"
+	// MetricsTailSamplerTraces is the metric name for the number of traces in the tail sampler buffer.
+	MetricsTailSamplerTraces = "datadog.trace_agent.sampler.tail.traces"
+	// MetricsTailSamplerBytes is the metric name for the size of the chunks held by the tail sampler.
+	MetricsTailSamplerBytes = "datadog.trace_agent.sampler.tail.bytes"
+
+	// tailSamplerPolicyKey is the chunk tag holding the name of the policy which kept the chunk.
+	tailSamplerPolicyKey = "_dd.tail_sampler.policy"
+	// tailSamplerFlushPeriod is the frequency at which the expired traces are removed.
+	tailSamplerFlushPeriod = time.Second
+	// tailTraceOverhead is the estimated size of a trace in the buffer, without its chunks.
+	tailTraceOverhead = 128
+)
+
+// TailSampledChunk is a chunk released by the TailSampler.
+type TailSampledChunk struct {
+	Chunk *pb.TraceChunk
+	// TracerPayload holds the metadata of the payload the chunk was received in, without its chunks.
+	TracerPayload *pb.TracerPayload
+}
+
+// tailTrace holds the state of a trace in the TailSampler buffer.
+type tailTrace struct {
+	id        uint64
+	firstSeen time.Time
+	// policy is the name of the policy which selected the trace, empty if
+	// none of its chunks matched a policy yet.
+	policy string
+	chunks []TailSampledChunk
+	size   int
+	elem   *list.Element
+}
+
+// TailSampler keeps the traces with a span matching one of its policies, even
+// when the span is received in a later chunk than the chunks dropped by the
+// other samplers. The dropped chunks are held for a window, keyed by trace ID:
+// when a chunk of their trace matches a policy, they are released as kept.
+// Once a trace is selected, its next chunks received during the window are
+// kept too.
+type TailSampler struct {
+	enabled  bool
+	window   time.Duration
+	maxBytes int
+	policies []*config.TailSamplerPolicy
+	statsd   statsd.ClientInterface
+
+	mu     sync.Mutex
+	traces map[uint64]*tailTrace
+	// order holds the traces sorted by their first chunk time.
+	order *list.List
+	size  int
+
+	exit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewTailSampler returns a TailSampler configured from conf.
+func NewTailSampler(conf *config.AgentConfig, statsd statsd.ClientInterface) *TailSampler {
+	if conf.TailSamplerEnabled && conf.ErrorTrackingStandalone {
+		log.Warn("The tail sampler is disabled in error tracking standalone mode, only the chunks kept by the error sampler are sent")
+	}
+	s := &TailSampler{
+		enabled:  conf.TailSamplerEnabled && !conf.ErrorTrackingStandalone,
+		window:   conf.TailSamplerWindow,
+		maxBytes: conf.TailSamplerMaxBufferBytes,
+		statsd:   statsd,
+		traces:   make(map[uint64]*tailTrace),
+		order:    list.New(),
+		exit:     make(chan struct{}),
+	}
+	for i, p := range conf.TailSamplerPolicies {
+		if p.Service == "" && p.Resource == "" && p.MinDurationMs <= 0 && !p.Error && len(p.Tags) == 0 {
+			log.Warnf("Ignoring tail sampler policy %q without condition, it would keep all the traces", p.Name)
+			continue
+		}
+		if p.Name == "" {
+			p.Name = fmt.Sprintf("policy_%d", i)
+		}
+		s.policies = append(s.policies, p)
+	}
+	return s
+}
+
+// Enabled returns true if the tail sampler is enabled.
+func (s *TailSampler) Enabled() bool {
+	return s != nil && s.enabled
+}
+
+// Start starts the removal of the expired traces.
+func (s *TailSampler) Start() {
+	if !s.enabled {
+		return
+	}
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		t := time.NewTicker(tailSamplerFlushPeriod)
+		defer t.Stop()
+		for {
+			select {
+			case now := <-t.C:
+				s.flush(now)
+			case <-s.exit:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops the tail sampler, the held chunks are dropped.
+func (s *TailSampler) Stop() {
+	if !s.enabled {
+		return
+	}
+	close(s.exit)
+	s.wg.Wait()
+}
+
+// Sample runs the policies on a chunk, after it went through the other
+// samplers. It returns true if the chunk must be kept, along with the chunks
+// of its trace held so far, which must be kept too.
+// The chunks dropped by the other samplers are held until the end of the
+// window of their trace: the spans of chunk must not be modified afterwards.
+// The chunks dropped by the user, with a negative priority, are neither held
+// nor kept. The written spans of a dropped chunk, kept by single span sampling
+// or as analytics events, are not held so that they are not sent twice.
+func (s *TailSampler) Sample(now time.Time, chunk *pb.TraceChunk, tp *pb.TracerPayload, priority SamplingPriority, sampled bool, written []*pb.Span) (keep bool, released []TailSampledChunk) {
+	if !s.enabled || len(chunk.Spans) == 0 || priority < 0 {
+		return sampled, nil
+	}
+	traceID := chunk.Spans[0].TraceID
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.traces[traceID]
+	if ok && t.policy != "" {
+		if !sampled {
+			s.keep(t.policy, chunk)
+		}
+		return true, nil
+	}
+
+	if policy := s.match(chunk.Spans); policy != "" {
+		if !ok {
+			t = s.add(traceID, now)
+		}
+		t.policy = policy
+		released = t.chunks
+		for _, c := range released {
+			s.keep(policy, c.Chunk)
+		}
+		s.size -= t.size
+		t.chunks, t.size = nil, 0
+		if !sampled {
+			s.keep(policy, chunk)
+		}
+		return true, released
+	}
+
+	if sampled {
+		return true, nil
+	}
+	if len(written) > 0 {
+		chunk = withoutSpans(chunk, written)
+		if len(chunk.Spans) == 0 {
+			return false, nil
+		}
+	}
+	if !ok {
+		t = s.add(traceID, now)
+	}
+	// Cut(0) copies the metadata of the payload without its chunks
+	metadata := tp.Cut(0)
+	metadata.Chunks = nil
+	size := chunk.Msgsize()
+	t.chunks = append(t.chunks, TailSampledChunk{Chunk: chunk, TracerPayload: metadata})
+	t.size += size
+	s.size += size
+	s.evict()
+	return false, nil
+}
+
+// withoutSpans returns a copy of chunk without the given spans.
+func withoutSpans(chunk *pb.TraceChunk, spans []*pb.Span) *pb.TraceChunk {
+	ids := make(map[uint64]struct{}, len(spans))
+	for _, span := range spans {
+		ids[span.SpanID] = struct{}{}
+	}
+	held := chunk.ShallowCopy()
+	held.Spans = make([]*pb.Span, 0, len(chunk.Spans))
+	for _, span := range chunk.Spans {
+		if _, ok := ids[span.SpanID]; !ok {
+			held.Spans = append(held.Spans, span)
+		}
+	}
+	return held
+}
+
+// match returns the name of the first policy matched by a span, or an empty
+// string if no span matches a policy.
+func (s *TailSampler) match(spans []*pb.Span) string {
+	for _, p := range s.policies {
+		for _, span := range spans {
+			if matchPolicy(p, span) {
+				return p.Name
+			}
+		}
+	}
+	return ""
+}
+
+func matchPolicy(p *config.TailSamplerPolicy, span *pb.Span) bool {
+	if p.Service != "" && span.Service != p.Service {
+		return false
+	}
+	if p.Resource != "" && span.Resource != p.Resource {
+		return false
+	}
+	if p.MinDurationMs > 0 && float64(span.Duration) < p.MinDurationMs*float64(time.Millisecond) {
+		return false
+	}
+	if p.Error && span.Error == 0 {
+		return false
+	}
+	for k, v := range p.Tags {
+		if span.Meta[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// keep marks a chunk as kept by a policy.
+func (s *TailSampler) keep(policy string, chunk *pb.TraceChunk) {
+	chunk.DroppedTrace = false
+	if chunk.Tags == nil {
+		chunk.Tags = make(map[string]string)
+	}
+	chunk.Tags[tailSamplerPolicyKey] = policy
+	_ = s.statsd.Count(MetricsTailSamplerKept, 1, []string{"policy:" + policy}, 1)
+}
+
+func (s *TailSampler) add(traceID uint64, now time.Time) *tailTrace {
+	t := &tailTrace{id: traceID, firstSeen: now}
+	t.elem = s.order.PushBack(t)
+	s.traces[traceID] = t
+	s.size += tailTraceOverhead
+	return t
+}
+
+func (s *TailSampler) remove(t *tailTrace) {
+	s.order.Remove(t.elem)
+	delete(s.traces, t.id)
+	s.size -= t.size + tailTraceOverhead
+}
+
+// evict removes the oldest traces until the buffer fits in its maximum size.
+func (s *TailSampler) evict() {
+	for s.size > s.maxBytes && s.order.Len() > 0 {
+		s.remove(s.order.Front().Value.(*tailTrace))
+		_ = s.statsd.Count(MetricsTailSamplerEvicted, 1, []string{"reason:memory"}, 1)
+	}
+}
+
+// flush removes the traces whose window ended and reports the size of the buffer.
+func (s *TailSampler) flush(now time.Time) {
+	s.mu.Lock()
+	var expired int64
+	for s.order.Len() > 0 {
+		t := s.order.Front().Value.(*tailTrace)
+		if now.Sub(t.firstSeen) < s.window {
+			break
+		}
+		if len(t.chunks) > 0 {
+			expired++
+		}
+		s.remove(t)
+	}
+	traces, size := len(s.traces), s.size
+	s.mu.Unlock()
+
+	if expired > 0 {
+		_ = s.statsd.Count(MetricsTailSamplerExpired, expired, nil, 1)
+	}
+	_ = s.statsd.Gauge(MetricsTailSamplerTraces, float64(traces), nil, 1)
+	_ = s.statsd.Gauge(MetricsTailSamplerBytes, float64(size), nil, 1)
+}
@@ -0,0 +1,209 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package sampler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	pb "github.com/DataDog/datadog-agent/pkg/proto/pbgo/trace"
+	"github.com/DataDog/datadog-agent/pkg/trace/config"
+	"github.com/DataDog/datadog-go/v5/statsd"
+)
+
+func newTestTailSampler(policies ...*config.TailSamplerPolicy) *TailSampler {
+	conf := config.New()
+	conf.TailSamplerEnabled = true
+	conf.TailSamplerPolicies = policies
+	return NewTailSampler(conf, &statsd.NoOpClient{})
+}
+
+func tailChunk(traceID uint64, spans ...*pb.Span) *pb.TraceChunk {
+	for _, span := range spans {
+		span.TraceID = traceID
+	}
+	return &pb.TraceChunk{Spans: spans, Tags: map[string]string{}, DroppedTrace: true}
+}
+
+func TestTailSamplerReleasesHeldChunks(t *testing.T) {
+	s := newTestTailSampler(&config.TailSamplerPolicy{Name: "slow-db", Service: "db", MinDurationMs: 100})
+	now := time.Now()
+	tp := &pb.TracerPayload{Env: "prod", ContainerID: "cid"}
+
+	first := tailChunk(1, &pb.Span{Service: "web", Duration: int64(time.Second)})
+	keep, released := s.Sample(now, first, tp, PriorityAutoDrop, false, nil)
+	assert.False(t, keep)
+	assert.Empty(t, released)
+
+	// fast spans of the db service do not match the policy
+	keep, _ = s.Sample(now, tailChunk(1, &pb.Span{Service: "db", Duration: int64(time.Millisecond)}), tp, PriorityAutoDrop, false, nil)
+	assert.False(t, keep)
+
+	last := tailChunk(1, &pb.Span{Service: "db", Duration: int64(200 * time.Millisecond)})
+	keep, released = s.Sample(now, last, tp, PriorityAutoDrop, false, nil)
+	assert.True(t, keep)
+	require.Len(t, released, 2)
+	assert.Same(t, first, released[0].Chunk)
+	assert.False(t, first.DroppedTrace)
+	assert.Equal(t, "slow-db", first.Tags[tailSamplerPolicyKey])
+	assert.Equal(t, "slow-db", last.Tags[tailSamplerPolicyKey])
+	assert.Equal(t, "prod", released[0].TracerPayload.Env)
+	assert.Equal(t, "cid", released[0].TracerPayload.ContainerID)
+	assert.Empty(t, released[0].TracerPayload.Chunks)
+	assert.Equal(t, tailTraceOverhead, s.size)
+
+	// the next chunks of the selected trace are kept
+	next := tailChunk(1, &pb.Span{Service: "web"})
+	keep, released = s.Sample(now, next, tp, PriorityAutoDrop, false, nil)
+	assert.True(t, keep)
+	assert.Empty(t, released)
+	assert.Equal(t, "slow-db", next.Tags[tailSamplerPolicyKey])
+
+	// other traces are not affected
+	keep, _ = s.Sample(now, tailChunk(2, &pb.Span{Service: "web"}), tp, PriorityAutoDrop, false, nil)
+	assert.False(t, keep)
+
+	// chunks kept by the other samplers are not held
+	sampled := tailChunk(3, &pb.Span{Service: "web"})
+	keep, _ = s.Sample(now, sampled, tp, PriorityAutoKeep, true, nil)
+	assert.True(t, keep)
+	assert.NotContains(t, s.traces, uint64(3))
+	assert.NotContains(t, sampled.Tags, tailSamplerPolicyKey)
+}
+
+func TestTailSamplerUserDrop(t *testing.T) {
+	s := newTestTailSampler(&config.TailSamplerPolicy{Name: "errors", Error: true})
+	now := time.Now()
+	tp := &pb.TracerPayload{}
+
+	// the chunks dropped by the user are not held
+	keep, _ := s.Sample(now, tailChunk(1, &pb.Span{}), tp, PriorityUserDrop, false, nil)
+	assert.False(t, keep)
+	assert.Empty(t, s.traces)
+
+	// nor kept when they match a policy
+	dropped := tailChunk(1, &pb.Span{Error: 1})
+	keep, _ = s.Sample(now, dropped, tp, PriorityUserDrop, false, nil)
+	assert.False(t, keep)
+	assert.True(t, dropped.DroppedTrace)
+	assert.Empty(t, s.traces)
+
+	// even once their trace is selected
+	keep, _ = s.Sample(now, tailChunk(1, &pb.Span{Error: 1}), tp, PriorityAutoDrop, false, nil)
+	assert.True(t, keep)
+	keep, _ = s.Sample(now, tailChunk(1, &pb.Span{}), tp, PriorityUserDrop, false, nil)
+	assert.False(t, keep)
+}
+
+func TestTailSamplerWrittenSpans(t *testing.T) {
+	s := newTestTailSampler(&config.TailSamplerPolicy{Name: "errors", Error: true})
+	now := time.Now()
+	tp := &pb.TracerPayload{}
+
+	// the written spans of a dropped chunk are not held
+	written := &pb.Span{SpanID: 1}
+	chunk := tailChunk(1, written, &pb.Span{SpanID: 2})
+	keep, _ := s.Sample(now, chunk, tp, PriorityAutoDrop, false, []*pb.Span{written})
+	assert.False(t, keep)
+	require.Len(t, chunk.Spans, 2)
+
+	keep, released := s.Sample(now, tailChunk(1, &pb.Span{SpanID: 3, Error: 1}), tp, PriorityAutoDrop, false, nil)
+	assert.True(t, keep)
+	require.Len(t, released, 1)
+	require.Len(t, released[0].Chunk.Spans, 1)
+	assert.EqualValues(t, 2, released[0].Chunk.Spans[0].SpanID)
+
+	// the chunks whose spans were all written are not held
+	only := &pb.Span{SpanID: 4}
+	s.Sample(now, tailChunk(2, only), tp, PriorityAutoDrop, false, []*pb.Span{only})
+	assert.NotContains(t, s.traces, uint64(2))
+}
+
+func TestTailSamplerErrorTrackingStandalone(t *testing.T) {
+	conf := config.New()
+	conf.TailSamplerEnabled = true
+	conf.ErrorTrackingStandalone = true
+	conf.TailSamplerPolicies = []*config.TailSamplerPolicy{{Name: "errors", Error: true}}
+	s := NewTailSampler(conf, &statsd.NoOpClient{})
+	assert.False(t, s.Enabled())
+
+	// the chunks rejected by the errors sampler stay dropped
+	keep, _ := s.Sample(time.Now(), tailChunk(1, &pb.Span{Error: 1}), &pb.TracerPayload{}, PriorityAutoDrop, false, nil)
+	assert.False(t, keep)
+}
+
+func TestTailSamplerPolicies(t *testing.T) {
+	span := &pb.Span{Service: "web", Resource: "GET /", Duration: int64(time.Second), Error: 1, Meta: map[string]string{"tier": "gold"}}
+	for _, tc := range []struct {
+		policy config.TailSamplerPolicy
+		match  bool
+	}{
+		{config.TailSamplerPolicy{Service: "web"}, true},
+		{config.TailSamplerPolicy{Service: "db"}, false},
+		{config.TailSamplerPolicy{Service: "web", Resource: "GET /"}, true},
+		{config.TailSamplerPolicy{Resource: "POST /"}, false},
+		{config.TailSamplerPolicy{MinDurationMs: 1000}, true},
+		{config.TailSamplerPolicy{MinDurationMs: 1001}, false},
+		{config.TailSamplerPolicy{Error: true}, true},
+		{config.TailSamplerPolicy{Tags: map[string]string{"tier": "gold"}}, true},
+		{config.TailSamplerPolicy{Tags: map[string]string{"tier": "silver"}}, false},
+		{config.TailSamplerPolicy{Service: "web", Tags: map[string]string{"region": "eu"}}, false},
+	} {
+		assert.Equal(t, tc.match, matchPolicy(&tc.policy, span), "%+v", tc.policy)
+	}
+
+	// policies without condition are ignored, unnamed policies get a name
+	s := newTestTailSampler(&config.TailSamplerPolicy{Name: "all"}, &config.TailSamplerPolicy{Error: true})
+	require.Len(t, s.policies, 1)
+	assert.Equal(t, "policy_1", s.policies[0].Name)
+}
+
+func TestTailSamplerExpiration(t *testing.T) {
+	s := newTestTailSampler(&config.TailSamplerPolicy{Name: "errors", Error: true})
+	now := time.Now()
+	tp := &pb.TracerPayload{}
+
+	s.Sample(now, tailChunk(1, &pb.Span{}), tp, PriorityAutoDrop, false, nil)
+	s.Sample(now.Add(s.window/2), tailChunk(2, &pb.Span{}), tp, PriorityAutoDrop, false, nil)
+	s.flush(now.Add(s.window))
+	assert.NotContains(t, s.traces, uint64(1))
+	assert.Contains(t, s.traces, uint64(2))
+
+	// the chunks of an expired trace are not released
+	keep, released := s.Sample(now.Add(s.window), tailChunk(1, &pb.Span{Error: 1}), tp, PriorityAutoDrop, false, nil)
+	assert.True(t, keep)
+	assert.Empty(t, released)
+}
+
+func TestTailSamplerEviction(t *testing.T) {
+	s := newTestTailSampler(&config.TailSamplerPolicy{Name: "errors", Error: true})
+	now := time.Now()
+	tp := &pb.TracerPayload{}
+	chunk := tailChunk(1, &pb.Span{Service: "web"})
+	s.maxBytes = 2 * (chunk.Msgsize() + tailTraceOverhead)
+
+	s.Sample(now, chunk, tp, PriorityAutoDrop, false, nil)
+	s.Sample(now, tailChunk(2, &pb.Span{Service: "web"}), tp, PriorityAutoDrop, false, nil)
+	assert.Len(t, s.traces, 2)
+
+	// the oldest trace is evicted
+	s.Sample(now, tailChunk(3, &pb.Span{Service: "web"}), tp, PriorityAutoDrop, false, nil)
+	assert.Len(t, s.traces, 2)
+	assert.NotContains(t, s.traces, uint64(1))
+	assert.LessOrEqual(t, s.size, s.maxBytes)
+}
+
+func TestTailSamplerDisabled(t *testing.T) {
+	s := NewTailSampler(config.New(), &statsd.NoOpClient{})
+	assert.False(t, s.Enabled())
+	keep, released := s.Sample(time.Now(), tailChunk(1, &pb.Span{}), &pb.TracerPayload{}, PriorityAutoDrop, false, nil)
+	assert.False(t, keep)
+	assert.Empty(t, released)
+	assert.Empty(t, s.traces)
+}
@@ -47,6 +47,8 @@ type Concentrator struct {
 	agentVersion  string
 	statsd        statsd.ClientInterface
 	peerTagKeys   []string
+	// spanMetrics is nil if the span-derived metrics are disabled.
+	spanMetrics *spanMetrics
 }
 
 // NewConcentrator initializes a new concentrator ready to be started
@@ -70,6 +72,7 @@ func NewConcentrator(conf *config.AgentConfig, writer Writer, now time.Time, sta
 		statsd:           statsd,
 		bsize:            bsize,
 		peerTagKeys:      conf.ConfiguredPeerTags(),
+		spanMetrics:      newSpanMetrics(conf, now, statsd),
 	}
 	return &c
 }
@@ -179,6 +182,9 @@ func (c *Concentrator) addNow(pt *traceutil.ProcessedTrace, tags infraTags) {
 		statSpan, ok := c.spanConcentrator.NewStatSpanFromPB(s, c.peerTagKeys)
 		if ok {
 			c.spanConcentrator.addSpan(statSpan, aggKey, tags, pt.TraceChunk.Origin, weight)
+			if c.spanMetrics != nil {
+				c.spanMetrics.add(statSpan, env, s.Meta, weight)
+			}
 		}
 	}
 }
@@ -191,6 +197,9 @@ func (c *Concentrator) Flush(force bool) *pb.StatsPayload {
 
 func (c *Concentrator) flushNow(now int64, force bool) *pb.StatsPayload {
 	sb := c.spanConcentrator.Flush(now, force)
+	if c.spanMetrics != nil {
+		c.spanMetrics.flush(now, force)
+	}
 	return &pb.StatsPayload{Stats: sb, AgentHostname: c.agentHostname, AgentEnv: c.agentEnv, AgentVersion: c.agentVersion}
 }
 
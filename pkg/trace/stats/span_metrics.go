@@ -0,0 +1,225 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package stats
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/DataDog/datadog-agent/pkg/trace/config"
+	"github.com/DataDog/datadog-agent/pkg/trace/log"
+	"github.com/DataDog/datadog-agent/pkg/trace/traceutil/normalize"
+
+	"github.com/DataDog/datadog-go/v5/statsd"
+	"github.com/DataDog/sketches-go/ddsketch"
+)
+
+const (
+	// spanMetricsPrefix prefixes the names of the span-derived metrics.
+	spanMetricsPrefix = "apm.span_derived."
+	// spanMetricsDroppedContexts is the name of the metric counting the spans
+	// ignored because their bucket reached the maximum number of contexts.
+	spanMetricsDroppedContexts = "datadog.trace_agent.stats.span_derived_metrics.dropped"
+	// spanMetricsRelativeAccuracy is the relative accuracy of the sketches of
+	// the durations, coarse so that they have few bins.
+	spanMetricsRelativeAccuracy = 0.1
+	// spanMetricsMaxBins is the maximum number of bins of the sketches of the
+	// durations, the lowest ones are collapsed beyond it.
+	spanMetricsMaxBins = 64
+)
+
+// spanMetricsKey is the set of tags of a span-derived metric.
+type spanMetricsKey struct {
+	env      string
+	service  string
+	name     string
+	resource string
+	// dimensions holds the tags of the configured dimensions, joined by commas.
+	dimensions string
+}
+
+type spanMetricsStats struct {
+	// tags holds the tags of the metrics of the key.
+	tags   []string
+	hits   float64
+	errors float64
+	// duration is the sum of the durations in nanoseconds, errors included.
+	duration float64
+	// durations is the sketch of the durations in nanoseconds, errors included.
+	durations *ddsketch.DDSketch
+}
+
+// spanMetrics computes the hits, errors and latency of the spans the
+// Concentrator computes stats for, aggregated on user-configured dimensions on
+// top of the env, service, name and resource. They are sent as DogStatsD
+// counts, timestamped with the start of their bucket, as the buckets of the
+// Concentrator are flushed. The latency is sent as the sum of the durations,
+// whose ratio to the hits is the average, and as the weighted counts of the
+// bins of a sketch of the durations, tagged with the upper bound of their bin,
+// for the percentiles. The spans of the tag sets over the maximum number of
+// contexts of their bucket are ignored.
+type spanMetrics struct {
+	dimensions  []string
+	maxContexts int
+	bsize       int64
+	bufferLen   int
+	statsd      statsd.ClientInterface
+
+	mu sync.Mutex
+	// oldestTs is the timestamp of the oldest bucket for which data is
+	// allowed, older spans are added to this bucket.
+	oldestTs int64
+	buckets  map[int64]map[spanMetricsKey]*spanMetricsStats
+	dropped  int64
+}
+
+// newSpanMetrics returns the spanMetrics configured from conf, or nil if they are disabled.
+func newSpanMetrics(conf *config.AgentConfig, now time.Time, statsd statsd.ClientInterface) *spanMetrics {
+	if !conf.SpanDerivedMetricsEnabled {
+		return nil
+	}
+	bsize := conf.BucketInterval.Nanoseconds()
+	return &spanMetrics{
+		dimensions:  conf.SpanDerivedMetricsDimensions,
+		maxContexts: conf.SpanDerivedMetricsMaxContexts,
+		bsize:       bsize,
+		bufferLen:   defaultBufferLen,
+		statsd:      statsd,
+		oldestTs:    alignTs(now.UnixNano(), bsize),
+		buckets:     make(map[int64]map[spanMetricsKey]*spanMetricsStats),
+	}
+}
+
+// add adds the span s, whose tags are meta, to its bucket.
+func (m *spanMetrics) add(s *StatSpan, env string, meta map[string]string, weight float64) {
+	key := spanMetricsKey{
+		env:        env,
+		service:    s.service,
+		name:       s.name,
+		resource:   s.resource,
+		dimensions: m.dimensionTags(meta),
+	}
+	end := s.start + s.duration
+	btime := max(end-end%m.bsize, m.oldestTs)
+
+	m.mu.Lock()
+	b, ok := m.buckets[btime]
+	if !ok {
+		b = make(map[spanMetricsKey]*spanMetricsStats)
+		m.buckets[btime] = b
+	}
+	st, ok := b[key]
+	if !ok {
+		if m.maxContexts > 0 && len(b) >= m.maxContexts {
+			m.dropped++
+			m.mu.Unlock()
+			return
+		}
+		durations, err := ddsketch.LogCollapsingLowestDenseDDSketch(spanMetricsRelativeAccuracy, spanMetricsMaxBins)
+		if err != nil {
+			log.Errorf("Error when creating ddsketch: %v", err)
+			m.mu.Unlock()
+			return
+		}
+		st = &spanMetricsStats{tags: key.tags(), durations: durations}
+		b[key] = st
+	}
+	st.hits += weight
+	if s.error != 0 {
+		st.errors += weight
+	}
+	st.duration += float64(s.duration) * weight
+	if err := st.durations.AddWithCount(float64(s.duration), weight); err != nil {
+		log.Debugf("Can't add the duration %d to the span-derived metrics: %v", s.duration, err)
+	}
+	m.mu.Unlock()
+}
+
+// tags returns the tags of the metrics of the key.
+func (k spanMetricsKey) tags() []string {
+	tags := []string{
+		"env:" + normalize.NormalizeTagValue(k.env),
+		"service:" + normalize.NormalizeTagValue(k.service),
+		"operation_name:" + normalize.NormalizeTagValue(k.name),
+		"resource_name:" + normalize.NormalizeTagValue(k.resource),
+	}
+	if k.dimensions != "" {
+		tags = append(tags, strings.Split(k.dimensions, ",")...)
+	}
+	return tags
+}
+
+// dimensionTags returns the tags of the configured dimensions found in meta,
+// joined by commas.
+func (m *spanMetrics) dimensionTags(meta map[string]string) string {
+	var sb strings.Builder
+	for _, k := range m.dimensions {
+		v, ok := meta[k]
+		if !ok || v == "" {
+			continue
+		}
+		if sb.Len() > 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteString(normalize.NormalizeTag(k + ":" + v))
+	}
+	return sb.String()
+}
+
+// flush sends the metrics of the buckets old enough to be flushed, or of all
+// the buckets if force is true, the same way the SpanConcentrator flushes them.
+func (m *spanMetrics) flush(now int64, force bool) {
+	m.mu.Lock()
+	flushed := make(map[int64]map[spanMetricsKey]*spanMetricsStats)
+	for ts, b := range m.buckets {
+		if !force && ts > now-int64(m.bufferLen)*m.bsize {
+			continue
+		}
+		flushed[ts] = b
+		delete(m.buckets, ts)
+	}
+	if newOldestTs := alignTs(now, m.bsize) - int64(m.bufferLen-1)*m.bsize; newOldestTs > m.oldestTs {
+		m.oldestTs = newOldestTs
+	}
+	dropped := m.dropped
+	m.dropped = 0
+	m.mu.Unlock()
+
+	for ts, b := range flushed {
+		for _, st := range b {
+			m.send(time.Unix(0, ts), st)
+		}
+	}
+	if dropped > 0 {
+		_ = m.statsd.Count(spanMetricsDroppedContexts, dropped, nil, 1)
+	}
+}
+
+func (m *spanMetrics) send(ts time.Time, st *spanMetricsStats) {
+	_ = m.statsd.CountWithTimestamp(spanMetricsPrefix+"hits", int64(round(st.hits)), st.tags, 1, ts)
+	_ = m.statsd.CountWithTimestamp(spanMetricsPrefix+"errors", int64(round(st.errors)), st.tags, 1, ts)
+	_ = m.statsd.CountWithTimestamp(spanMetricsPrefix+"duration.sum", int64(round(st.duration)), st.tags, 1, ts)
+
+	m.sendBin(ts, st, 0, st.durations.GetZeroCount())
+	st.durations.GetPositiveValueStore().ForEach(func(index int, count float64) bool {
+		m.sendBin(ts, st, st.durations.LowerBound(index+1), count)
+		return false
+	})
+}
+
+// sendBin sends the weighted count of a bin of the sketch of the durations,
+// tagged with the upper bound of the bin in nanoseconds. The bounds of the bins
+// only depend on the relative accuracy, they are the same for all the keys.
+func (m *spanMetrics) sendBin(ts time.Time, st *spanMetricsStats, upperBound, count float64) {
+	n := round(count)
+	if n == 0 {
+		return
+	}
+	tags := append(st.tags[:len(st.tags):len(st.tags)], "upper_bound:"+strconv.FormatFloat(upperBound, 'g', 3, 64))
+	_ = m.statsd.CountWithTimestamp(spanMetricsPrefix+"duration.bucket", int64(n), tags, 1, ts)
+}
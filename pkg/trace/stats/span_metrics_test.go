@@ -0,0 +1,175 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package stats
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	pb "github.com/DataDog/datadog-agent/pkg/proto/pbgo/trace"
+	"github.com/DataDog/datadog-agent/pkg/trace/config"
+	"github.com/DataDog/datadog-agent/pkg/trace/teststatsd"
+	"github.com/DataDog/datadog-agent/pkg/trace/traceutil"
+	"github.com/DataDog/sketches-go/ddsketch"
+)
+
+func newTestSpanMetricsConcentrator(now time.Time, statsd *teststatsd.Client, dimensions ...string) *Concentrator {
+	cfg := config.AgentConfig{
+		BucketInterval:                time.Duration(testBucketInterval),
+		DefaultEnv:                    "env",
+		Hostname:                      "hostname",
+		SpanDerivedMetricsEnabled:     true,
+		SpanDerivedMetricsDimensions:  dimensions,
+		SpanDerivedMetricsMaxContexts: 10000,
+	}
+	return NewConcentrator(&cfg, noopStatsWriter{}, now, statsd)
+}
+
+func TestSpanMetrics(t *testing.T) {
+	now := time.Now()
+	statsd := &teststatsd.Client{}
+	c := newTestSpanMetricsConcentrator(now, statsd, "http.route", "customer.tier")
+
+	spans := []*pb.Span{
+		testSpan(now, 1, 0, 100, 0, "web", "GET /users", 0, map[string]string{"http.route": "/users", "customer.tier": "Gold"}),
+		testSpan(now, 2, 0, 200, 0, "web", "GET /users", 0, map[string]string{"http.route": "/users", "customer.tier": "Gold"}),
+		testSpan(now, 3, 0, 300, 0, "web", "GET /users", 1, map[string]string{"http.route": "/users", "customer.tier": "Gold"}),
+		testSpan(now, 4, 0, 200, 0, "web", "GET /users", 0, map[string]string{"http.route": "/users", "customer.tier": "silver"}),
+		// the missing dimensions are not tagged
+		testSpan(now, 5, 0, 50, 0, "web", "GET /health", 0, nil),
+	}
+	traceutil.ComputeTopLevel(spans)
+	c.addNow(toProcessedTrace(spans, "prod", "", "", "", ""), infraTags{})
+
+	// the metrics are sent as their bucket is flushed
+	c.flushNow(now.UnixNano(), false)
+	assert.Empty(t, statsd.CountCalls)
+	c.flushNow(now.UnixNano()+int64(c.spanConcentrator.bufferLen)*testBucketInterval, false)
+
+	gold := []string{"env:prod", "service:web", "operation_name:query", "resource_name:get_/users", "http.route:/users", "customer.tier:gold"}
+	silver := []string{"env:prod", "service:web", "operation_name:query", "resource_name:get_/users", "http.route:/users", "customer.tier:silver"}
+	health := []string{"env:prod", "service:web", "operation_name:query", "resource_name:get_/health"}
+	ts := time.Unix(0, alignTs(now.UnixNano(), testBucketInterval))
+
+	counts := make(map[string]map[string]float64)
+	bins := make(map[string]map[string]float64)
+	for _, call := range statsd.CountCalls {
+		assert.Equal(t, ts, call.Timestamp)
+		if call.Name == "apm.span_derived.duration.bucket" {
+			key := call.Tags[len(call.Tags)-2]
+			if bins[key] == nil {
+				bins[key] = make(map[string]float64)
+			}
+			bins[key][call.Tags[len(call.Tags)-1]] = call.Value
+			continue
+		}
+		if counts[call.Name] == nil {
+			counts[call.Name] = make(map[string]float64)
+		}
+		counts[call.Name][call.Tags[len(call.Tags)-1]] = call.Value
+	}
+	assert.Equal(t, map[string]map[string]float64{
+		"apm.span_derived.hits":         {gold[5]: 3, silver[5]: 1, health[3]: 1},
+		"apm.span_derived.errors":       {gold[5]: 1, silver[5]: 0, health[3]: 0},
+		"apm.span_derived.duration.sum": {gold[5]: 600, silver[5]: 200, health[3]: 50},
+	}, counts)
+	assert.Empty(t, statsd.GaugeCalls)
+	// the durations are counted in the bins of a sketch, for their percentiles
+	assert.Equal(t, map[string]map[string]float64{
+		gold[5]:   {upperBoundTag(t, 100): 1, upperBoundTag(t, 200): 1, upperBoundTag(t, 300): 1},
+		silver[5]: {upperBoundTag(t, 200): 1},
+		health[3]: {upperBoundTag(t, 50): 1},
+	}, bins)
+	for _, call := range statsd.CountCalls {
+		if call.Name == "apm.span_derived.duration.bucket" {
+			continue
+		}
+		switch call.Tags[len(call.Tags)-1] {
+		case gold[5]:
+			assert.Equal(t, gold, call.Tags)
+		case health[3]:
+			assert.Equal(t, health, call.Tags)
+		}
+	}
+}
+
+func TestSpanMetricsMaxContexts(t *testing.T) {
+	now := time.Now()
+	statsd := &teststatsd.Client{}
+	c := newTestSpanMetricsConcentrator(now, statsd, "customer.id")
+	c.spanMetrics.maxContexts = 2
+
+	var spans []*pb.Span
+	for i, id := range []string{"1", "2", "3", "1"} {
+		spans = append(spans, testSpan(now, uint64(i+1), 0, 100, 0, "web", "GET /", 0, map[string]string{"customer.id": id}))
+	}
+	traceutil.ComputeTopLevel(spans)
+	c.addNow(toProcessedTrace(spans, "prod", "", "", "", ""), infraTags{})
+	c.flushNow(now.UnixNano(), true)
+
+	summaries := statsd.GetCountSummaries()
+	require.Contains(t, summaries, "apm.span_derived.hits")
+	assert.Len(t, summaries["apm.span_derived.hits"].Calls, 2)
+	assert.EqualValues(t, 3, summaries["apm.span_derived.hits"].Sum)
+	require.Contains(t, summaries, spanMetricsDroppedContexts)
+	assert.EqualValues(t, 1, summaries[spanMetricsDroppedContexts].Sum)
+	// the spans of the dropped contexts are not counted in the sketches
+	require.Contains(t, summaries, "apm.span_derived.duration.bucket")
+	assert.EqualValues(t, 3, summaries["apm.span_derived.duration.bucket"].Sum)
+}
+
+func TestSpanMetricsWeighted(t *testing.T) {
+	now := time.Now()
+	statsd := &teststatsd.Client{}
+	c := newTestSpanMetricsConcentrator(now, statsd)
+
+	// the spans of client-sampled traces are weighted by the inverse of the sample rate
+	span := testSpan(now, 1, 0, 100, 0, "web", "GET /", 0, nil)
+	span.Metrics = map[string]float64{"_sample_rate": 0.25}
+	spans := []*pb.Span{span}
+	traceutil.ComputeTopLevel(spans)
+	c.addNow(toProcessedTrace(spans, "prod", "", "", "", ""), infraTags{})
+	spans = []*pb.Span{testSpan(now, 2, 0, 300, 0, "web", "GET /", 0, nil)}
+	traceutil.ComputeTopLevel(spans)
+	c.addNow(toProcessedTrace(spans, "prod", "", "", "", ""), infraTags{})
+	c.flushNow(now.UnixNano(), true)
+
+	summaries := statsd.GetCountSummaries()
+	assert.EqualValues(t, 5, summaries["apm.span_derived.hits"].Sum)
+	assert.EqualValues(t, 700, summaries["apm.span_derived.duration.sum"].Sum)
+	bins := make(map[string]float64)
+	for _, call := range summaries["apm.span_derived.duration.bucket"].Calls {
+		bins[call.Tags[len(call.Tags)-1]] = call.Value
+	}
+	assert.Equal(t, map[string]float64{upperBoundTag(t, 100): 4, upperBoundTag(t, 300): 1}, bins)
+}
+
+// upperBoundTag returns the tag of the upper bound of the bin of a duration.
+func upperBoundTag(t *testing.T, duration float64) string {
+	sketch, err := ddsketch.LogCollapsingLowestDenseDDSketch(spanMetricsRelativeAccuracy, spanMetricsMaxBins)
+	require.NoError(t, err)
+	upperBound := sketch.LowerBound(sketch.Index(duration) + 1)
+	assert.Greater(t, upperBound, duration)
+	return "upper_bound:" + strconv.FormatFloat(upperBound, 'g', 3, 64)
+}
+
+func TestSpanMetricsDisabled(t *testing.T) {
+	now := time.Now()
+	statsd := &teststatsd.Client{}
+	c := NewConcentrator(&config.AgentConfig{BucketInterval: time.Duration(testBucketInterval), DefaultEnv: "env"}, noopStatsWriter{}, now, statsd)
+	assert.Nil(t, c.spanMetrics)
+
+	spans := []*pb.Span{testSpan(now, 1, 0, 100, 0, "web", "GET /", 0, nil)}
+	traceutil.ComputeTopLevel(spans)
+	c.addNow(toProcessedTrace(spans, "prod", "", "", "", ""), infraTags{})
+	c.flushNow(now.UnixNano(), true)
+	assert.Empty(t, statsd.CountCalls)
+	assert.Empty(t, statsd.GaugeCalls)
+}
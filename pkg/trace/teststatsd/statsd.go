@@ -21,6 +21,8 @@ type MetricsArgs struct {
 	Value float64
 	Tags  []string
 	Rate  float64
+	// Timestamp is set for the calls to the WithTimestamp methods.
+	Timestamp time.Time
 }
 
 // CountSummary contains a summary of all Count method calls to a particular StatsClient for a particular key.
@@ -90,6 +92,14 @@ func (c *Client) Count(name string, value int64, tags []string, rate float64) er
 	return c.CountErr
 }
 
+// CountWithTimestamp records a call to a CountWithTimestamp operation and replies with CountErr
+func (c *Client) CountWithTimestamp(name string, value int64, tags []string, rate float64, timestamp time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.CountCalls = append(c.CountCalls, MetricsArgs{Name: name, Value: float64(value), Tags: tags, Rate: rate, Timestamp: timestamp})
+	return c.CountErr
+}
+
 // Decr implements the Statsd Decr interface
 func (c *Client) Decr(name string, tags []string, rate float64) error {
 	return c.Count(name, -1, tags, rate)
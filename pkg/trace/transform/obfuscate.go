@@ -18,6 +18,8 @@ const (
 	TagValkeyRawCommand = "valkey.raw_command"
 	// TagMemcachedCommand represents a memcached command tag
 	TagMemcachedCommand = "memcached.command"
+	// TagGraphQLSource represents a GraphQL query tag
+	TagGraphQLSource = "graphql.source"
 	// TagMongoDBQuery represents a MongoDB query tag
 	TagMongoDBQuery = "mongodb.query"
 	// TagElasticBody represents an Elasticsearch body tag
@@ -0,0 +1,428 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package writer
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.opentelemetry.io/collector/pdata/ptrace/ptraceotlp"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/DataDog/datadog-agent/pkg/proto/pbgo/trace"
+	"github.com/DataDog/datadog-agent/pkg/trace/config"
+	"github.com/DataDog/datadog-agent/pkg/trace/info"
+	"github.com/DataDog/datadog-agent/pkg/trace/log"
+	"github.com/DataDog/datadog-agent/pkg/trace/timing"
+
+	"github.com/DataDog/datadog-go/v5/statsd"
+)
+
+// otlpExporter exports OTLP traces to an endpoint.
+type otlpExporter interface {
+	// export sends the request, returning a retriableError if it may be retried.
+	export(ctx context.Context, req ptraceotlp.ExportRequest) error
+	// close releases the connections of the exporter.
+	close()
+}
+
+// newOTLPExporter returns the exporter of the protocol configured in cfg.
+func newOTLPExporter(cfg *config.AgentConfig) (otlpExporter, error) {
+	ecfg := cfg.OTLPExporter
+	if ecfg.Endpoint == "" {
+		return nil, errors.New("no OTLP export endpoint configured")
+	}
+	switch ecfg.Protocol {
+	case config.OTLPExportProtocolGRPC, "":
+		return newOTLPGRPCExporter(&ecfg, cfg.SkipSSLValidation)
+	case config.OTLPExportProtocolHTTP:
+		return newOTLPHTTPExporter(&ecfg, cfg.NewHTTPClient()), nil
+	default:
+		return nil, fmt.Errorf("unknown OTLP export protocol %q, expected %q or %q", ecfg.Protocol, config.OTLPExportProtocolGRPC, config.OTLPExportProtocolHTTP)
+	}
+}
+
+// otlpGRPCExporter exports traces with OTLP/gRPC.
+type otlpGRPCExporter struct {
+	conn   *grpc.ClientConn
+	client ptraceotlp.GRPCClient
+	md     metadata.MD
+}
+
+func newOTLPGRPCExporter(cfg *config.OTLPExporter, skipSSLValidation bool) (*otlpGRPCExporter, error) {
+	creds := insecure.NewCredentials()
+	if !cfg.Insecure {
+		creds = credentials.NewTLS(&tls.Config{InsecureSkipVerify: skipSSLValidation})
+	}
+	conn, err := grpc.NewClient(cfg.Endpoint, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		return nil, err
+	}
+	return &otlpGRPCExporter{
+		conn:   conn,
+		client: ptraceotlp.NewGRPCClient(conn),
+		md:     metadata.New(cfg.Headers),
+	}, nil
+}
+
+// export implements otlpExporter.
+func (e *otlpGRPCExporter) export(ctx context.Context, req ptraceotlp.ExportRequest) error {
+	resp, err := e.client.Export(metadata.NewOutgoingContext(ctx, e.md), req)
+	if err != nil {
+		switch status.Code(err) {
+		case codes.Canceled, codes.DeadlineExceeded, codes.Aborted, codes.OutOfRange,
+			codes.Unavailable, codes.DataLoss, codes.ResourceExhausted:
+			return &retriableError{err}
+		}
+		return err
+	}
+	logPartialSuccess(resp)
+	return nil
+}
+
+// close implements otlpExporter.
+func (e *otlpGRPCExporter) close() {
+	if err := e.conn.Close(); err != nil {
+		log.Debugf("Error closing OTLP gRPC connection: %v", err)
+	}
+}
+
+// otlpHTTPExporter exports traces with OTLP/HTTP, encoded as protobuf.
+type otlpHTTPExporter struct {
+	url     string
+	headers map[string]string
+	client  *config.ResetClient
+}
+
+func newOTLPHTTPExporter(cfg *config.OTLPExporter, client *config.ResetClient) *otlpHTTPExporter {
+	return &otlpHTTPExporter{
+		url:     cfg.Endpoint,
+		headers: cfg.Headers,
+		client:  client,
+	}
+}
+
+// export implements otlpExporter.
+func (e *otlpHTTPExporter) export(ctx context.Context, req ptraceotlp.ExportRequest) error {
+	body, err := req.MarshalProto()
+	if err != nil {
+		return err
+	}
+	hreq, err := http.NewRequestWithContext(ctx, http.MethodPost, e.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for k, v := range e.headers {
+		hreq.Header.Set(k, v)
+	}
+	hreq.Header.Set("Content-Type", "application/x-protobuf")
+	hresp, err := e.client.Do(hreq)
+	if err != nil {
+		return &retriableError{err}
+	}
+	defer hresp.Body.Close()
+	data, err := io.ReadAll(hresp.Body)
+	if err != nil {
+		log.Debugf("Error reading OTLP export response: %v", err)
+	}
+	switch code := hresp.StatusCode; {
+	case code == http.StatusTooManyRequests || code == http.StatusBadGateway ||
+		code == http.StatusServiceUnavailable || code == http.StatusGatewayTimeout:
+		return &retriableError{fmt.Errorf("server responded with %q", hresp.Status)}
+	case code/100 != 2:
+		return errors.New(hresp.Status)
+	}
+	resp := ptraceotlp.NewExportResponse()
+	if len(data) > 0 && hresp.Header.Get("Content-Type") == "application/x-protobuf" {
+		if err := resp.UnmarshalProto(data); err != nil {
+			log.Debugf("Error decoding OTLP export response: %v", err)
+		}
+	}
+	logPartialSuccess(resp)
+	return nil
+}
+
+// close implements otlpExporter.
+func (e *otlpHTTPExporter) close() {}
+
+// logPartialSuccess logs the spans rejected by the endpoint, if any.
+func logPartialSuccess(resp ptraceotlp.ExportResponse) {
+	if ps := resp.PartialSuccess(); ps.RejectedSpans() > 0 || ps.ErrorMessage() != "" {
+		log.Warnf("OTLP export endpoint rejected %d spans: %s", ps.RejectedSpans(), ps.ErrorMessage())
+	}
+}
+
+// OTLPTraceWriter implements TraceWriter. It buffers the sampled chunks, converts them to
+// OTLP traces and exports them to the OTLP endpoint of the configuration, instead of
+// sending them to Datadog.
+type OTLPTraceWriter struct {
+	exporter   otlpExporter
+	timeout    time.Duration
+	maxRetries int
+
+	flushTicker *time.Ticker
+	tick        time.Duration // flush frequency
+	hostname    string
+	env         string
+	queue       chan ptrace.Traces // traces waiting to be exported
+	stop        chan struct{}
+	wg          sync.WaitGroup // waits flusher + reporter
+	exporters   sync.WaitGroup // waits the routines exporting the queue
+
+	stats           *info.TraceWriterInfo
+	statsLastMinute *info.TraceWriterInfo // aggregated stats over the last minute. Shared with info package
+
+	mu             sync.Mutex          // guards below
+	tracerPayloads []*pb.TracerPayload // tracer payloads buffered
+	bufferedSize   int                 // estimated buffer size
+	stopped        bool                // reports whether the queue is closed
+
+	// syncMode reports whether the writer should flush on its own or only when FlushSync is called
+	syncMode bool
+
+	easylog *log.ThrottledLogger
+	statsd  statsd.ClientInterface
+	timing  timing.Reporter
+}
+
+// NewOTLPTraceWriter returns a new OTLPTraceWriter exporting the traces to the OTLP endpoint
+// of cfg.
+func NewOTLPTraceWriter(cfg *config.AgentConfig, statsd statsd.ClientInterface, timing timing.Reporter) (*OTLPTraceWriter, error) {
+	exporter, err := newOTLPExporter(cfg)
+	if err != nil {
+		return nil, err
+	}
+	climit := cfg.TraceWriter.ConnectionLimit
+	if climit == 0 {
+		climit = defaultConnectionLimit
+	}
+	w := &OTLPTraceWriter{
+		exporter:        exporter,
+		timeout:         cfg.OTLPExporter.Timeout,
+		maxRetries:      cfg.MaxSenderRetries,
+		tick:            5 * time.Second,
+		hostname:        cfg.Hostname,
+		env:             cfg.DefaultEnv,
+		queue:           make(chan ptrace.Traces, climit),
+		stop:            make(chan struct{}),
+		stats:           &info.TraceWriterInfo{},
+		statsLastMinute: &info.TraceWriterInfo{},
+		syncMode:        cfg.SynchronousFlushing,
+		easylog:         log.NewThrottled(5, 10*time.Second), // no more than 5 messages every 10 seconds
+		statsd:          statsd,
+		timing:          timing,
+	}
+	if s := cfg.TraceWriter.FlushPeriodSeconds; s != 0 {
+		w.tick = time.Duration(s*1000) * time.Millisecond
+	}
+	w.flushTicker = time.NewTicker(w.tick)
+
+	log.Infof("OTLP trace writer initialized (protocol=%s endpoint=%s climit=%d)", cfg.OTLPExporter.Protocol, cfg.OTLPExporter.Endpoint, climit)
+	for i := 0; i < climit; i++ {
+		w.exporters.Add(1)
+		go w.exportQueue()
+	}
+	w.wg.Add(1)
+	go w.timeFlush()
+	w.wg.Add(1)
+	go w.reporter()
+	return w, nil
+}
+
+// UpdateAPIKey implements TraceWriter. The Datadog API keys are not used by the OTLP endpoint.
+func (w *OTLPTraceWriter) UpdateAPIKey(_, _ string) {}
+
+func (w *OTLPTraceWriter) reporter() {
+	tck := time.NewTicker(w.tick)
+	info.UpdateTraceWriterInfo(w.statsLastMinute)
+	var lastReset time.Time
+	defer tck.Stop()
+	defer w.wg.Done()
+	for {
+		select {
+		case now := <-tck.C:
+			if now.Sub(lastReset) >= time.Minute {
+				w.statsLastMinute.Reset()
+				lastReset = now
+			}
+			w.report()
+		case <-w.stop:
+			return
+		}
+	}
+}
+
+func (w *OTLPTraceWriter) timeFlush() {
+	defer w.wg.Done()
+	for {
+		select {
+		case <-w.flushTicker.C:
+			w.flush()
+		case <-w.stop:
+			return
+		}
+	}
+}
+
+// Stop stops the OTLPTraceWriter and attempts to export whatever is left in its buffer.
+func (w *OTLPTraceWriter) Stop() {
+	log.Debug("Exiting OTLP trace writer. Trying to flush whatever is left...")
+	close(w.stop)
+	w.wg.Wait()
+	w.mu.Lock()
+	w.flushPayloads(w.tracerPayloads)
+	w.resetBuffer()
+	w.stopped = true
+	close(w.queue)
+	w.mu.Unlock()
+	w.exporters.Wait()
+	w.exporter.close()
+	w.flushTicker.Stop()
+	w.report()
+}
+
+// FlushSync blocks and exports pending traces when syncMode is true
+func (w *OTLPTraceWriter) FlushSync() error {
+	if !w.syncMode {
+		return errors.New("not flushing; sync mode not enabled")
+	}
+	defer w.report()
+
+	w.flush()
+	return nil
+}
+
+// WriteChunks buffers the provided chunks, exporting them once the buffer is full.
+func (w *OTLPTraceWriter) WriteChunks(pkg *SampledChunks) {
+	w.stats.Spans.Add(pkg.SpanCount)
+	w.stats.Traces.Add(int64(len(pkg.TracerPayload.Chunks)))
+	w.stats.Events.Add(pkg.EventCount)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if pkg.Size+w.bufferedSize > MaxPayloadSize {
+		// reached maximum allowed buffered size
+		w.flushPayloads(w.tracerPayloads)
+		w.resetBuffer()
+	}
+	if len(pkg.TracerPayload.Chunks) > 0 {
+		w.tracerPayloads = append(w.tracerPayloads, pkg.TracerPayload)
+	}
+	w.bufferedSize += pkg.Size
+}
+
+func (w *OTLPTraceWriter) resetBuffer() {
+	w.bufferedSize = 0
+	w.tracerPayloads = make([]*pb.TracerPayload, 0, len(w.tracerPayloads))
+}
+
+func (w *OTLPTraceWriter) flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	defer w.resetBuffer()
+	w.flushPayloads(w.tracerPayloads)
+}
+
+// flushPayloads converts the payloads to OTLP traces, and exports them synchronously in sync
+// mode, or queues them otherwise. w must be locked.
+func (w *OTLPTraceWriter) flushPayloads(payloads []*pb.TracerPayload) {
+	w.flushTicker.Reset(w.tick) // reset the flush timer whenever we flush
+	if len(payloads) == 0 {
+		return
+	}
+	if w.stopped {
+		w.easylog.Warn("OTLP trace writer is stopped, dropping %d tracer payloads.", len(payloads))
+		return
+	}
+	start := time.Now()
+	traces := toOTLPTraces(w.hostname, w.env, payloads)
+	w.timing.Since("datadog.trace_agent.trace_writer.encode_ms", start)
+
+	if w.syncMode {
+		w.export(traces)
+		return
+	}
+	select {
+	case w.queue <- traces:
+	default:
+		w.easylog.Warn("OTLP export queue is full, dropping %d spans.", traces.SpanCount())
+		_ = w.statsd.Count("datadog.trace_agent.trace_writer.dropped", 1, nil, 1)
+	}
+}
+
+// exportQueue exports the queued traces until the queue is closed.
+func (w *OTLPTraceWriter) exportQueue() {
+	defer w.exporters.Done()
+	for traces := range w.queue {
+		w.export(traces)
+	}
+}
+
+// export exports traces, retrying up to maxRetries times on retriable errors.
+func (w *OTLPTraceWriter) export(traces ptrace.Traces) {
+	req := ptraceotlp.NewExportRequestFromTraces(traces)
+	size := (&ptrace.ProtoMarshaler{}).TracesSize(traces)
+	w.stats.BytesUncompressed.Add(int64(size))
+	for attempt := 0; ; attempt++ {
+		time.Sleep(backoffDuration(attempt))
+		start := time.Now()
+		err := w.exportOnce(req)
+		if err == nil {
+			log.Debugf("Exported traces to the OTLP endpoint; time: %s, bytes: %d", time.Since(start), size)
+			w.timing.Since("datadog.trace_agent.trace_writer.flush_duration", start)
+			w.stats.Bytes.Add(int64(size))
+			w.stats.Payloads.Inc()
+			return
+		}
+		var rerr *retriableError
+		if errors.As(err, &rerr) && attempt < w.maxRetries {
+			log.Debugf("Retrying to export traces to the OTLP endpoint; error: %v", err)
+			w.stats.Retries.Inc()
+			continue
+		}
+		w.easylog.Warn("Error exporting %d spans to the OTLP endpoint, dropping them: %v", traces.SpanCount(), err)
+		w.stats.Errors.Inc()
+		return
+	}
+}
+
+// exportOnce exports req, within the timeout of the writer, if any.
+func (w *OTLPTraceWriter) exportOnce(req ptraceotlp.ExportRequest) error {
+	ctx := context.Background()
+	if w.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, w.timeout)
+		defer cancel()
+	}
+	return w.exporter.export(ctx, req)
+}
+
+func (w *OTLPTraceWriter) report() {
+	// update aggregated stats before reseting them.
+	w.statsLastMinute.Acc(w.stats)
+
+	_ = w.statsd.Count("datadog.trace_agent.trace_writer.payloads", w.stats.Payloads.Swap(0), nil, 1)
+	_ = w.statsd.Count("datadog.trace_agent.trace_writer.bytes_uncompressed", w.stats.BytesUncompressed.Swap(0), nil, 1)
+	_ = w.statsd.Count("datadog.trace_agent.trace_writer.retries", w.stats.Retries.Swap(0), nil, 1)
+	_ = w.statsd.Count("datadog.trace_agent.trace_writer.bytes", w.stats.Bytes.Swap(0), nil, 1)
+	_ = w.statsd.Count("datadog.trace_agent.trace_writer.errors", w.stats.Errors.Swap(0), nil, 1)
+	_ = w.statsd.Count("datadog.trace_agent.trace_writer.traces", w.stats.Traces.Swap(0), nil, 1)
+	_ = w.statsd.Count("datadog.trace_agent.trace_writer.events", w.stats.Events.Swap(0), nil, 1)
+	_ = w.statsd.Count("datadog.trace_agent.trace_writer.spans", w.stats.Spans.Swap(0), nil, 1)
+}
@@ -0,0 +1,390 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package writer
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"strconv"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+
+	pb "github.com/DataDog/datadog-agent/pkg/proto/pbgo/trace"
+	"github.com/DataDog/datadog-agent/pkg/trace/log"
+)
+
+// Span tags which are converted to OTLP span fields instead of attributes. Most of them
+// are set by the OTLP receiver when converting OTLP spans to Datadog spans.
+const (
+	tagOTelTraceID           = "otel.trace_id"
+	tagOTelLibraryName       = "otel.library.name"
+	tagOTelLibraryVersion    = "otel.library.version"
+	tagOTelStatusCode        = "otel.status_code"
+	tagOTelStatusDescription = "otel.status_description"
+	tagSpanKind              = "span.kind"
+	tagTraceState            = "w3c.tracestate"
+	tagSpanLinks             = "_dd.span_links"
+	tagSpanEvents            = "events"
+	// tagTraceIDHigh holds the high 64 bits of 128-bit trace IDs, as 16 hexadecimal digits.
+	tagTraceIDHigh = "_dd.p.tid"
+	tagOrigin      = "_dd.origin"
+)
+
+// scopeKey identifies the instrumentation scope of spans.
+type scopeKey struct {
+	name    string
+	version string
+}
+
+// resourceSpans holds the OTLP spans of a service, by instrumentation scope.
+type resourceSpans struct {
+	rs     ptrace.ResourceSpans
+	scopes map[scopeKey]ptrace.SpanSlice
+}
+
+// toOTLPTraces converts the tracer payloads to OTLP traces. The spans are grouped in a
+// resource by tracer payload and service, which holds the attributes of the payload, and in
+// a scope by instrumentation library. The hostname and env are the defaults of the agent,
+// used when the payloads do not have any.
+func toOTLPTraces(hostname, env string, payloads []*pb.TracerPayload) ptrace.Traces {
+	traces := ptrace.NewTraces()
+	for _, tp := range payloads {
+		services := make(map[string]*resourceSpans)
+		for _, chunk := range tp.Chunks {
+			high := chunkTraceIDHigh(chunk)
+			for _, s := range chunk.Spans {
+				rspans, ok := services[s.Service]
+				if !ok {
+					rspans = &resourceSpans{
+						rs:     traces.ResourceSpans().AppendEmpty(),
+						scopes: make(map[scopeKey]ptrace.SpanSlice),
+					}
+					setResourceAttributes(rspans.rs.Resource().Attributes(), hostname, env, tp, s.Service)
+					services[s.Service] = rspans
+				}
+				scope := scopeKey{name: s.Meta[tagOTelLibraryName], version: s.Meta[tagOTelLibraryVersion]}
+				spans, ok := rspans.scopes[scope]
+				if !ok {
+					ss := rspans.rs.ScopeSpans().AppendEmpty()
+					ss.Scope().SetName(scope.name)
+					ss.Scope().SetVersion(scope.version)
+					spans = ss.Spans()
+					rspans.scopes[scope] = spans
+				}
+				toOTLPSpan(spans.AppendEmpty(), chunk, high, s)
+			}
+		}
+	}
+	return traces
+}
+
+// setResourceAttributes sets the attributes of the resource holding the spans of service
+// from the tracer payload tp.
+func setResourceAttributes(attrs pcommon.Map, hostname, env string, tp *pb.TracerPayload, service string) {
+	for k, v := range tp.Tags {
+		attrs.PutStr(k, v)
+	}
+	putNonEmpty := func(k, v string) {
+		if v != "" {
+			attrs.PutStr(k, v)
+		}
+	}
+	if tp.Hostname != "" {
+		hostname = tp.Hostname
+	}
+	if tp.Env != "" {
+		env = tp.Env
+	}
+	putNonEmpty("service.name", service)
+	putNonEmpty("service.version", tp.AppVersion)
+	putNonEmpty("deployment.environment.name", env)
+	putNonEmpty("host.name", hostname)
+	putNonEmpty("container.id", tp.ContainerID)
+	putNonEmpty("telemetry.sdk.language", tp.LanguageName)
+	putNonEmpty("telemetry.sdk.version", tp.TracerVersion)
+	putNonEmpty("process.runtime.version", tp.LanguageVersion)
+}
+
+// chunkTraceIDHigh returns the high 64 bits of the trace ID of the chunk, which the tracers
+// only set on one of its spans.
+func chunkTraceIDHigh(chunk *pb.TraceChunk) uint64 {
+	for _, s := range chunk.Spans {
+		if v, ok := s.Meta[tagTraceIDHigh]; ok {
+			if high, err := strconv.ParseUint(v, 16, 64); err == nil {
+				return high
+			}
+		}
+	}
+	return 0
+}
+
+// toOTLPSpan converts the span s of chunk to the OTLP span out. The tags and metrics of the
+// span, and the tags of the chunk, become attributes. high is the high 64 bits of the trace ID.
+func toOTLPSpan(out ptrace.Span, chunk *pb.TraceChunk, high uint64, s *pb.Span) {
+	traceID, ok := parseOTelTraceID(s.Meta[tagOTelTraceID])
+	if !ok {
+		traceID = otlpTraceID(high, s.TraceID)
+	}
+	out.SetTraceID(traceID)
+	out.SetSpanID(otlpSpanID(s.SpanID))
+	if s.ParentID != 0 {
+		out.SetParentSpanID(otlpSpanID(s.ParentID))
+	}
+	// the OTLP span name is the equivalent of the resource name
+	out.SetName(s.Resource)
+	out.SetKind(otlpSpanKind(s.Meta[tagSpanKind]))
+	out.SetStartTimestamp(pcommon.Timestamp(s.Start))
+	out.SetEndTimestamp(pcommon.Timestamp(s.Start + s.Duration))
+	if ts, ok := s.Meta[tagTraceState]; ok {
+		out.TraceState().FromRaw(ts)
+	}
+	setOTLPStatus(out.Status(), s)
+
+	attrs := out.Attributes()
+	attrs.EnsureCapacity(len(s.Meta) + len(s.Metrics) + len(chunk.Tags) + 3)
+	attrs.PutStr("operation.name", s.Name)
+	attrs.PutStr("resource.name", s.Resource)
+	if s.Type != "" {
+		attrs.PutStr("span.type", s.Type)
+	}
+	for k, v := range s.Meta {
+		switch k {
+		case tagOTelTraceID, tagOTelLibraryName, tagOTelLibraryVersion, tagOTelStatusCode,
+			tagOTelStatusDescription, tagSpanKind, tagTraceState:
+			continue
+		case tagSpanLinks:
+			if len(s.SpanLinks) == 0 && appendJSONSpanLinks(out.Links(), v) {
+				continue
+			}
+		case tagSpanEvents:
+			if len(s.SpanEvents) == 0 && appendJSONSpanEvents(out.Events(), v) {
+				continue
+			}
+		}
+		attrs.PutStr(k, v)
+	}
+	for k, v := range s.Metrics {
+		attrs.PutDouble(k, v)
+	}
+	for k, v := range chunk.Tags {
+		if _, ok := attrs.Get(k); !ok {
+			attrs.PutStr(k, v)
+		}
+	}
+	if chunk.Origin != "" {
+		if _, ok := attrs.Get(tagOrigin); !ok {
+			attrs.PutStr(tagOrigin, chunk.Origin)
+		}
+	}
+	for _, l := range s.SpanLinks {
+		appendSpanLink(out.Links(), l)
+	}
+	for _, e := range s.SpanEvents {
+		appendSpanEvent(out.Events(), e)
+	}
+}
+
+// setOTLPStatus sets the OTLP status of the span s.
+func setOTLPStatus(status ptrace.Status, s *pb.Span) {
+	if s.Error != 0 {
+		status.SetCode(ptrace.StatusCodeError)
+		for _, k := range []string{tagOTelStatusDescription, "error.msg", "error.message"} {
+			if msg := s.Meta[k]; msg != "" {
+				status.SetMessage(msg)
+				break
+			}
+		}
+		return
+	}
+	if s.Meta[tagOTelStatusCode] == ptrace.StatusCodeOk.String() {
+		status.SetCode(ptrace.StatusCodeOk)
+	}
+}
+
+// otlpSpanKind returns the OTLP span kind of the "span.kind" tag value.
+func otlpSpanKind(kind string) ptrace.SpanKind {
+	switch kind {
+	case "server":
+		return ptrace.SpanKindServer
+	case "client":
+		return ptrace.SpanKindClient
+	case "producer":
+		return ptrace.SpanKindProducer
+	case "consumer":
+		return ptrace.SpanKindConsumer
+	case "internal":
+		return ptrace.SpanKindInternal
+	default:
+		return ptrace.SpanKindUnspecified
+	}
+}
+
+func otlpTraceID(high, low uint64) pcommon.TraceID {
+	var id [16]byte
+	binary.BigEndian.PutUint64(id[:8], high)
+	binary.BigEndian.PutUint64(id[8:], low)
+	return id
+}
+
+func otlpSpanID(id uint64) pcommon.SpanID {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], id)
+	return b
+}
+
+// parseOTelTraceID parses a trace ID made of 32 hexadecimal digits.
+func parseOTelTraceID(v string) (pcommon.TraceID, bool) {
+	var id [16]byte
+	if len(v) != 2*len(id) {
+		return id, false
+	}
+	if _, err := hex.Decode(id[:], []byte(v)); err != nil {
+		return id, false
+	}
+	return id, true
+}
+
+func appendSpanLink(links ptrace.SpanLinkSlice, l *pb.SpanLink) {
+	out := links.AppendEmpty()
+	out.SetTraceID(otlpTraceID(l.TraceIDHigh, l.TraceID))
+	out.SetSpanID(otlpSpanID(l.SpanID))
+	out.TraceState().FromRaw(l.Tracestate)
+	// the high bit of the flags reports whether they are set
+	out.SetFlags(l.Flags &^ (1 << 31))
+	for k, v := range l.Attributes {
+		out.Attributes().PutStr(k, v)
+	}
+}
+
+func appendSpanEvent(events ptrace.SpanEventSlice, e *pb.SpanEvent) {
+	out := events.AppendEmpty()
+	out.SetName(e.Name)
+	out.SetTimestamp(pcommon.Timestamp(e.TimeUnixNano))
+	for k, v := range e.Attributes {
+		if v == nil {
+			continue
+		}
+		setAnyValue(out.Attributes().PutEmpty(k), v)
+	}
+}
+
+func setAnyValue(out pcommon.Value, v *pb.AttributeAnyValue) {
+	switch v.Type {
+	case pb.AttributeAnyValue_BOOL_VALUE:
+		out.SetBool(v.BoolValue)
+	case pb.AttributeAnyValue_INT_VALUE:
+		out.SetInt(v.IntValue)
+	case pb.AttributeAnyValue_DOUBLE_VALUE:
+		out.SetDouble(v.DoubleValue)
+	case pb.AttributeAnyValue_ARRAY_VALUE:
+		values := out.SetEmptySlice()
+		if v.ArrayValue == nil {
+			return
+		}
+		for _, av := range v.ArrayValue.Values {
+			if av == nil {
+				continue
+			}
+			switch av.Type {
+			case pb.AttributeArrayValue_BOOL_VALUE:
+				values.AppendEmpty().SetBool(av.BoolValue)
+			case pb.AttributeArrayValue_INT_VALUE:
+				values.AppendEmpty().SetInt(av.IntValue)
+			case pb.AttributeArrayValue_DOUBLE_VALUE:
+				values.AppendEmpty().SetDouble(av.DoubleValue)
+			default:
+				values.AppendEmpty().SetStr(av.StringValue)
+			}
+		}
+	default:
+		out.SetStr(v.StringValue)
+	}
+}
+
+// jsonSpanLink is a span link encoded in the "_dd.span_links" tag.
+type jsonSpanLink struct {
+	TraceID                string            `json:"trace_id"`
+	SpanID                 string            `json:"span_id"`
+	Tracestate             string            `json:"tracestate"`
+	Flags                  uint32            `json:"flags"`
+	Attributes             map[string]string `json:"attributes"`
+	DroppedAttributesCount uint32            `json:"dropped_attributes_count"`
+}
+
+// appendJSONSpanLinks appends the span links encoded in the "_dd.span_links" tag value v to
+// links. It reports whether v could be decoded.
+func appendJSONSpanLinks(links ptrace.SpanLinkSlice, v string) bool {
+	var decoded []jsonSpanLink
+	if err := json.Unmarshal([]byte(v), &decoded); err != nil {
+		log.Debugf("Error decoding span links %q: %v", v, err)
+		return false
+	}
+	type link struct {
+		traceID pcommon.TraceID
+		spanID  uint64
+	}
+	ids := make([]link, len(decoded))
+	for i, l := range decoded {
+		traceID, ok := parseOTelTraceID(l.TraceID)
+		if !ok {
+			// the tracers encode the trace IDs of 64 bits with 16 digits
+			low, err := strconv.ParseUint(l.TraceID, 16, 64)
+			if err != nil {
+				log.Debugf("Invalid trace ID in span links %q", v)
+				return false
+			}
+			traceID = otlpTraceID(0, low)
+		}
+		spanID, err := strconv.ParseUint(l.SpanID, 16, 64)
+		if err != nil {
+			log.Debugf("Invalid span ID in span links %q", v)
+			return false
+		}
+		ids[i] = link{traceID: traceID, spanID: spanID}
+	}
+	for i, l := range decoded {
+		out := links.AppendEmpty()
+		out.SetTraceID(ids[i].traceID)
+		out.SetSpanID(otlpSpanID(ids[i].spanID))
+		out.TraceState().FromRaw(l.Tracestate)
+		out.SetFlags(l.Flags &^ (1 << 31))
+		out.SetDroppedAttributesCount(l.DroppedAttributesCount)
+		for k, v := range l.Attributes {
+			out.Attributes().PutStr(k, v)
+		}
+	}
+	return true
+}
+
+// jsonSpanEvent is a span event encoded in the "events" tag.
+type jsonSpanEvent struct {
+	TimeUnixNano           uint64         `json:"time_unix_nano"`
+	Name                   string         `json:"name"`
+	Attributes             map[string]any `json:"attributes"`
+	DroppedAttributesCount uint32         `json:"dropped_attributes_count"`
+}
+
+// appendJSONSpanEvents appends the span events encoded in the "events" tag value v to
+// events. It reports whether v could be decoded.
+func appendJSONSpanEvents(events ptrace.SpanEventSlice, v string) bool {
+	var decoded []jsonSpanEvent
+	if err := json.Unmarshal([]byte(v), &decoded); err != nil {
+		log.Debugf("Error decoding span events %q: %v", v, err)
+		return false
+	}
+	for _, e := range decoded {
+		out := events.AppendEmpty()
+		out.SetName(e.Name)
+		out.SetTimestamp(pcommon.Timestamp(e.TimeUnixNano))
+		out.SetDroppedAttributesCount(e.DroppedAttributesCount)
+		if err := out.Attributes().FromRaw(e.Attributes); err != nil {
+			log.Debugf("Error decoding the attributes of span event %q: %v", e.Name, err)
+		}
+	}
+	return true
+}
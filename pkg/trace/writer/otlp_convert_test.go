@@ -0,0 +1,191 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package writer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+
+	pb "github.com/DataDog/datadog-agent/pkg/proto/pbgo/trace"
+)
+
+func TestToOTLPTraces(t *testing.T) {
+	root := &pb.Span{
+		Service:  "web",
+		Name:     "http.request",
+		Resource: "GET /users",
+		Type:     "web",
+		TraceID:  0x2,
+		SpanID:   0x10,
+		Start:    1000,
+		Duration: 500,
+		Error:    1,
+		Meta: map[string]string{
+			"_dd.p.tid":      "00000000000000ab",
+			"span.kind":      "server",
+			"error.msg":      "boom",
+			"http.method":    "GET",
+			"w3c.tracestate": "dd=s:2",
+		},
+		Metrics: map[string]float64{"_sampling_priority_v1": 2},
+		SpanLinks: []*pb.SpanLink{{
+			TraceIDHigh: 0x1,
+			TraceID:     0x3,
+			SpanID:      0x4,
+			Attributes:  map[string]string{"link.kind": "follows"},
+			Tracestate:  "dd=s:1",
+			Flags:       1<<31 | 1,
+		}},
+		SpanEvents: []*pb.SpanEvent{{
+			Name:         "exception",
+			TimeUnixNano: 1200,
+			Attributes: map[string]*pb.AttributeAnyValue{
+				"exception.message": {Type: pb.AttributeAnyValue_STRING_VALUE, StringValue: "boom"},
+				"count":             {Type: pb.AttributeAnyValue_INT_VALUE, IntValue: 3},
+			},
+		}},
+	}
+	child := &pb.Span{
+		Service:  "db",
+		Name:     "postgres.query",
+		Resource: "SELECT users",
+		TraceID:  0x2,
+		SpanID:   0x11,
+		ParentID: 0x10,
+		Start:    1100,
+		Duration: 100,
+		Meta: map[string]string{
+			"otel.library.name":    "pgx",
+			"otel.library.version": "v5",
+			"otel.status_code":     "Ok",
+			"_dd.span_links":       `[{"trace_id":"000000000000000a000000000000000b","span_id":"000000000000000c","attributes":{"a":"b"}}]`,
+			"events":               `[{"time_unix_nano":1150,"name":"retry","attributes":{"attempt":1}}]`,
+		},
+	}
+	payloads := []*pb.TracerPayload{{
+		Hostname:      "",
+		Env:           "prod",
+		ContainerID:   "cid",
+		LanguageName:  "go",
+		TracerVersion: "v1.70.0",
+		AppVersion:    "1.2.3",
+		Tags:          map[string]string{"_dd.tags.container": "pod:web"},
+		Chunks: []*pb.TraceChunk{{
+			Priority: 2,
+			Origin:   "lambda",
+			Spans:    []*pb.Span{root, child},
+			Tags:     map[string]string{"_dd.p.dm": "-4"},
+		}},
+	}}
+
+	traces := toOTLPTraces("agent-host", "agent-env", payloads)
+	require.Equal(t, 2, traces.ResourceSpans().Len())
+	assert.Equal(t, 2, traces.SpanCount())
+
+	// the root span, in the resource of the "web" service
+	rs := traces.ResourceSpans().At(0)
+	assert.Equal(t, map[string]any{
+		"service.name":                "web",
+		"service.version":             "1.2.3",
+		"deployment.environment.name": "prod",
+		"host.name":                   "agent-host",
+		"container.id":                "cid",
+		"telemetry.sdk.language":      "go",
+		"telemetry.sdk.version":       "v1.70.0",
+		"_dd.tags.container":          "pod:web",
+	}, rs.Resource().Attributes().AsRaw())
+	require.Equal(t, 1, rs.ScopeSpans().Len())
+	assert.Equal(t, "", rs.ScopeSpans().At(0).Scope().Name())
+	span := rs.ScopeSpans().At(0).Spans().At(0)
+	assert.Equal(t, pcommon.TraceID{0, 0, 0, 0, 0, 0, 0, 0xab, 0, 0, 0, 0, 0, 0, 0, 0x2}, span.TraceID())
+	assert.Equal(t, pcommon.SpanID{0, 0, 0, 0, 0, 0, 0, 0x10}, span.SpanID())
+	assert.True(t, span.ParentSpanID().IsEmpty())
+	assert.Equal(t, "GET /users", span.Name())
+	assert.Equal(t, ptrace.SpanKindServer, span.Kind())
+	assert.Equal(t, pcommon.Timestamp(1000), span.StartTimestamp())
+	assert.Equal(t, pcommon.Timestamp(1500), span.EndTimestamp())
+	assert.Equal(t, "dd=s:2", span.TraceState().AsRaw())
+	assert.Equal(t, ptrace.StatusCodeError, span.Status().Code())
+	assert.Equal(t, "boom", span.Status().Message())
+	assert.Equal(t, map[string]any{
+		"operation.name":        "http.request",
+		"resource.name":         "GET /users",
+		"span.type":             "web",
+		"_dd.p.tid":             "00000000000000ab",
+		"error.msg":             "boom",
+		"http.method":           "GET",
+		"_sampling_priority_v1": float64(2),
+		"_dd.p.dm":              "-4",
+		"_dd.origin":            "lambda",
+	}, span.Attributes().AsRaw())
+	require.Equal(t, 1, span.Links().Len())
+	link := span.Links().At(0)
+	assert.Equal(t, pcommon.TraceID{0, 0, 0, 0, 0, 0, 0, 0x1, 0, 0, 0, 0, 0, 0, 0, 0x3}, link.TraceID())
+	assert.Equal(t, pcommon.SpanID{0, 0, 0, 0, 0, 0, 0, 0x4}, link.SpanID())
+	assert.Equal(t, "dd=s:1", link.TraceState().AsRaw())
+	assert.Equal(t, uint32(1), link.Flags())
+	assert.Equal(t, map[string]any{"link.kind": "follows"}, link.Attributes().AsRaw())
+	require.Equal(t, 1, span.Events().Len())
+	event := span.Events().At(0)
+	assert.Equal(t, "exception", event.Name())
+	assert.Equal(t, pcommon.Timestamp(1200), event.Timestamp())
+	assert.Equal(t, map[string]any{"exception.message": "boom", "count": int64(3)}, event.Attributes().AsRaw())
+
+	// the child span, in the resource of the "db" service
+	rs = traces.ResourceSpans().At(1)
+	assert.Equal(t, "db", rs.Resource().Attributes().AsRaw()["service.name"])
+	scope := rs.ScopeSpans().At(0).Scope()
+	assert.Equal(t, "pgx", scope.Name())
+	assert.Equal(t, "v5", scope.Version())
+	span = rs.ScopeSpans().At(0).Spans().At(0)
+	// the high bits of the trace ID are only set on the root span
+	assert.Equal(t, pcommon.TraceID{0, 0, 0, 0, 0, 0, 0, 0xab, 0, 0, 0, 0, 0, 0, 0, 0x2}, span.TraceID())
+	assert.Equal(t, pcommon.SpanID{0, 0, 0, 0, 0, 0, 0, 0x10}, span.ParentSpanID())
+	assert.Equal(t, ptrace.SpanKindUnspecified, span.Kind())
+	assert.Equal(t, ptrace.StatusCodeOk, span.Status().Code())
+	assert.Equal(t, map[string]any{
+		"operation.name": "postgres.query",
+		"resource.name":  "SELECT users",
+		"_dd.p.dm":       "-4",
+		"_dd.origin":     "lambda",
+	}, span.Attributes().AsRaw())
+	require.Equal(t, 1, span.Links().Len())
+	link = span.Links().At(0)
+	assert.Equal(t, pcommon.TraceID{0, 0, 0, 0, 0, 0, 0, 0xa, 0, 0, 0, 0, 0, 0, 0, 0xb}, link.TraceID())
+	assert.Equal(t, pcommon.SpanID{0, 0, 0, 0, 0, 0, 0, 0xc}, link.SpanID())
+	assert.Equal(t, map[string]any{"a": "b"}, link.Attributes().AsRaw())
+	require.Equal(t, 1, span.Events().Len())
+	assert.Equal(t, "retry", span.Events().At(0).Name())
+	assert.Equal(t, map[string]any{"attempt": float64(1)}, span.Events().At(0).Attributes().AsRaw())
+}
+
+func TestToOTLPTracesOTelTraceID(t *testing.T) {
+	traces := toOTLPTraces("host", "env", []*pb.TracerPayload{{
+		Chunks: []*pb.TraceChunk{{
+			Spans: []*pb.Span{{
+				Service: "svc",
+				TraceID: 0x2,
+				SpanID:  0x1,
+				Meta: map[string]string{
+					"otel.trace_id":  "0102030405060708090a0b0c0d0e0f10",
+					"_dd.span_links": "not json",
+				},
+			}},
+		}},
+	}})
+	span := traces.ResourceSpans().At(0).ScopeSpans().At(0).Spans().At(0)
+	assert.Equal(t, pcommon.TraceID{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}, span.TraceID())
+	// the tags which can not be decoded are kept
+	v, ok := span.Attributes().Get("_dd.span_links")
+	assert.True(t, ok)
+	assert.Equal(t, "not json", v.Str())
+	assert.Zero(t, span.Links().Len())
+	assert.Equal(t, "env", traces.ResourceSpans().At(0).Resource().Attributes().AsRaw()["deployment.environment.name"])
+}
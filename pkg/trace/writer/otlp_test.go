@@ -0,0 +1,186 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package writer
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/ptrace/ptraceotlp"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/DataDog/datadog-agent/pkg/trace/config"
+	"github.com/DataDog/datadog-agent/pkg/trace/timing"
+
+	"github.com/DataDog/datadog-go/v5/statsd"
+)
+
+// otlpTestServer is an OTLP/gRPC server recording the spans and headers it receives.
+type otlpTestServer struct {
+	ptraceotlp.UnimplementedGRPCServer
+
+	mu      sync.Mutex
+	spans   int
+	headers []string
+	errs    []error // errors returned to the next requests
+}
+
+// Export implements ptraceotlp.GRPCServer.
+func (s *otlpTestServer) Export(ctx context.Context, req ptraceotlp.ExportRequest) (ptraceotlp.ExportResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	md, _ := metadata.FromIncomingContext(ctx)
+	s.headers = append(s.headers, md.Get("x-tenant")...)
+	if len(s.errs) > 0 {
+		err := s.errs[0]
+		s.errs = s.errs[1:]
+		return ptraceotlp.NewExportResponse(), err
+	}
+	s.spans += req.Traces().SpanCount()
+	return ptraceotlp.NewExportResponse(), nil
+}
+
+func (s *otlpTestServer) received() (int, []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.spans, s.headers
+}
+
+func newOTLPTestConfig(protocol, endpoint string) *config.AgentConfig {
+	cfg := config.New()
+	cfg.Hostname = testHostname
+	cfg.DefaultEnv = testEnv
+	cfg.OTLPExporter = config.OTLPExporter{
+		Enabled:  true,
+		Protocol: protocol,
+		Endpoint: endpoint,
+		Headers:  map[string]string{"x-tenant": "apm"},
+		Insecure: true,
+		Timeout:  time.Second,
+	}
+	return cfg
+}
+
+func TestOTLPTraceWriter(t *testing.T) {
+	defer useBackoffDuration(time.Millisecond)()
+
+	t.Run("grpc", func(t *testing.T) {
+		srv := &otlpTestServer{errs: []error{status.Error(codes.Unavailable, "unavailable")}}
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		gsrv := grpc.NewServer()
+		ptraceotlp.RegisterGRPCServer(gsrv, srv)
+		go gsrv.Serve(ln)
+		defer gsrv.Stop()
+
+		w, err := NewOTLPTraceWriter(newOTLPTestConfig(config.OTLPExportProtocolGRPC, ln.Addr().String()), &statsd.NoOpClient{}, &timing.NoopReporter{})
+		require.NoError(t, err)
+		w.WriteChunks(randomSampledSpans(20, 0))
+		w.WriteChunks(randomSampledSpans(10, 0))
+		w.Stop()
+
+		spans, headers := srv.received()
+		assert.Equal(t, 30, spans)
+		// the first request is retried
+		assert.Equal(t, []string{"apm", "apm"}, headers)
+		assert.EqualValues(t, 1, w.statsLastMinute.Payloads.Load())
+		assert.EqualValues(t, 1, w.statsLastMinute.Retries.Load())
+		assert.EqualValues(t, 30, w.statsLastMinute.Spans.Load())
+	})
+
+	t.Run("http", func(t *testing.T) {
+		var (
+			mu      sync.Mutex
+			spans   int
+			headers []string
+		)
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, err := io.ReadAll(r.Body)
+			require.NoError(t, err)
+			assert.Equal(t, "application/x-protobuf", r.Header.Get("Content-Type"))
+			req := ptraceotlp.NewExportRequest()
+			require.NoError(t, req.UnmarshalProto(body))
+			mu.Lock()
+			defer mu.Unlock()
+			headers = append(headers, r.Header.Get("x-tenant"))
+			spans += req.Traces().SpanCount()
+			w.Header().Set("Content-Type", "application/x-protobuf")
+		}))
+		defer srv.Close()
+
+		w, err := NewOTLPTraceWriter(newOTLPTestConfig(config.OTLPExportProtocolHTTP, srv.URL+"/v1/traces"), &statsd.NoOpClient{}, &timing.NoopReporter{})
+		require.NoError(t, err)
+		// each write flushes the previous one
+		defer useFlushThreshold(1)()
+		w.WriteChunks(randomSampledSpans(20, 0))
+		w.WriteChunks(randomSampledSpans(10, 0))
+		w.Stop()
+
+		mu.Lock()
+		defer mu.Unlock()
+		assert.Equal(t, 30, spans)
+		assert.Equal(t, []string{"apm", "apm"}, headers)
+		assert.EqualValues(t, 2, w.statsLastMinute.Payloads.Load())
+	})
+
+	t.Run("http-error", func(t *testing.T) {
+		var calls int
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			calls++
+			w.WriteHeader(http.StatusBadRequest)
+		}))
+		defer srv.Close()
+
+		w, err := NewOTLPTraceWriter(newOTLPTestConfig(config.OTLPExportProtocolHTTP, srv.URL), &statsd.NoOpClient{}, &timing.NoopReporter{})
+		require.NoError(t, err)
+		w.WriteChunks(randomSampledSpans(20, 0))
+		w.Stop()
+
+		// non-retriable errors are not retried
+		assert.Equal(t, 1, calls)
+		assert.EqualValues(t, 1, w.statsLastMinute.Errors.Load())
+		assert.Zero(t, w.statsLastMinute.Payloads.Load())
+	})
+
+	t.Run("sync", func(t *testing.T) {
+		srv := &otlpTestServer{}
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		gsrv := grpc.NewServer()
+		ptraceotlp.RegisterGRPCServer(gsrv, srv)
+		go gsrv.Serve(ln)
+		defer gsrv.Stop()
+
+		cfg := newOTLPTestConfig(config.OTLPExportProtocolGRPC, ln.Addr().String())
+		cfg.SynchronousFlushing = true
+		w, err := NewOTLPTraceWriter(cfg, &statsd.NoOpClient{}, &timing.NoopReporter{})
+		require.NoError(t, err)
+		defer w.Stop()
+		w.WriteChunks(randomSampledSpans(20, 0))
+		require.NoError(t, w.FlushSync())
+
+		spans, _ := srv.received()
+		assert.Equal(t, 20, spans)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := NewOTLPTraceWriter(newOTLPTestConfig(config.OTLPExportProtocolGRPC, ""), &statsd.NoOpClient{}, &timing.NoopReporter{})
+		assert.Error(t, err)
+		_, err = NewOTLPTraceWriter(newOTLPTestConfig("thrift", "localhost:4317"), &statsd.NoOpClient{}, &timing.NoopReporter{})
+		assert.Error(t, err)
+	})
+}
@@ -17,6 +17,7 @@ import (
 	"net/http"
 	"net/url"
 	"os"
+	"path/filepath"
 	"strconv"
 	"sync"
 	"time"
@@ -46,6 +47,7 @@ func newSenders(cfg *config.AgentConfig, r eventRecorder, path string, climit, q
 			os.Exit(1)
 		}
 		senders[i] = newSender(&senderConfig{
+			spool:        newSenderSpool(cfg, url, endpoint.APIKey, statsd),
 			client:       cfg.NewHTTPClient(),
 			maxConns:     int(maxConns),
 			maxQueued:    qsize,
@@ -61,6 +63,25 @@ func newSenders(cfg *config.AgentConfig, r eventRecorder, path string, climit, q
 	return senders
 }
 
+// newSenderSpool returns the spool of the sender writing to url with apiKey, or nil if
+// spooling is disabled or the spool can not be created.
+func newSenderSpool(cfg *config.AgentConfig, url *url.URL, apiKey string, statsd statsd.ClientInterface) *spool {
+	if !cfg.SpoolEnabled {
+		return nil
+	}
+	if cfg.SpoolDir == "" {
+		log.Warn("Payload spooling is enabled but no spool directory is configured; payloads will not be spooled.")
+		return nil
+	}
+	dir := filepath.Join(cfg.SpoolDir, spoolDirName(url, apiKey))
+	s, err := newSpool(dir, cfg.SpoolMaxBytes, cfg.SpoolMaxAge, statsd, []string{"endpoint:" + url.Host + url.Path})
+	if err != nil {
+		log.Errorf("Error creating payload spool in %s, payloads will not be spooled: %v", dir, err)
+		return nil
+	}
+	return s
+}
+
 func maxConns(climit int, endpoints []*config.Endpoint) int {
 	// spread out the the maximum connection limit (climit) between senders.
 	// We exclude multi-region failover senders from this calculation, since they
@@ -100,6 +121,9 @@ const (
 	// eventTypeDropped specifies that a payload had to be dropped to make room
 	// in the queue.
 	eventTypeDropped
+	// eventTypeSpooled specifies that a payload was persisted to the spool, to be
+	// replayed later, because it could not be queued or sent.
+	eventTypeSpooled
 )
 
 var eventTypeStrings = map[eventType]string{
@@ -107,6 +131,7 @@ var eventTypeStrings = map[eventType]string{
 	eventTypeSent:     "eventTypeSent",
 	eventTypeRejected: "eventTypeRejected",
 	eventTypeDropped:  "eventTypeDropped",
+	eventTypeSpooled:  "eventTypeSpooled",
 }
 
 // String implements fmt.Stringer.
@@ -160,6 +185,9 @@ type senderConfig struct {
 	isMRF bool
 	// IsMRFEnabled determines whether Multi-Region Failover is enabled.
 	isMRFEnabled func() bool
+	// spool persists the payloads which can not be queued, or sent after maxRetries,
+	// to replay them later. It is nil when spooling is disabled.
+	spool *spool
 }
 
 // sender is responsible for sending payloads to a given URL. It uses a size-limited
@@ -175,22 +203,32 @@ type sender struct {
 	closed  bool         // closed reports if the loop is stopped
 	statsd  statsd.ClientInterface
 	enabled bool // false on inactive MRF senders. True otherwise
+
+	healthy *atomic.Bool   // healthy reports whether the last attempt to send a payload succeeded
+	exit    chan struct{}  // exit stops the replay of the spooled payloads
+	wg      sync.WaitGroup // waits for the replay of the spooled payloads to stop
 }
 
 // newSender returns a new sender based on the given config cfg.
 func newSender(cfg *senderConfig, statsd statsd.ClientInterface) *sender {
-	s := sender{
+	s := &sender{
 		cfg:        cfg,
 		queue:      make(chan *payload, cfg.maxQueued),
 		inflight:   atomic.NewInt32(0),
 		maxRetries: int32(cfg.maxRetries),
 		statsd:     statsd,
 		enabled:    true,
+		healthy:    atomic.NewBool(true),
+		exit:       make(chan struct{}),
 	}
 	for i := 0; i < cfg.maxConns; i++ {
 		go s.loop()
 	}
-	return &s
+	if cfg.spool != nil {
+		s.wg.Add(1)
+		go s.replay()
+	}
+	return s
 }
 
 // loop runs the main sender loop.
@@ -200,6 +238,44 @@ func (s *sender) loop() {
 	}
 }
 
+// replay pushes the spooled payloads back onto the queue, oldest first, as long as the
+// payloads are sent successfully and the queue has room. While the intake fails, a
+// single payload is replayed when the sender is idle, to probe for its recovery.
+func (s *sender) replay() {
+	defer s.wg.Done()
+	tick := time.NewTicker(spoolReplayInterval)
+	defer tick.Stop()
+	for {
+		select {
+		case <-s.exit:
+			return
+		case <-tick.C:
+		}
+		s.cfg.spool.report()
+		if s.cfg.isMRF && s.cfg.isMRFEnabled != nil && !s.cfg.isMRFEnabled() {
+			// inactive MRF senders keep their payloads until they are failed over to
+			continue
+		}
+		for (s.healthy.Load() && 2*len(s.queue) < cap(s.queue)) || s.inflight.Load() == 0 {
+			p, ok := s.cfg.spool.next()
+			if !ok {
+				break
+			}
+			s.inflight.Inc()
+			select {
+			case s.queue <- p:
+			case <-s.exit:
+				s.inflight.Dec()
+				if err := s.cfg.spool.store(p); err != nil {
+					log.Warnf("Error spooling payload for %s: %v", s.cfg.url, err)
+				}
+				ppool.Put(p)
+				return
+			}
+		}
+	}
+}
+
 // backoff triggers a sleep period proportional to the retry attempt, if any.
 func (s *sender) backoff(attempt int) {
 	delay := backoffDuration(attempt)
@@ -213,6 +289,8 @@ func (s *sender) backoff(attempt int) {
 // with a timeout of 5 seconds.
 func (s *sender) Stop() {
 	s.WaitForInflight()
+	close(s.exit)
+	s.wg.Wait()
 	s.mu.Lock()
 	s.closed = true
 	s.mu.Unlock()
@@ -248,6 +326,12 @@ func (s *sender) Push(p *payload) {
 	select {
 	case s.queue <- p:
 	default:
+		if s.spoolPayload(p) {
+			// the queue is full, the payload will be replayed from the spool
+			s.recordEvent(eventTypeSpooled, &eventData{bytes: p.body.Len(), count: 1})
+			ppool.Put(p)
+			return
+		}
 		_ = s.statsd.Count("datadog.trace_agent.sender.push_blocked", 1, nil, 1)
 		s.queue <- p
 	}
@@ -287,9 +371,14 @@ func (s *sender) sendOnce(p *payload) bool {
 	switch err.(type) {
 	case *retriableError:
 		// request failed again, but can be retried
+		s.healthy.Store(false)
 		s.mu.RLock()
 		defer s.mu.RUnlock()
 		if s.closed {
+			if s.spoolPayload(p) {
+				s.releasePayload(p, eventTypeSpooled, stats)
+				return true
+			}
 			s.releasePayload(p, eventTypeDropped, stats)
 			// sender is stopped
 			return true
@@ -302,6 +391,11 @@ func (s *sender) sendOnce(p *payload) bool {
 			log.Warnf("Retried payload %d times: %s", r, err.Error())
 		}
 		if p.retries.Load() >= s.maxRetries {
+			if s.spoolPayload(p) {
+				log.Debugf("Spooling payload after %d retries, due to: %v.", p.retries.Load(), err)
+				s.releasePayload(p, eventTypeSpooled, stats)
+				return true
+			}
 			log.Warnf("Dropping Payload after %d retries, due to: %v.\n", p.retries.Load(), err)
 			// queue is full; since this is the oldest payload, we drop it
 			s.releasePayload(p, eventTypeDropped, stats)
@@ -310,6 +404,7 @@ func (s *sender) sendOnce(p *payload) bool {
 		s.recordEvent(eventTypeRetry, stats)
 		return false
 	case nil:
+		s.healthy.Store(true)
 		s.releasePayload(p, eventTypeSent, stats)
 	default:
 		// this is a fatal error, we have to drop this payload
@@ -319,6 +414,18 @@ func (s *sender) sendOnce(p *payload) bool {
 	return true
 }
 
+// spoolPayload persists p to the spool, if any, and reports whether it succeeded.
+func (s *sender) spoolPayload(p *payload) bool {
+	if s.cfg.spool == nil {
+		return false
+	}
+	if err := s.cfg.spool.store(p); err != nil {
+		log.Warnf("Error spooling payload for %s: %v", s.cfg.url, err)
+		return false
+	}
+	return true
+}
+
 // waitForSenders blocks until all senders have sent their inflight payloads
 func waitForSenders(senders []*sender) {
 	var wg sync.WaitGroup
@@ -434,6 +541,8 @@ type payload struct {
 	body    *bytes.Buffer     // request body
 	headers map[string]string // request headers
 	retries *atomic.Int32     // number of retries sending this payload
+	// spoolName is the name of the spool file the payload was replayed from, if any.
+	spoolName string
 }
 
 // ppool is a pool of payloads.
@@ -454,6 +563,7 @@ func newPayload(headers map[string]string) *payload {
 	p.body.Reset()
 	p.headers = headers
 	p.retries.Store(0)
+	p.spoolName = ""
 	return p
 }
 
@@ -262,6 +262,63 @@ func TestSender(t *testing.T) {
 		}
 	})
 
+	t.Run("spool", func(t *testing.T) {
+		assert := assert.New(t)
+		server := newTestServer()
+		defer server.Close()
+		defer useBackoffDuration(0)()
+		defer func(old time.Duration) { spoolReplayInterval = old }(spoolReplayInterval)
+		spoolReplayInterval = 10 * time.Millisecond
+
+		var recorder mockRecorder
+		cfg := testSenderConfig(server.URL)
+		cfg.recorder = &recorder
+		sp, err := newSpool(t.TempDir(), 1024*1024, time.Hour, statsd, nil)
+		assert.NoError(err)
+		cfg.spool = sp
+		s := newSender(cfg, statsd)
+
+		// the payload is spooled after its 4 retries, and replayed once the intake recovers
+		s.Push(expectResponses(503, 503, 503, 503, 200))
+		assert.Eventually(func() bool { return server.Accepted() == 1 }, 5*time.Second, 10*time.Millisecond)
+		s.Stop()
+
+		assert.Equal(5, server.Total(), "total")
+		assert.Equal(4, server.Retried(), "retry")
+		assert.Len(recorder.data(eventTypeSpooled), 1)
+		assert.Empty(recorder.data(eventTypeDropped))
+		assert.Zero(sp.len())
+	})
+
+	t.Run("spool-queue-full", func(t *testing.T) {
+		assert := assert.New(t)
+		server := newTestServerWithLatency(20 * time.Millisecond)
+		defer server.Close()
+		defer func(old time.Duration) { spoolReplayInterval = old }(spoolReplayInterval)
+		spoolReplayInterval = 10 * time.Millisecond
+
+		var recorder mockRecorder
+		cfg := testSenderConfig(server.URL)
+		cfg.recorder = &recorder
+		cfg.maxConns = 1
+		cfg.maxQueued = 1
+		sp, err := newSpool(t.TempDir(), 1024*1024, time.Hour, statsd, nil)
+		assert.NoError(err)
+		cfg.spool = sp
+		s := newSender(cfg, statsd)
+
+		// pushing does not block on a full queue, the payloads are spooled instead
+		for i := 0; i < 10; i++ {
+			s.Push(expectResponses(200))
+		}
+		assert.NotEmpty(recorder.data(eventTypeSpooled))
+		assert.Eventually(func() bool { return server.Accepted() == 10 }, 5*time.Second, 10*time.Millisecond)
+		s.Stop()
+
+		assert.Equal(10, server.Total(), "total")
+		assert.Zero(sp.len())
+	})
+
 	t.Run("mrf", func(t *testing.T) {
 		assert := assert.New(t)
 		servers := []*testServer{
@@ -369,6 +426,7 @@ func useBackoffDuration(d time.Duration) func() {
 type mockRecorder struct {
 	mu                             sync.RWMutex
 	retry, sent, dropped, rejected []*eventData
+	spooled                        []*eventData
 }
 
 // data returns all call data for the given eventType.
@@ -384,6 +442,8 @@ func (r *mockRecorder) data(t eventType) []*eventData {
 		return r.dropped
 	case eventTypeRejected:
 		return r.rejected
+	case eventTypeSpooled:
+		return r.spooled
 	default:
 		panic("unknown event")
 	}
@@ -402,5 +462,7 @@ func (r *mockRecorder) recordEvent(t eventType, data *eventData) {
 		r.dropped = append(r.dropped, data)
 	case eventTypeRejected:
 		r.rejected = append(r.rejected, data)
+	case eventTypeSpooled:
+		r.spooled = append(r.spooled, data)
 	}
 }
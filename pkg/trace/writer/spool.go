@@ -0,0 +1,282 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package writer
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/DataDog/datadog-agent/pkg/trace/log"
+
+	"github.com/DataDog/datadog-go/v5/statsd"
+)
+
+const (
+	// spoolFileExt is the extension of the spooled payload files.
+	spoolFileExt = ".payload"
+	// spoolTempExt is the extension of the files being written, which are renamed
+	// once complete.
+	spoolTempExt = ".tmp"
+	// spoolTimestampLen is the length of the creation timestamp prefixing the names of the
+	// spooled payload files, in nanoseconds, so that their names sort from oldest to newest.
+	spoolTimestampLen = 19
+)
+
+// spoolReplayInterval is the interval at which the sender replays its spooled payloads.
+var spoolReplayInterval = time.Second
+
+// spoolFile is a payload file of the spool.
+type spoolFile struct {
+	name    string
+	size    int64
+	created time.Time
+}
+
+// spool is a bounded on-disk queue of payloads, from oldest to newest. It holds the
+// payloads which the sender could neither queue nor send, so that they can be replayed
+// once the intake recovers, including after a restart of the agent. When the maximum
+// size is reached, the oldest payloads are evicted, and payloads older than the
+// maximum age are discarded.
+type spool struct {
+	dir     string
+	maxSize int64
+	maxAge  time.Duration
+	statsd  statsd.ClientInterface
+	tags    []string
+
+	mu    sync.Mutex  // guards below
+	files []spoolFile // sorted by name
+	size  int64       // total size of the files
+	seq   uint64      // sequence number of the last stored file
+}
+
+// newSpool returns a spool storing its payloads in dir, which is created if it does not
+// exist, and loads the payloads spooled there by a previous run. The spool holds at most
+// maxSize bytes and discards the payloads older than maxAge, if non-zero.
+func newSpool(dir string, maxSize int64, maxAge time.Duration, statsd statsd.ClientInterface, tags []string) (*spool, error) {
+	if maxSize <= 0 {
+		return nil, fmt.Errorf("invalid spool size: %d", maxSize)
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	s := &spool{
+		dir:     dir,
+		maxSize: maxSize,
+		maxAge:  maxAge,
+		statsd:  statsd,
+		tags:    tags,
+	}
+	// entries are sorted by name, hence from oldest to newest
+	for _, e := range entries {
+		name := e.Name()
+		if strings.HasSuffix(name, spoolTempExt) {
+			// left over by an interrupted write
+			_ = os.Remove(filepath.Join(dir, name))
+			continue
+		}
+		created, ok := parseSpoolFileName(name)
+		if !ok {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		s.files = append(s.files, spoolFile{name: name, size: info.Size(), created: created})
+		s.size += info.Size()
+	}
+	if len(s.files) > 0 {
+		log.Infof("Found %d spooled payloads (%d bytes) in %s, they will be replayed.", len(s.files), s.size, dir)
+	}
+	return s, nil
+}
+
+// spoolDirName returns the name of the directory spooling the payloads sent to u with
+// apiKey. The name includes a hash of the API key, as the additional endpoints may send
+// to the same URL with different API keys, which must not share their payloads.
+func spoolDirName(u *url.URL, apiKey string) string {
+	sum := sha256.Sum256([]byte(apiKey))
+	return strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '.' || r == '-' {
+			return r
+		}
+		return '_'
+	}, strings.TrimSuffix(u.Host+u.Path, "/")) + "_" + hex.EncodeToString(sum[:4])
+}
+
+// parseSpoolFileName returns the creation time encoded in the name of a spooled payload
+// file, and whether name is the name of such a file.
+func parseSpoolFileName(name string) (time.Time, bool) {
+	if !strings.HasSuffix(name, spoolFileExt) || len(name) < spoolTimestampLen {
+		return time.Time{}, false
+	}
+	ns, err := strconv.ParseInt(name[:spoolTimestampLen], 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(0, ns), true
+}
+
+// store persists the payload p, evicting the oldest payloads if the spool is full. A
+// payload replayed from the spool keeps its position, so that it is replayed first.
+func (s *spool) store(p *payload) error {
+	headers, err := json.Marshal(p.headers)
+	if err != nil {
+		s.error(err)
+		return err
+	}
+	size := int64(len(headers) + 1 + p.body.Len())
+	if size > s.maxSize {
+		err := fmt.Errorf("payload of %d bytes exceeds the spool size of %d bytes", size, s.maxSize)
+		s.error(err)
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for s.size+size > s.maxSize && len(s.files) > 0 {
+		f := s.files[0]
+		s.files = s.files[1:]
+		s.size -= f.size
+		if err := os.Remove(filepath.Join(s.dir, f.name)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			log.Debugf("Error removing spooled payload: %v", err)
+		}
+		_ = s.statsd.Count("datadog.trace_agent.sender.spool.evicted", 1, s.tags, 1)
+	}
+	name := p.spoolName
+	if name == "" {
+		s.seq++
+		name = fmt.Sprintf("%0*d-%010d%s", spoolTimestampLen, time.Now().UnixNano(), s.seq, spoolFileExt)
+	}
+	created, _ := parseSpoolFileName(name)
+	if err := s.write(name, headers, p.body.Bytes()); err != nil {
+		s.error(err)
+		return err
+	}
+	i := sort.Search(len(s.files), func(i int) bool { return s.files[i].name >= name })
+	s.files = slices.Insert(s.files, i, spoolFile{name: name, size: size, created: created})
+	s.size += size
+	return nil
+}
+
+// write atomically writes the file name, made of the JSON encoded headers on the first
+// line, followed by the body.
+func (s *spool) write(name string, headers, body []byte) error {
+	f, err := os.CreateTemp(s.dir, "*"+spoolTempExt)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(append(headers, '\n'))
+	if err == nil {
+		_, err = f.Write(body)
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), filepath.Join(s.dir, name))
+	}
+	if err != nil {
+		_ = os.Remove(f.Name())
+	}
+	return err
+}
+
+// next removes the oldest payload from the spool and returns it, discarding the expired
+// ones. It returns false if the spool is empty.
+func (s *spool) next() (*payload, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.expire()
+	for len(s.files) > 0 {
+		f := s.files[0]
+		s.files = s.files[1:]
+		s.size -= f.size
+		path := filepath.Join(s.dir, f.name)
+		data, err := os.ReadFile(path)
+		if err == nil {
+			err = os.Remove(path)
+		}
+		if err != nil {
+			s.error(err)
+			continue
+		}
+		i := bytes.IndexByte(data, '\n')
+		if i == -1 {
+			s.error(fmt.Errorf("malformed spooled payload %s", f.name))
+			continue
+		}
+		var headers map[string]string
+		if err := json.Unmarshal(data[:i], &headers); err != nil {
+			s.error(err)
+			continue
+		}
+		p := newPayload(headers)
+		p.body.Write(data[i+1:])
+		p.spoolName = f.name
+		_ = s.statsd.Count("datadog.trace_agent.sender.spool.replayed", 1, s.tags, 1)
+		return p, true
+	}
+	return nil, false
+}
+
+// expire discards the payloads older than the maximum age. It must be called with
+// s.mu held.
+func (s *spool) expire() {
+	if s.maxAge <= 0 {
+		return
+	}
+	for len(s.files) > 0 && time.Since(s.files[0].created) > s.maxAge {
+		f := s.files[0]
+		s.files = s.files[1:]
+		s.size -= f.size
+		if err := os.Remove(filepath.Join(s.dir, f.name)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			log.Debugf("Error removing spooled payload: %v", err)
+		}
+		_ = s.statsd.Count("datadog.trace_agent.sender.spool.expired", 1, s.tags, 1)
+	}
+}
+
+// report discards the expired payloads and reports the size of the spool.
+func (s *spool) report() {
+	s.mu.Lock()
+	s.expire()
+	n, size := len(s.files), s.size
+	s.mu.Unlock()
+	_ = s.statsd.Gauge("datadog.trace_agent.sender.spool.payloads", float64(n), s.tags, 1)
+	_ = s.statsd.Gauge("datadog.trace_agent.sender.spool.bytes", float64(size), s.tags, 1)
+}
+
+// len returns the number of payloads in the spool.
+func (s *spool) len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.files)
+}
+
+// error logs and counts err.
+func (s *spool) error(err error) {
+	log.Debugf("Spool error in %s: %v", s.dir, err)
+	_ = s.statsd.Count("datadog.trace_agent.sender.spool.errors", 1, s.tags, 1)
+}
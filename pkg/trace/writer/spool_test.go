@@ -0,0 +1,155 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package writer
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DataDog/datadog-agent/pkg/trace/config"
+	"github.com/DataDog/datadog-agent/pkg/trace/telemetry"
+	"github.com/DataDog/datadog-agent/pkg/trace/teststatsd"
+)
+
+func newTestSpoolPayload(body string) *payload {
+	p := newPayload(map[string]string{"Content-Type": "application/msgpack"})
+	p.body.WriteString(body)
+	return p
+}
+
+// nextBodies returns the bodies of the payloads remaining in s.
+func nextBodies(s *spool) []string {
+	var bodies []string
+	for {
+		p, ok := s.next()
+		if !ok {
+			return bodies
+		}
+		bodies = append(bodies, p.body.String())
+	}
+}
+
+func TestSpool(t *testing.T) {
+	t.Run("order", func(t *testing.T) {
+		statsd := &teststatsd.Client{}
+		s, err := newSpool(t.TempDir(), 1024, time.Hour, statsd, nil)
+		require.NoError(t, err)
+
+		for _, body := range []string{"a", "b", "c"} {
+			require.NoError(t, s.store(newTestSpoolPayload(body)))
+		}
+		assert.Equal(t, 3, s.len())
+
+		p, ok := s.next()
+		require.True(t, ok)
+		assert.Equal(t, "a", p.body.String())
+		assert.Equal(t, map[string]string{"Content-Type": "application/msgpack"}, p.headers)
+
+		// a replayed payload keeps its position
+		require.NoError(t, s.store(p))
+		assert.Equal(t, []string{"a", "b", "c"}, nextBodies(s))
+		assert.EqualValues(t, 4, statsd.GetCountSummaries()["datadog.trace_agent.sender.spool.replayed"].Sum)
+
+		entries, err := os.ReadDir(s.dir)
+		require.NoError(t, err)
+		assert.Empty(t, entries)
+		assert.Zero(t, s.size)
+	})
+
+	t.Run("evict", func(t *testing.T) {
+		statsd := &teststatsd.Client{}
+		// each payload takes 49 bytes: 38 bytes of headers, a new line and 10 bytes of body
+		s, err := newSpool(t.TempDir(), 100, time.Hour, statsd, nil)
+		require.NoError(t, err)
+
+		for _, body := range []string{"aaaaaaaaaa", "bbbbbbbbbb", "cccccccccc"} {
+			require.NoError(t, s.store(newTestSpoolPayload(body)))
+		}
+		assert.EqualValues(t, 98, s.size)
+		assert.Equal(t, []string{"bbbbbbbbbb", "cccccccccc"}, nextBodies(s))
+		assert.EqualValues(t, 1, statsd.GetCountSummaries()["datadog.trace_agent.sender.spool.evicted"].Sum)
+
+		// payloads larger than the spool are not stored
+		assert.Error(t, s.store(newTestSpoolPayload(string(make([]byte, 100)))))
+		assert.EqualValues(t, 1, statsd.GetCountSummaries()["datadog.trace_agent.sender.spool.errors"].Sum)
+	})
+
+	t.Run("expire", func(t *testing.T) {
+		statsd := &teststatsd.Client{}
+		s, err := newSpool(t.TempDir(), 1024, time.Minute, statsd, nil)
+		require.NoError(t, err)
+
+		old := newTestSpoolPayload("old")
+		old.spoolName = "0000000000000000001-0000000001" + spoolFileExt
+		require.NoError(t, s.store(old))
+		require.NoError(t, s.store(newTestSpoolPayload("new")))
+
+		s.report()
+		assert.EqualValues(t, 1, statsd.GetCountSummaries()["datadog.trace_agent.sender.spool.expired"].Sum)
+		gauges := statsd.GetGaugeSummaries()
+		assert.EqualValues(t, 1, gauges["datadog.trace_agent.sender.spool.payloads"].Last)
+		assert.Equal(t, []string{"new"}, nextBodies(s))
+	})
+
+	t.Run("reload", func(t *testing.T) {
+		dir := t.TempDir()
+		s, err := newSpool(dir, 1024, time.Hour, &teststatsd.Client{}, nil)
+		require.NoError(t, err)
+		for _, body := range []string{"a", "b"} {
+			require.NoError(t, s.store(newTestSpoolPayload(body)))
+		}
+		// interrupted writes and unknown files are ignored
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "123"+spoolTempExt), []byte("partial"), 0o600))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "README"), []byte("unknown"), 0o600))
+
+		s, err = newSpool(dir, 1024, time.Hour, &teststatsd.Client{}, nil)
+		require.NoError(t, err)
+		assert.Equal(t, 2, s.len())
+		assert.NoFileExists(t, filepath.Join(dir, "123"+spoolTempExt))
+		assert.Equal(t, []string{"a", "b"}, nextBodies(s))
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := newSpool(t.TempDir(), 0, time.Hour, &teststatsd.Client{}, nil)
+		assert.Error(t, err)
+	})
+}
+
+func TestSpoolDirName(t *testing.T) {
+	u, err := url.Parse("https://trace.agent.datadoghq.com:443/api/v0.2/stats")
+	require.NoError(t, err)
+	assert.Equal(t, "trace.agent.datadoghq.com_443_api_v0.2_stats_2bb80d53", spoolDirName(u, "secret"))
+}
+
+func TestSenderSpoolDirs(t *testing.T) {
+	// two additional endpoints sending to the same host with different API keys
+	cfg := config.New()
+	cfg.SpoolEnabled = true
+	cfg.SpoolDir = t.TempDir()
+	cfg.Endpoints = []*config.Endpoint{
+		{Host: "https://trace.agent.datadoghq.com", APIKey: "key1"},
+		{Host: "https://trace.agent.datadoghq.com", APIKey: "key2"},
+	}
+	senders := newSenders(cfg, &mockRecorder{}, pathTraces, 10, 10, telemetry.NewNoopCollector(), &teststatsd.Client{})
+	defer func() {
+		for _, s := range senders {
+			s.Stop()
+		}
+	}()
+
+	require.Len(t, senders, 2)
+	require.NotNil(t, senders[0].cfg.spool)
+	require.NotNil(t, senders[1].cfg.spool)
+	assert.NotEqual(t, senders[0].cfg.spool.dir, senders[1].cfg.spool.dir)
+	assert.DirExists(t, senders[0].cfg.spool.dir)
+	assert.DirExists(t, senders[1].cfg.spool.dir)
+}
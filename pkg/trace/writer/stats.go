@@ -404,5 +404,10 @@ func (w *DatadogStatsWriter) recordEvent(t eventType, data *eventData) {
 		w.easylog.Warn("Stats writer queue full. Payload dropped (%.2fKB).", float64(data.bytes)/1024)
 		_ = w.statsd.Count("datadog.trace_agent.stats_writer.dropped", 1, nil, 1)
 		_ = w.statsd.Count("datadog.trace_agent.stats_writer.dropped_bytes", int64(data.bytes), nil, 1)
+
+	case eventTypeSpooled:
+		log.Debugf("Stats payload spooled to disk (%.2fKB).", float64(data.bytes)/1024)
+		_ = w.statsd.Count("datadog.trace_agent.stats_writer.spooled", 1, nil, 1)
+		_ = w.statsd.Count("datadog.trace_agent.stats_writer.spooled_bytes", int64(data.bytes), nil, 1)
 	}
 }
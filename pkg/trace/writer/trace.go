@@ -368,5 +368,10 @@ func (w *TraceWriter) recordEvent(t eventType, data *eventData) {
 		w.easylog.Warn("Trace Payload dropped (%.2fKB).", float64(data.bytes)/1024)
 		_ = w.statsd.Count("datadog.trace_agent.trace_writer.dropped", 1, nil, 1)
 		_ = w.statsd.Count("datadog.trace_agent.trace_writer.dropped_bytes", int64(data.bytes), nil, 1)
+
+	case eventTypeSpooled:
+		log.Debugf("Trace payload spooled to disk (%.2fKB).", float64(data.bytes)/1024)
+		_ = w.statsd.Count("datadog.trace_agent.trace_writer.spooled", 1, nil, 1)
+		_ = w.statsd.Count("datadog.trace_agent.trace_writer.spooled_bytes", int64(data.bytes), nil, 1)
 	}
 }
@@ -23,6 +23,7 @@ def build(
     install_path=None,
     major_version='7',
     go_mod="readonly",
+    include_sds=False,
 ):
     """
     Build the trace agent.
@@ -61,6 +62,9 @@ def build(
 
     build_tags = get_build_tags(build_include, build_exclude)
 
+    if include_sds:
+        build_tags.append("sds")
+
     race_opt = "-race" if race else ""
     build_type = "-a" if rebuild else ""
     go_build_tags = " ".join(build_tags)